@@ -2,11 +2,19 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the pgx driver for the SQL backend.
 	"github.com/tinkerbell/smee/internal/backend/file"
 	"github.com/tinkerbell/smee/internal/backend/kube"
 	"github.com/tinkerbell/smee/internal/backend/noop"
+	"github.com/tinkerbell/smee/internal/backend/plugin"
+	restbackend "github.com/tinkerbell/smee/internal/backend/rest"
+	"github.com/tinkerbell/smee/internal/backend/sqldb"
 	"github.com/tinkerbell/smee/internal/dhcp/handler"
 	"github.com/tinkerbell/tink/api/v1alpha1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -14,7 +22,6 @@ import (
 	"k8s.io/client-go/scale/scheme"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
-	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/cluster"
 )
 
@@ -24,9 +31,28 @@ type Kube struct {
 	// APIURL is the Kubernetes API URL.
 	APIURL string
 	// Namespace is an override for the Namespace the kubernetes client will watch.
-	// The default is the Namespace the pod is running in.
+	// It is a comma separated list to watch more than one Namespace. All Namespaces are watched when it is empty.
 	Namespace string
-	Enabled   bool
+	// LabelSelector and FieldSelector select the Hardware that is watched.
+	LabelSelector string
+	FieldSelector string
+	// ConflictPolicy decides which Hardware is served when more than one Hardware has a MAC or IP address, refuse or oldest.
+	ConflictPolicy string
+	// Progress configures writing the boot progress of machines to their Hardware.
+	Progress KubeProgress
+	// RequireWorkflow only boots the OSIE on machines whose Hardware has a pending Workflow.
+	RequireWorkflow bool
+	// SyncTimeout is how long to wait for the informer cache to sync before the backend fails to start.
+	SyncTimeout time.Duration
+	Enabled     bool
+}
+
+type KubeProgress struct {
+	Enabled bool
+	// Interval is the minimum time between two writes of the same boot stage of a machine.
+	Interval time.Duration
+	// QPS is the maximum number of writes per second to the Kubernetes API.
+	QPS float64
 }
 type File struct {
 	// FilePath is the path to a JSON FilePath containing hardware data.
@@ -38,11 +64,38 @@ type Noop struct {
 	Enabled bool
 }
 
+type Plugin struct {
+	// Target is the gRPC target of the backend plugin, for example unix:///var/run/smee/backend.sock.
+	Target string
+	// Timeout is the deadline of lookups that are calls to the plugin.
+	Timeout time.Duration
+	Enabled bool
+}
+
+type Cache struct {
+	// TTL is how long a lookup that found hardware is cached.
+	TTL time.Duration
+	// NegativeTTL is how long a lookup that did not find hardware is cached.
+	NegativeTTL time.Duration
+}
+
+type Rest struct {
+	// Config is the configuration of the REST backend.
+	Config  restbackend.Config
+	Enabled bool
+}
+
+type SQL struct {
+	// Config is the configuration of the SQL backend.
+	Config  sqldb.Config
+	Enabled bool
+}
+
 func (n *Noop) backend() handler.BackendReader {
 	return &noop.Backend{}
 }
 
-func (k *Kube) getClient() (*rest.Config, error) {
+func (k *Kube) clientConfig() clientcmd.ClientConfig {
 	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
 	loadingRules.ExplicitPath = k.ConfigFilePath
 
@@ -51,15 +104,41 @@ func (k *Kube) getClient() (*rest.Config, error) {
 			Server: k.APIURL,
 		},
 		Context: clientcmdapi.Context{
-			Namespace: k.Namespace,
+			Namespace: k.namespaces()[0],
 		},
 	}
-	loader := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
 
-	return loader.ClientConfig()
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
+}
+
+func (k *Kube) getClient() (*rest.Config, error) {
+	return k.clientConfig().ClientConfig()
+}
+
+// namespaces returns the namespaces of the namespace override. It returns a single empty namespace when there is no override.
+func (k *Kube) namespaces() []string {
+	var ns []string
+	for _, n := range strings.Split(k.Namespace, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			ns = append(ns, n)
+		}
+	}
+	if len(ns) == 0 {
+		return []string{""}
+	}
+
+	return ns
+}
+
+// namespace returns the namespace of the kubernetes client.
+// That is the first namespace of the override, the namespace of the kubeconfig context or the namespace the pod is running in.
+func (k *Kube) namespace() (string, error) {
+	ns, _, err := k.clientConfig().Namespace()
+
+	return ns, err
 }
 
-func (k *Kube) backend(ctx context.Context) (handler.BackendReader, error) {
+func (k *Kube) backend(ctx context.Context, logger logr.Logger) (handler.BackendReader, error) {
 	config, err := k.getClient()
 	if err != nil {
 		return nil, err
@@ -77,26 +156,115 @@ func (k *Kube) backend(ctx context.Context) (handler.BackendReader, error) {
 
 	conf := func(opts *cluster.Options) {
 		opts.Scheme = rs
-		if k.Namespace != "" {
-			opts.Cache.DefaultNamespaces = map[string]cache.Config{k.Namespace: {}}
-		}
 	}
 
-	kb, err := kube.NewBackend(config, conf)
+	scope := kube.Scope{LabelSelector: k.LabelSelector, FieldSelector: k.FieldSelector}
+	if ns := k.namespaces(); ns[0] != "" {
+		scope.Namespaces = ns
+	}
+	scopeOpt, err := scope.ClusterOption()
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := kube.ParseConflictPolicy(k.ConflictPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	kb, err := kube.NewBackend(config, conf, scopeOpt)
 	if err != nil {
 		return nil, err
 	}
+	kb.ConflictPolicy = policy
 
+	if err := kb.DetectConflicts(logger.WithName("conflicts")); err != nil {
+		return nil, err
+	}
+
+	if k.RequireWorkflow {
+		if err := kb.RequireWorkflows(logger.WithName("workflows")); err != nil {
+			return nil, err
+		}
+	}
+
+	// The handlers are only created after the cache synced, so that lookups do not miss Hardware that is not cached yet.
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- kb.Start(ctx)
+	}()
+	syncCtx, cancel := context.WithTimeout(ctx, k.SyncTimeout)
+	defer cancel()
+	logger.Info("waiting for the kubernetes cache to sync", "timeout", k.SyncTimeout)
+	if !kb.WaitForCacheSync(syncCtx) {
+		select {
+		case err := <-errCh:
+			return nil, fmt.Errorf("failed to start the kubernetes cache: %w", err)
+		default:
+			return nil, fmt.Errorf("the kubernetes cache did not sync within %v", k.SyncTimeout)
+		}
+	}
 	go func() {
-		err = kb.Start(ctx)
-		if err != nil {
-			panic(err)
+		// The backend reports the error as not ready, so /readyz fails instead of smee crashing.
+		if err := <-errCh; err != nil {
+			logger.Error(err, "the kubernetes cache stopped, the backend is not ready")
 		}
 	}()
 
 	return kb, nil
 }
 
+// progressRecorder returns the writer of the boot progress of machines to their Hardware, when it is enabled.
+// It requires the kubernetes backend, alone or in a backend chain.
+func (c *config) progressRecorder(ctx context.Context, log logr.Logger, backend handler.BackendReader) (handler.ProgressRecorder, error) {
+	p := c.backends.kubernetes.Progress
+	if !p.Enabled {
+		return nil, nil
+	}
+	kb, ok := kubeBackend(backend)
+	if !ok {
+		return nil, errors.New("--backend-kube-progress-enabled requires the kubernetes backend")
+	}
+	if p.QPS <= 0 {
+		return nil, fmt.Errorf("--backend-kube-progress-qps must be greater than 0, got %v", p.QPS)
+	}
+	w := kb.NewProgressWriter(p.Interval, p.QPS, log.WithName("progress"))
+	go w.Start(ctx)
+
+	return w, nil
+}
+
+func (p *Plugin) backend(ctx context.Context, logger logr.Logger) (handler.BackendReader, error) {
+	b, err := plugin.NewBackend(p.Target, p.Timeout, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	go b.Start(ctx)
+
+	return b, nil
+}
+
+func (r *Rest) backend(logger logr.Logger) (handler.BackendReader, error) {
+	return restbackend.NewBackend(r.Config, logger)
+}
+
+func (s *SQL) backend(ctx context.Context, logger logr.Logger) (handler.BackendReader, error) {
+	b, err := sqldb.NewBackend(ctx, s.Config, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := b.Close(); err != nil {
+			logger.Error(err, "failed to close the SQL backend")
+		}
+	}()
+
+	return b, nil
+}
+
 func (s *File) backend(ctx context.Context, logger logr.Logger) (handler.BackendReader, error) {
 	f, err := file.NewWatcher(logger, s.FilePath)
 	if err != nil {
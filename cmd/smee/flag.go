@@ -16,6 +16,7 @@ import (
 
 	"github.com/peterbourgon/ff/v3"
 	"github.com/peterbourgon/ff/v3/ffcli"
+	"github.com/peterbourgon/ff/v3/ffyaml"
 	"github.com/vishvananda/netlink"
 )
 
@@ -109,6 +110,7 @@ func ipxeHTTPScriptFlags(c *config, fs *flag.FlagSet) {
 	fs.IntVar(&c.ipxeHTTPScript.bindPort, "http-port", 8080, "[http] local port to listen on for iPXE HTTP script requests")
 	fs.StringVar(&c.ipxeHTTPScript.extraKernelArgs, "extra-kernel-args", "", "[http] extra set of kernel args (k=v k=v) that are appended to the kernel cmdline iPXE script")
 	fs.StringVar(&c.ipxeHTTPScript.trustedProxies, "trusted-proxies", "", "[http] comma separated list of trusted proxies in CIDR notation")
+	fs.StringVar(&c.ipxeHTTPScript.adminToken, "http-admin-token", "", "[http] bearer token of the admin API that simulates the DHCP reply and iPXE script for a MAC address, the API is disabled when empty")
 	fs.StringVar(&c.ipxeHTTPScript.hookURL, "osie-url", "", "[http] URL where OSIE (HookOS) images are located")
 	fs.StringVar(&c.ipxeHTTPScript.tinkServer, "tink-server", "", "[http] IP:Port for the Tink server")
 	fs.BoolVar(&c.ipxeHTTPScript.tinkServerUseTLS, "tink-server-tls", false, "[http] use TLS for Tink server")
@@ -122,7 +124,9 @@ func dhcpFlags(c *config, fs *flag.FlagSet) {
 	fs.StringVar(&c.dhcp.mode, "dhcp-mode", dhcpModeReservation.String(), fmt.Sprintf("[dhcp] DHCP mode (%s, %s, %s)", dhcpModeReservation, dhcpModeProxy, dhcpModeAutoProxy))
 	fs.StringVar(&c.dhcp.bindAddr, "dhcp-addr", "0.0.0.0:67", "[dhcp] local IP:Port to listen on for DHCP requests")
 	fs.StringVar(&c.dhcp.bindInterface, "dhcp-iface", "", "[dhcp] interface to bind to for DHCP requests")
+	fs.StringVar(&c.dhcp.ifaceConfig, "dhcp-iface-config", "", "[dhcp] semicolon separated per interface DHCP config, iface:key=value,... (keys: mode, ip-for-packet, syslog-ip, tftp-ip, http-ipxe-binary-url, http-ipxe-script-url), only configured interfaces are served")
 	fs.StringVar(&c.dhcp.ipForPacket, "dhcp-ip-for-packet", detectPublicIPv4(), "[dhcp] IP address to use in DHCP packets (opt 54, etc)")
+	fs.StringVar(&c.dhcp.subnetsFile, "dhcp-subnets-file", "", "[dhcp] YAML file with per subnet DHCP defaults (gateway, DNS, NTP, domain, lease time, netboot URLs), selected by giaddr, option 118 or option 82.5")
 	fs.StringVar(&c.dhcp.syslogIP, "dhcp-syslog-ip", detectPublicIPv4(), "[dhcp] Syslog server IP address to use in DHCP packets (opt 7)")
 	fs.StringVar(&c.dhcp.tftpIP, "dhcp-tftp-ip", detectPublicIPv4(), "[dhcp] TFTP server IP address to use in DHCP packets (opt 66, etc)")
 	fs.IntVar(&c.dhcp.tftpPort, "dhcp-tftp-port", 69, "[dhcp] TFTP server port to use in DHCP packets (opt 66, etc)")
@@ -136,16 +140,72 @@ func dhcpFlags(c *config, fs *flag.FlagSet) {
 	fs.StringVar(&c.dhcp.httpIpxeScript.Path, "dhcp-http-ipxe-script-path", "/auto.ipxe", "[dhcp] HTTP iPXE script path to use in DHCP packets")
 	fs.StringVar(&c.dhcp.httpIpxeScriptURL, "dhcp-http-ipxe-script-url", "", "[dhcp] HTTP iPXE script URL to use in DHCP packets, this overrides the flags for dhcp-http-ipxe-script-{scheme, host, port, path}")
 	fs.BoolVar(&c.dhcp.httpIpxeScript.injectMacAddress, "dhcp-http-ipxe-script-prepend-mac", true, "[dhcp] prepend the hardware MAC address to iPXE script URL base, http://1.2.3.4/auto.ipxe -> http://1.2.3.4/40:15:ff:89:cc:0e/auto.ipxe")
+	fs.StringVar(&c.dhcp.ha.mode, "dhcp-ha-mode", "", fmt.Sprintf("[dhcp] high availability mode so only one replica answers each client (%s, %s), both require the kubernetes backend", haModeLeader, haModeLoadBalance))
+	fs.StringVar(&c.dhcp.ha.leaseName, "dhcp-ha-lease-name", "smee-dhcp", "[dhcp] name of the Lease used for leader election, and the prefix of the Lease names of the replicas in load-balance mode")
+	fs.StringVar(&c.dhcp.ha.leaseNamespace, "dhcp-ha-lease-namespace", "", "[dhcp] namespace of the Leases used for high availability, defaults to the kubernetes backend namespace")
+	fs.IntVar(&c.dhcp.ha.replicas, "dhcp-ha-replicas", 1, "[dhcp] number of replicas that split the clients by the RFC 3074 hash of their client identifier, load-balance mode only")
+	fs.IntVar(&c.dhcp.ha.index, "dhcp-ha-index", 0, "[dhcp] zero based index of this replica, load-balance mode only")
+	fs.IntVar(&c.dhcp.limits.workers, "dhcp-workers", 0, "[dhcp] number of workers handling DHCP packets, 0 handles every packet in a new goroutine")
+	fs.IntVar(&c.dhcp.limits.queueSize, "dhcp-queue-size", 1024, "[dhcp] number of DHCP packets waiting for a worker, packets are dropped when the queue is full")
+	fs.Float64Var(&c.dhcp.limits.clientRate, "dhcp-rate-limit-client", 0, "[dhcp] DHCP packets per second allowed from a client by MAC address, 0 disables the limit")
+	fs.IntVar(&c.dhcp.limits.clientBurst, "dhcp-rate-limit-client-burst", 10, "[dhcp] DHCP packets a client is allowed to send at once")
+	fs.Float64Var(&c.dhcp.limits.relayRate, "dhcp-rate-limit-relay", 0, "[dhcp] DHCP packets per second allowed from a relay agent by giaddr, 0 disables the limit")
+	fs.IntVar(&c.dhcp.limits.relayBurst, "dhcp-rate-limit-relay-burst", 100, "[dhcp] DHCP packets a relay agent is allowed to send at once")
+	fs.DurationVar(&c.dhcp.limits.retransmitWindow, "dhcp-retransmit-window", 0, "[dhcp] drop retransmits of a DHCP packet with the same xid and message type from a client within this window, 0 disables it")
+	fs.StringVar(&c.dhcp.leaseFile, "dhcp-lease-file", "", "[dhcp] file to persist DHCP leases in, leases are only kept in memory when not set, reservation mode only")
+	fs.StringVar(&c.dhcp.pool.cidr, "dhcp-pool-cidr", "", "[dhcp] IPv4 CIDR to allocate addresses from for clients without a host reservation, reservation mode only")
+	fs.StringVar(&c.dhcp.pool.exclude, "dhcp-pool-exclude", "", "[dhcp] comma separated list of IPs or IP ranges (start-end) in the pool CIDR that are never allocated")
+	fs.StringVar(&c.dhcp.pool.gateway, "dhcp-pool-gateway", "", "[dhcp] default gateway to use in DHCP packets for pool leases (opt 3)")
+	fs.StringVar(&c.dhcp.pool.nameServers, "dhcp-pool-dns", "", "[dhcp] comma separated list of DNS servers to use in DHCP packets for pool leases (opt 6)")
+	fs.DurationVar(&c.dhcp.pool.leaseTime, "dhcp-pool-lease-time", time.Hour, "[dhcp] lease time to use for pool leases (opt 51)")
+}
+
+func dhcp6Flags(c *config, fs *flag.FlagSet) {
+	fs.BoolVar(&c.dhcp6.enabled, "dhcp6-enabled", false, "[dhcp6] enable DHCPv6 server, only reservation mode is supported")
+	fs.StringVar(&c.dhcp6.bindAddr, "dhcp6-addr", "[::]:547", "[dhcp6] local IP:Port to listen on for DHCPv6 requests")
+	fs.StringVar(&c.dhcp6.bindInterface, "dhcp6-iface", "", "[dhcp6] interface to bind to for DHCPv6 requests, also used for the server identifier (DUID)")
+	fs.StringVar(&c.dhcp6.tftpIP, "dhcp6-tftp-ip", "", "[dhcp6] IPv6 TFTP server address to use in the DHCPv6 Bootfile URL option (opt 59), the port is taken from dhcp-tftp-port")
 }
 
 func backendFlags(c *config, fs *flag.FlagSet) {
+	fs.DurationVar(&c.backends.cache.TTL, "backend-cache-ttl", 0, "[backend] how long a backend lookup that found hardware is cached, 0 disables caching them, any backend")
+	fs.DurationVar(&c.backends.cache.NegativeTTL, "backend-cache-negative-ttl", 0, "[backend] how long a backend lookup that did not find hardware is cached, 0 disables caching them, any backend")
+	fs.StringVar(&c.backends.chain, "backend-chain", "", "[backend] comma separated list of backends to query in order, for example file,kube, the first backend with the hardware answers, overrides the -backend-*-enabled flags")
 	fs.BoolVar(&c.backends.file.Enabled, "backend-file-enabled", false, "[backend] enable the file backend for DHCP and the HTTP iPXE script")
-	fs.StringVar(&c.backends.file.FilePath, "backend-file-path", "", "[backend] the hardware yaml file path for the file backend")
+	fs.StringVar(&c.backends.file.FilePath, "backend-file-path", "", "[backend] the hardware yaml file path, or a directory of yaml and json files, for the file backend")
 	fs.BoolVar(&c.backends.kubernetes.Enabled, "backend-kube-enabled", true, "[backend] enable the kubernetes backend for DHCP and the HTTP iPXE script")
 	fs.StringVar(&c.backends.kubernetes.ConfigFilePath, "backend-kube-config", "", "[backend] the Kubernetes config file location, kube backend only")
 	fs.StringVar(&c.backends.kubernetes.APIURL, "backend-kube-api", "", "[backend] the Kubernetes API URL, used for in-cluster client construction, kube backend only")
-	fs.StringVar(&c.backends.kubernetes.Namespace, "backend-kube-namespace", "", "[backend] an optional Kubernetes namespace override to query hardware data from, kube backend only")
+	fs.StringVar(&c.backends.kubernetes.Namespace, "backend-kube-namespace", "", "[backend] an optional Kubernetes namespace override to query hardware data from, a comma separated list for more than one, kube backend only")
+	fs.StringVar(&c.backends.kubernetes.LabelSelector, "backend-kube-label-selector", "", "[backend] an optional label selector of the hardware to serve, for example tenant=a, kube backend only")
+	fs.StringVar(&c.backends.kubernetes.FieldSelector, "backend-kube-field-selector", "", "[backend] an optional field selector of the hardware to serve, only metadata.name and metadata.namespace are supported, kube backend only")
+	fs.StringVar(&c.backends.kubernetes.ConflictPolicy, "backend-kube-conflict-policy", "refuse", "[backend] the hardware to serve when more than one hardware has a MAC or IP address (refuse, oldest), oldest serves the hardware created first, kube backend only")
+	fs.BoolVar(&c.backends.kubernetes.Progress.Enabled, "backend-kube-progress-enabled", false, "[backend] write the last time each boot stage of a machine was served to annotations on its hardware, kube backend only")
+	fs.DurationVar(&c.backends.kubernetes.Progress.Interval, "backend-kube-progress-interval", time.Minute, "[backend] minimum time between two writes of the same boot stage of a machine, kube backend only")
+	fs.Float64Var(&c.backends.kubernetes.Progress.QPS, "backend-kube-progress-qps", 5, "[backend] maximum number of boot progress writes per second to the Kubernetes API, kube backend only")
+	fs.BoolVar(&c.backends.kubernetes.RequireWorkflow, "backend-kube-require-workflow", false, "[backend] only serve the OSIE iPXE script to machines whose hardware has a pending workflow, others boot from their local disk, kube backend only")
+	fs.DurationVar(&c.backends.kubernetes.SyncTimeout, "backend-kube-sync-timeout", time.Minute, "[backend] how long to wait for the hardware cache to sync before failing to start, kube backend only")
 	fs.BoolVar(&c.backends.Noop.Enabled, "backend-noop-enabled", false, "[backend] enable the noop backend for DHCP and the HTTP iPXE script")
+	fs.BoolVar(&c.backends.plugin.Enabled, "backend-plugin-enabled", false, "[backend] enable the gRPC backend plugin for DHCP and the HTTP iPXE script")
+	fs.StringVar(&c.backends.plugin.Target, "backend-plugin-target", "", "[backend] the gRPC target of the backend plugin, for example unix:///var/run/smee/backend.sock, plugin backend only")
+	fs.DurationVar(&c.backends.plugin.Timeout, "backend-plugin-timeout", 2*time.Second, "[backend] deadline of a lookup call to the backend plugin, plugin backend only")
+	fs.BoolVar(&c.backends.rest.Enabled, "backend-rest-enabled", false, "[backend] enable the REST backend for DHCP and the HTTP iPXE script")
+	fs.StringVar(&c.backends.rest.Config.URL, "backend-rest-url", "", "[backend] the URL of the REST endpoint for hardware lookups by mac and ip query parameter, rest backend only")
+	fs.StringVar(&c.backends.rest.Config.Token, "backend-rest-token", "", "[backend] bearer token sent to the REST endpoint, rest backend only")
+	fs.DurationVar(&c.backends.rest.Config.Timeout, "backend-rest-timeout", 5*time.Second, "[backend] timeout of a request to the REST endpoint, rest backend only")
+	fs.IntVar(&c.backends.rest.Config.Retries, "backend-rest-retries", 2, "[backend] number of times a failed request to the REST endpoint is retried, rest backend only")
+	fs.DurationVar(&c.backends.rest.Config.RetryDelay, "backend-rest-retry-delay", 500*time.Millisecond, "[backend] delay before the first retry of a request to the REST endpoint, doubled for every retry, rest backend only")
+	fs.StringVar(&c.backends.rest.Config.CAFile, "backend-rest-ca-cert", "", "[backend] CA certificate file to verify the REST endpoint, defaults to the system CAs, rest backend only")
+	fs.StringVar(&c.backends.rest.Config.CertFile, "backend-rest-client-cert", "", "[backend] client certificate file to authenticate to the REST endpoint, rest backend only")
+	fs.StringVar(&c.backends.rest.Config.KeyFile, "backend-rest-client-key", "", "[backend] client key file to authenticate to the REST endpoint, rest backend only")
+	fs.BoolVar(&c.backends.rest.Config.InsecureSkipVerify, "backend-rest-insecure-tls", false, "[backend] skip verification of the REST endpoint's certificate, rest backend only")
+	fs.BoolVar(&c.backends.sql.Enabled, "backend-sql-enabled", false, "[backend] enable the SQL database backend for DHCP and the HTTP iPXE script")
+	fs.StringVar(&c.backends.sql.Config.Driver, "backend-sql-driver", "pgx", "[backend] the database/sql driver, pgx for PostgreSQL, sql backend only")
+	fs.StringVar(&c.backends.sql.Config.DSN, "backend-sql-dsn", "", "[backend] the data source name of the database, for example postgres://smee@localhost:5432/smee, sql backend only")
+	fs.IntVar(&c.backends.sql.Config.MaxOpenConns, "backend-sql-max-open-conns", 10, "[backend] maximum number of open connections to the database, 0 means no limit, sql backend only")
+	fs.IntVar(&c.backends.sql.Config.MaxIdleConns, "backend-sql-max-idle-conns", 2, "[backend] maximum number of idle connections to the database, sql backend only")
+	fs.DurationVar(&c.backends.sql.Config.ConnMaxLifetime, "backend-sql-conn-max-lifetime", 5*time.Minute, "[backend] how long a connection to the database is reused, 0 means no limit, sql backend only")
+	fs.DurationVar(&c.backends.sql.Config.Timeout, "backend-sql-timeout", 2*time.Second, "[backend] timeout of a lookup in the database, sql backend only")
 }
 
 func otelFlags(c *config, fs *flag.FlagSet) {
@@ -162,7 +222,9 @@ func isoFlags(c *config, fs *flag.FlagSet) {
 
 func setFlags(c *config, fs *flag.FlagSet) {
 	fs.StringVar(&c.logLevel, "log-level", "info", "log level (debug, info)")
+	fs.StringVar(&c.configFile, "config", "", "YAML config file with flag names as keys, reloaded when it changes or on SIGHUP, flags and environment variables take precedence")
 	dhcpFlags(c, fs)
+	dhcp6Flags(c, fs)
 	tftpFlags(c, fs)
 	ipxeHTTPBinaryFlags(c, fs)
 	ipxeHTTPScriptFlags(c, fs)
@@ -172,6 +234,15 @@ func setFlags(c *config, fs *flag.FlagSet) {
 	isoFlags(c, fs)
 }
 
+// ffOptions are the options for parsing the flags from the command line, environment variables and the config file.
+func ffOptions() []ff.Option {
+	return []ff.Option{
+		ff.WithEnvVarPrefix(name),
+		ff.WithConfigFileFlag("config"),
+		ff.WithConfigFileParser(ffyaml.Parser),
+	}
+}
+
 func newCLI(cfg *config, fs *flag.FlagSet) *ffcli.Command {
 	setFlags(cfg, fs)
 	return &ffcli.Command{
@@ -179,7 +250,7 @@ func newCLI(cfg *config, fs *flag.FlagSet) *ffcli.Command {
 		ShortUsage: "smee [flags]",
 		LongHelp:   "Smee is the DHCP and Network boot service for use in the Tinkerbell stack.",
 		FlagSet:    fs,
-		Options:    []ff.Option{ff.WithEnvVarPrefix(name)},
+		Options:    ffOptions(),
 		UsageFunc:  customUsageFunc,
 	}
 }
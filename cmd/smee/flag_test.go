@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/smee/internal/backend/rest"
+	"github.com/tinkerbell/smee/internal/backend/sqldb"
 )
 
 func TestParser(t *testing.T) {
@@ -55,6 +57,21 @@ func TestParser(t *testing.T) {
 				},
 				injectMacAddress: true,
 			},
+			pool: dhcpPoolConfig{
+				leaseTime: time.Hour,
+			},
+			ha: dhcpHAConfig{
+				leaseName: "smee-dhcp",
+				replicas:  1,
+			},
+			limits: dhcpLimitConfig{
+				queueSize:   1024,
+				clientBurst: 10,
+				relayBurst:  100,
+			},
+		},
+		dhcp6: dhcp6Config{
+			bindAddr: "[::]:547",
 		},
 		iso: isoConfig{
 			enabled:     true,
@@ -64,7 +81,20 @@ func TestParser(t *testing.T) {
 		logLevel: "info",
 		backends: dhcpBackends{
 			file:       File{},
-			kubernetes: Kube{Enabled: true},
+			kubernetes: Kube{Enabled: true, ConflictPolicy: "refuse", Progress: KubeProgress{Interval: time.Minute, QPS: 5}, SyncTimeout: time.Minute},
+			plugin:     Plugin{Timeout: 2 * time.Second},
+			rest: Rest{Config: rest.Config{
+				Timeout:    5 * time.Second,
+				Retries:    2,
+				RetryDelay: 500 * time.Millisecond,
+			}},
+			sql: SQL{Config: sqldb.Config{
+				Driver:          "pgx",
+				MaxOpenConns:    10,
+				MaxIdleConns:    2,
+				ConnMaxLifetime: 5 * time.Minute,
+				Timeout:         2 * time.Second,
+			}},
 		},
 		otel: otelConfig{
 			insecure: true,
@@ -95,6 +125,10 @@ func TestParser(t *testing.T) {
 		cmp.AllowUnexported(ipxeHTTPBinary{}),
 		cmp.AllowUnexported(ipxeHTTPScript{}),
 		cmp.AllowUnexported(dhcpConfig{}),
+		cmp.AllowUnexported(dhcp6Config{}),
+		cmp.AllowUnexported(dhcpPoolConfig{}),
+		cmp.AllowUnexported(dhcpHAConfig{}),
+		cmp.AllowUnexported(dhcpLimitConfig{}),
 		cmp.AllowUnexported(dhcpBackends{}),
 		cmp.AllowUnexported(httpIpxeScript{}),
 		cmp.AllowUnexported(isoConfig{}),
@@ -115,16 +149,57 @@ USAGE
   smee [flags]
 
 FLAGS
+  -config                             YAML config file with flag names as keys, reloaded when it changes or on SIGHUP, flags and environment variables take precedence
   -log-level                          log level (debug, info) (default "info")
+  -backend-cache-negative-ttl         [backend] how long a backend lookup that did not find hardware is cached, 0 disables caching them, any backend (default "0s")
+  -backend-cache-ttl                  [backend] how long a backend lookup that found hardware is cached, 0 disables caching them, any backend (default "0s")
+  -backend-chain                      [backend] comma separated list of backends to query in order, for example file,kube, the first backend with the hardware answers, overrides the -backend-*-enabled flags
   -backend-file-enabled               [backend] enable the file backend for DHCP and the HTTP iPXE script (default "false")
-  -backend-file-path                  [backend] the hardware yaml file path for the file backend
+  -backend-file-path                  [backend] the hardware yaml file path, or a directory of yaml and json files, for the file backend
   -backend-kube-api                   [backend] the Kubernetes API URL, used for in-cluster client construction, kube backend only
   -backend-kube-config                [backend] the Kubernetes config file location, kube backend only
+  -backend-kube-conflict-policy       [backend] the hardware to serve when more than one hardware has a MAC or IP address (refuse, oldest), oldest serves the hardware created first, kube backend only (default "refuse")
   -backend-kube-enabled               [backend] enable the kubernetes backend for DHCP and the HTTP iPXE script (default "true")
-  -backend-kube-namespace             [backend] an optional Kubernetes namespace override to query hardware data from, kube backend only
+  -backend-kube-field-selector        [backend] an optional field selector of the hardware to serve, only metadata.name and metadata.namespace are supported, kube backend only
+  -backend-kube-label-selector        [backend] an optional label selector of the hardware to serve, for example tenant=a, kube backend only
+  -backend-kube-namespace             [backend] an optional Kubernetes namespace override to query hardware data from, a comma separated list for more than one, kube backend only
+  -backend-kube-progress-enabled      [backend] write the last time each boot stage of a machine was served to annotations on its hardware, kube backend only (default "false")
+  -backend-kube-progress-interval     [backend] minimum time between two writes of the same boot stage of a machine, kube backend only (default "1m0s")
+  -backend-kube-progress-qps          [backend] maximum number of boot progress writes per second to the Kubernetes API, kube backend only (default "5")
+  -backend-kube-require-workflow      [backend] only serve the OSIE iPXE script to machines whose hardware has a pending workflow, others boot from their local disk, kube backend only (default "false")
+  -backend-kube-sync-timeout          [backend] how long to wait for the hardware cache to sync before failing to start, kube backend only (default "1m0s")
   -backend-noop-enabled               [backend] enable the noop backend for DHCP and the HTTP iPXE script (default "false")
+  -backend-plugin-enabled             [backend] enable the gRPC backend plugin for DHCP and the HTTP iPXE script (default "false")
+  -backend-plugin-target              [backend] the gRPC target of the backend plugin, for example unix:///var/run/smee/backend.sock, plugin backend only
+  -backend-plugin-timeout             [backend] deadline of a lookup call to the backend plugin, plugin backend only (default "2s")
+  -backend-rest-ca-cert               [backend] CA certificate file to verify the REST endpoint, defaults to the system CAs, rest backend only
+  -backend-rest-client-cert           [backend] client certificate file to authenticate to the REST endpoint, rest backend only
+  -backend-rest-client-key            [backend] client key file to authenticate to the REST endpoint, rest backend only
+  -backend-rest-enabled               [backend] enable the REST backend for DHCP and the HTTP iPXE script (default "false")
+  -backend-rest-insecure-tls          [backend] skip verification of the REST endpoint's certificate, rest backend only (default "false")
+  -backend-rest-retries               [backend] number of times a failed request to the REST endpoint is retried, rest backend only (default "2")
+  -backend-rest-retry-delay           [backend] delay before the first retry of a request to the REST endpoint, doubled for every retry, rest backend only (default "500ms")
+  -backend-rest-timeout               [backend] timeout of a request to the REST endpoint, rest backend only (default "5s")
+  -backend-rest-token                 [backend] bearer token sent to the REST endpoint, rest backend only
+  -backend-rest-url                   [backend] the URL of the REST endpoint for hardware lookups by mac and ip query parameter, rest backend only
+  -backend-sql-conn-max-lifetime      [backend] how long a connection to the database is reused, 0 means no limit, sql backend only (default "5m0s")
+  -backend-sql-driver                 [backend] the database/sql driver, pgx for PostgreSQL, sql backend only (default "pgx")
+  -backend-sql-dsn                    [backend] the data source name of the database, for example postgres://smee@localhost:5432/smee, sql backend only
+  -backend-sql-enabled                [backend] enable the SQL database backend for DHCP and the HTTP iPXE script (default "false")
+  -backend-sql-max-idle-conns         [backend] maximum number of idle connections to the database, sql backend only (default "2")
+  -backend-sql-max-open-conns         [backend] maximum number of open connections to the database, 0 means no limit, sql backend only (default "10")
+  -backend-sql-timeout                [backend] timeout of a lookup in the database, sql backend only (default "2s")
+  -dhcp6-addr                         [dhcp6] local IP:Port to listen on for DHCPv6 requests (default "[::]:547")
+  -dhcp6-enabled                      [dhcp6] enable DHCPv6 server, only reservation mode is supported (default "false")
+  -dhcp6-iface                        [dhcp6] interface to bind to for DHCPv6 requests, also used for the server identifier (DUID)
+  -dhcp6-tftp-ip                      [dhcp6] IPv6 TFTP server address to use in the DHCPv6 Bootfile URL option (opt 59), the port is taken from dhcp-tftp-port
   -dhcp-addr                          [dhcp] local IP:Port to listen on for DHCP requests (default "0.0.0.0:67")
   -dhcp-enabled                       [dhcp] enable DHCP server (default "true")
+  -dhcp-ha-index                      [dhcp] zero based index of this replica, load-balance mode only (default "0")
+  -dhcp-ha-lease-name                 [dhcp] name of the Lease used for leader election, and the prefix of the Lease names of the replicas in load-balance mode (default "smee-dhcp")
+  -dhcp-ha-lease-namespace            [dhcp] namespace of the Leases used for high availability, defaults to the kubernetes backend namespace
+  -dhcp-ha-mode                       [dhcp] high availability mode so only one replica answers each client (leader, load-balance), both require the kubernetes backend
+  -dhcp-ha-replicas                   [dhcp] number of replicas that split the clients by the RFC 3074 hash of their client identifier, load-balance mode only (default "1")
   -dhcp-http-ipxe-binary-host         [dhcp] HTTP iPXE binaries host or IP to use in DHCP packets (default "%[1]v")
   -dhcp-http-ipxe-binary-path         [dhcp] HTTP iPXE binaries path to use in DHCP packets (default "/ipxe/")
   -dhcp-http-ipxe-binary-port         [dhcp] HTTP iPXE binaries port to use in DHCP packets (default "8080")
@@ -136,13 +211,29 @@ FLAGS
   -dhcp-http-ipxe-script-scheme       [dhcp] HTTP iPXE script scheme to use in DHCP packets (default "http")
   -dhcp-http-ipxe-script-url          [dhcp] HTTP iPXE script URL to use in DHCP packets, this overrides the flags for dhcp-http-ipxe-script-{scheme, host, port, path}
   -dhcp-iface                         [dhcp] interface to bind to for DHCP requests
+  -dhcp-iface-config                  [dhcp] semicolon separated per interface DHCP config, iface:key=value,... (keys: mode, ip-for-packet, syslog-ip, tftp-ip, http-ipxe-binary-url, http-ipxe-script-url), only configured interfaces are served
   -dhcp-ip-for-packet                 [dhcp] IP address to use in DHCP packets (opt 54, etc) (default "%[1]v")
+  -dhcp-lease-file                    [dhcp] file to persist DHCP leases in, leases are only kept in memory when not set, reservation mode only
   -dhcp-mode                          [dhcp] DHCP mode (reservation, proxy, auto-proxy) (default "reservation")
+  -dhcp-pool-cidr                     [dhcp] IPv4 CIDR to allocate addresses from for clients without a host reservation, reservation mode only
+  -dhcp-pool-dns                      [dhcp] comma separated list of DNS servers to use in DHCP packets for pool leases (opt 6)
+  -dhcp-pool-exclude                  [dhcp] comma separated list of IPs or IP ranges (start-end) in the pool CIDR that are never allocated
+  -dhcp-pool-gateway                  [dhcp] default gateway to use in DHCP packets for pool leases (opt 3)
+  -dhcp-pool-lease-time               [dhcp] lease time to use for pool leases (opt 51) (default "1h0m0s")
+  -dhcp-queue-size                    [dhcp] number of DHCP packets waiting for a worker, packets are dropped when the queue is full (default "1024")
+  -dhcp-rate-limit-client             [dhcp] DHCP packets per second allowed from a client by MAC address, 0 disables the limit (default "0")
+  -dhcp-rate-limit-client-burst       [dhcp] DHCP packets a client is allowed to send at once (default "10")
+  -dhcp-rate-limit-relay              [dhcp] DHCP packets per second allowed from a relay agent by giaddr, 0 disables the limit (default "0")
+  -dhcp-rate-limit-relay-burst        [dhcp] DHCP packets a relay agent is allowed to send at once (default "100")
+  -dhcp-retransmit-window             [dhcp] drop retransmits of a DHCP packet with the same xid and message type from a client within this window, 0 disables it (default "0s")
+  -dhcp-subnets-file                  [dhcp] YAML file with per subnet DHCP defaults (gateway, DNS, NTP, domain, lease time, netboot URLs), selected by giaddr, option 118 or option 82.5
   -dhcp-syslog-ip                     [dhcp] Syslog server IP address to use in DHCP packets (opt 7) (default "%[1]v")
   -dhcp-tftp-ip                       [dhcp] TFTP server IP address to use in DHCP packets (opt 66, etc) (default "%[1]v")
   -dhcp-tftp-port                     [dhcp] TFTP server port to use in DHCP packets (opt 66, etc) (default "69")
+  -dhcp-workers                       [dhcp] number of workers handling DHCP packets, 0 handles every packet in a new goroutine (default "0")
   -extra-kernel-args                  [http] extra set of kernel args (k=v k=v) that are appended to the kernel cmdline iPXE script
   -http-addr                          [http] local IP to listen on for iPXE HTTP script requests (default "%[1]v")
+  -http-admin-token                   [http] bearer token of the admin API that simulates the DHCP reply and iPXE script for a MAC address, the API is disabled when empty
   -http-ipxe-binary-enabled           [http] enable iPXE HTTP binary server (default "true")
   -http-ipxe-script-enabled           [http] enable iPXE HTTP script server (default "true")
   -http-port                          [http] local port to listen on for iPXE HTTP script requests (default "8080")
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/smee/internal/backend/cache"
+	"github.com/tinkerbell/smee/internal/backend/chain"
+	"github.com/tinkerbell/smee/internal/backend/kube"
+	"github.com/tinkerbell/smee/internal/dhcp/ha"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
+	"github.com/tinkerbell/smee/internal/dhcp/server"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+const (
+	haModeLeader      = "leader"
+	haModeLoadBalance = "load-balance"
+)
+
+// haHandler wraps h so that only one replica answers each DHCP client when high availability is enabled.
+func (c *config) haHandler(ctx context.Context, log logr.Logger, backend handler.BackendReader, h server.Handler) (server.Handler, error) {
+	switch c.dhcp.ha.mode {
+	case "":
+		return h, nil
+	case haModeLeader:
+		kb, ok := kubeBackend(backend)
+		if !ok {
+			return nil, fmt.Errorf("--dhcp-ha-mode=%s requires the kubernetes backend", haModeLeader)
+		}
+		ns, id, err := c.haLease()
+		if err != nil {
+			return nil, err
+		}
+		le := kube.LeaderElection{
+			Namespace: ns,
+			Name:      c.dhcp.ha.leaseName,
+			Identity:  id,
+		}
+		l := &ha.Leader{}
+		le.OnLeaderChange = func(leader bool) {
+			l.SetLeader(leader)
+			log.Info("dhcp leader election", "leader", leader, "identity", le.Identity, "lease", ns+"/"+le.Name)
+		}
+		go func() {
+			if err := kb.RunLeaderElection(ctx, le); err != nil {
+				log.Error(err, "dhcp leader election failed, not answering DHCP requests")
+			}
+		}()
+
+		return &ha.Handler{Next: h, Responder: l, Log: log}, nil
+	case haModeLoadBalance:
+		kb, ok := kubeBackend(backend)
+		if !ok {
+			return nil, fmt.Errorf("--dhcp-ha-mode=%s requires the kubernetes backend", haModeLoadBalance)
+		}
+		lb, err := ha.NewLoadBalancer(c.dhcp.ha.replicas, c.dhcp.ha.index)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dhcp load balancing config: %w", err)
+		}
+		ns, id, err := c.haLease()
+		if err != nil {
+			return nil, err
+		}
+		// Every replica holds a Lease of its own, so the replicas that are running answer the clients of those that are not.
+		m := kube.Membership{Namespace: ns, Index: lb.Index, Identity: id}
+		for i := range lb.Replicas {
+			m.Names = append(m.Names, fmt.Sprintf("%s-%d", c.dhcp.ha.leaseName, i))
+		}
+		m.OnChange = func(running []bool) {
+			lb.SetRunning(running)
+			log.Info("dhcp load balancing replicas changed", "running", running, "index", lb.Index)
+		}
+		go func() {
+			if err := kb.RunMembership(ctx, m); err != nil {
+				log.Error(err, "dhcp load balancing membership failed, only answering the clients of this replica")
+			}
+		}()
+		log.Info("dhcp load balancing", "replicas", lb.Replicas, "index", lb.Index)
+
+		return &ha.Handler{Next: h, Responder: lb, Log: log}, nil
+	default:
+		return nil, errors.New("invalid dhcp ha mode: " + c.dhcp.ha.mode)
+	}
+}
+
+// haLease returns the namespace of the Leases used for high availability and the identity of this replica in them.
+func (c *config) haLease() (namespace, identity string, err error) {
+	namespace = c.dhcp.ha.leaseNamespace
+	if namespace == "" {
+		if namespace, err = c.backends.kubernetes.namespace(); err != nil {
+			return "", "", fmt.Errorf("failed to determine the dhcp ha lease namespace: %w", err)
+		}
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to determine the dhcp ha lease identity: %w", err)
+	}
+
+	return namespace, host + "_" + string(uuid.NewUUID()), nil
+}
+
+// kubeBackend returns the kubernetes backend of b. b is the kubernetes backend itself,
+// or a cache or chain in front of it.
+func kubeBackend(b handler.BackendReader) (*kube.Backend, bool) {
+	switch b := b.(type) {
+	case *kube.Backend:
+		return b, true
+	case *cache.Backend:
+		return kubeBackend(b.Backend)
+	case *chain.Backend:
+		for _, e := range b.Backends {
+			if kb, ok := kubeBackend(e.Backend); ok {
+				return kb, true
+			}
+		}
+	}
+
+	return nil, false
+}
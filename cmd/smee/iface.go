@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// dhcpHandlerConfig is the part of the DHCP configuration that can be set per interface.
+type dhcpHandlerConfig struct {
+	mode          dhcpMode
+	ipForPacket   netip.Addr
+	syslogIP      string
+	tftpIP        netip.AddrPort
+	httpBinaryURL *url.URL
+	ipxeScriptURL func(net.HardwareAddr) *url.URL
+}
+
+// dhcpIfaceConfig is the DHCP configuration of a single interface.
+type dhcpIfaceConfig struct {
+	name string
+	dhcpHandlerConfig
+}
+
+// parseDHCPIfaceConfigs parses the per interface DHCP configuration.
+// Interfaces are separated by semicolons. Each interface is its name, a colon and a comma separated
+// list of key=value settings, for example "eth1:mode=proxy,ip-for-packet=192.168.2.1;eth2:tftp-ip=192.168.3.1".
+// Settings that are not given are taken from def.
+func parseDHCPIfaceConfigs(s string, def dhcpHandlerConfig, injectMac bool) ([]dhcpIfaceConfig, error) {
+	var ifaces []dhcpIfaceConfig
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, settings, _ := strings.Cut(entry, ":")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("missing interface name in %q", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("interface %q is configured more than once", name)
+		}
+		seen[name] = true
+
+		ic := dhcpIfaceConfig{name: name, dhcpHandlerConfig: def}
+		for _, kv := range strings.Split(settings, ",") {
+			if strings.TrimSpace(kv) == "" {
+				continue
+			}
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				return nil, fmt.Errorf("interface %q: setting %q is not in key=value form", name, kv)
+			}
+			if err := ic.set(strings.TrimSpace(k), strings.TrimSpace(v), injectMac); err != nil {
+				return nil, fmt.Errorf("interface %q: %w", name, err)
+			}
+		}
+		ifaces = append(ifaces, ic)
+	}
+
+	return ifaces, nil
+}
+
+// set updates a single setting of an interface configuration.
+func (c *dhcpIfaceConfig) set(k, v string, injectMac bool) error {
+	switch k {
+	case "mode":
+		switch m := dhcpMode(v); m {
+		case dhcpModeReservation, dhcpModeProxy, dhcpModeAutoProxy:
+			c.mode = m
+		default:
+			return fmt.Errorf("invalid mode %q", v)
+		}
+	case "ip-for-packet":
+		ip, err := netip.ParseAddr(v)
+		if err != nil {
+			return fmt.Errorf("invalid ip-for-packet: %w", err)
+		}
+		c.ipForPacket = ip
+	case "syslog-ip":
+		if _, err := netip.ParseAddr(v); err != nil {
+			return fmt.Errorf("invalid syslog-ip: %w", err)
+		}
+		c.syslogIP = v
+	case "tftp-ip":
+		ip, err := netip.ParseAddr(v)
+		if err != nil {
+			return fmt.Errorf("invalid tftp-ip: %w", err)
+		}
+		c.tftpIP = netip.AddrPortFrom(ip, c.tftpIP.Port())
+	case "http-ipxe-binary-url":
+		u, err := url.Parse(v)
+		if err != nil {
+			return fmt.Errorf("invalid http-ipxe-binary-url: %w", err)
+		}
+		c.httpBinaryURL = u
+	case "http-ipxe-script-url":
+		u, err := url.Parse(v)
+		if err != nil {
+			return fmt.Errorf("invalid http-ipxe-script-url: %w", err)
+		}
+		c.ipxeScriptURL = scriptURLFunc(u, injectMac)
+	default:
+		return fmt.Errorf("unknown setting %q", k)
+	}
+
+	return nil
+}
+
+// scriptURLFunc returns a function that builds the iPXE script URL for a MAC address.
+// When injectMac is true the MAC address is prepended to the file name of u.
+func scriptURLFunc(u *url.URL, injectMac bool) func(net.HardwareAddr) *url.URL {
+	if !injectMac {
+		return func(net.HardwareAddr) *url.URL {
+			return u
+		}
+	}
+
+	return func(mac net.HardwareAddr) *url.URL {
+		s := *u
+		p := path.Base(s.Path)
+		s.Path = path.Join(path.Dir(s.Path), mac.String(), p)
+		return &s
+	}
+}
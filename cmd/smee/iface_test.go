@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net"
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+func TestParseDHCPIfaceConfigs(t *testing.T) {
+	def := dhcpHandlerConfig{
+		mode:          dhcpModeReservation,
+		ipForPacket:   netip.MustParseAddr("192.168.2.4"),
+		syslogIP:      "192.168.2.4",
+		tftpIP:        netip.MustParseAddrPort("192.168.2.4:69"),
+		httpBinaryURL: &url.URL{Scheme: "http", Host: "192.168.2.4:8080", Path: "/ipxe/"},
+		ipxeScriptURL: scriptURLFunc(&url.URL{Scheme: "http", Host: "192.168.2.4:8080", Path: "/auto.ipxe"}, true),
+	}
+	mac := net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+
+	got, err := parseDHCPIfaceConfigs("eth1:mode=proxy,ip-for-packet=10.0.1.1,tftp-ip=10.0.1.1,http-ipxe-script-url=http://10.0.1.1/auto.ipxe ; eth2:", def, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d interfaces, want 2", len(got))
+	}
+	eth1, eth2 := got[0], got[1]
+	if eth1.name != "eth1" || eth1.mode != dhcpModeProxy || eth1.ipForPacket != netip.MustParseAddr("10.0.1.1") || eth1.tftpIP != netip.MustParseAddrPort("10.0.1.1:69") {
+		t.Errorf("unexpected eth1 config: %+v", eth1)
+	}
+	if u := eth1.ipxeScriptURL(mac).String(); u != "http://10.0.1.1/00:01:02:03:04:05/auto.ipxe" {
+		t.Errorf("eth1 script url = %v", u)
+	}
+	if eth1.httpBinaryURL != def.httpBinaryURL || eth1.syslogIP != def.syslogIP {
+		t.Errorf("eth1 did not inherit the defaults: %+v", eth1)
+	}
+	if eth2.name != "eth2" || eth2.mode != def.mode || eth2.ipForPacket != def.ipForPacket {
+		t.Errorf("unexpected eth2 config: %+v", eth2)
+	}
+
+	errs := map[string]string{
+		"no name":       ":mode=proxy",
+		"duplicate":     "eth1:mode=proxy;eth1:mode=reservation",
+		"bad mode":      "eth1:mode=foo",
+		"bad ip":        "eth1:ip-for-packet=foo",
+		"unknown key":   "eth1:foo=bar",
+		"not key value": "eth1:mode",
+	}
+	for name, s := range errs {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseDHCPIfaceConfigs(s, def, true); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
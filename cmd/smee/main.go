@@ -11,7 +11,6 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
-	"path"
 	"path/filepath"
 	"strings"
 	"syscall"
@@ -20,12 +19,22 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/insomniacslk/dhcp/dhcpv4/server4"
-	"github.com/tinkerbell/ipxedust"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/peterbourgon/ff/v3/ffcli"
 	"github.com/tinkerbell/ipxedust/ihttp"
+	"github.com/tinkerbell/smee/internal/admin"
+	"github.com/tinkerbell/smee/internal/backend/cache"
+	"github.com/tinkerbell/smee/internal/backend/chain"
 	"github.com/tinkerbell/smee/internal/dhcp/handler"
 	"github.com/tinkerbell/smee/internal/dhcp/handler/proxy"
 	"github.com/tinkerbell/smee/internal/dhcp/handler/reservation"
+	"github.com/tinkerbell/smee/internal/dhcp/handler/reservation6"
+	"github.com/tinkerbell/smee/internal/dhcp/lease"
+	"github.com/tinkerbell/smee/internal/dhcp/pool"
 	"github.com/tinkerbell/smee/internal/dhcp/server"
+	"github.com/tinkerbell/smee/internal/dhcp/subnet"
+	"github.com/tinkerbell/smee/internal/health"
 	"github.com/tinkerbell/smee/internal/ipxe/http"
 	"github.com/tinkerbell/smee/internal/ipxe/script"
 	"github.com/tinkerbell/smee/internal/iso"
@@ -33,6 +42,7 @@ import (
 	"github.com/tinkerbell/smee/internal/otel"
 	"github.com/tinkerbell/smee/internal/syslog"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
 )
 
 var (
@@ -42,6 +52,9 @@ var (
 	startTime = time.Now()
 )
 
+// otelErrorWindow is how long an error of the OpenTelemetry exporter is reported by the health checks.
+const otelErrorWindow = time.Minute
+
 const (
 	name                         = "smee"
 	dhcpModeProxy       dhcpMode = "proxy"
@@ -58,12 +71,15 @@ type config struct {
 	ipxeHTTPBinary ipxeHTTPBinary
 	ipxeHTTPScript ipxeHTTPScript
 	dhcp           dhcpConfig
+	dhcp6          dhcp6Config
 	iso            isoConfig
 
 	// loglevel is the log level for smee.
 	logLevel string
-	backends dhcpBackends
-	otel     otelConfig
+	// configFile is the path to a YAML file with flag values.
+	configFile string
+	backends   dhcpBackends
+	otel       otelConfig
 }
 
 type syslogConfig struct {
@@ -95,6 +111,7 @@ type ipxeHTTPScript struct {
 	tinkServerUseTLS      bool
 	tinkServerInsecureTLS bool
 	trustedProxies        string
+	adminToken            string
 	retries               int
 	retryDelay            int
 }
@@ -113,6 +130,45 @@ type dhcpConfig struct {
 	httpIpxeBinaryURL urlBuilder
 	httpIpxeScript    httpIpxeScript
 	httpIpxeScriptURL string
+	pool              dhcpPoolConfig
+	leaseFile         string
+	ifaceConfig       string
+	subnetsFile       string
+	ha                dhcpHAConfig
+	limits            dhcpLimitConfig
+}
+
+type dhcpLimitConfig struct {
+	workers          int
+	queueSize        int
+	clientRate       float64
+	clientBurst      int
+	relayRate        float64
+	relayBurst       int
+	retransmitWindow time.Duration
+}
+
+type dhcpHAConfig struct {
+	mode           string
+	leaseName      string
+	leaseNamespace string
+	replicas       int
+	index          int
+}
+
+type dhcpPoolConfig struct {
+	cidr        string
+	exclude     string
+	gateway     string
+	nameServers string
+	leaseTime   time.Duration
+}
+
+type dhcp6Config struct {
+	enabled       bool
+	bindAddr      string
+	bindInterface string
+	tftpIP        string
 }
 
 type urlBuilder struct {
@@ -134,6 +190,12 @@ type dhcpBackends struct {
 	file       File
 	kubernetes Kube
 	Noop       Noop
+	rest       Rest
+	plugin     Plugin
+	sql        SQL
+	// chain is a comma separated list of backends that are queried in order.
+	chain string
+	cache Cache
 }
 
 type otelConfig struct {
@@ -150,19 +212,27 @@ type isoConfig struct {
 
 func main() {
 	cfg := &config{}
-	cli := newCLI(cfg, flag.NewFlagSet(name, flag.ExitOnError))
-	_ = cli.Parse(os.Args[1:])
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	cli := newCLI(cfg, fs)
+	if err := cli.Parse(os.Args[1:]); err != nil && !errors.As(err, &ffcli.NoExecError{}) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
 	log := defaultLogger(cfg.logLevel)
 	log.Info("starting", "version", GitRev)
 
-	ctx, done := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGHUP, syscall.SIGTERM)
+	ctx, done := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer done()
+	// the health of the subsystems, reported by the http server.
+	hr := &health.Registry{}
+	otelErrors := &health.Errors{Window: otelErrorWindow}
 	oCfg := otel.Config{
 		Servicename: "smee",
 		Endpoint:    cfg.otel.endpoint,
 		Insecure:    cfg.otel.insecure,
 		Logger:      log,
+		OnError:     otelErrors.Handle,
 	}
 	ctx, otelShutdown, err := otel.Init(ctx, oCfg)
 	if err != nil {
@@ -170,6 +240,9 @@ func main() {
 		panic(err)
 	}
 	defer otelShutdown()
+	if cfg.otel.endpoint != "" {
+		hr.Register("otel", health.Informational, otelErrors.Check)
+	}
 	metric.Init()
 
 	g, ctx := errgroup.WithContext(ctx)
@@ -177,8 +250,10 @@ func main() {
 	if cfg.syslog.enabled {
 		addr := fmt.Sprintf("%s:%d", cfg.syslog.bindAddr, cfg.syslog.bindPort)
 		log.Info("starting syslog server", "bind_addr", addr)
+		sl := &health.Listener{}
+		hr.Register("syslog", health.Liveness, sl.Check)
 		g.Go(func() error {
-			if err := syslog.StartReceiver(ctx, log, addr, 1); err != nil {
+			if _, err := syslog.StartReceiver(ctx, log, addr, 1, sl); err != nil {
 				log.Error(err, "syslog server failure")
 				return err
 			}
@@ -188,27 +263,43 @@ func main() {
 		})
 	}
 
+	// dhcp lease store, shared by the dhcp handler and the http lease table.
+	var leases lease.Store
+	if cfg.dhcp.enabled && cfg.dhcpModeEnabled(dhcpModeReservation) {
+		leases, err = cfg.leaseStore()
+		if err != nil {
+			log.Error(err, "failed to create dhcp lease store")
+			panic(fmt.Errorf("failed to create dhcp lease store: %w", err))
+		}
+	}
+	// the backend is shared by the dhcp, dhcpv6, http ipxe script and iso handlers.
+	var backend handler.BackendReader
+	if cfg.dhcp.enabled || cfg.dhcp6.enabled || cfg.ipxeHTTPScript.enabled || cfg.iso.enabled {
+		backend, err = cfg.backend(ctx, log)
+		if err != nil {
+			panic(fmt.Errorf("failed to create backend: %w", err))
+		}
+	}
+	if rc, ok := backend.(handler.ReadinessChecker); ok {
+		hr.Register("backend", health.Readiness, func(ctx context.Context) (string, error) {
+			return "", rc.Ready(ctx)
+		})
+	}
+	progress, err := cfg.progressRecorder(ctx, log, backend)
+	if err != nil {
+		panic(fmt.Errorf("failed to create boot progress writer: %w", err))
+	}
+
 	// tftp
 	if cfg.tftp.enabled {
-		tftpServer := &ipxedust.Server{
-			Log:                  log.WithValues("service", "github.com/tinkerbell/smee").WithName("github.com/tinkerbell/ipxedust"),
-			HTTP:                 ipxedust.ServerSpec{Disabled: true}, // disabled because below we use the http handlerfunc instead.
-			EnableTFTPSinglePort: true,
-		}
-		tftpServer.EnableTFTPSinglePort = true
 		addr := fmt.Sprintf("%s:%d", cfg.tftp.bindAddr, cfg.tftp.bindPort)
 		if ip, err := netip.ParseAddrPort(addr); err == nil {
-			tftpServer.TFTP = ipxedust.ServerSpec{
-				Disabled:  false,
-				Addr:      ip,
-				Timeout:   cfg.tftp.timeout,
-				Patch:     []byte(cfg.tftp.ipxeScriptPatch),
-				BlockSize: cfg.tftp.blockSize,
-			}
 			// start the ipxe binary tftp server
 			log.Info("starting tftp server", "bind_addr", addr)
+			tl := &health.Listener{}
+			hr.Register("tftp", health.Liveness, tl.Check)
 			g.Go(func() error {
-				return tftpServer.ListenAndServe(ctx)
+				return cfg.serveTFTP(ctx, log.WithValues("service", "github.com/tinkerbell/smee").WithName("github.com/tinkerbell/ipxedust"), ip, progress, tl)
 			})
 		} else {
 			log.Error(err, "invalid bind address")
@@ -216,108 +307,123 @@ func main() {
 		}
 	}
 
-	handlers := http.HandlerMapping{}
-	// http ipxe binaries
-	if cfg.ipxeHTTPBinary.enabled {
-		// serve ipxe binaries from the "/ipxe/" URI.
-		handlers["/ipxe/"] = ihttp.Handler{
-			Log:   log.WithValues("service", "github.com/tinkerbell/smee").WithName("github.com/tinkerbell/ipxedust"),
-			Patch: []byte(cfg.tftp.ipxeScriptPatch),
-		}.Handle
-	}
-
-	// http ipxe script
-	if cfg.ipxeHTTPScript.enabled {
-		br, err := cfg.backend(ctx, log)
-		if err != nil {
-			panic(fmt.Errorf("failed to create backend: %w", err))
-		}
-		jh := script.Handler{
-			Logger:                log,
-			Backend:               br,
-			OSIEURL:               cfg.ipxeHTTPScript.hookURL,
-			ExtraKernelParams:     strings.Split(cfg.ipxeHTTPScript.extraKernelArgs, " "),
-			PublicSyslogFQDN:      cfg.dhcp.syslogIP,
-			TinkServerTLS:         cfg.ipxeHTTPScript.tinkServerUseTLS,
-			TinkServerInsecureTLS: cfg.ipxeHTTPScript.tinkServerInsecureTLS,
-			TinkServerGRPCAddr:    cfg.ipxeHTTPScript.tinkServer,
-			IPXEScriptRetries:     cfg.ipxeHTTPScript.retries,
-			IPXEScriptRetryDelay:  cfg.ipxeHTTPScript.retryDelay,
-			StaticIPXEEnabled:     (dhcpMode(cfg.dhcp.mode) == dhcpModeAutoProxy),
-		}
+	rl := &reloader{log: log, args: os.Args[1:], fs: fs, backend: backend, leases: leases, progress: progress, health: hr}
 
-		// serve ipxe script from the "/" URI.
-		handlers["/"] = jh.HandlerFunc()
-	}
-
-	if cfg.iso.enabled {
-		br, err := cfg.backend(ctx, log)
+	// The DHCP handler is created before the http handlers, so that the admin API can simulate its replies.
+	if cfg.dhcp.enabled {
+		p, err := cfg.dhcpPool(log)
 		if err != nil {
-			panic(fmt.Errorf("failed to create backend: %w", err))
-		}
-		ih := iso.Handler{
-			Logger:             log,
-			Backend:            br,
-			SourceISO:          cfg.iso.url,
-			ExtraKernelParams:  strings.Split(cfg.ipxeHTTPScript.extraKernelArgs, " "),
-			Syslog:             cfg.dhcp.syslogIP,
-			TinkServerTLS:      cfg.ipxeHTTPScript.tinkServerUseTLS,
-			TinkServerGRPCAddr: cfg.ipxeHTTPScript.tinkServer,
-			StaticIPAMEnabled:  cfg.iso.staticIPAMEnabled,
-			MagicString: func() string {
-				if cfg.iso.magicString == "" {
-					return magicString
-				}
-				return cfg.iso.magicString
-			}(),
+			log.Error(err, "failed to create dhcp listener")
+			panic(fmt.Errorf("failed to create dhcp listener: %w", err))
 		}
-		isoHandler, err := ih.HandlerFunc()
+		dh, err := cfg.dhcpHandler(log, backend, leases, p, progress)
 		if err != nil {
-			panic(fmt.Errorf("failed to create iso handler: %w", err))
+			log.Error(err, "failed to create dhcp listener")
+			panic(fmt.Errorf("failed to create dhcp listener: %w", err))
 		}
-		handlers["/iso/"] = isoHandler
+		rl.pool = p
+		rl.dhcp = &server.Reloadable{}
+		rl.dhcp.Store(dh)
 	}
 
+	handlers, err := cfg.httpHandlers(log, backend, leases, progress, rl.dhcp)
+	if err != nil {
+		panic(err)
+	}
 	if len(handlers) > 0 {
 		// start the http server for ipxe binaries and scripts
-		tp := parseTrustedProxies(cfg.ipxeHTTPScript.trustedProxies)
-		httpServer := &http.Config{
-			GitRev:         GitRev,
-			StartTime:      startTime,
-			Logger:         log,
-			TrustedProxies: tp,
+		httpServer, err := cfg.httpConfig(log, hr)
+		if err != nil {
+			log.Error(err, "failed to create http handler")
+			panic(err)
 		}
+		h, err := httpServer.Handler(handlers)
+		if err != nil {
+			log.Error(err, "failed to create http handler")
+			panic(err)
+		}
+		rl.http = &http.Reloadable{}
+		rl.http.Store(h)
 		bindAddr := fmt.Sprintf("%s:%d", cfg.ipxeHTTPScript.bindAddr, cfg.ipxeHTTPScript.bindPort)
-		log.Info("serving http", "addr", bindAddr, "trusted_proxies", tp)
+		log.Info("serving http", "addr", bindAddr, "trusted_proxies", httpServer.TrustedProxies)
 		g.Go(func() error {
-			return httpServer.ServeHTTP(ctx, bindAddr, handlers)
+			return httpServer.Serve(ctx, bindAddr, rl.http)
 		})
 	}
 
 	// dhcp serving
 	if cfg.dhcp.enabled {
-		dh, err := cfg.dhcpHandler(ctx, log)
+		h, err := cfg.haHandler(ctx, log, backend, rl.dhcp)
 		if err != nil {
 			log.Error(err, "failed to create dhcp listener")
 			panic(fmt.Errorf("failed to create dhcp listener: %w", err))
 		}
 		log.Info("starting dhcp server", "bind_addr", cfg.dhcp.bindAddr)
+		dl := &health.Listener{}
+		hr.Register("dhcp", health.Liveness, dl.Check)
 		g.Go(func() error {
 			bindAddr, err := netip.ParseAddrPort(cfg.dhcp.bindAddr)
 			if err != nil {
 				panic(fmt.Errorf("invalid tftp address for DHCP server: %w", err))
 			}
-			conn, err := server4.NewIPv4UDPConn(cfg.dhcp.bindInterface, net.UDPAddrFromAddrPort(bindAddr))
+			// With per interface configuration, listen on all interfaces and let the handler pick the
+			// configuration by the interface a message was received on.
+			bindInterface := cfg.dhcp.bindInterface
+			if cfg.dhcp.ifaceConfig != "" {
+				bindInterface = ""
+			}
+			conn, err := server4.NewIPv4UDPConn(bindInterface, net.UDPAddrFromAddrPort(bindAddr))
 			if err != nil {
 				panic(err)
 			}
 			defer conn.Close()
-			ds := &server.DHCP{Logger: log, Conn: conn, Handlers: []server.Handler{dh}}
+			ds := &server.DHCP{
+				Logger:    log,
+				Conn:      conn,
+				Handlers:  []server.Handler{h},
+				Workers:   cfg.dhcp.limits.workers,
+				QueueSize: cfg.dhcp.limits.queueSize,
+				Limiter:   cfg.dhcpLimiter(),
+				Health:    dl,
+			}
 
 			return ds.Serve(ctx)
 		})
 	}
 
+	// dhcpv6 serving
+	if cfg.dhcp6.enabled {
+		dh, err := cfg.dhcp6Handler(log, backend)
+		if err != nil {
+			log.Error(err, "failed to create dhcpv6 listener")
+			panic(fmt.Errorf("failed to create dhcpv6 listener: %w", err))
+		}
+		rl.dhcp6 = &server.Reloadable6{}
+		rl.dhcp6.Store(dh)
+		log.Info("starting dhcpv6 server", "bind_addr", cfg.dhcp6.bindAddr)
+		dl := &health.Listener{}
+		hr.Register("dhcpv6", health.Liveness, dl.Check)
+		g.Go(func() error {
+			bindAddr, err := netip.ParseAddrPort(cfg.dhcp6.bindAddr)
+			if err != nil {
+				panic(fmt.Errorf("invalid bind address for DHCPv6 server: %w", err))
+			}
+			ds, err := server.NewServer6(cfg.dhcp6.bindInterface, net.UDPAddrFromAddrPort(bindAddr), rl.dhcp6)
+			if err != nil {
+				panic(err)
+			}
+			ds.Logger = log
+			ds.Health = dl
+
+			return ds.Serve(ctx)
+		})
+	}
+
+	// configuration reloading
+	g.Go(func() error {
+		return rl.run(ctx, cfg.configFile)
+	})
+
 	if err := g.Wait(); err != nil && !errors.Is(err, context.Canceled) {
 		log.Error(err, "failed running all Smee services")
 		panic(err)
@@ -336,50 +442,189 @@ func numTrue(b ...bool) int {
 }
 
 func (c *config) backend(ctx context.Context, log logr.Logger) (handler.BackendReader, error) {
-	if c.backends.file.Enabled || c.backends.Noop.Enabled {
-		// the kubernetes backend is enabled by default so we disable it
-		// if another backend is enabled so that users don't have to explicitly
-		// set the CLI flag to disable it when using another backend.
-		c.backends.kubernetes.Enabled = false
+	b, err := c.selectBackend(ctx, log)
+	if err != nil {
+		return nil, err
+	}
+	if c.backends.cache.TTL <= 0 && c.backends.cache.NegativeTTL <= 0 {
+		return b, nil
 	}
-	var be handler.BackendReader
+
+	return cache.New(b, c.backends.cache.TTL, c.backends.cache.NegativeTTL), nil
+}
+
+// selectBackend creates the enabled backend, or the backends in the chain.
+func (c *config) selectBackend(ctx context.Context, log logr.Logger) (handler.BackendReader, error) {
+	if c.backends.chain != "" {
+		return c.chainBackend(ctx, log)
+	}
+	// the kubernetes backend is enabled by default so we disable it
+	// if another backend is enabled so that users don't have to explicitly
+	// set the CLI flag to disable it when using another backend.
+	// The config is not changed, so the flags keep the values they were set to.
+	kubeEnabled := c.backends.kubernetes.Enabled && !c.backends.file.Enabled && !c.backends.Noop.Enabled && !c.backends.rest.Enabled && !c.backends.plugin.Enabled && !c.backends.sql.Enabled
 	switch {
-	case numTrue(c.backends.file.Enabled, c.backends.kubernetes.Enabled, c.backends.Noop.Enabled) > 1:
-		return nil, errors.New("only one backend can be enabled at a time")
+	case numTrue(c.backends.file.Enabled, kubeEnabled, c.backends.Noop.Enabled, c.backends.rest.Enabled, c.backends.plugin.Enabled, c.backends.sql.Enabled) > 1:
+		return nil, errors.New("only one backend can be enabled at a time, use -backend-chain to query more than one")
 	case c.backends.Noop.Enabled:
 		if c.dhcp.mode != string(dhcpModeAutoProxy) {
 			return nil, errors.New("noop backend can only be used with --dhcp-mode=auto-proxy")
 		}
-		be = c.backends.Noop.backend()
+		return c.backends.Noop.backend(), nil
 	case c.backends.file.Enabled:
-		b, err := c.backends.file.backend(ctx, log)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create file backend: %w", err)
-		}
-		be = b
+		return c.namedBackend(ctx, log, "file")
+	case c.backends.rest.Enabled:
+		return c.namedBackend(ctx, log, "rest")
+	case c.backends.plugin.Enabled:
+		return c.namedBackend(ctx, log, "plugin")
+	case c.backends.sql.Enabled:
+		return c.namedBackend(ctx, log, "sql")
 	default: // default backend is kubernetes
-		b, err := c.backends.kubernetes.backend(ctx)
+		return c.namedBackend(ctx, log, "kube")
+	}
+}
+
+// namedBackend creates the backend with name, configured by its flags.
+func (c *config) namedBackend(ctx context.Context, log logr.Logger, name string) (handler.BackendReader, error) {
+	var b handler.BackendReader
+	var err error
+	switch name {
+	case "file":
+		b, err = c.backends.file.backend(ctx, log)
+	case "kube":
+		b, err = c.backends.kubernetes.backend(ctx, log)
+	case "rest":
+		b, err = c.backends.rest.backend(log)
+	case "plugin":
+		b, err = c.backends.plugin.backend(ctx, log)
+	case "sql":
+		b, err = c.backends.sql.backend(ctx, log)
+	default:
+		return nil, fmt.Errorf("unknown backend %q, must be one of file, kube, rest, plugin or sql", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s backend: %w", name, err)
+	}
+
+	return b, nil
+}
+
+// chainBackend creates the backends in the chain, in order, and returns a backend that queries them in that order.
+// The -backend-*-enabled flags are not used, the chain selects the backends.
+func (c *config) chainBackend(ctx context.Context, log logr.Logger) (handler.BackendReader, error) {
+	cb := &chain.Backend{}
+	seen := map[string]bool{}
+	for _, name := range strings.Split(c.backends.chain, ",") {
+		name = strings.TrimSpace(name)
+		if seen[name] {
+			return nil, fmt.Errorf("backend %q is in the chain more than once", name)
+		}
+		seen[name] = true
+		b, err := c.namedBackend(ctx, log, name)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create kubernetes backend: %w", err)
+			return nil, err
 		}
-		be = b
+		cb.Backends = append(cb.Backends, chain.Entry{Name: name, Backend: b})
 	}
 
-	return be, nil
+	return cb, nil
 }
 
-func (c *config) dhcpHandler(ctx context.Context, log logr.Logger) (server.Handler, error) {
-	// 1. create the handler
-	// 2. create the backend
-	// 3. add the backend to the handler
-	pktIP, err := netip.ParseAddr(c.dhcp.ipForPacket)
-	if err != nil {
-		return nil, fmt.Errorf("invalid bind address: %w", err)
+// httpHandlers returns the handlers of the http server.
+// The admin API simulates the replies of dhcp, which is nil when DHCP is not enabled.
+func (c *config) httpHandlers(log logr.Logger, backend handler.BackendReader, leases lease.Store, progress handler.ProgressRecorder, dhcp *server.Reloadable) (http.HandlerMapping, error) {
+	handlers := http.HandlerMapping{}
+	if leases != nil {
+		handlers["/leases"] = lease.HandlerFunc(leases, log)
 	}
-	tftpIP, err := netip.ParseAddrPort(fmt.Sprintf("%s:%d", c.dhcp.tftpIP, c.dhcp.tftpPort))
+	// http ipxe binaries
+	if c.ipxeHTTPBinary.enabled {
+		// serve ipxe binaries from the "/ipxe/" URI.
+		handlers["/ipxe/"] = ihttp.Handler{
+			Log:   log.WithValues("service", "github.com/tinkerbell/smee").WithName("github.com/tinkerbell/ipxedust"),
+			Patch: []byte(c.tftp.ipxeScriptPatch),
+		}.Handle
+	}
+
+	var ah *admin.Handler
+	if c.ipxeHTTPScript.adminToken != "" {
+		ah = &admin.Handler{Log: log.WithName("admin"), Token: c.ipxeHTTPScript.adminToken}
+		if dhcp != nil {
+			ah.DHCP = dhcp
+		}
+		handlers["/admin/"] = ah.HandlerFunc()
+	}
+
+	// http ipxe script
+	if c.ipxeHTTPScript.enabled {
+		jh := &script.Handler{
+			Logger:                log,
+			Backend:               backend,
+			OSIEURL:               c.ipxeHTTPScript.hookURL,
+			ExtraKernelParams:     strings.Split(c.ipxeHTTPScript.extraKernelArgs, " "),
+			PublicSyslogFQDN:      c.dhcp.syslogIP,
+			TinkServerTLS:         c.ipxeHTTPScript.tinkServerUseTLS,
+			TinkServerInsecureTLS: c.ipxeHTTPScript.tinkServerInsecureTLS,
+			TinkServerGRPCAddr:    c.ipxeHTTPScript.tinkServer,
+			IPXEScriptRetries:     c.ipxeHTTPScript.retries,
+			IPXEScriptRetryDelay:  c.ipxeHTTPScript.retryDelay,
+			StaticIPXEEnabled:     (c.dhcpModeEnabled(dhcpModeAutoProxy) || c.dhcp.pool.cidr != ""),
+			Progress:              progress,
+		}
+
+		// serve ipxe script from the "/" URI.
+		handlers["/"] = jh.HandlerFunc()
+		if ah != nil {
+			ah.Script = jh
+		}
+	}
+
+	if c.iso.enabled {
+		ih := iso.Handler{
+			Logger:             log,
+			Backend:            backend,
+			SourceISO:          c.iso.url,
+			ExtraKernelParams:  strings.Split(c.ipxeHTTPScript.extraKernelArgs, " "),
+			Syslog:             c.dhcp.syslogIP,
+			TinkServerTLS:      c.ipxeHTTPScript.tinkServerUseTLS,
+			TinkServerGRPCAddr: c.ipxeHTTPScript.tinkServer,
+			StaticIPAMEnabled:  c.iso.staticIPAMEnabled,
+			Progress:           progress,
+			MagicString: func() string {
+				if c.iso.magicString == "" {
+					return magicString
+				}
+				return c.iso.magicString
+			}(),
+		}
+		isoHandler, err := ih.HandlerFunc()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create iso handler: %w", err)
+		}
+		handlers["/iso/"] = isoHandler
+	}
+
+	return handlers, nil
+}
+
+// httpConfig returns the configuration of the http server, which reports the health of the subsystems in hr.
+func (c *config) httpConfig(log logr.Logger, hr *health.Registry) (*http.Config, error) {
+	tp, err := parseTrustedProxies(c.ipxeHTTPScript.trustedProxies)
 	if err != nil {
-		return nil, fmt.Errorf("invalid tftp address for DHCP server: %w", err)
+		return nil, err
 	}
+
+	return &http.Config{
+		GitRev:         GitRev,
+		StartTime:      startTime,
+		Logger:         log,
+		TrustedProxies: tp,
+		Health:         hr,
+	}, nil
+}
+
+// httpBinaryURL returns the URL of the HTTP iPXE binary server used in DHCP packets.
+func (c *config) httpBinaryURL() (*url.URL, error) {
 	httpBinaryURL := &url.URL{
 		Scheme: c.dhcp.httpIpxeBinaryURL.Scheme,
 		Host:   fmt.Sprintf("%s:%d", c.dhcp.httpIpxeBinaryURL.Host, c.dhcp.httpIpxeBinaryURL.Port),
@@ -389,8 +634,14 @@ func (c *config) dhcpHandler(ctx context.Context, log logr.Logger) (server.Handl
 		return nil, fmt.Errorf("invalid http ipxe binary url: %w", err)
 	}
 
+	return httpBinaryURL, nil
+}
+
+// ipxeScriptURL returns a function that builds the iPXE script URL used in DHCP packets for a given MAC address.
+func (c *config) ipxeScriptURL() (func(net.HardwareAddr) *url.URL, error) {
 	var httpScriptURL *url.URL
 	if c.dhcp.httpIpxeScriptURL != "" {
+		var err error
 		httpScriptURL, err = url.Parse(c.dhcp.httpIpxeScriptURL)
 		if err != nil {
 			return nil, fmt.Errorf("invalid http ipxe script url: %w", err)
@@ -418,70 +669,161 @@ func (c *config) dhcpHandler(ctx context.Context, log logr.Logger) (server.Handl
 	if _, err := url.Parse(httpScriptURL.String()); err != nil {
 		return nil, fmt.Errorf("invalid http ipxe script url: %w", err)
 	}
-	ipxeScript := func(*dhcpv4.DHCPv4) *url.URL {
-		return httpScriptURL
+	return scriptURLFunc(httpScriptURL, c.dhcp.httpIpxeScript.injectMacAddress), nil
+}
+
+// dhcpHandler returns the DHCP handler. p is the address pool for clients without a host reservation, it is nil
+// when no pool is configured.
+func (c *config) dhcpHandler(log logr.Logger, backend handler.BackendReader, leases lease.Store, p *pool.Pool, progress handler.ProgressRecorder) (server.Handler, error) {
+	hc, err := c.dhcpHandlerConfig()
+	if err != nil {
+		return nil, err
+	}
+	ifaces, err := parseDHCPIfaceConfigs(c.dhcp.ifaceConfig, hc, c.dhcp.httpIpxeScript.injectMacAddress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dhcp interface config: %w", err)
+	}
+	if c.dhcp.pool.cidr != "" && hc.mode != dhcpModeReservation {
+		return nil, fmt.Errorf("a DHCP pool is only supported with --dhcp-mode=%s", dhcpModeReservation)
+	}
+	subnets, err := c.dhcpSubnets()
+	if err != nil {
+		return nil, err
+	}
+	dh, err := newDHCPHandler(hc, backend, log, leases, p, subnets, progress)
+	if err != nil {
+		return nil, err
 	}
-	if c.dhcp.httpIpxeScript.injectMacAddress {
-		ipxeScript = func(d *dhcpv4.DHCPv4) *url.URL {
-			u := *httpScriptURL
-			p := path.Base(u.Path)
-			u.Path = path.Join(path.Dir(u.Path), d.ClientHWAddr.String(), p)
-			return &u
+	if len(ifaces) == 0 {
+		return dh, nil
+	}
+
+	// With per interface configuration the DHCP server listens on all interfaces, so only the interfaces
+	// that are configured are served. The flags configure the handler for --dhcp-iface, if it is set.
+	mux := &server.InterfaceMux{Handlers: map[string]server.Handler{}}
+	if c.dhcp.bindInterface != "" {
+		mux.Handlers[c.dhcp.bindInterface] = dh
+	}
+	for _, ic := range ifaces {
+		// The address pool belongs to the subnet of --dhcp-iface, so it is not used for other interfaces.
+		h, err := newDHCPHandler(ic.dhcpHandlerConfig, backend, log, leases, nil, subnets, progress)
+		if err != nil {
+			return nil, fmt.Errorf("interface %q: %w", ic.name, err)
 		}
+		mux.Handlers[ic.name] = h
+		log.Info("serving dhcp on interface", "interface", ic.name, "mode", ic.mode, "ipForPacket", ic.ipForPacket)
+	}
+
+	return mux, nil
+}
+
+// dhcpLimiter returns the DHCP rate limiter, or nil when no limit is configured.
+func (c *config) dhcpLimiter() *server.Limiter {
+	l := c.dhcp.limits
+	if l.clientRate <= 0 && l.relayRate <= 0 && l.retransmitWindow <= 0 {
+		return nil
+	}
+
+	return &server.Limiter{
+		ClientRate:       rate.Limit(l.clientRate),
+		ClientBurst:      l.clientBurst,
+		RelayRate:        rate.Limit(l.relayRate),
+		RelayBurst:       l.relayBurst,
+		RetransmitWindow: l.retransmitWindow,
+	}
+}
+
+// dhcpHandlerConfig returns the DHCP handler configuration from the flags.
+func (c *config) dhcpHandlerConfig() (dhcpHandlerConfig, error) {
+	pktIP, err := netip.ParseAddr(c.dhcp.ipForPacket)
+	if err != nil {
+		return dhcpHandlerConfig{}, fmt.Errorf("invalid bind address: %w", err)
+	}
+	tftpIP, err := netip.ParseAddrPort(fmt.Sprintf("%s:%d", c.dhcp.tftpIP, c.dhcp.tftpPort))
+	if err != nil {
+		return dhcpHandlerConfig{}, fmt.Errorf("invalid tftp address for DHCP server: %w", err)
+	}
+	httpBinaryURL, err := c.httpBinaryURL()
+	if err != nil {
+		return dhcpHandlerConfig{}, err
 	}
-	backend, err := c.backend(ctx, log)
+	scriptURL, err := c.ipxeScriptURL()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create backend: %w", err)
+		return dhcpHandlerConfig{}, err
 	}
 
-	switch dhcpMode(c.dhcp.mode) {
+	return dhcpHandlerConfig{
+		mode:          dhcpMode(c.dhcp.mode),
+		ipForPacket:   pktIP,
+		syslogIP:      c.dhcp.syslogIP,
+		tftpIP:        tftpIP,
+		httpBinaryURL: httpBinaryURL,
+		ipxeScriptURL: scriptURL,
+	}, nil
+}
+
+// newDHCPHandler returns the DHCP handler for a mode.
+func newDHCPHandler(hc dhcpHandlerConfig, backend handler.BackendReader, log logr.Logger, leases lease.Store, p *pool.Pool, subnets *subnet.Registry, progress handler.ProgressRecorder) (server.Handler, error) {
+	ipxeScript := func(d *dhcpv4.DHCPv4) *url.URL {
+		return hc.ipxeScriptURL(d.ClientHWAddr)
+	}
+
+	switch hc.mode {
 	case dhcpModeReservation:
-		syslogIP, err := netip.ParseAddr(c.dhcp.syslogIP)
+		syslogIP, err := netip.ParseAddr(hc.syslogIP)
 		if err != nil {
 			return nil, fmt.Errorf("invalid syslog address: %w", err)
 		}
 		dh := &reservation.Handler{
 			Backend: backend,
-			IPAddr:  pktIP,
+			IPAddr:  hc.ipForPacket,
 			Log:     log,
 			Netboot: reservation.Netboot{
-				IPXEBinServerTFTP: tftpIP,
-				IPXEBinServerHTTP: httpBinaryURL,
+				IPXEBinServerTFTP: hc.tftpIP,
+				IPXEBinServerHTTP: hc.httpBinaryURL,
 				IPXEScriptURL:     ipxeScript,
 				Enabled:           true,
 			},
 			OTELEnabled: true,
 			SyslogAddr:  syslogIP,
+			Pool:        p,
+			Leases:      leases,
+			Subnets:     subnets,
+			Progress:    progress,
 		}
 		return dh, nil
 	case dhcpModeProxy:
 		dh := &proxy.Handler{
 			Backend: backend,
-			IPAddr:  pktIP,
+			IPAddr:  hc.ipForPacket,
 			Log:     log,
 			Netboot: proxy.Netboot{
-				IPXEBinServerTFTP: tftpIP,
-				IPXEBinServerHTTP: httpBinaryURL,
+				IPXEBinServerTFTP: hc.tftpIP,
+				IPXEBinServerHTTP: hc.httpBinaryURL,
 				IPXEScriptURL:     ipxeScript,
 				Enabled:           true,
 			},
 			OTELEnabled:      true,
 			AutoProxyEnabled: false,
+			Subnets:          subnets,
+			Progress:         progress,
 		}
 		return dh, nil
 	case dhcpModeAutoProxy:
 		dh := &proxy.Handler{
 			Backend: backend,
-			IPAddr:  pktIP,
+			IPAddr:  hc.ipForPacket,
 			Log:     log,
 			Netboot: proxy.Netboot{
-				IPXEBinServerTFTP: tftpIP,
-				IPXEBinServerHTTP: httpBinaryURL,
+				IPXEBinServerTFTP: hc.tftpIP,
+				IPXEBinServerHTTP: hc.httpBinaryURL,
 				IPXEScriptURL:     ipxeScript,
 				Enabled:           true,
 			},
 			OTELEnabled:      true,
 			AutoProxyEnabled: true,
+			Subnets:          subnets,
+			Progress:         progress,
 		}
 		return dh, nil
 	}
@@ -489,6 +831,139 @@ func (c *config) dhcpHandler(ctx context.Context, log logr.Logger) (server.Handl
 	return nil, errors.New("invalid dhcp mode")
 }
 
+// dhcpModeEnabled reports whether m is the DHCP mode of the flags or of any interface.
+func (c *config) dhcpModeEnabled(m dhcpMode) bool {
+	if dhcpMode(c.dhcp.mode) == m {
+		return true
+	}
+	for _, entry := range strings.Split(c.dhcp.ifaceConfig, ";") {
+		_, settings, _ := strings.Cut(entry, ":")
+		for _, kv := range strings.Split(settings, ",") {
+			if k, v, _ := strings.Cut(kv, "="); strings.TrimSpace(k) == "mode" && dhcpMode(strings.TrimSpace(v)) == m {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// leaseStore returns the store for DHCP leases. Leases are persisted to a file when one is configured.
+func (c *config) leaseStore() (lease.Store, error) {
+	if c.dhcp.leaseFile == "" {
+		return lease.NewMemory(), nil
+	}
+
+	return lease.NewFile(c.dhcp.leaseFile)
+}
+
+// dhcpPool returns the address pool for clients without a host reservation, or nil when no pool is configured.
+func (c *config) dhcpPool(log logr.Logger) (*pool.Pool, error) {
+	if c.dhcp.pool.cidr == "" {
+		return nil, nil
+	}
+	prefix, err := netip.ParsePrefix(c.dhcp.pool.cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dhcp pool cidr: %w", err)
+	}
+	pc := pool.Config{Prefix: prefix, LeaseTime: c.dhcp.pool.leaseTime}
+	for _, r := range strings.Split(c.dhcp.pool.exclude, ",") {
+		if strings.TrimSpace(r) == "" {
+			continue
+		}
+		rng, err := pool.ParseRange(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dhcp pool exclude: %w", err)
+		}
+		pc.Exclude = append(pc.Exclude, rng)
+	}
+	if c.dhcp.pool.gateway != "" {
+		gw, err := netip.ParseAddr(c.dhcp.pool.gateway)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dhcp pool gateway: %w", err)
+		}
+		pc.Options.DefaultGateway = gw
+	}
+	for _, ns := range strings.Split(c.dhcp.pool.nameServers, ",") {
+		if strings.TrimSpace(ns) == "" {
+			continue
+		}
+		ip := net.ParseIP(strings.TrimSpace(ns))
+		if ip == nil {
+			return nil, fmt.Errorf("invalid dhcp pool dns server: %q", ns)
+		}
+		pc.Options.NameServers = append(pc.Options.NameServers, ip)
+	}
+	log.Info("allocating addresses for clients without a host reservation", "cidr", c.dhcp.pool.cidr, "exclude", c.dhcp.pool.exclude)
+
+	return pool.New(pc)
+}
+
+func (c *config) dhcp6Handler(log logr.Logger, backend handler.BackendReader) (server.Handler6, error) {
+	if dhcpMode(c.dhcp.mode) != dhcpModeReservation {
+		return nil, fmt.Errorf("DHCPv6 is only supported with --dhcp-mode=%s", dhcpModeReservation)
+	}
+	var tftpIP netip.AddrPort
+	if c.dhcp6.tftpIP != "" {
+		ap, err := netip.ParseAddrPort(fmt.Sprintf("[%s]:%d", c.dhcp6.tftpIP, c.dhcp.tftpPort))
+		if err != nil {
+			return nil, fmt.Errorf("invalid IPv6 tftp address for DHCPv6 server: %w", err)
+		}
+		tftpIP = ap
+	}
+	httpBinaryURL, err := c.httpBinaryURL()
+	if err != nil {
+		return nil, err
+	}
+	scriptURL, err := c.ipxeScriptURL()
+	if err != nil {
+		return nil, err
+	}
+	duid, err := serverDUID(c.dhcp6.bindInterface)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create DHCPv6 server identifier: %w", err)
+	}
+
+	return &reservation6.Handler{
+		Backend:  backend,
+		ServerID: duid,
+		Log:      log,
+		Netboot: reservation6.Netboot{
+			IPXEBinServerTFTP: tftpIP,
+			IPXEBinServerHTTP: httpBinaryURL,
+			IPXEScriptURL:     scriptURL,
+			Enabled:           true,
+		},
+		OTELEnabled: true,
+	}, nil
+}
+
+// serverDUID returns a DUID-LL, based on the hardware address of the named interface, to use as the DHCPv6 server identifier.
+// When no interface name is given, the first non-loopback interface with an Ethernet hardware address is used.
+func serverDUID(ifname string) (dhcpv6.DUID, error) {
+	var ifaces []net.Interface
+	if ifname != "" {
+		iface, err := net.InterfaceByName(ifname)
+		if err != nil {
+			return nil, err
+		}
+		ifaces = append(ifaces, *iface)
+	} else {
+		all, err := net.Interfaces()
+		if err != nil {
+			return nil, err
+		}
+		ifaces = all
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback == 0 && len(iface.HardwareAddr) == 6 {
+			return &dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: iface.HardwareAddr}, nil
+		}
+	}
+
+	return nil, errors.New("no interface with an Ethernet hardware address found")
+}
+
 // defaultLogger uses the slog logr implementation.
 func defaultLogger(level string) logr.Logger {
 	// source file and function can be long. This makes the logs less readable.
@@ -525,7 +1000,8 @@ func defaultLogger(level string) logr.Logger {
 	return logr.FromSlogHandler(log.Handler())
 }
 
-func parseTrustedProxies(trustedProxies string) (result []string) {
+func parseTrustedProxies(trustedProxies string) ([]string, error) {
+	var result []string
 	for _, cidr := range strings.Split(trustedProxies, ",") {
 		cidr = strings.TrimSpace(cidr)
 		if cidr == "" {
@@ -541,14 +1017,13 @@ func parseTrustedProxies(trustedProxies string) (result []string) {
 					cidr += "/128"
 				}
 			} else {
-				// not an IP, panic
-				panic("invalid ip cidr in TRUSTED_PROXIES cidr=" + cidr)
+				return nil, fmt.Errorf("invalid ip cidr in trusted proxies: %q", cidr)
 			}
 		}
 		result = append(result, cidr)
 	}
 
-	return result
+	return result, nil
 }
 
 func (d dhcpMode) String() string {
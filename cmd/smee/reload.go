@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+	"github.com/peterbourgon/ff/v3"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
+	"github.com/tinkerbell/smee/internal/dhcp/lease"
+	"github.com/tinkerbell/smee/internal/dhcp/pool"
+	"github.com/tinkerbell/smee/internal/dhcp/server"
+	"github.com/tinkerbell/smee/internal/health"
+	"github.com/tinkerbell/smee/internal/ipxe/http"
+)
+
+// reloadDelay is how long to wait for more changes to the config file before it is reloaded.
+// Editors and Kubernetes ConfigMap updates change a file in several steps.
+const reloadDelay = time.Second
+
+// reloadableFlags are the flags that can be changed without a restart.
+// The handlers that use them are replaced while the DHCP, TFTP and HTTP listeners keep running.
+var reloadableFlags = map[string]bool{
+	"extra-kernel-args":                 true,
+	"trusted-proxies":                   true,
+	"http-admin-token":                  true,
+	"osie-url":                          true,
+	"tink-server":                       true,
+	"tink-server-tls":                   true,
+	"tink-server-insecure-tls":          true,
+	"ipxe-script-retries":               true,
+	"ipxe-script-retry-delay":           true,
+	"iso-url":                           true,
+	"iso-magic-string":                  true,
+	"iso-static-ipam-enabled":           true,
+	"dhcp-ip-for-packet":                true,
+	"dhcp-syslog-ip":                    true,
+	"dhcp-tftp-ip":                      true,
+	"dhcp-tftp-port":                    true,
+	"dhcp-http-ipxe-binary-scheme":      true,
+	"dhcp-http-ipxe-binary-host":        true,
+	"dhcp-http-ipxe-binary-port":        true,
+	"dhcp-http-ipxe-binary-path":        true,
+	"dhcp-http-ipxe-script-scheme":      true,
+	"dhcp-http-ipxe-script-host":        true,
+	"dhcp-http-ipxe-script-port":        true,
+	"dhcp-http-ipxe-script-path":        true,
+	"dhcp-http-ipxe-script-url":         true,
+	"dhcp-http-ipxe-script-prepend-mac": true,
+	"dhcp-subnets-file":                 true,
+	"dhcp-pool-cidr":                    true,
+	"dhcp-pool-exclude":                 true,
+	"dhcp-pool-gateway":                 true,
+	"dhcp-pool-dns":                     true,
+	"dhcp-pool-lease-time":              true,
+	"dhcp6-tftp-ip":                     true,
+}
+
+// reloader rebuilds the handlers from the configuration and replaces the running handlers with them.
+type reloader struct {
+	log     logr.Logger
+	args    []string
+	backend handler.BackendReader
+	leases  lease.Store
+	// pool is the address pool of the running DHCP handler, it is nil when no pool is configured.
+	pool *pool.Pool
+	// progress records the boot progress of machines, it is nil when it is not enabled.
+	progress handler.ProgressRecorder
+	// health has the checks of the subsystems that the http server reports.
+	health *health.Registry
+	// fs is the flag set of the running configuration.
+	fs *flag.FlagSet
+
+	// http, dhcp and dhcp6 are the handlers of the servers that are running. They are nil for servers that are not.
+	http  *http.Reloadable
+	dhcp  *server.Reloadable
+	dhcp6 *server.Reloadable6
+}
+
+// run reloads the configuration on SIGHUP and when configFile changes, until ctx is canceled.
+func (r *reloader) run(ctx context.Context, configFile string) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if configFile != "" {
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("failed to watch config file: %w", err)
+		}
+		defer w.Close()
+		// Watch the directory, so the file is still watched after it is replaced. This is how ConfigMap volumes are updated.
+		if err := w.Add(filepath.Dir(configFile)); err != nil {
+			return fmt.Errorf("failed to watch config file: %w", err)
+		}
+		events, errs = w.Events, w.Errors
+	}
+
+	var delay <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+			r.log.Info("received SIGHUP, reloading configuration")
+			r.reloadAndLog()
+		case e := <-events:
+			// ConfigMap volumes replace the "..data" symlink that the file links to.
+			if base := filepath.Base(e.Name); base == filepath.Base(configFile) || base == "..data" {
+				delay = time.After(reloadDelay)
+			}
+		case <-delay:
+			delay = nil
+			r.log.Info("config file changed, reloading configuration", "file", configFile)
+			r.reloadAndLog()
+		case err := <-errs:
+			r.log.Info("error watching config file", "err", err)
+		}
+	}
+}
+
+func (r *reloader) reloadAndLog() {
+	if err := r.reload(); err != nil {
+		r.log.Error(err, "failed to reload configuration, keeping the running configuration")
+		return
+	}
+	r.log.Info("reloaded configuration")
+}
+
+// reload parses the configuration and replaces the running handlers with handlers built from it.
+// Nothing is replaced when the configuration is not valid or changes a flag that is not reloadable.
+func (r *reloader) reload() error {
+	c := &config{}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	setFlags(c, fs)
+	if err := ff.Parse(fs, r.args, ffOptions()...); err != nil {
+		return fmt.Errorf("failed to parse configuration: %w", err)
+	}
+	if changed := restartFlags(r.fs, fs); len(changed) > 0 {
+		return fmt.Errorf("changing %s requires a restart", strings.Join(changed, ", "))
+	}
+
+	var hh nethttp.Handler
+	if r.http != nil {
+		handlers, err := c.httpHandlers(r.log, r.backend, r.leases, r.progress, r.dhcp)
+		if err != nil {
+			return err
+		}
+		hc, err := c.httpConfig(r.log, r.health)
+		if err != nil {
+			return err
+		}
+		if hh, err = hc.Handler(handlers); err != nil {
+			return err
+		}
+	}
+	var dh server.Handler
+	p := r.pool
+	if r.dhcp != nil {
+		var err error
+		// The pool has the addresses that are offered, bound and declined, so it is only replaced when it is reconfigured.
+		if poolChanged(r.fs, fs) {
+			if p, err = c.dhcpPool(r.log); err != nil {
+				return err
+			}
+		}
+		if dh, err = c.dhcpHandler(r.log, r.backend, r.leases, p, r.progress); err != nil {
+			return err
+		}
+	}
+	var dh6 server.Handler6
+	if r.dhcp6 != nil {
+		var err error
+		if dh6, err = c.dhcp6Handler(r.log, r.backend); err != nil {
+			return err
+		}
+	}
+
+	if hh != nil {
+		r.http.Store(hh)
+	}
+	if dh != nil {
+		r.dhcp.Store(dh)
+		r.pool = p
+	}
+	if dh6 != nil {
+		r.dhcp6.Store(dh6)
+	}
+	r.fs = fs
+
+	return nil
+}
+
+// restartFlags returns the names of the flags that are not reloadable and have a different value in next than in running.
+func restartFlags(running, next *flag.FlagSet) []string {
+	var changed []string
+	running.VisitAll(func(f *flag.Flag) {
+		if reloadableFlags[f.Name] {
+			return
+		}
+		n := next.Lookup(f.Name)
+		if n == nil || n.Value.String() != f.Value.String() {
+			changed = append(changed, f.Name)
+		}
+	})
+
+	return changed
+}
+
+// poolChanged reports whether a flag of the DHCP address pool has a different value in next than in running.
+func poolChanged(running, next *flag.FlagSet) bool {
+	changed := false
+	running.VisitAll(func(f *flag.Flag) {
+		if !strings.HasPrefix(f.Name, "dhcp-pool-") {
+			return
+		}
+		if n := next.Lookup(f.Name); n == nil || n.Value.String() != f.Value.String() {
+			changed = true
+		}
+	})
+
+	return changed
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/peterbourgon/ff/v3"
+	"github.com/tinkerbell/smee/internal/dhcp/server"
+	ihttp "github.com/tinkerbell/smee/internal/ipxe/http"
+)
+
+func TestRestartFlags(t *testing.T) {
+	tests := map[string]struct {
+		args []string
+		want []string
+	}{
+		"no changes":      {},
+		"reloadable flag": {args: []string{"-osie-url", "http://10.0.0.1/hook"}},
+		"restart flag":    {args: []string{"-tftp-port", "70"}, want: []string{"tftp-port"}},
+		"both kinds of flags": {
+			args: []string{"-osie-url", "http://10.0.0.1/hook", "-dhcp-mode", "proxy", "-http-port", "9090"},
+			want: []string{"dhcp-mode", "http-port"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			running := flag.NewFlagSet(name, flag.ContinueOnError)
+			setFlags(&config{}, running)
+			if err := ff.Parse(running, nil, ffOptions()...); err != nil {
+				t.Fatal(err)
+			}
+			next := flag.NewFlagSet(name, flag.ContinueOnError)
+			setFlags(&config{}, next)
+			if err := ff.Parse(next, tt.args, ffOptions()...); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, restartFlags(running, next)); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "smee.yaml")
+	write := func(s string) {
+		t.Helper()
+		if err := os.WriteFile(file, []byte(s), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("osie-url: http://10.0.0.1/hook\n")
+	args := []string{"-config", file}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	setFlags(&config{}, fs)
+	if err := ff.Parse(fs, args, ffOptions()...); err != nil {
+		t.Fatal(err)
+	}
+	r := &reloader{log: logr.Discard(), args: args, fs: fs, http: &ihttp.Reloadable{}, dhcp: &server.Reloadable{}}
+	r.http.Store(http.NotFoundHandler())
+
+	write("osie-url: http://10.0.0.2/hook\ndhcp-tftp-ip: 10.0.0.2\n")
+	if err := r.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.fs.Lookup("osie-url").Value.String(); got != "http://10.0.0.2/hook" {
+		t.Fatalf("osie-url = %q after reload, want http://10.0.0.2/hook", got)
+	}
+
+	write("osie-url: http://10.0.0.3/hook\ntftp-port: 70\n")
+	if err := r.reload(); err == nil {
+		t.Fatal("expected error reloading a flag that requires a restart")
+	}
+	if got := r.fs.Lookup("osie-url").Value.String(); got != "http://10.0.0.2/hook" {
+		t.Fatalf("osie-url = %q after a failed reload, want http://10.0.0.2/hook", got)
+	}
+
+	write("osie-url: http://10.0.0.3/hook\ndhcp-tftp-ip: not-an-ip\n")
+	if err := r.reload(); err == nil {
+		t.Fatal("expected error reloading an invalid configuration")
+	}
+
+	write("trusted-proxies: not-a-cidr\n")
+	if err := r.reload(); err == nil {
+		t.Fatal("expected error reloading invalid trusted proxies")
+	}
+}
+
+func TestReloadKeepsPool(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "smee.yaml")
+	write := func(s string) {
+		t.Helper()
+		if err := os.WriteFile(file, []byte(s), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("")
+	args := []string{"-config", file}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	setFlags(&config{}, fs)
+	if err := ff.Parse(fs, args, ffOptions()...); err != nil {
+		t.Fatal(err)
+	}
+	r := &reloader{log: logr.Discard(), args: args, fs: fs, dhcp: &server.Reloadable{}}
+
+	write("dhcp-pool-cidr: 192.168.2.0/24\n")
+	if err := r.reload(); err != nil {
+		t.Fatal(err)
+	}
+	p := r.pool
+	if p == nil {
+		t.Fatal("no pool after configuring dhcp-pool-cidr")
+	}
+
+	write("dhcp-pool-cidr: 192.168.2.0/24\nosie-url: http://10.0.0.2/hook\n")
+	if err := r.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if r.pool != p {
+		t.Fatal("pool was replaced by a reload that does not change it")
+	}
+
+	write("dhcp-pool-cidr: 192.168.2.0/24\ndhcp-pool-exclude: 192.168.2.1-192.168.2.9\n")
+	if err := r.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if r.pool == p {
+		t.Fatal("pool was not replaced after changing dhcp-pool-exclude")
+	}
+}
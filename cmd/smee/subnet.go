@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+
+	"github.com/ghodss/yaml"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/smee/internal/dhcp/subnet"
+)
+
+// subnetFile is the structure of a single subnet in the DHCP subnets file.
+type subnetFile struct {
+	CIDR             string   `json:"cidr"`
+	DefaultGateway   string   `json:"defaultGateway"`
+	NameServers      []string `json:"nameServers"`
+	NTPServers       []string `json:"ntpServers"`
+	DomainName       string   `json:"domainName"`
+	DomainSearch     []string `json:"domainSearch"`
+	BroadcastAddress string   `json:"broadcastAddress"`
+	LeaseTime        uint32   `json:"leaseTime"`
+	Netboot          struct {
+		TFTPIP        string `json:"tftpIP"`
+		IPXEBinaryURL string `json:"ipxeBinaryURL"`
+		IPXEScriptURL string `json:"ipxeScriptURL"`
+	} `json:"netboot"`
+}
+
+// dhcpSubnets returns the subnet registry from the DHCP subnets file, or nil when no file is configured.
+func (c *config) dhcpSubnets() (*subnet.Registry, error) {
+	if c.dhcp.subnetsFile == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(c.dhcp.subnetsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dhcp subnets file: %w", err)
+	}
+
+	return parseSubnets(b, c.dhcp.tftpPort, c.dhcp.httpIpxeScript.injectMacAddress)
+}
+
+// parseSubnets parses a YAML list of subnets. The TFTP port of every subnet is tftpPort.
+func parseSubnets(b []byte, tftpPort int, injectMac bool) (*subnet.Registry, error) {
+	var sf []subnetFile
+	if err := yaml.Unmarshal(b, &sf); err != nil {
+		return nil, fmt.Errorf("failed to parse dhcp subnets: %w", err)
+	}
+	subnets := make([]subnet.Subnet, 0, len(sf))
+	for _, f := range sf {
+		s, err := f.subnet(tftpPort, injectMac)
+		if err != nil {
+			return nil, fmt.Errorf("subnet %q: %w", f.CIDR, err)
+		}
+		subnets = append(subnets, s)
+	}
+
+	return subnet.NewRegistry(subnets...)
+}
+
+func (f subnetFile) subnet(tftpPort int, injectMac bool) (subnet.Subnet, error) {
+	var s subnet.Subnet
+	var err error
+	if s.Prefix, err = netip.ParsePrefix(f.CIDR); err != nil {
+		return s, fmt.Errorf("invalid cidr: %w", err)
+	}
+	if f.DefaultGateway != "" {
+		if s.Options.DefaultGateway, err = netip.ParseAddr(f.DefaultGateway); err != nil {
+			return s, fmt.Errorf("invalid default gateway: %w", err)
+		}
+	}
+	if f.BroadcastAddress != "" {
+		if s.Options.BroadcastAddress, err = netip.ParseAddr(f.BroadcastAddress); err != nil {
+			return s, fmt.Errorf("invalid broadcast address: %w", err)
+		}
+	}
+	if s.Options.NameServers, err = parseIPs(f.NameServers); err != nil {
+		return s, fmt.Errorf("invalid name server: %w", err)
+	}
+	if s.Options.NTPServers, err = parseIPs(f.NTPServers); err != nil {
+		return s, fmt.Errorf("invalid ntp server: %w", err)
+	}
+	s.Options.DomainName = f.DomainName
+	s.Options.DomainSearch = f.DomainSearch
+	s.Options.LeaseTime = f.LeaseTime
+
+	if f.Netboot.TFTPIP != "" {
+		if s.Netboot.IPXEBinServerTFTP, err = netip.ParseAddrPort(fmt.Sprintf("%s:%d", f.Netboot.TFTPIP, tftpPort)); err != nil {
+			return s, fmt.Errorf("invalid tftp ip: %w", err)
+		}
+	}
+	if f.Netboot.IPXEBinaryURL != "" {
+		if s.Netboot.IPXEBinServerHTTP, err = url.Parse(f.Netboot.IPXEBinaryURL); err != nil {
+			return s, fmt.Errorf("invalid ipxe binary url: %w", err)
+		}
+	}
+	if f.Netboot.IPXEScriptURL != "" {
+		u, err := url.Parse(f.Netboot.IPXEScriptURL)
+		if err != nil {
+			return s, fmt.Errorf("invalid ipxe script url: %w", err)
+		}
+		scriptURL := scriptURLFunc(u, injectMac)
+		s.Netboot.IPXEScriptURL = func(d *dhcpv4.DHCPv4) *url.URL {
+			return scriptURL(d.ClientHWAddr)
+		}
+	}
+
+	return s, nil
+}
+
+func parseIPs(ss []string) ([]net.IP, error) {
+	var ips []net.IP
+	for _, s := range ss {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("%q is not an IP address", s)
+		}
+		ips = append(ips, ip)
+	}
+
+	return ips, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+)
+
+func TestParseSubnets(t *testing.T) {
+	y := `
+- cidr: 192.168.2.0/24
+  defaultGateway: 192.168.2.1
+  nameServers: [1.1.1.1]
+  domainName: example.com
+  leaseTime: 3600
+  netboot:
+    tftpIP: 192.168.2.4
+    ipxeScriptURL: http://192.168.2.4:8080/auto.ipxe
+- cidr: 192.168.3.0/24
+`
+	r, err := parseSubnets([]byte(y), 69, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, ok := r.Lookup(netip.MustParseAddr("192.168.2.10"))
+	if !ok {
+		t.Fatal("subnet not found")
+	}
+	want := data.DHCP{
+		DefaultGateway: netip.MustParseAddr("192.168.2.1"),
+		NameServers:    []net.IP{net.ParseIP("1.1.1.1")},
+		DomainName:     "example.com",
+		LeaseTime:      3600,
+	}
+	if diff := cmp.Diff(want, s.Options, cmp.Comparer(func(a, b netip.Addr) bool { return a == b })); diff != "" {
+		t.Error(diff)
+	}
+	if want := netip.MustParseAddrPort("192.168.2.4:69"); s.Netboot.IPXEBinServerTFTP != want {
+		t.Errorf("tftp = %v, want %v", s.Netboot.IPXEBinServerTFTP, want)
+	}
+	u := s.Netboot.IPXEScriptURL(&dhcpv4.DHCPv4{ClientHWAddr: net.HardwareAddr{0, 1, 2, 3, 4, 5}})
+	if want := "http://192.168.2.4:8080/00:01:02:03:04:05/auto.ipxe"; u.String() != want {
+		t.Errorf("script url = %v, want %v", u, want)
+	}
+	if _, ok := r.Lookup(netip.MustParseAddr("192.168.3.10")); !ok {
+		t.Error("subnet 192.168.3.0/24 not found")
+	}
+
+	errs := map[string]string{
+		"not yaml":    "not: [valid",
+		"bad cidr":    "- cidr: foo",
+		"bad gateway": "- cidr: 192.168.2.0/24\n  defaultGateway: foo",
+		"bad dns":     "- cidr: 192.168.2.0/24\n  nameServers: [foo]",
+		"overlapping": "- cidr: 192.168.2.0/24\n- cidr: 192.168.2.0/25",
+	}
+	for name, y := range errs {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseSubnets([]byte(y), 69, true); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/netip"
+	"path"
+	"strings"
+
+	"github.com/go-logr/logr"
+	pintftp "github.com/pin/tftp/v3"
+	"github.com/tinkerbell/ipxedust/itftp"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
+	"github.com/tinkerbell/smee/internal/health"
+)
+
+// serveTFTP serves the iPXE binaries over TFTP on addr until ctx is done.
+// When progress is set, the binaries that are served to machines are recorded as their boot progress.
+// hl tracks whether the server listens and when it last received a read request.
+func (c *config) serveTFTP(ctx context.Context, log logr.Logger, addr netip.AddrPort, progress handler.ProgressRecorder, hl *health.Listener) (err error) {
+	a, err := net.ResolveUDPAddr("udp", addr.String())
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenUDP("udp", a)
+	if err != nil {
+		hl.Stopped(err)
+		return err
+	}
+	hl.Listening()
+	defer func() { hl.Stopped(err) }()
+
+	h := &itftp.Handler{Log: log, Patch: []byte(c.tftp.ipxeScriptPatch)}
+	read := tftpReadHandler(h.HandleRead, progress)
+	ts := pintftp.NewServer(func(filename string, rf io.ReaderFrom) error {
+		hl.Received()
+		return read(filename, rf)
+	}, h.HandleWrite)
+	ts.SetTimeout(c.tftp.timeout)
+	ts.SetBlockSize(c.tftp.blockSize)
+	ts.EnableSinglePort()
+	log.Info("serving iPXE binaries via TFTP", "addr", addr, "blocksize", c.tftp.blockSize, "timeout", c.tftp.timeout, "singlePortEnabled", true)
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+		ts.Shutdown()
+	}()
+
+	return itftp.Serve(ctx, conn, ts)
+}
+
+// tftpReadHandler returns read, recording the iPXE binaries it serves with progress, when progress is set.
+func tftpReadHandler(read func(string, io.ReaderFrom) error, progress handler.ProgressRecorder) func(string, io.ReaderFrom) error {
+	if progress == nil {
+		return read
+	}
+
+	return func(filename string, rf io.ReaderFrom) error {
+		if err := read(filename, rf); err != nil {
+			return err
+		}
+		var client net.IP
+		if ot, ok := rf.(pintftp.OutgoingTransfer); ok {
+			client = ot.RemoteAddr().IP
+		}
+		mac, binary := tftpFile(filename)
+		progress.RecordProgress(context.Background(), mac, client, handler.StageTFTP, binary)
+
+		return nil
+	}
+}
+
+// tftpFile returns the mac address and the iPXE binary of a TFTP filename.
+// The filename is the binary, optionally in a directory named by the mac address, 0a:00:27:00:00:02/snp.efi,
+// and optionally followed by a traceparent, snp.efi-00-23b1e307bb35484f535a1f772c06910e-d887dc3912240434-01.
+// The mac address is nil when the filename does not have one.
+func tftpFile(filename string) (net.HardwareAddr, string) {
+	mac, err := net.ParseMAC(path.Dir(filename))
+	if err != nil {
+		mac = nil
+	}
+	binary := path.Base(filename)
+	if i := strings.Index(binary, "-00-"); i > 0 {
+		binary = binary[:i]
+	}
+
+	return mac, binary
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTFTPFile(t *testing.T) {
+	tests := map[string]struct {
+		filename   string
+		wantMAC    net.HardwareAddr
+		wantBinary string
+	}{
+		"binary":              {filename: "snp.efi", wantBinary: "snp.efi"},
+		"mac directory":       {filename: "0a:00:27:00:00:02/undionly.kpxe", wantMAC: net.HardwareAddr{0x0a, 0x00, 0x27, 0x00, 0x00, 0x02}, wantBinary: "undionly.kpxe"},
+		"traceparent":         {filename: "snp.efi-00-23b1e307bb35484f535a1f772c06910e-d887dc3912240434-01", wantBinary: "snp.efi"},
+		"not a mac directory": {filename: "ipxe/snp.efi", wantBinary: "snp.efi"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mac, binary := tftpFile(tt.filename)
+			if diff := cmp.Diff(tt.wantMAC, mac); diff != "" {
+				t.Error(diff)
+			}
+			if binary != tt.wantBinary {
+				t.Errorf("binary = %q, want %q", binary, tt.wantBinary)
+			}
+		})
+	}
+}
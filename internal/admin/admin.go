@@ -0,0 +1,235 @@
+// Package admin is an HTTP API for operators to inspect what smee answers a machine, without the machine booting.
+//
+// GET /admin/simulate?mac=<mac address> builds the DHCPDISCOVER of a network boot client with the mac address,
+// passes it through the option logic of the configured DHCP handler and renders the iPXE script that is served to
+// the machine. Nothing is sent on the network, and no lease or boot progress is recorded. The optional parameters
+// are:
+//
+//   - arch: the client system architecture (DHCP option 93) as a number, for example 7 for EFI x86-64. Defaults to 0, BIOS.
+//   - user-class: the user class (DHCP option 77), for example iPXE or Tinkerbell, to simulate a request of iPXE.
+//   - client-type: PXEClient (the default) or HTTPClient, the start of the vendor class identifier (DHCP option 60).
+//   - giaddr: the address of the relay agent that forwarded the request.
+//   - interface: the name of the interface the request was received on, for per interface DHCP configuration.
+//
+// Requests must have an "Authorization: Bearer <token>" header with the configured token.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/tinkerbell/smee/internal/dhcp"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
+)
+
+// Handler serves the admin API.
+type Handler struct {
+	Log logr.Logger
+	// Token authenticates requests. When it is empty, every request is refused.
+	Token string
+	// DHCP simulates the DHCP replies. When nil, no DHCP reply is simulated.
+	DHCP handler.Simulator
+	// Script renders the iPXE scripts. When nil, no iPXE script is rendered.
+	Script ScriptRenderer
+}
+
+// ScriptRenderer renders the iPXE script that is served to a machine.
+type ScriptRenderer interface {
+	Render(ctx context.Context, mac net.HardwareAddr) (name, script string, err error)
+}
+
+// Simulation is what smee answers a machine.
+type Simulation struct {
+	MAC string `json:"mac"`
+	// DHCP is the DHCP reply, it is nil when none is sent.
+	DHCP *Reply `json:"dhcp,omitempty"`
+	// DHCPError describes why no DHCP reply is sent.
+	DHCPError string `json:"dhcp_error,omitempty"`
+	// IPXEScript is the iPXE script, it is nil when none is served.
+	IPXEScript *Script `json:"ipxe_script,omitempty"`
+	// IPXEScriptError describes why no iPXE script is served.
+	IPXEScriptError string `json:"ipxe_script_error,omitempty"`
+}
+
+// Reply is a DHCP reply.
+type Reply struct {
+	MessageType    string   `json:"message_type"`
+	YourIP         string   `json:"your_ip"`
+	NextServer     string   `json:"next_server"`
+	ServerHostname string   `json:"server_hostname,omitempty"`
+	Bootfile       string   `json:"bootfile,omitempty"`
+	Options        []Option `json:"options"`
+}
+
+// Option is a DHCP option of a reply.
+type Option struct {
+	Code uint8  `json:"code"`
+	Name string `json:"name"`
+	// Value is the human-readable value of the option.
+	Value string `json:"value"`
+	// Raw is the hex encoded value of the option.
+	Raw string `json:"raw"`
+}
+
+// Script is an iPXE script.
+type Script struct {
+	Name   string `json:"name"`
+	Script string `json:"script"`
+}
+
+// HandlerFunc returns an http.HandlerFunc that serves the admin API under /admin/.
+func (h *Handler) HandlerFunc() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.authorized(r) {
+			h.Log.Info("unauthorized admin API request", "path", r.URL.Path, "client", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="smee"`)
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+		if r.URL.Path != "/admin/simulate" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		p, err := packet(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		res := h.simulate(r.Context(), p)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			h.Log.Error(err, "marshaling simulation json")
+		}
+	}
+}
+
+// authorized reports whether r has the bearer token of the handler.
+func (h *Handler) authorized(r *http.Request) bool {
+	if h.Token == "" {
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) == 1
+}
+
+// simulate returns what smee answers the client of p.
+func (h *Handler) simulate(ctx context.Context, p data.Packet) Simulation {
+	s := Simulation{MAC: p.Pkt.ClientHWAddr.String()}
+	if h.DHCP == nil {
+		s.DHCPError = "DHCP is not enabled"
+	} else if reply, err := h.DHCP.Simulate(ctx, p); err != nil {
+		s.DHCPError = err.Error()
+	} else {
+		s.DHCP = newReply(reply)
+	}
+
+	if h.Script == nil {
+		s.IPXEScriptError = "the iPXE script server is not enabled"
+	} else if name, script, err := h.Script.Render(ctx, p.Pkt.ClientHWAddr); err != nil {
+		s.IPXEScriptError = err.Error()
+	} else {
+		s.IPXEScript = &Script{Name: name, Script: script}
+	}
+
+	return s
+}
+
+// packet returns the DHCPDISCOVER of a network boot client that is described by the query of r.
+func packet(r *http.Request) (data.Packet, error) {
+	q := r.URL.Query()
+	mac, err := net.ParseMAC(q.Get("mac"))
+	if err != nil {
+		return data.Packet{}, fmt.Errorf("invalid mac: %w", err)
+	}
+	var arch iana.Arch
+	if a := q.Get("arch"); a != "" {
+		n, err := strconv.ParseUint(a, 0, 16)
+		if err != nil {
+			return data.Packet{}, fmt.Errorf("invalid arch, must be the number of the client system architecture: %w", err)
+		}
+		arch = iana.Arch(n)
+	}
+	clientType := dhcp.PXEClient.String()
+	if ct := q.Get("client-type"); ct != "" {
+		clientType = ct
+	}
+	mods := []dhcpv4.Modifier{
+		dhcpv4.WithOption(dhcpv4.OptClassIdentifier(fmt.Sprintf("%s:Arch:%05d:UNDI:003001", clientType, uint16(arch)))),
+		dhcpv4.WithOption(dhcpv4.OptClientArch(arch)),
+		// UNDI version 3.1.
+		dhcpv4.WithGeneric(dhcpv4.OptionClientNetworkInterfaceIdentifier, []byte{0x01, 0x03, 0x01}),
+	}
+	if uc := q.Get("user-class"); uc != "" {
+		mods = append(mods, dhcpv4.WithGeneric(dhcpv4.OptionUserClassInformation, []byte(uc)))
+	}
+	if gi := q.Get("giaddr"); gi != "" {
+		ip := net.ParseIP(gi).To4()
+		if ip == nil {
+			return data.Packet{}, errors.New("invalid giaddr, must be an IPv4 address")
+		}
+		mods = append(mods, dhcpv4.WithGatewayIP(ip))
+	}
+	pkt, err := dhcpv4.NewDiscovery(mac, mods...)
+	if err != nil {
+		return data.Packet{}, err
+	}
+
+	return data.Packet{
+		Pkt:  pkt,
+		Peer: &net.UDPAddr{IP: net.IPv4bcast, Port: dhcpv4.ClientPort},
+		Md:   &data.Metadata{IfName: q.Get("interface")},
+	}, nil
+}
+
+// newReply returns the description of a DHCP reply.
+func newReply(reply *dhcpv4.DHCPv4) *Reply {
+	r := &Reply{
+		MessageType:    reply.MessageType().String(),
+		YourIP:         reply.YourIPAddr.String(),
+		NextServer:     reply.ServerIPAddr.String(),
+		ServerHostname: reply.ServerHostName,
+		Bootfile:       reply.BootFileName,
+		Options:        []Option{},
+	}
+	for _, code := range sortedCodes(reply.Options) {
+		raw := reply.Options[code]
+		// Summary humanizes the options the same way the dhcp library prints a packet, "<name>: <value>".
+		name, value, _ := strings.Cut(strings.TrimSpace(dhcpv4.Options{code: raw}.Summary(nil)), ": ")
+		r.Options = append(r.Options, Option{Code: code, Name: name, Value: value, Raw: hex.EncodeToString(raw)})
+	}
+
+	return r
+}
+
+func sortedCodes(o dhcpv4.Options) []uint8 {
+	codes := make([]uint8, 0, len(o))
+	for c := 0; c < 256; c++ {
+		if _, ok := o[uint8(c)]; ok {
+			codes = append(codes, uint8(c))
+		}
+	}
+
+	return codes
+}
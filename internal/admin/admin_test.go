@@ -0,0 +1,186 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+)
+
+// simulator replies with an offer of 192.168.2.10 and a boot file, or with err.
+type simulator struct {
+	err error
+}
+
+func (s *simulator) Simulate(_ context.Context, p data.Packet) (*dhcpv4.DHCPv4, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return dhcpv4.NewReplyFromRequest(p.Pkt,
+		dhcpv4.WithMessageType(dhcpv4.MessageTypeOffer),
+		dhcpv4.WithYourIP(net.IP{192, 168, 2, 10}),
+		dhcpv4.WithServerIP(net.IP{192, 168, 2, 1}),
+		dhcpv4.WithOption(dhcpv4.OptSubnetMask(net.IPv4Mask(255, 255, 255, 0))),
+		func(d *dhcpv4.DHCPv4) { d.BootFileName = "snp.efi" },
+	)
+}
+
+type renderer struct {
+	err error
+}
+
+func (r renderer) Render(_ context.Context, mac net.HardwareAddr) (string, string, error) {
+	if r.err != nil {
+		return "", "", r.err
+	}
+
+	return "auto.ipxe", "#!ipxe\necho " + mac.String(), nil
+}
+
+func TestAuthorization(t *testing.T) {
+	tests := map[string]struct {
+		token  string
+		header string
+		want   int
+	}{
+		"valid token":    {token: "secret", header: "Bearer secret", want: http.StatusOK},
+		"wrong token":    {token: "secret", header: "Bearer guess", want: http.StatusUnauthorized},
+		"no header":      {token: "secret", want: http.StatusUnauthorized},
+		"basic auth":     {token: "secret", header: "Basic c2VjcmV0", want: http.StatusUnauthorized},
+		"no token set":   {header: "Bearer ", want: http.StatusUnauthorized},
+		"empty password": {token: "secret", header: "Bearer ", want: http.StatusUnauthorized},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{Log: logr.Discard(), Token: tt.token}
+			r := httptest.NewRequest(http.MethodGet, "/admin/simulate?mac=3c:ec:ef:4c:4f:54", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.HandlerFunc()(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	tests := map[string]struct {
+		url        string
+		method     string
+		dhcp       *simulator
+		script     ScriptRenderer
+		wantStatus int
+		want       Simulation
+	}{
+		"reply and script": {
+			url:        "/admin/simulate?mac=3c:ec:ef:4c:4f:54",
+			dhcp:       &simulator{},
+			script:     renderer{},
+			wantStatus: http.StatusOK,
+			want: Simulation{
+				MAC: "3c:ec:ef:4c:4f:54",
+				DHCP: &Reply{
+					MessageType: "OFFER",
+					YourIP:      "192.168.2.10",
+					NextServer:  "192.168.2.1",
+					Bootfile:    "snp.efi",
+					Options: []Option{
+						{Code: 1, Name: "Subnet Mask", Value: "ffffff00", Raw: "ffffff00"},
+						{Code: 53, Name: "DHCP Message Type", Value: "OFFER", Raw: "02"},
+					},
+				},
+				IPXEScript: &Script{Name: "auto.ipxe", Script: "#!ipxe\necho 3c:ec:ef:4c:4f:54"},
+			},
+		},
+		"no reply": {
+			url:        "/admin/simulate?mac=3c:ec:ef:4c:4f:54",
+			dhcp:       &simulator{err: errors.New("DHCP is disabled for this MAC address")},
+			script:     renderer{err: errors.New("not found")},
+			wantStatus: http.StatusOK,
+			want:       Simulation{MAC: "3c:ec:ef:4c:4f:54", DHCPError: "DHCP is disabled for this MAC address", IPXEScriptError: "not found"},
+		},
+		"not enabled": {
+			url:        "/admin/simulate?mac=3c:ec:ef:4c:4f:54",
+			wantStatus: http.StatusOK,
+			want:       Simulation{MAC: "3c:ec:ef:4c:4f:54", DHCPError: "DHCP is not enabled", IPXEScriptError: "the iPXE script server is not enabled"},
+		},
+		"invalid mac":    {url: "/admin/simulate?mac=3c:ec", wantStatus: http.StatusBadRequest},
+		"invalid arch":   {url: "/admin/simulate?mac=3c:ec:ef:4c:4f:54&arch=x86", wantStatus: http.StatusBadRequest},
+		"invalid giaddr": {url: "/admin/simulate?mac=3c:ec:ef:4c:4f:54&giaddr=fe80::1", wantStatus: http.StatusBadRequest},
+		"unknown path":   {url: "/admin/leases", wantStatus: http.StatusNotFound},
+		"method not GET": {url: "/admin/simulate?mac=3c:ec:ef:4c:4f:54", method: http.MethodPost, wantStatus: http.StatusMethodNotAllowed},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{Log: logr.Discard(), Token: "secret", Script: tt.script}
+			if tt.dhcp != nil {
+				h.DHCP = tt.dhcp
+			}
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, tt.url, nil)
+			r.Header.Set("Authorization", "Bearer secret")
+			w := httptest.NewRecorder()
+			h.HandlerFunc()(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var got Simulation
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestPacket(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/admin/simulate?mac=3c:ec:ef:4c:4f:54&arch=0x10&user-class=Tinkerbell&client-type=HTTPClient&giaddr=192.168.3.1&interface=eth1", nil)
+	p, err := packet(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{
+		"type":       p.Pkt.MessageType().String(),
+		"mac":        p.Pkt.ClientHWAddr.String(),
+		"giaddr":     p.Pkt.GatewayIPAddr.String(),
+		"interface":  p.Md.IfName,
+		"class":      string(p.Pkt.GetOneOption(dhcpv4.OptionClassIdentifier)),
+		"arch":       p.Pkt.ClientArch()[0].String(),
+		"user class": string(p.Pkt.GetOneOption(dhcpv4.OptionUserClassInformation)),
+	}
+	want := map[string]string{
+		"type":       "DISCOVER",
+		"mac":        "3c:ec:ef:4c:4f:54",
+		"giaddr":     "192.168.3.1",
+		"interface":  "eth1",
+		"class":      "HTTPClient:Arch:00016:UNDI:003001",
+		"arch":       "EFI x86-64 boot from HTTP",
+		"user class": "Tinkerbell",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+	if !p.Pkt.Options.Has(dhcpv4.OptionClientNetworkInterfaceIdentifier) {
+		t.Error("option 94 is not set")
+	}
+}
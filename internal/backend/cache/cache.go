@@ -0,0 +1,263 @@
+// Package cache is a backend that caches the lookups of another backend.
+//
+// Lookups that find hardware and lookups that do not are cached with their own TTL, so that the DHCP retransmits
+// and iPXE script requests of a client, and the requests of unknown clients in proxy mode, do not all reach the backend.
+// Concurrent lookups of the same key are coalesced into a single backend lookup. Errors other than not found are not cached.
+// When the backend implements handler.ChangeNotifier, its changes invalidate the cache before the TTL expires.
+package cache
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
+	"github.com/tinkerbell/smee/internal/metric"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"golang.org/x/sync/singleflight"
+)
+
+const tracerName = "github.com/tinkerbell/smee/dhcp"
+
+// Kinds of lookups. They are used as the lookup label of metric.BackendCacheTotal and as the prefix of cache keys.
+const (
+	lookupMAC        = "mac"
+	lookupIP         = "ip"
+	lookupRelayAgent = "relay_agent"
+)
+
+// Backend caches the lookups of a backend.
+type Backend struct {
+	// Backend is the backend whose lookups are cached.
+	Backend handler.BackendReader
+	// TTL is how long a lookup that found hardware is cached. 0 disables caching them.
+	TTL time.Duration
+	// NegativeTTL is how long a lookup that did not find hardware is cached. 0 disables caching them.
+	NegativeTTL time.Duration
+
+	mu      sync.Mutex // protects entries, gen and lastSweep
+	entries map[string]entry
+	// gen is incremented by every invalidation, so that a lookup that started before it is not cached.
+	gen       uint64
+	lastSweep time.Time
+	group     singleflight.Group
+	// now returns the current time, it is replaced in tests.
+	now func() time.Time
+}
+
+// entry is a cached lookup. err is the not found error of the backend when the lookup did not find hardware.
+type entry struct {
+	d       *data.DHCP
+	n       *data.Netboot
+	err     error
+	expires time.Time
+}
+
+// result is the result of a backend lookup that is shared by coalesced lookups.
+type result struct {
+	d *data.DHCP
+	n *data.Netboot
+}
+
+// New returns a Backend that caches the lookups of b.
+// When b implements handler.ChangeNotifier, the cache is invalidated by its changes.
+func New(b handler.BackendReader, ttl, negativeTTL time.Duration) *Backend {
+	c := &Backend{
+		Backend:     b,
+		TTL:         ttl,
+		NegativeTTL: negativeTTL,
+	}
+	if cn, ok := b.(handler.ChangeNotifier); ok {
+		cn.OnChange(c.Invalidate)
+	}
+
+	return c
+}
+
+// GetByMac implements the handler.BackendReader interface and returns DHCP and netboot data based on a mac address.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	return b.get(ctx, lookupMAC, mac.String(), func(ctx context.Context) (*data.DHCP, *data.Netboot, error) {
+		return b.Backend.GetByMac(ctx, mac)
+	})
+}
+
+// GetByIP implements the handler.BackendReader interface and returns DHCP and netboot data based on an IP address.
+func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	return b.get(ctx, lookupIP, ip.String(), func(ctx context.Context) (*data.DHCP, *data.Netboot, error) {
+		return b.Backend.GetByIP(ctx, ip)
+	})
+}
+
+// GetByRelayAgent implements the handler.RelayAgentReader interface and returns DHCP and netboot data based on
+// relay agent information. When the backend does not implement handler.RelayAgentReader, the hardware is not found.
+func (b *Backend) GetByRelayAgent(ctx context.Context, ra data.RelayAgent) (*data.DHCP, *data.Netboot, error) {
+	rr, ok := b.Backend.(handler.RelayAgentReader)
+	if !ok {
+		return nil, nil, handler.NotFoundError{Key: "circuitID " + ra.CircuitID}
+	}
+
+	return b.get(ctx, lookupRelayAgent, ra.CircuitID+"/"+ra.RemoteID, func(ctx context.Context) (*data.DHCP, *data.Netboot, error) {
+		return rr.GetByRelayAgent(ctx, ra)
+	})
+}
+
+// Ready implements the handler.ReadinessChecker interface. The cache is ready when its backend is,
+// or when its backend does not implement handler.ReadinessChecker.
+func (b *Backend) Ready(ctx context.Context) error {
+	if rc, ok := b.Backend.(handler.ReadinessChecker); ok {
+		return rc.Ready(ctx)
+	}
+
+	return nil
+}
+
+// Invalidate removes the cached lookups of the hardware with the mac addresses, and all cached lookups by IP address
+// or relay agent information that did not find hardware, as the changed hardware might be found by them now.
+// Without mac addresses, all cached lookups are removed.
+func (b *Backend) Invalidate(macs ...net.HardwareAddr) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.gen++
+	if len(macs) == 0 {
+		clear(b.entries)
+		return
+	}
+	for k, e := range b.entries {
+		if e.err != nil && !strings.HasPrefix(k, lookupMAC+"=") {
+			delete(b.entries, k)
+			continue
+		}
+		for _, mac := range macs {
+			if k == lookupMAC+"="+mac.String() || (e.d != nil && bytes.Equal(e.d.MACAddress, mac)) {
+				delete(b.entries, k)
+				break
+			}
+		}
+	}
+}
+
+// get returns the cached lookup of key, or looks it up with f and caches it.
+func (b *Backend) get(ctx context.Context, lookup, key string, f func(context.Context) (*data.DHCP, *data.Netboot, error)) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.cache.Get")
+	defer span.End()
+	key = lookup + "=" + key
+	span.SetAttributes(attribute.String("backend.cache.key", key))
+	e, gen, ok := b.cached(key)
+	if ok {
+		span.SetAttributes(attribute.Bool("backend.cache.hit", true))
+		if e.err != nil {
+			metric.BackendCacheTotal.WithLabelValues(lookup, "negative_hit").Inc()
+			span.SetStatus(codes.Error, e.err.Error())
+
+			return nil, nil, e.err
+		}
+		metric.BackendCacheTotal.WithLabelValues(lookup, "hit").Inc()
+		span.SetStatus(codes.Ok, "")
+		d, n := clone(e.d, e.n)
+
+		return d, n, nil
+	}
+
+	metric.BackendCacheTotal.WithLabelValues(lookup, "miss").Inc()
+	span.SetAttributes(attribute.Bool("backend.cache.hit", false))
+
+	// The lookup is shared by the lookups that are coalesced with it, so it is not canceled with ctx.
+	ch := b.group.DoChan(key, func() (any, error) {
+		d, n, err := f(context.WithoutCancel(ctx))
+		b.store(key, gen, d, n, err)
+
+		return result{d: d, n: n}, err
+	})
+	select {
+	case <-ctx.Done():
+		span.SetStatus(codes.Error, ctx.Err().Error())
+
+		return nil, nil, ctx.Err()
+	case r := <-ch:
+		if r.Err != nil {
+			span.SetStatus(codes.Error, r.Err.Error())
+
+			return nil, nil, r.Err
+		}
+		span.SetStatus(codes.Ok, "")
+		res := r.Val.(result)
+		d, n := clone(res.d, res.n)
+
+		return d, n, nil
+	}
+}
+
+// cached returns the cached lookup of key. ok is false when there is no lookup cached, or it has expired.
+// gen is the generation of the cache, to be passed to store.
+func (b *Backend) cached(key string) (e entry, gen uint64, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e, ok = b.entries[key]
+	if ok && !b.timeNow().Before(e.expires) {
+		return entry{}, b.gen, false
+	}
+
+	return e, b.gen, ok
+}
+
+// store caches a lookup of key that started at generation gen of the cache. It is not cached when the cache was
+// invalidated since then, or when err is not a not found error. Expired lookups are removed at most once per TTL.
+func (b *Backend) store(key string, gen uint64, d *data.DHCP, n *data.Netboot, err error) {
+	ttl := b.TTL
+	if err != nil {
+		if !handler.IsNotFound(err) {
+			return
+		}
+		ttl = b.NegativeTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if gen != b.gen {
+		return
+	}
+	now := b.timeNow()
+	if b.entries == nil {
+		b.entries = make(map[string]entry)
+	}
+	if now.Sub(b.lastSweep) >= max(b.TTL, b.NegativeTTL) {
+		for k, e := range b.entries {
+			if !now.Before(e.expires) {
+				delete(b.entries, k)
+			}
+		}
+		b.lastSweep = now
+	}
+	b.entries[key] = entry{d: d, n: n, err: err, expires: now.Add(ttl)}
+}
+
+func (b *Backend) timeNow() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+
+	return time.Now()
+}
+
+// clone returns copies of d and n, so that the handlers can set defaults in them without changing the cached lookup.
+func clone(d *data.DHCP, n *data.Netboot) (*data.DHCP, *data.Netboot) {
+	if d != nil {
+		dc := *d
+		d = &dc
+	}
+	if n != nil {
+		nc := *n
+		n = &nc
+	}
+
+	return d, n
+}
@@ -0,0 +1,237 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
+	"github.com/tinkerbell/smee/internal/metric"
+)
+
+var errOutage = errors.New("backend unavailable")
+
+var (
+	mac1 = net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67}
+	mac2 = net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x68}
+)
+
+// fake is a backend with hardware for mac1 at 192.168.2.153. Lookups block until release is closed, when it is set.
+type fake struct {
+	calls    atomic.Int32
+	err      error
+	release  chan struct{}
+	hostname string
+	onChange func(...net.HardwareAddr)
+}
+
+func (f *fake) get(found bool) (*data.DHCP, *data.Netboot, error) {
+	f.calls.Add(1)
+	if f.release != nil {
+		<-f.release
+	}
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+	if !found {
+		return nil, nil, handler.NotFoundError{Key: "fake"}
+	}
+
+	return &data.DHCP{MACAddress: mac1, Hostname: f.hostname}, &data.Netboot{AllowNetboot: true}, nil
+}
+
+func (f *fake) GetByMac(_ context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	return f.get(mac.String() == mac1.String())
+}
+
+func (f *fake) GetByIP(_ context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	return f.get(ip.Equal(net.IPv4(192, 168, 2, 153)))
+}
+
+func (f *fake) OnChange(fn func(...net.HardwareAddr)) {
+	f.onChange = fn
+}
+
+func TestMain(m *testing.M) {
+	metric.Init()
+	os.Exit(m.Run())
+}
+
+func TestGetByMac(t *testing.T) {
+	tests := map[string]struct {
+		mac       net.HardwareAddr
+		err       error
+		ttl       time.Duration
+		negative  time.Duration
+		wantErr   bool
+		wantCalls int32
+	}{
+		"hit":                     {mac: mac1, ttl: time.Minute, wantCalls: 1},
+		"hits not cached":         {mac: mac1, negative: time.Minute, wantCalls: 3},
+		"not found":               {mac: mac2, negative: time.Minute, wantErr: true, wantCalls: 1},
+		"not found is not cached": {mac: mac2, ttl: time.Minute, wantErr: true, wantCalls: 3},
+		"errors are not cached":   {mac: mac1, err: errOutage, ttl: time.Minute, negative: time.Minute, wantErr: true, wantCalls: 3},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := &fake{err: tt.err}
+			b := New(f, tt.ttl, tt.negative)
+			for range 3 {
+				_, _, err := b.GetByMac(context.Background(), tt.mac)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("GetByMac() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if tt.err == nil && err != nil && !handler.IsNotFound(err) {
+					t.Fatalf("GetByMac() error = %v, want a not found error", err)
+				}
+			}
+			if got := f.calls.Load(); got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	f := &fake{}
+	b := New(f, time.Minute, 10*time.Second)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+
+	b.GetByMac(context.Background(), mac1) //nolint:errcheck // only the calls matter.
+	b.GetByMac(context.Background(), mac2) //nolint:errcheck // only the calls matter.
+	now = now.Add(10 * time.Second)
+	b.GetByMac(context.Background(), mac1) //nolint:errcheck // only the calls matter.
+	b.GetByMac(context.Background(), mac2) //nolint:errcheck // only the calls matter.
+	if got := f.calls.Load(); got != 3 {
+		t.Errorf("calls = %d, want the not found lookup to expire first", got)
+	}
+	now = now.Add(50 * time.Second)
+	b.GetByMac(context.Background(), mac1) //nolint:errcheck // only the calls matter.
+	if got := f.calls.Load(); got != 4 {
+		t.Errorf("calls = %d, want 4", got)
+	}
+}
+
+func TestCopies(t *testing.T) {
+	b := New(&fake{hostname: "sandbox"}, time.Minute, 0)
+	d, n, err := b.GetByMac(context.Background(), mac1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Hostname = "changed"
+	n.AllowNetboot = false
+	d, n, err = b.GetByMac(context.Background(), mac1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Hostname != "sandbox" || !n.AllowNetboot {
+		t.Errorf("the cached lookup was changed by the caller: %+v %+v", d, n)
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	f := &fake{release: make(chan struct{})}
+	b := New(f, 0, 0)
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := b.GetByMac(context.Background(), mac1)
+			errs <- err
+		}()
+	}
+	// Wait for the lookups to be coalesced with the first one, which blocks until it is released.
+	for f.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(f.release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := f.calls.Load(); got != 1 {
+		t.Errorf("calls = %d, want 1", got)
+	}
+}
+
+func TestCanceled(t *testing.T) {
+	f := &fake{release: make(chan struct{})}
+	b := New(f, time.Minute, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := b.GetByMac(ctx, mac1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("GetByMac() error = %v, want %v", err, context.Canceled)
+	}
+	// The lookup is not canceled with the caller and is cached when it finishes.
+	close(f.release)
+	for range 100 {
+		if _, _, ok := b.cached("mac=" + mac1.String()); ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("lookup was not cached")
+}
+
+func TestInvalidate(t *testing.T) {
+	tests := map[string]struct {
+		macs      []net.HardwareAddr
+		wantCalls int32
+	}{
+		"all":                {wantCalls: 6},
+		"changed hardware":   {macs: []net.HardwareAddr{mac1}, wantCalls: 6},
+		"unchanged hardware": {macs: []net.HardwareAddr{mac2}, wantCalls: 4},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := &fake{}
+			b := New(f, time.Minute, time.Minute)
+			lookup := func() {
+				b.GetByMac(context.Background(), mac1)                      //nolint:errcheck // only the calls matter.
+				b.GetByIP(context.Background(), net.IPv4(192, 168, 2, 153)) //nolint:errcheck // only the calls matter.
+				b.GetByIP(context.Background(), net.IPv4(192, 168, 2, 154)) //nolint:errcheck // only the calls matter.
+			}
+			lookup()
+			// The backend notifies the cache of the change.
+			f.onChange(tt.macs...)
+			lookup()
+			if got := f.calls.Load(); got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	b := New(&fake{}, time.Minute, time.Minute)
+	hits := testutil.ToFloat64(metric.BackendCacheTotal.WithLabelValues("ip", "hit"))
+	negativeHits := testutil.ToFloat64(metric.BackendCacheTotal.WithLabelValues("ip", "negative_hit"))
+	misses := testutil.ToFloat64(metric.BackendCacheTotal.WithLabelValues("ip", "miss"))
+	for range 3 {
+		b.GetByIP(context.Background(), net.IPv4(192, 168, 2, 153)) //nolint:errcheck // only the metrics matter.
+		b.GetByIP(context.Background(), net.IPv4(192, 168, 2, 154)) //nolint:errcheck // only the metrics matter.
+	}
+	if got := testutil.ToFloat64(metric.BackendCacheTotal.WithLabelValues("ip", "hit")) - hits; got != 2 {
+		t.Errorf("hits = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(metric.BackendCacheTotal.WithLabelValues("ip", "negative_hit")) - negativeHits; got != 2 {
+		t.Errorf("negative hits = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(metric.BackendCacheTotal.WithLabelValues("ip", "miss")) - misses; got != 2 {
+		t.Errorf("misses = %v, want 2", got)
+	}
+}
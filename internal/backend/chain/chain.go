@@ -0,0 +1,128 @@
+// Package chain is a backend that queries several backends in order.
+//
+// The hardware of the backends is merged: the first backend that has hardware for a lookup answers it,
+// so an earlier backend, for example a file of overrides, takes precedence over a later one, for example Kubernetes.
+// A backend that has no hardware for a lookup, that is its error implements NotFound() and it returns true,
+// passes the lookup on to the next backend. Any other error fails the lookup, the next backends are not queried.
+// This makes sure that a backend outage does not change the answer to a lookup to the hardware of a later backend.
+package chain
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/tinkerbell/smee/dhcp"
+
+// Entry is a backend in a chain.
+type Entry struct {
+	// Name identifies the backend in errors and traces.
+	Name    string
+	Backend handler.BackendReader
+}
+
+// Backend queries its backends in order.
+type Backend struct {
+	// Backends are queried in order.
+	Backends []Entry
+}
+
+// OnChange implements the handler.ChangeNotifier interface. f is called for the changes of all backends that implement it.
+func (b *Backend) OnChange(f func(macs ...net.HardwareAddr)) {
+	for _, e := range b.Backends {
+		if cn, ok := e.Backend.(handler.ChangeNotifier); ok {
+			cn.OnChange(f)
+		}
+	}
+}
+
+// Ready implements the handler.ReadinessChecker interface. The chain is ready when all its backends that implement it are,
+// as a backend that is not ready could answer lookups that a later backend answers differently.
+func (b *Backend) Ready(ctx context.Context) error {
+	for _, e := range b.Backends {
+		if rc, ok := e.Backend.(handler.ReadinessChecker); ok {
+			if err := rc.Ready(ctx); err != nil {
+				return fmt.Errorf("%s backend: %w", e.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// lookup is a lookup in a single backend.
+type lookup func(handler.BackendReader) (*data.DHCP, *data.Netboot, error)
+
+// GetByMac implements the handler.BackendReader interface and returns DHCP and netboot data based on a mac address.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.chain.GetByMac")
+	defer span.End()
+
+	return b.get(span, mac.String(), func(br handler.BackendReader) (*data.DHCP, *data.Netboot, error) {
+		return br.GetByMac(ctx, mac)
+	})
+}
+
+// GetByIP implements the handler.BackendReader interface and returns DHCP and netboot data based on an IP address.
+func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.chain.GetByIP")
+	defer span.End()
+
+	return b.get(span, ip.String(), func(br handler.BackendReader) (*data.DHCP, *data.Netboot, error) {
+		return br.GetByIP(ctx, ip)
+	})
+}
+
+// GetByRelayAgent implements the handler.RelayAgentReader interface and returns DHCP and netboot data based on
+// relay agent information. Backends that do not implement handler.RelayAgentReader are treated as not having the hardware.
+func (b *Backend) GetByRelayAgent(ctx context.Context, ra data.RelayAgent) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.chain.GetByRelayAgent")
+	defer span.End()
+
+	key := "circuitID " + ra.CircuitID
+	return b.get(span, key, func(br handler.BackendReader) (*data.DHCP, *data.Netboot, error) {
+		rr, ok := br.(handler.RelayAgentReader)
+		if !ok {
+			return nil, nil, handler.NotFoundError{Key: key}
+		}
+		return rr.GetByRelayAgent(ctx, ra)
+	})
+}
+
+// get runs l on the backends in order, until one has hardware or fails.
+func (b *Backend) get(span trace.Span, key string, l lookup) (*data.DHCP, *data.Netboot, error) {
+	for _, e := range b.Backends {
+		d, n, err := l(e.Backend)
+		if err != nil {
+			if handler.IsNotFound(err) {
+				continue
+			}
+			err = fmt.Errorf("%s backend: %w", e.Name, err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, nil, err
+		}
+
+		span.SetAttributes(attribute.String("backend.chain.name", e.Name))
+		span.SetAttributes(d.EncodeToAttributes()...)
+		span.SetAttributes(n.EncodeToAttributes()...)
+		span.SetStatus(codes.Ok, "")
+
+		return d, n, nil
+	}
+	err := handler.NotFoundError{Key: key}
+	span.SetStatus(codes.Error, err.Error())
+
+	return nil, nil, err
+}
@@ -0,0 +1,256 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/smee/internal/backend/file"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
+)
+
+var errOutage = errors.New("backend unavailable")
+
+// fake is a backend with hardware for a single MAC address, or that always fails when err is set.
+type fake struct {
+	mac      string
+	hostname string
+	err      error
+	calls    int
+}
+
+func (f *fake) get(key string) (*data.DHCP, *data.Netboot, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+	if key != f.mac {
+		return nil, nil, handler.NotFoundError{Key: key}
+	}
+
+	return &data.DHCP{Hostname: f.hostname}, &data.Netboot{}, nil
+}
+
+func (f *fake) GetByMac(_ context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	return f.get(mac.String())
+}
+
+func (f *fake) GetByIP(_ context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	return f.get(ip.String())
+}
+
+// relayFake is a fake that also matches on the circuit ID.
+type relayFake struct {
+	fake
+}
+
+func (f *relayFake) GetByRelayAgent(_ context.Context, ra data.RelayAgent) (*data.DHCP, *data.Netboot, error) {
+	return f.get(ra.CircuitID)
+}
+
+// readyFake is a fake that is not ready when notReady is set.
+type readyFake struct {
+	fake
+	notReady error
+}
+
+func (f *readyFake) Ready(context.Context) error {
+	return f.notReady
+}
+
+func TestGetByMac(t *testing.T) {
+	mac := net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67}
+	tests := map[string]struct {
+		backends     []*fake
+		wantHostname string
+		wantCalls    []int
+		wantErr      error
+		wantNotFound bool
+	}{
+		"first backend has the hardware": {
+			backends:     []*fake{{mac: mac.String(), hostname: "override"}, {mac: mac.String(), hostname: "kube"}},
+			wantHostname: "override",
+			wantCalls:    []int{1, 0},
+		},
+		"not found falls through": {
+			backends:     []*fake{{}, {mac: mac.String(), hostname: "kube"}},
+			wantHostname: "kube",
+			wantCalls:    []int{1, 1},
+		},
+		"error does not fall through": {
+			backends:  []*fake{{err: errOutage}, {mac: mac.String(), hostname: "kube"}},
+			wantCalls: []int{1, 0},
+			wantErr:   errOutage,
+		},
+		"error after not found": {
+			backends:  []*fake{{}, {err: errOutage}},
+			wantCalls: []int{1, 1},
+			wantErr:   errOutage,
+		},
+		"not found in any backend": {
+			backends:     []*fake{{}, {}},
+			wantCalls:    []int{1, 1},
+			wantNotFound: true,
+		},
+		"no backends": {
+			wantNotFound: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := &Backend{}
+			for _, f := range tt.backends {
+				b.Backends = append(b.Backends, Entry{Name: "fake", Backend: f})
+			}
+			d, _, err := b.GetByMac(context.Background(), mac)
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetByMac() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := handler.IsNotFound(err); got != tt.wantNotFound {
+				t.Fatalf("IsNotFound() = %v, want %v, error = %v", got, tt.wantNotFound, err)
+			}
+			if err == nil && d.Hostname != tt.wantHostname {
+				t.Errorf("Hostname = %q, want %q", d.Hostname, tt.wantHostname)
+			}
+			var calls []int
+			for _, f := range tt.backends {
+				calls = append(calls, f.calls)
+			}
+			if diff := cmp.Diff(tt.wantCalls, calls); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+// TestFileInFront checks that lookups of hardware missing from a file backend fall through to the next backend.
+func TestFileInFront(t *testing.T) {
+	w, err := file.NewWatcher(logr.Discard(), "../file/testdata/example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kube := &relayFake{fake{mac: "02:00:00:00:00:01", hostname: "kube"}}
+	b := &Backend{Backends: []Entry{{Name: "file", Backend: w}, {Name: "kube", Backend: kube}}}
+
+	tests := map[string]struct {
+		lookup       func() (*data.DHCP, *data.Netboot, error)
+		wantHostname string
+		wantNotFound bool
+	}{
+		"mac in the file": {
+			lookup: func() (*data.DHCP, *data.Netboot, error) {
+				return b.GetByMac(context.Background(), net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67})
+			},
+			wantHostname: "pxe-virtualbox",
+		},
+		"mac missing from the file": {
+			lookup: func() (*data.DHCP, *data.Netboot, error) {
+				return b.GetByMac(context.Background(), net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01})
+			},
+			wantHostname: "kube",
+		},
+		"ip missing from the file": {
+			lookup: func() (*data.DHCP, *data.Netboot, error) {
+				return b.GetByIP(context.Background(), net.IPv4(2, 0, 0, 1))
+			},
+			wantNotFound: true,
+		},
+		"relay agent missing from the file": {
+			lookup: func() (*data.DHCP, *data.Netboot, error) {
+				return b.GetByRelayAgent(context.Background(), data.RelayAgent{CircuitID: "02:00:00:00:00:01"})
+			},
+			wantHostname: "kube",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			d, _, err := tt.lookup()
+			if got := handler.IsNotFound(err); got != tt.wantNotFound {
+				t.Fatalf("IsNotFound() = %v, want %v, error = %v", got, tt.wantNotFound, err)
+			}
+			if err == nil && d.Hostname != tt.wantHostname {
+				t.Errorf("Hostname = %q, want %q", d.Hostname, tt.wantHostname)
+			}
+		})
+	}
+}
+
+func TestGetByIP(t *testing.T) {
+	b := &Backend{Backends: []Entry{
+		{Name: "file", Backend: &fake{}},
+		{Name: "kube", Backend: &fake{mac: "192.168.2.153", hostname: "kube"}},
+	}}
+	d, _, err := b.GetByIP(context.Background(), net.IPv4(192, 168, 2, 153))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Hostname != "kube" {
+		t.Errorf("Hostname = %q, want %q", d.Hostname, "kube")
+	}
+}
+
+func TestGetByRelayAgent(t *testing.T) {
+	tests := map[string]struct {
+		backends     []Entry
+		wantHostname string
+		wantNotFound bool
+	}{
+		"backends without relay agent lookups are skipped": {
+			backends: []Entry{
+				{Name: "rest", Backend: &fake{mac: "Ethernet1/1", hostname: "rest"}},
+				{Name: "file", Backend: &relayFake{fake{mac: "Ethernet1/1", hostname: "file"}}},
+			},
+			wantHostname: "file",
+		},
+		"no backend with relay agent lookups": {
+			backends:     []Entry{{Name: "rest", Backend: &fake{mac: "Ethernet1/1"}}},
+			wantNotFound: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := &Backend{Backends: tt.backends}
+			d, _, err := b.GetByRelayAgent(context.Background(), data.RelayAgent{CircuitID: "Ethernet1/1"})
+			if got := handler.IsNotFound(err); got != tt.wantNotFound {
+				t.Fatalf("IsNotFound() = %v, want %v, error = %v", got, tt.wantNotFound, err)
+			}
+			if err == nil && d.Hostname != tt.wantHostname {
+				t.Errorf("Hostname = %q, want %q", d.Hostname, tt.wantHostname)
+			}
+		})
+	}
+}
+
+func TestReady(t *testing.T) {
+	tests := map[string]struct {
+		backends []Entry
+		wantErr  string
+	}{
+		"no readiness checks": {backends: []Entry{{Name: "rest", Backend: &fake{}}}},
+		"all ready": {backends: []Entry{
+			{Name: "file", Backend: &readyFake{}},
+			{Name: "kube", Backend: &readyFake{}},
+		}},
+		"later backend not ready": {backends: []Entry{
+			{Name: "file", Backend: &readyFake{}},
+			{Name: "rest", Backend: &fake{}},
+			{Name: "kube", Backend: &readyFake{notReady: errors.New("the hardware cache has not synced")}},
+		}, wantErr: "kube backend: the hardware cache has not synced"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := &Backend{Backends: tt.backends}
+			var got string
+			if err := b.Ready(context.Background()); err != nil {
+				got = err.Error()
+			}
+			if got != tt.wantErr {
+				t.Errorf("Ready() error = %q, want %q", got, tt.wantErr)
+			}
+		})
+	}
+}
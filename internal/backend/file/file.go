@@ -1,4 +1,4 @@
-// Package file watches a file for changes and updates the in memory DHCP data.
+// Package file watches a file, or a directory of files, for changes and updates the in memory DHCP data.
 package file
 
 import (
@@ -9,16 +9,18 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 
 	"github.com/ccoveille/go-safecast"
 	"github.com/fsnotify/fsnotify"
-	"github.com/ghodss/yaml"
 	"github.com/go-logr/logr"
 	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const tracerName = "github.com/tinkerbell/smee/dhcp"
@@ -26,11 +28,13 @@ const tracerName = "github.com/tinkerbell/smee/dhcp"
 // Errors used by the file watcher.
 var (
 	// errFileFormat is returned when the file is not in the correct format, e.g. not valid YAML.
-	errFileFormat     = fmt.Errorf("invalid file format")
-	errRecordNotFound = fmt.Errorf("record not found")
-	errParseIP        = fmt.Errorf("failed to parse IP from File")
-	errParseSubnet    = fmt.Errorf("failed to parse subnet mask from File")
-	errParseURL       = fmt.Errorf("failed to parse URL")
+	errFileFormat      = fmt.Errorf("invalid file format")
+	errParseIP         = fmt.Errorf("failed to parse IP from File")
+	errParseSubnet     = fmt.Errorf("failed to parse subnet mask from File")
+	errParseURL        = fmt.Errorf("failed to parse URL")
+	errMultipleRecords = fmt.Errorf("multiple records found")
+	// errRecordNotFound is a handler.NotFoundError so that a chain of backends falls through to the next one.
+	errRecordNotFound = handler.NotFoundError{}
 )
 
 // netboot is the structure for the data expected in a file.
@@ -38,13 +42,43 @@ type netboot struct {
 	AllowPXE      bool   `yaml:"allowPxe"`      // If true, the client will be provided netboot options in the DHCP offer/ack.
 	IPXEScriptURL string `yaml:"ipxeScriptUrl"` // Overrides default value of that is passed into DHCP on startup.
 	IPXEScript    string `yaml:"ipxeScript"`    // Overrides a default value that is passed into DHCP on startup.
+	IPXE          ipxe   `yaml:"ipxe"`          // The same as ipxeScriptUrl and ipxeScript, in the format of a Hardware object. Takes precedence.
 	Console       string `yaml:"console"`
-	Facility      string `yaml:"facility"`
+	Facility      string `yaml:"facility"` // Overrides the facility of the machine.
+	OSIE          osie   `yaml:"osie"`
+}
+
+// ipxe is the structure for the iPXE script data expected in a file.
+type ipxe struct {
+	URL      string `yaml:"url"`      // The URL of an iPXE script.
+	Contents string `yaml:"contents"` // The contents of an iPXE script.
+}
+
+// osie is the structure for the OS installation environment data expected in a file.
+type osie struct {
+	BaseURL string `yaml:"baseURL"` // The URL where the kernel and initrd are located.
+	Kernel  string `yaml:"kernel"`  // The name of the kernel file.
+	Initrd  string `yaml:"initrd"`  // The name of the initrd file.
+}
+
+// machine is the structure for a machine with one or more interfaces expected in a file.
+// It is the equivalent of a Hardware object of the Kubernetes backend.
+type machine struct {
+	Facility   string     `yaml:"facility"`   // The facility of all interfaces.
+	RelayAgent relayAgent `yaml:"relayAgent"` // Matches relayed requests by switch port to the first interface.
+	Interfaces []dhcp     `yaml:"interfaces"` // The MAC address of an interface is set with mac.
+}
+
+// relayAgent is the structure for the relay agent information (DHCP option 82) expected in a file.
+type relayAgent struct {
+	CircuitID string `yaml:"circuitID"` // DHCP option 82.1.
+	RemoteID  string `yaml:"remoteID"`  // DHCP option 82.2, optional.
 }
 
 // dhcp is the structure for the data expected in a file.
 type dhcp struct {
 	MACAddress       net.HardwareAddr // The MAC address of the client.
+	MAC              string           `yaml:"mac"`              // The MAC address of an interface of a machine.
 	IPAddress        string           `yaml:"ipAddress"`        // yiaddr DHCP header.
 	SubnetMask       string           `yaml:"subnetMask"`       // DHCP option 1.
 	DefaultGateway   string           `yaml:"defaultGateway"`   // DHCP option 3.
@@ -59,144 +93,284 @@ type dhcp struct {
 	DomainSearch     []string         `yaml:"domainSearch"`     // DHCP option 119.
 	Disabled         bool             // If true, no DHCP response should be sent.
 	Netboot          netboot          `yaml:"netboot"`
+	RelayAgent       relayAgent       `yaml:"relayAgent"` // Matches relayed requests by switch port.
 }
 
+// fileExts are the extensions of the files that are read from a directory.
+var fileExts = []string{".yaml", ".yml", ".json"}
+
 // Watcher represents the backend for watching a file for changes and updating the in memory DHCP data.
 type Watcher struct {
 	fileMu sync.RWMutex // protects FilePath for reads
 
 	// FilePath is the path to the file to watch.
+	// When it is a directory, the records of all the YAML and JSON files in it are used.
 	FilePath string
 
 	// Log is the logger to be used in the File backend.
-	Log     logr.Logger
-	dataMu  sync.RWMutex // protects data
-	data    []byte       // data from file
-	watcher *fsnotify.Watcher
+	Log      logr.Logger
+	dir      bool         // FilePath is a directory
+	dataMu   sync.RWMutex // protects data, records, err and onChange
+	data     []byte       // data from file, not used for a directory
+	records  *records     // parsed data from file, used for lookups
+	err      error        // validation error of the last file change
+	onChange []func(...net.HardwareAddr)
+	watcher  *fsnotify.Watcher
 }
 
-// NewWatcher creates a new file watcher.
+// NewWatcher creates a new file watcher. f is a file or a directory of files.
 func NewWatcher(l logr.Logger, f string) (*Watcher, error) {
+	fi, err := os.Stat(f)
+	if err != nil {
+		return nil, err
+	}
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
 	}
-	if err := watcher.Add(f); err != nil {
+	// Watch the directory of a file instead of the file itself, so the file is still watched
+	// after an editor replaces it by renaming a new file over it.
+	dir := f
+	if !fi.IsDir() {
+		dir = filepath.Dir(f)
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
 		return nil, err
 	}
 
 	w := &Watcher{
 		FilePath: f,
+		dir:      fi.IsDir(),
 		watcher:  watcher,
 		Log:      l,
 	}
+	if err := w.load(); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
 
+// load reads and parses the file, or the files in the directory, and updates the data used for lookups.
+func (w *Watcher) load() error {
 	w.fileMu.RLock()
-	w.data, err = os.ReadFile(filepath.Clean(f))
-	w.fileMu.RUnlock()
+	defer w.fileMu.RUnlock()
+	if !w.dir {
+		d, err := os.ReadFile(filepath.Clean(w.FilePath))
+		if err != nil {
+			return err
+		}
+		w.update(d, w.parse(d))
+
+		return nil
+	}
+
+	entries, err := os.ReadDir(w.FilePath)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	files := make(map[string][]byte)
+	var errs []error
+	for _, e := range entries {
+		if e.IsDir() || !isDataFile(e.Name()) {
+			continue
+		}
+		d, err := os.ReadFile(filepath.Join(w.FilePath, e.Name()))
+		if err != nil {
+			// The file may have been removed since the directory was read, the event for that reloads the directory again.
+			errs = append(errs, err)
+			continue
+		}
+		files[e.Name()] = d
 	}
+	next := w.parseDir(files)
+	next.invalid = append(errs, next.invalid...)
+	w.update(nil, next)
 
-	return w, nil
+	return nil
+}
+
+// isDataFile reports whether name is a file that is read from a directory.
+// Hidden files are skipped, editors and Kubernetes ConfigMap volumes use them for temporary files.
+func isDataFile(name string) bool {
+	return !strings.HasPrefix(name, ".") && slices.Contains(fileExts, filepath.Ext(name))
+}
+
+// changed reports whether the event is for the file, or for a file in the directory, that is watched.
+func (w *Watcher) changed(e fsnotify.Event) bool {
+	if e.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+		return false
+	}
+	w.fileMu.RLock()
+	defer w.fileMu.RUnlock()
+	if w.dir {
+		return isDataFile(filepath.Base(e.Name))
+	}
+
+	return filepath.Base(e.Name) == filepath.Base(w.FilePath)
+}
+
+// update makes next, parsed from d, the data used for lookups.
+// When the data is not valid and the data in use is, the data in use is kept.
+// The data in use is always replaced when it cannot be parsed, so that valid records are served as soon as possible.
+func (w *Watcher) update(d []byte, next *records) {
+	err := next.Err()
+
+	w.dataMu.Lock()
+	defer w.dataMu.Unlock()
+	w.err = err
+	if err != nil {
+		cur := w.records
+		if cur != nil && cur.err == nil && (next.err != nil || len(cur.invalid) == 0) {
+			w.Log.Error(err, "file is not valid, keeping the last valid data", "file", w.FilePath)
+			return
+		}
+		w.Log.Error(err, "file is not valid", "file", w.FilePath)
+	}
+	w.data = d
+	w.records = next
+	for _, f := range w.onChange {
+		f()
+	}
+}
+
+// OnChange implements the handler.ChangeNotifier interface. f is called every time the data used for lookups is replaced.
+func (w *Watcher) OnChange(f func(macs ...net.HardwareAddr)) {
+	w.dataMu.Lock()
+	defer w.dataMu.Unlock()
+	w.onChange = append(w.onChange, f)
+}
+
+// Err returns the validation error of the last change to the file, or files, or nil when they are valid.
+func (w *Watcher) Err() error {
+	w.dataMu.RLock()
+	defer w.dataMu.RUnlock()
+
+	return w.err
+}
+
+// snapshot returns the data used for lookups.
+func (w *Watcher) snapshot() *records {
+	w.dataMu.RLock()
+	defer w.dataMu.RUnlock()
+	if w.records == nil {
+		return &records{err: fmt.Errorf("no data loaded: %w", errFileFormat)}
+	}
+
+	return w.records
 }
 
 // GetByMac is the implementation of the Backend interface.
-// It reads a given file from the in memory data (w.data).
+// It looks up a mac address in the data parsed from the file.
 func (w *Watcher) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
 	tracer := otel.Tracer(tracerName)
 	_, span := tracer.Start(ctx, "backend.file.GetByMac")
 	defer span.End()
 
-	// get data from file, translate it, then pass it into setDHCPOpts and setNetworkBootOpts
-	w.dataMu.RLock()
-	d := w.data
-	w.dataMu.RUnlock()
-	r := make(map[string]dhcp)
-	if err := yaml.Unmarshal(d, &r); err != nil {
-		err := fmt.Errorf("%w: %w", err, errFileFormat)
-		w.Log.Error(err, "failed to unmarshal file data")
-		span.SetStatus(codes.Error, err.Error())
+	rs := w.snapshot()
+	if rs.err != nil {
+		span.SetStatus(codes.Error, rs.err.Error())
 
-		return nil, nil, err
+		return nil, nil, rs.err
 	}
-	for k, v := range r {
-		if strings.EqualFold(k, mac.String()) {
-			// found a record for this mac address
-			v.MACAddress = mac
-			d, n, err := w.translate(v)
-			if err != nil {
-				span.SetStatus(codes.Error, err.Error())
-
-				return nil, nil, err
-			}
-			span.SetAttributes(d.EncodeToAttributes()...)
-			span.SetAttributes(n.EncodeToAttributes()...)
-			span.SetStatus(codes.Ok, "")
+	r, ok := rs.byMAC[mac.String()]
+	if !ok {
+		err := fmt.Errorf("%w: %s", errRecordNotFound, mac.String())
+		span.SetStatus(codes.Error, err.Error())
 
-			return d, n, nil
-		}
+		return nil, nil, err
 	}
 
-	err := fmt.Errorf("%w: %s", errRecordNotFound, mac.String())
-	span.SetStatus(codes.Error, err.Error())
-
-	return nil, nil, err
+	return r.result(span)
 }
 
 // GetByIP is the implementation of the Backend interface.
-// It reads a given file from the in memory data (w.data).
+// It looks up an IP address in the data parsed from the file.
 func (w *Watcher) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
 	tracer := otel.Tracer(tracerName)
 	_, span := tracer.Start(ctx, "backend.file.GetByIP")
 	defer span.End()
 
-	// get data from file, translate it, then pass it into setDHCPOpts and setNetworkBootOpts
-	w.dataMu.RLock()
-	d := w.data
-	w.dataMu.RUnlock()
-	r := make(map[string]dhcp)
-	if err := yaml.Unmarshal(d, &r); err != nil {
-		err := fmt.Errorf("%w: %w", err, errFileFormat)
-		w.Log.Error(err, "failed to unmarshal file data")
+	rs := w.snapshot()
+	if rs.err != nil {
+		span.SetStatus(codes.Error, rs.err.Error())
+
+		return nil, nil, rs.err
+	}
+	addr, _ := netip.AddrFromSlice(ip)
+	found := rs.byIP[addr.Unmap()]
+	switch len(found) {
+	case 0:
+		err := fmt.Errorf("%w: %s", errRecordNotFound, ip.String())
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, err
+	case 1:
+	default:
+		err := fmt.Errorf("%w: ip %s: %v", errMultipleRecords, ip, macs(found))
 		span.SetStatus(codes.Error, err.Error())
 
 		return nil, nil, err
 	}
-	for k, v := range r {
-		if v.IPAddress == ip.String() {
-			// found a record for this ip address
-			v.IPAddress = ip.String()
-			mac, err := net.ParseMAC(k)
-			if err != nil {
-				err := fmt.Errorf("%w: %w", err, errFileFormat)
-				w.Log.Error(err, "failed to parse mac address")
-				span.SetStatus(codes.Error, err.Error())
-
-				return nil, nil, err
-			}
-			v.MACAddress = mac
-			d, n, err := w.translate(v)
-			if err != nil {
-				span.SetStatus(codes.Error, err.Error())
 
-				return nil, nil, err
-			}
-			span.SetAttributes(d.EncodeToAttributes()...)
-			span.SetAttributes(n.EncodeToAttributes()...)
-			span.SetStatus(codes.Ok, "")
+	return found[0].result(span)
+}
 
-			return d, n, nil
+// GetByRelayAgent is the implementation of the handler.RelayAgentReader interface.
+// It returns the record from the data parsed from the file whose relay agent information matches.
+func (w *Watcher) GetByRelayAgent(ctx context.Context, ra data.RelayAgent) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	_, span := tracer.Start(ctx, "backend.file.GetByRelayAgent")
+	defer span.End()
+
+	rs := w.snapshot()
+	if rs.err != nil {
+		span.SetStatus(codes.Error, rs.err.Error())
+
+		return nil, nil, rs.err
+	}
+	var found []*record
+	for _, r := range rs.byCircuitID[ra.CircuitID] {
+		if r.relay.Matches(ra) {
+			found = append(found, r)
 		}
 	}
+	switch len(found) {
+	case 0:
+		err := fmt.Errorf("%w: circuit ID %q, remote ID %q", errRecordNotFound, ra.CircuitID, ra.RemoteID)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, err
+	case 1:
+	default:
+		err := fmt.Errorf("%w: circuit ID %q, remote ID %q: %v", errMultipleRecords, ra.CircuitID, ra.RemoteID, macs(found))
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, err
+	}
+
+	return found[0].result(span)
+}
+
+// result returns a copy of the data of a record and records it in span.
+func (r *record) result(span trace.Span) (*data.DHCP, *data.Netboot, error) {
+	if r.err != nil {
+		span.SetStatus(codes.Error, r.err.Error())
 
-	err := fmt.Errorf("%w: %s", errRecordNotFound, ip.String())
-	span.SetStatus(codes.Error, err.Error())
+		return nil, nil, r.err
+	}
+	d, n := r.data()
+	span.SetAttributes(d.EncodeToAttributes()...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
 
-	return nil, nil, err
+	return d, n, nil
 }
 
-// Start starts watching a file for changes and updates the in memory data (w.data) on changes.
+// Start starts watching a file, or directory, for changes and updates the in memory data on changes.
+// Files that are written, created, renamed or removed are changes.
 // Start is a blocking method. Use a context cancellation to exit.
 func (w *Watcher) Start(ctx context.Context) {
 	for {
@@ -208,18 +382,12 @@ func (w *Watcher) Start(ctx context.Context) {
 			if !ok {
 				continue
 			}
-			if event.Op&fsnotify.Write == fsnotify.Write {
+			if w.changed(event) {
 				w.Log.Info("file changed, updating cache")
-				w.fileMu.RLock()
-				d, err := os.ReadFile(w.FilePath)
-				w.fileMu.RUnlock()
-				if err != nil {
+				if err := w.load(); err != nil {
+					// A file that is replaced by a rename is read again on the create event that follows.
 					w.Log.Error(err, "failed to read file", "file", w.FilePath)
-					break
 				}
-				w.dataMu.Lock()
-				w.data = d
-				w.dataMu.Unlock()
 			}
 		case err, ok := <-w.watcher.Errors:
 			if !ok {
@@ -243,12 +411,14 @@ func (w *Watcher) translate(r dhcp) (*data.DHCP, *data.Netboot, error) {
 	}
 	d.IPAddress = ip
 
-	// subnet mask, required
-	sm := net.ParseIP(r.SubnetMask)
-	if sm == nil {
-		return nil, nil, errParseSubnet
+	// subnet mask, optional, it can come from the subnet defaults of the DHCP server
+	if r.SubnetMask != "" {
+		sm := net.ParseIP(r.SubnetMask)
+		if sm == nil {
+			return nil, nil, errParseSubnet
+		}
+		d.SubnetMask = net.IPMask(sm.To4())
 	}
-	d.SubnetMask = net.IPMask(sm.To4())
 
 	// default gateway, optional
 	if dg, err := netip.ParseAddr(r.DefaultGateway); err != nil {
@@ -313,8 +483,12 @@ func (w *Watcher) translate(r dhcp) (*data.DHCP, *data.Netboot, error) {
 	n.AllowNetboot = r.Netboot.AllowPXE
 
 	// ipxe script url is optional but if provided, it must be a valid url
-	if r.Netboot.IPXEScriptURL != "" {
-		u, err := url.Parse(r.Netboot.IPXEScriptURL)
+	su := r.Netboot.IPXEScriptURL
+	if r.Netboot.IPXE.URL != "" {
+		su = r.Netboot.IPXE.URL
+	}
+	if su != "" {
+		u, err := url.Parse(su)
 		if err != nil {
 			return nil, nil, fmt.Errorf("%w: %w", err, errParseURL)
 		}
@@ -325,6 +499,9 @@ func (w *Watcher) translate(r dhcp) (*data.DHCP, *data.Netboot, error) {
 	if r.Netboot.IPXEScript != "" {
 		n.IPXEScript = r.Netboot.IPXEScript
 	}
+	if r.Netboot.IPXE.Contents != "" {
+		n.IPXEScript = r.Netboot.IPXE.Contents
+	}
 
 	// console
 	if r.Netboot.Console != "" {
@@ -336,5 +513,16 @@ func (w *Watcher) translate(r dhcp) (*data.DHCP, *data.Netboot, error) {
 		n.Facility = r.Netboot.Facility
 	}
 
+	// osie base url is optional but if provided, it must be a valid url
+	if r.Netboot.OSIE.BaseURL != "" {
+		u, err := url.Parse(r.Netboot.OSIE.BaseURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", err, errParseURL)
+		}
+		n.OSIE.BaseURL = u
+	}
+	n.OSIE.Kernel = r.Netboot.OSIE.Kernel
+	n.OSIE.Initrd = r.Netboot.OSIE.Initrd
+
 	return d, n, nil
 }
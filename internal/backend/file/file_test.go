@@ -11,6 +11,7 @@ import (
 	"net/netip"
 	"net/url"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -106,7 +107,7 @@ func TestStartFileUpdateError(t *testing.T) {
 	go func() {
 		<-time.After(time.Millisecond)
 		got.FilePath = "not-found.txt"
-		got.watcher.Events <- fsnotify.Event{Op: fsnotify.Write}
+		got.watcher.Events <- fsnotify.Event{Name: "not-found.txt", Op: fsnotify.Write}
 		cancel()
 	}()
 	got.Start(ctx)
@@ -242,6 +243,7 @@ func TestTranslate(t *testing.T) {
 			IPXEScript:    "#!ipxe\nchain http://boot.netboot.xyz",
 			Console:       "ttyS0",
 			Facility:      "onprem",
+			OSIE:          osie{BaseURL: "http://10.1.1.1:8080", Kernel: "vmlinuz-x86_64", Initrd: "initramfs-x86_64"},
 		},
 	}
 	wantDHCP := &data.DHCP{
@@ -265,6 +267,11 @@ func TestTranslate(t *testing.T) {
 		IPXEScript:    "#!ipxe\nchain http://boot.netboot.xyz",
 		Console:       "ttyS0",
 		Facility:      "onprem",
+		OSIE: data.OSIE{
+			BaseURL: &url.URL{Scheme: "http", Host: "10.1.1.1:8080"},
+			Kernel:  "vmlinuz-x86_64",
+			Initrd:  "initramfs-x86_64",
+		},
 	}
 	w := &Watcher{Log: logr.Discard()}
 	gotDHCP, gotNetboot, err := w.translate(input)
@@ -286,6 +293,7 @@ func TestTranslateErrors(t *testing.T) {
 	}{
 		"invalid IP":                {input: dhcp{IPAddress: "not an IP"}, wantErr: errParseIP},
 		"invalid subnet mask":       {input: dhcp{IPAddress: "1.1.1.1", SubnetMask: "not a mask"}, wantErr: errParseSubnet},
+		"no subnet mask":            {input: dhcp{IPAddress: "1.1.1.1"}, wantErr: nil},
 		"invalid gateway":           {input: dhcp{IPAddress: "1.1.1.1", SubnetMask: "192.168.1.255", DefaultGateway: "not a gateway"}, wantErr: nil},
 		"invalid broadcast address": {input: dhcp{IPAddress: "1.1.1.1", SubnetMask: "192.168.1.255"}, wantErr: nil},
 		"invalid NameServers":       {input: dhcp{IPAddress: "1.1.1.1", SubnetMask: "192.168.1.255", NameServers: []string{"no good"}}, wantErr: nil},
@@ -370,3 +378,422 @@ func TestGetByIP(t *testing.T) {
 		})
 	}
 }
+
+func TestGetByRelayAgent(t *testing.T) {
+	records := `---
+08:00:27:29:4e:67:
+  ipAddress: "192.168.2.153"
+  subnetMask: "255.255.255.0"
+  relayAgent:
+    circuitID: "Ethernet1/1"
+    remoteID: "switch01"
+52:54:00:aa:88:2a:
+  ipAddress: "192.168.2.15"
+  subnetMask: "255.255.255.0"
+  relayAgent:
+    circuitID: "Ethernet1/2"
+52:54:00:aa:88:2b:
+  ipAddress: "192.168.2.16"
+  subnetMask: "255.255.255.0"
+  relayAgent:
+    circuitID: "Ethernet1/2"
+`
+	tests := map[string]struct {
+		relay   data.RelayAgent
+		wantMAC net.HardwareAddr
+		wantErr error
+	}{
+		"circuit and remote id": {
+			relay:   data.RelayAgent{CircuitID: "Ethernet1/1", RemoteID: "switch01"},
+			wantMAC: net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67},
+		},
+		"remote id mismatch": {relay: data.RelayAgent{CircuitID: "Ethernet1/1", RemoteID: "switch02"}, wantErr: errRecordNotFound},
+		"no record found":    {relay: data.RelayAgent{CircuitID: "Ethernet1/3"}, wantErr: errRecordNotFound},
+		"multiple records":   {relay: data.RelayAgent{CircuitID: "Ethernet1/2"}, wantErr: errMultipleRecords},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := createFile([]byte(records))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(f)
+			w, err := NewWatcher(logr.Discard(), f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, _, err := w.GetByRelayAgent(context.Background(), tt.relay)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatal(err)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.wantMAC, d.MACAddress); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	valid := `---
+08:00:27:29:4e:67:
+  ipAddress: "192.168.2.153"
+  subnetMask: "255.255.255.0"
+`
+	tests := map[string]struct {
+		initial    string
+		next       string
+		wantMAC    net.HardwareAddr
+		wantErr    error
+		wantValid  error
+		wantChange bool
+	}{
+		"valid change": {
+			initial:    valid,
+			next:       "---\n08:00:27:29:4e:68:\n  ipAddress: \"192.168.2.153\"\n",
+			wantMAC:    net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x68},
+			wantChange: true,
+		},
+		"keep last valid data on file format error": {
+			initial:   valid,
+			next:      "not a yaml file",
+			wantMAC:   net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67},
+			wantValid: errFileFormat,
+		},
+		"keep last valid data on record error": {
+			initial:   valid,
+			next:      "---\n08:00:27:29:4e:68:\n  ipAddress: \"192.168.2\"\n",
+			wantMAC:   net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67},
+			wantValid: errParseIP,
+		},
+		"keep last valid data on duplicate ip": {
+			initial:   valid,
+			next:      valid + "08:00:27:29:4e:68:\n  ipAddress: \"192.168.2.153\"\n",
+			wantMAC:   net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67},
+			wantValid: errDuplicateIP,
+		},
+		"replace data that cannot be parsed": {
+			initial:    "not a yaml file",
+			next:       valid + "08:00:27:29:4e:68:\n  ipAddress: \"192.168.2\"\n",
+			wantMAC:    net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67},
+			wantValid:  errParseIP,
+			wantChange: true,
+		},
+		"duplicate ip lookup": {
+			initial:    valid + "08:00:27:29:4e:68:\n  ipAddress: \"192.168.2.153\"\n",
+			next:       valid + "08:00:27:29:4e:68:\n  ipAddress: \"192.168.2.153\"\n",
+			wantErr:    errMultipleRecords,
+			wantValid:  errDuplicateIP,
+			wantChange: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := createFile([]byte(tt.initial))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(f)
+			w, err := NewWatcher(logr.Discard(), f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			changed := false
+			w.OnChange(func(...net.HardwareAddr) { changed = true })
+			w.update([]byte(tt.next), w.parse([]byte(tt.next)))
+			if changed != tt.wantChange {
+				t.Errorf("changed = %v, want %v", changed, tt.wantChange)
+			}
+			if err := w.Err(); !errors.Is(err, tt.wantValid) || (err == nil) != (tt.wantValid == nil) {
+				t.Fatalf("Err() = %v, want %v", err, tt.wantValid)
+			}
+			d, _, err := w.GetByIP(context.Background(), net.IPv4(192, 168, 2, 153))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatal(err)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.wantMAC, d.MACAddress); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestDirectory(t *testing.T) {
+	host1 := "---\n08:00:27:29:4e:67:\n  ipAddress: \"192.168.2.153\"\n"
+	host2 := "08:00:27:29:4e:68:\n  ipAddress: \"192.168.2.154\"\n"
+	tests := map[string]struct {
+		files     map[string]string
+		ip        net.IP
+		wantMAC   net.HardwareAddr
+		wantErr   error
+		wantValid error
+	}{
+		"records from all files": {
+			files:   map[string]string{"a.yaml": host1, "b.yml": host2, "c.json": `{"08:00:27:29:4e:69": {"ipAddress": "192.168.2.155"}}`},
+			ip:      net.IPv4(192, 168, 2, 155),
+			wantMAC: net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x69},
+		},
+		"other files are skipped": {
+			files:   map[string]string{"a.yaml": host1, "b.txt": "not a yaml file", ".b.yaml.swp": "not a yaml file"},
+			ip:      net.IPv4(192, 168, 2, 153),
+			wantMAC: net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67},
+		},
+		"file that cannot be parsed": {
+			files:     map[string]string{"a.yaml": host1, "b.yaml": "not a yaml file"},
+			ip:        net.IPv4(192, 168, 2, 153),
+			wantMAC:   net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67},
+			wantValid: errFileFormat,
+		},
+		"duplicate mac": {
+			files:     map[string]string{"a.yaml": host1, "b.yaml": "08:00:27:29:4e:67:\n  ipAddress: \"192.168.2.154\"\n"},
+			ip:        net.IPv4(192, 168, 2, 154),
+			wantErr:   errRecordNotFound,
+			wantValid: errDuplicateMAC,
+		},
+		"duplicate ip": {
+			files:     map[string]string{"a.yaml": host1, "b.yaml": "08:00:27:29:4e:68:\n  ipAddress: \"192.168.2.153\"\n"},
+			ip:        net.IPv4(192, 168, 2, 153),
+			wantErr:   errMultipleRecords,
+			wantValid: errDuplicateIP,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			for n, c := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, n), []byte(c), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			w, err := NewWatcher(logr.Discard(), dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Err(); !errors.Is(err, tt.wantValid) || (err == nil) != (tt.wantValid == nil) {
+				t.Fatalf("Err() = %v, want %v", err, tt.wantValid)
+			}
+			d, _, err := w.GetByIP(context.Background(), tt.ip)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatal(err)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.wantMAC, d.MACAddress); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestStartChanges(t *testing.T) {
+	host1 := "---\n08:00:27:29:4e:67:\n  ipAddress: \"192.168.2.153\"\n"
+	host2 := "---\n08:00:27:29:4e:68:\n  ipAddress: \"192.168.2.154\"\n"
+	tests := map[string]struct {
+		dir     bool
+		change  func(t *testing.T, path string)
+		ip      net.IP
+		wantErr error
+	}{
+		"file replaced by rename": {
+			change: func(t *testing.T, path string) {
+				t.Helper()
+				tmp := filepath.Join(filepath.Dir(path), ".hosts.yaml.tmp")
+				if err := os.WriteFile(tmp, []byte(host2), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Rename(tmp, path); err != nil {
+					t.Fatal(err)
+				}
+			},
+			ip: net.IPv4(192, 168, 2, 154),
+		},
+		"file created in directory": {
+			dir: true,
+			change: func(t *testing.T, path string) {
+				t.Helper()
+				if err := os.WriteFile(filepath.Join(path, "b.yaml"), []byte(host2), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			},
+			ip: net.IPv4(192, 168, 2, 154),
+		},
+		"file removed from directory": {
+			dir: true,
+			change: func(t *testing.T, path string) {
+				t.Helper()
+				if err := os.Remove(filepath.Join(path, "a.yaml")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			ip:      net.IPv4(192, 168, 2, 153),
+			wantErr: errRecordNotFound,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := t.TempDir()
+			if tt.dir {
+				if err := os.WriteFile(filepath.Join(path, "a.yaml"), []byte(host1), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				path = filepath.Join(path, "hosts.yaml")
+				if err := os.WriteFile(path, []byte(host1), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			w, err := NewWatcher(logr.Discard(), path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go w.Start(ctx)
+			tt.change(t, path)
+
+			var gotErr error
+			for range 100 {
+				if _, _, gotErr = w.GetByIP(context.Background(), tt.ip); errors.Is(gotErr, tt.wantErr) {
+					return
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			t.Fatalf("GetByIP() error = %v, want %v", gotErr, tt.wantErr)
+		})
+	}
+}
+
+func TestMachine(t *testing.T) {
+	records := `---
+machine1:
+  facility: onprem
+  relayAgent:
+    circuitID: "Ethernet1/1"
+  interfaces:
+    - mac: "08:00:27:29:4e:67"
+      ipAddress: "192.168.2.153"
+      netboot:
+        allowPxe: true
+        ipxe:
+          contents: "#!ipxe\nexit"
+        osie:
+          baseURL: "http://10.1.1.1:8080"
+          kernel: "vmlinuz-x86_64"
+          initrd: "initramfs-x86_64"
+    - mac: "08:00:27:29:4e:68"
+      ipAddress: "192.168.2.154"
+      netboot:
+        facility: lab
+        ipxe:
+          url: "http://10.1.1.1/auto.ipxe"
+52:54:00:aa:88:2a:
+  ipAddress: "192.168.2.15"
+`
+	tests := map[string]struct {
+		mac         net.HardwareAddr
+		relay       *data.RelayAgent
+		wantNetboot *data.Netboot
+		wantErr     error
+	}{
+		"first interface": {
+			mac: net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67},
+			wantNetboot: &data.Netboot{
+				AllowNetboot: true,
+				IPXEScript:   "#!ipxe\nexit",
+				Facility:     "onprem",
+				OSIE: data.OSIE{
+					BaseURL: &url.URL{Scheme: "http", Host: "10.1.1.1:8080"},
+					Kernel:  "vmlinuz-x86_64",
+					Initrd:  "initramfs-x86_64",
+				},
+			},
+		},
+		"second interface": {
+			mac: net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x68},
+			wantNetboot: &data.Netboot{
+				IPXEScriptURL: &url.URL{Scheme: "http", Host: "10.1.1.1", Path: "/auto.ipxe"},
+				Facility:      "lab",
+			},
+		},
+		"record": {
+			mac:         net.HardwareAddr{0x52, 0x54, 0x00, 0xaa, 0x88, 0x2a},
+			wantNetboot: &data.Netboot{},
+		},
+		"relay agent matches first interface": {
+			relay: &data.RelayAgent{CircuitID: "Ethernet1/1"},
+			wantNetboot: &data.Netboot{
+				AllowNetboot: true,
+				IPXEScript:   "#!ipxe\nexit",
+				Facility:     "onprem",
+				OSIE: data.OSIE{
+					BaseURL: &url.URL{Scheme: "http", Host: "10.1.1.1:8080"},
+					Kernel:  "vmlinuz-x86_64",
+					Initrd:  "initramfs-x86_64",
+				},
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := createFile([]byte(records))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(f)
+			w, err := NewWatcher(logr.Discard(), f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Err(); err != nil {
+				t.Fatal(err)
+			}
+			var n *data.Netboot
+			if tt.relay != nil {
+				_, n, err = w.GetByRelayAgent(context.Background(), *tt.relay)
+			} else {
+				_, n, err = w.GetByMac(context.Background(), tt.mac)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.wantNetboot, n); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestMachineErrors(t *testing.T) {
+	tests := map[string]struct {
+		records string
+		wantErr error
+	}{
+		"no interfaces":       {records: "machine1:\n  facility: onprem\n", wantErr: errFileFormat},
+		"invalid mac":         {records: "machine1:\n  interfaces:\n    - mac: \"08:00\"\n      ipAddress: \"192.168.2.153\"\n", wantErr: errFileFormat},
+		"invalid osie url":    {records: "machine1:\n  interfaces:\n    - mac: \"08:00:27:29:4e:67\"\n      ipAddress: \"192.168.2.153\"\n      netboot:\n        osie:\n          baseURL: \"http://[::1\"\n", wantErr: errParseURL},
+		"duplicate mac":       {records: "machine1:\n  interfaces:\n    - mac: \"08:00:27:29:4e:67\"\n      ipAddress: \"192.168.2.153\"\n08:00:27:29:4e:67:\n  ipAddress: \"192.168.2.154\"\n", wantErr: errDuplicateMAC},
+		"invalid record type": {records: "08:00:27:29:4e:67:\n  leaseTime: \"one day\"\n", wantErr: errFileFormat},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := createFile([]byte(tt.records))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(f)
+			w, err := NewWatcher(logr.Discard(), f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Err(); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Err() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+
+	"github.com/ghodss/yaml"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+)
+
+// Errors used when records conflict.
+var (
+	// errDuplicateIP is returned when more than one record has the same IP address.
+	errDuplicateIP = errors.New("duplicate IP address")
+	// errDuplicateMAC is returned when more than one record, or interface of a machine, has the same MAC address.
+	errDuplicateMAC = errors.New("duplicate MAC address")
+)
+
+// records is the data from a file, or the files in a directory, parsed, validated and indexed once per change.
+type records struct {
+	// err is set when the file cannot be parsed. Every lookup returns it.
+	// It is never set for a directory, a file that cannot be parsed is one of the invalid errors instead.
+	err error
+	// invalid holds the validation errors of the records in the file, or files.
+	invalid []error
+
+	byMAC       map[string]*record
+	byIP        map[netip.Addr][]*record
+	byCircuitID map[string][]*record
+}
+
+// record is a single record from a file. When err is set, the record is not valid and lookups of it return err.
+type record struct {
+	// file is the name of the file in a directory the record is from. It is empty for a single file.
+	file string
+	// key is the key of the record in the file, or the machine and interface for an interface of a machine.
+	key     string
+	mac     string
+	relay   data.RelayAgent
+	dhcp    *data.DHCP
+	netboot *data.Netboot
+	err     error
+}
+
+func newRecords() *records {
+	return &records{
+		byMAC:       map[string]*record{},
+		byIP:        map[netip.Addr][]*record{},
+		byCircuitID: map[string][]*record{},
+	}
+}
+
+// parse parses, validates and indexes the data from a file.
+func (w *Watcher) parse(b []byte) *records {
+	rs := newRecords()
+	if err := rs.add(w, "", b); err != nil {
+		rs.err = err
+		return rs
+	}
+	rs.checkIPs()
+
+	return rs
+}
+
+// parseDir parses, validates and indexes the data from the files in a directory, keyed by file name.
+// Records with a MAC address that is already in another record are not valid. Files are added in name order,
+// so the record from the file whose name sorts first is the one that is used.
+func (w *Watcher) parseDir(files map[string][]byte) *records {
+	rs := newRecords()
+	names := make([]string, 0, len(files))
+	for n := range files {
+		names = append(names, n)
+	}
+	slices.Sort(names)
+	for _, n := range names {
+		if err := rs.add(w, n, files[n]); err != nil {
+			rs.invalid = append(rs.invalid, fmt.Errorf("file %q: %w", n, err))
+		}
+	}
+	rs.checkIPs()
+
+	return rs
+}
+
+// add parses the data from file and indexes its records. An error is returned when the data cannot be parsed.
+// A key is either the MAC address of a record or the name of a machine with one or more interfaces.
+func (rs *records) add(w *Watcher, file string, b []byte) error {
+	r := make(map[string]json.RawMessage)
+	if err := yaml.Unmarshal(b, &r); err != nil {
+		return fmt.Errorf("%w: %w", err, errFileFormat)
+	}
+	keys := make([]string, 0, len(r))
+	for k := range r {
+		keys = append(keys, k)
+	}
+	// sort the keys so that validation errors are always reported in the same order.
+	slices.Sort(keys)
+	for _, k := range keys {
+		mac, err := net.ParseMAC(k)
+		if err != nil {
+			var m machine
+			if err := json.Unmarshal(r[k], &m); err != nil || len(m.Interfaces) == 0 {
+				rs.invalid = append(rs.invalid, recordError(file, k, fmt.Errorf("not a MAC address or a machine with interfaces: %w", errFileFormat)))
+				continue
+			}
+			rs.addMachine(w, file, k, m)
+			continue
+		}
+		var v dhcp
+		if err := json.Unmarshal(r[k], &v); err != nil {
+			rs.invalid = append(rs.invalid, recordError(file, k, fmt.Errorf("%w: %w", err, errFileFormat)))
+			continue
+		}
+		v.MACAddress = mac
+		rs.addRecord(w, file, k, v)
+	}
+
+	return nil
+}
+
+// addMachine indexes the interfaces of a machine.
+// The facility and relay agent information of the machine apply to all of its interfaces, the same as for a Hardware object.
+func (rs *records) addMachine(w *Watcher, file, name string, m machine) {
+	for i, v := range m.Interfaces {
+		key := fmt.Sprintf("%s interface %d", name, i)
+		mac, err := net.ParseMAC(v.MAC)
+		if err != nil {
+			rs.invalid = append(rs.invalid, recordError(file, key, fmt.Errorf("%w: %w", err, errFileFormat)))
+			continue
+		}
+		v.MACAddress = mac
+		if v.Netboot.Facility == "" {
+			v.Netboot.Facility = m.Facility
+		}
+		// Relayed requests are matched to the first interface of a machine, the same as for a Hardware object.
+		if i == 0 && v.RelayAgent.CircuitID == "" {
+			v.RelayAgent = m.RelayAgent
+		}
+		rs.addRecord(w, file, key, v)
+	}
+}
+
+// addRecord translates and indexes a single record.
+func (rs *records) addRecord(w *Watcher, file, key string, v dhcp) {
+	if dup, ok := rs.byMAC[v.MACAddress.String()]; ok {
+		rs.invalid = append(rs.invalid, recordError(file, key, fmt.Errorf("%w: also in %s", errDuplicateMAC, dup.source())))
+		return
+	}
+	rec := &record{file: file, key: key, mac: v.MACAddress.String(), relay: data.RelayAgent(v.RelayAgent)}
+	rec.dhcp, rec.netboot, rec.err = w.translate(v)
+	if rec.err != nil {
+		rs.invalid = append(rs.invalid, recordError(file, key, rec.err))
+	}
+	rs.byMAC[rec.mac] = rec
+	if rec.err == nil {
+		rs.byIP[rec.dhcp.IPAddress] = append(rs.byIP[rec.dhcp.IPAddress], rec)
+	}
+	if rec.relay.CircuitID != "" {
+		rs.byCircuitID[rec.relay.CircuitID] = append(rs.byCircuitID[rec.relay.CircuitID], rec)
+	}
+}
+
+// checkIPs adds a validation error for every IP address that more than one record has.
+func (rs *records) checkIPs() {
+	ips := make([]netip.Addr, 0, len(rs.byIP))
+	for ip, recs := range rs.byIP {
+		if len(recs) > 1 {
+			ips = append(ips, ip)
+		}
+	}
+	slices.SortFunc(ips, netip.Addr.Compare)
+	for _, ip := range ips {
+		rs.invalid = append(rs.invalid, fmt.Errorf("%w %v: %v", errDuplicateIP, ip, macs(rs.byIP[ip])))
+	}
+}
+
+// source describes where in the file, or files, the record is.
+func (r *record) source() string {
+	if r.file == "" {
+		return fmt.Sprintf("record %q", r.key)
+	}
+
+	return fmt.Sprintf("file %q: record %q", r.file, r.key)
+}
+
+func recordError(file, key string, err error) error {
+	if file == "" {
+		return fmt.Errorf("record %q: %w", key, err)
+	}
+
+	return fmt.Errorf("file %q: record %q: %w", file, key, err)
+}
+
+// Err returns the error of the file and the validation errors of its records.
+func (rs *records) Err() error {
+	return errors.Join(append([]error{rs.err}, rs.invalid...)...)
+}
+
+// data returns a copy of the DHCP and netboot data of a record, so that callers can change it.
+func (r *record) data() (*data.DHCP, *data.Netboot) {
+	d := *r.dhcp
+	d.MACAddress = slices.Clone(r.dhcp.MACAddress)
+	d.SubnetMask = slices.Clone(r.dhcp.SubnetMask)
+	d.NameServers = slices.Clone(r.dhcp.NameServers)
+	d.NTPServers = slices.Clone(r.dhcp.NTPServers)
+	d.DomainSearch = slices.Clone(r.dhcp.DomainSearch)
+	n := *r.netboot
+	if r.netboot.IPXEScriptURL != nil {
+		u := *r.netboot.IPXEScriptURL
+		n.IPXEScriptURL = &u
+	}
+	if r.netboot.OSIE.BaseURL != nil {
+		u := *r.netboot.OSIE.BaseURL
+		n.OSIE.BaseURL = &u
+	}
+
+	return &d, &n
+}
+
+func macs(recs []*record) []string {
+	m := make([]string, 0, len(recs))
+	for _, r := range recs {
+		if r.file != "" {
+			m = append(m, r.file+":"+r.mac)
+			continue
+		}
+		m = append(m, r.mac)
+	}
+	slices.Sort(m)
+
+	return m
+}
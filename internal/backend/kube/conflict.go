@@ -0,0 +1,241 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/smee/internal/metric"
+	"github.com/tinkerbell/tink/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ConflictPolicy decides which Hardware a lookup returns when more than one Hardware has the MAC or IP address.
+type ConflictPolicy string
+
+const (
+	// ConflictPolicyRefuse fails the lookup, so that none of the conflicting Hardware is served. It is the default.
+	ConflictPolicyRefuse ConflictPolicy = "refuse"
+	// ConflictPolicyOldest returns the Hardware that was created first.
+	ConflictPolicyOldest ConflictPolicy = "oldest"
+)
+
+// ParseConflictPolicy returns the ConflictPolicy named s. An empty s is ConflictPolicyRefuse.
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(s); p {
+	case "", ConflictPolicyRefuse:
+		return ConflictPolicyRefuse, nil
+	case ConflictPolicyOldest:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown conflict policy %q, must be one of %q or %q", s, ConflictPolicyRefuse, ConflictPolicyOldest)
+	}
+}
+
+// Reasons of the Events that are recorded on conflicting Hardware.
+const (
+	ReasonDuplicateMAC     = "DuplicateMACAddress"
+	ReasonDuplicateIP      = "DuplicateIPAddress"
+	ReasonConflictResolved = "ConflictResolved"
+)
+
+// Kinds of addresses that can conflict. They are used as the address label of metric.KubeHardwareConflicts.
+const (
+	addressMAC = "mac"
+	addressIP  = "ip"
+)
+
+type conflictError struct {
+	address string
+	value   string
+	names   []string
+}
+
+func (e conflictError) Error() string {
+	return fmt.Sprintf("%d hardware objects have %s %s: %s", len(e.names), e.address, e.value, strings.Join(e.names, ", "))
+}
+
+// resolve returns the Hardware of hws that a lookup of the address returns, according to the conflict policy.
+func (p ConflictPolicy) resolve(hws []v1alpha1.Hardware, address, value string) (*v1alpha1.Hardware, error) {
+	if len(hws) == 1 {
+		return &hws[0], nil
+	}
+	if p == ConflictPolicyOldest {
+		return oldest(hws), nil
+	}
+
+	return nil, conflictError{address: address, value: value, names: names(hws)}
+}
+
+// DetectConflicts records Events on the Hardware objects that have the same MAC or IP address as another Hardware,
+// and sets metric.KubeHardwareConflicts. Conflicts are detected when Hardware is added to, updated in or deleted from
+// the informer cache, not when it is looked up. It must be called before Start.
+//
+// The v1alpha1 Hardware status has no conditions, so conflicts are only visible as Events and in the metric.
+func (b *Backend) DetectConflicts(log logr.Logger) error {
+	inf, err := b.cluster.GetCache().GetInformer(context.Background(), &v1alpha1.Hardware{}, cache.BlockUntilSynced(false))
+	if err != nil {
+		return fmt.Errorf("failed to get the hardware informer: %w", err)
+	}
+	c := &conflicts{
+		reader:   b.cluster.GetClient(),
+		recorder: b.cluster.GetEventRecorderFor("smee"),
+		policy:   b.ConflictPolicy,
+		log:      log,
+	}
+	check := func(objs ...any) {
+		var hws []*v1alpha1.Hardware
+		for _, obj := range objs {
+			if d, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = d.Obj
+			}
+			if hw, ok := obj.(*v1alpha1.Hardware); ok {
+				hws = append(hws, hw)
+			}
+		}
+		c.check(context.Background(), hws...)
+	}
+	if _, err := inf.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) { check(obj) },
+		UpdateFunc: func(oldObj, newObj any) {
+			if specChanged(oldObj, newObj) {
+				check(oldObj, newObj)
+			}
+		},
+		DeleteFunc: func(obj any) { check(obj) },
+	}); err != nil {
+		return fmt.Errorf("failed to add the conflict event handler: %w", err)
+	}
+
+	return nil
+}
+
+// conflicts tracks the MAC and IP addresses that more than one Hardware has.
+type conflicts struct {
+	reader   client.Reader
+	recorder record.EventRecorder
+	policy   ConflictPolicy
+	log      logr.Logger
+
+	mu sync.Mutex // protects active
+	// active maps the conflicting addresses to the names of the Hardware that has them.
+	active map[conflictKey][]string
+}
+
+type conflictKey struct {
+	address string
+	value   string
+}
+
+// check updates the conflicts of the addresses of hws with the Hardware in the informer cache.
+// Events are recorded when the Hardware that has an address changes.
+func (c *conflicts) check(ctx context.Context, hws ...*v1alpha1.Hardware) {
+	var keys []conflictKey
+	for _, hw := range hws {
+		for _, mac := range GetMACs(hw) {
+			keys = append(keys, conflictKey{address: addressMAC, value: mac})
+		}
+		for _, ip := range GetIPs(hw) {
+			keys = append(keys, conflictKey{address: addressIP, value: ip})
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active == nil {
+		c.active = make(map[conflictKey][]string)
+	}
+	for _, k := range slices.Compact(sortKeys(keys)) {
+		index := MACAddrIndex
+		if k.address == addressIP {
+			index = IPAddrIndex
+		}
+		list := &v1alpha1.HardwareList{}
+		if err := c.reader.List(ctx, list, client.MatchingFields{index: k.value}); err != nil {
+			c.log.Error(err, "failed listing hardware to detect conflicts", "address", k.address, "value", k.value)
+			continue
+		}
+		current := names(list.Items)
+		previous, ok := c.active[k]
+		switch {
+		case len(current) > 1 && !slices.Equal(current, previous):
+			c.active[k] = current
+			c.log.Info("hardware conflict", "address", k.address, "value", k.value, "hardware", current, "policy", c.policy)
+			msg := fmt.Sprintf("%s address %s is used by %s, %s", k.address, k.value, strings.Join(current, ", "), c.resolution(list.Items))
+			reason := ReasonDuplicateMAC
+			if k.address == addressIP {
+				reason = ReasonDuplicateIP
+			}
+			for i := range list.Items {
+				c.recorder.Event(&list.Items[i], corev1.EventTypeWarning, reason, msg)
+			}
+		case len(current) <= 1 && ok:
+			delete(c.active, k)
+			c.log.Info("hardware conflict resolved", "address", k.address, "value", k.value)
+			for i := range list.Items {
+				c.recorder.Eventf(&list.Items[i], corev1.EventTypeNormal, ReasonConflictResolved, "%s address %s is no longer used by other hardware", k.address, k.value)
+			}
+		}
+	}
+
+	counts := map[string]float64{addressMAC: 0, addressIP: 0}
+	for k := range c.active {
+		counts[k.address]++
+	}
+	for address, n := range counts {
+		metric.KubeHardwareConflicts.WithLabelValues(address).Set(n)
+	}
+}
+
+// resolution describes what lookups of a conflicting address return.
+func (c *conflicts) resolution(hws []v1alpha1.Hardware) string {
+	if c.policy == ConflictPolicyOldest {
+		hw := oldest(hws)
+		return fmt.Sprintf("lookups return the oldest hardware %s/%s", hw.Namespace, hw.Name)
+	}
+
+	return "lookups of it are refused"
+}
+
+// oldest returns the Hardware of hws that was created first. Hardware created at the same time is ordered by name.
+func oldest(hws []v1alpha1.Hardware) *v1alpha1.Hardware {
+	o := &hws[0]
+	for i := range hws[1:] {
+		hw := &hws[i+1]
+		if hw.CreationTimestamp.Before(&o.CreationTimestamp) ||
+			(hw.CreationTimestamp.Equal(&o.CreationTimestamp) && hw.Namespace+"/"+hw.Name < o.Namespace+"/"+o.Name) {
+			o = hw
+		}
+	}
+
+	return o
+}
+
+// names returns the sorted namespace/name of hws.
+func names(hws []v1alpha1.Hardware) []string {
+	n := make([]string, 0, len(hws))
+	for _, hw := range hws {
+		n = append(n, hw.Namespace+"/"+hw.Name)
+	}
+	slices.Sort(n)
+
+	return n
+}
+
+func sortKeys(keys []conflictKey) []conflictKey {
+	slices.SortFunc(keys, func(a, b conflictKey) int {
+		if c := strings.Compare(a.address, b.address); c != 0 {
+			return c
+		}
+		return strings.Compare(a.value, b.value)
+	})
+
+	return keys
+}
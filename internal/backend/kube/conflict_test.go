@@ -0,0 +1,155 @@
+package kube
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tinkerbell/smee/internal/metric"
+	"github.com/tinkerbell/tink/api/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMain(m *testing.M) {
+	metric.Init()
+	os.Exit(m.Run())
+}
+
+func TestParseConflictPolicy(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		want    ConflictPolicy
+		wantErr bool
+	}{
+		"default": {want: ConflictPolicyRefuse},
+		"refuse":  {in: "refuse", want: ConflictPolicyRefuse},
+		"oldest":  {in: "oldest", want: ConflictPolicyOldest},
+		"unknown": {in: "newest", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseConflictPolicy(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseConflictPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseConflictPolicy() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	older := conflictHardware("b", "3c:ec:ef:4c:4f:54", "172.16.10.100", 0)
+	newer := conflictHardware("a", "3c:ec:ef:4c:4f:54", "172.16.10.101", time.Hour)
+	tests := map[string]struct {
+		policy   ConflictPolicy
+		hws      []v1alpha1.Hardware
+		wantName string
+		wantErr  bool
+	}{
+		"one hardware":   {hws: []v1alpha1.Hardware{newer}, wantName: "a"},
+		"refuse":         {hws: []v1alpha1.Hardware{newer, older}, wantErr: true},
+		"oldest":         {policy: ConflictPolicyOldest, hws: []v1alpha1.Hardware{newer, older}, wantName: "b"},
+		"oldest by name": {policy: ConflictPolicyOldest, hws: []v1alpha1.Hardware{older, conflictHardware("a", "3c:ec:ef:4c:4f:54", "", 0)}, wantName: "a"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			hw, err := tt.policy.resolve(tt.hws, addressMAC, "3c:ec:ef:4c:4f:54")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if hw.Name != tt.wantName {
+				t.Errorf("resolve() = %s, want %s", hw.Name, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	rs := runtime.NewScheme()
+	if err := scheme.AddToScheme(rs); err != nil {
+		t.Fatal(err)
+	}
+	if err := v1alpha1.AddToScheme(rs); err != nil {
+		t.Fatal(err)
+	}
+	cl := fake.NewClientBuilder().WithScheme(rs).WithIndex(&v1alpha1.Hardware{}, MACAddrIndex, MACAddrs).WithIndex(&v1alpha1.Hardware{}, IPAddrIndex, IPAddrs).Build()
+	recorder := record.NewFakeRecorder(10)
+	c := &conflicts{reader: cl, recorder: recorder, policy: ConflictPolicyOldest, log: logr.Discard()}
+	ctx := context.Background()
+
+	a := conflictHardware("a", "3c:ec:ef:4c:4f:54", "172.16.10.100", 0)
+	b := conflictHardware("b", "3c:ec:ef:4c:4f:54", "172.16.10.101", time.Hour)
+	steps := []struct {
+		name       string
+		change     func(*v1alpha1.Hardware) error
+		hw         *v1alpha1.Hardware
+		wantEvents []string
+		wantMAC    float64
+	}{
+		{name: "create a", change: func(hw *v1alpha1.Hardware) error { return cl.Create(ctx, hw) }, hw: &a},
+		{name: "create b with the mac of a", change: func(hw *v1alpha1.Hardware) error { return cl.Create(ctx, hw) }, hw: &b, wantMAC: 1, wantEvents: []string{
+			"Warning DuplicateMACAddress mac address 3c:ec:ef:4c:4f:54 is used by default/a, default/b, lookups return the oldest hardware default/a",
+			"Warning DuplicateMACAddress mac address 3c:ec:ef:4c:4f:54 is used by default/a, default/b, lookups return the oldest hardware default/a",
+		}},
+		{name: "update b", change: func(hw *v1alpha1.Hardware) error {
+			hw.Labels = map[string]string{"updated": "true"}
+			return cl.Update(ctx, hw)
+		}, hw: &b, wantMAC: 1},
+		{name: "delete b", change: func(hw *v1alpha1.Hardware) error { return cl.Delete(ctx, hw) }, hw: &b, wantEvents: []string{
+			"Normal ConflictResolved mac address 3c:ec:ef:4c:4f:54 is no longer used by other hardware",
+		}},
+	}
+	for _, s := range steps {
+		if err := s.change(s.hw); err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		c.check(ctx, s.hw)
+
+		var events []string
+		for len(recorder.Events) > 0 {
+			events = append(events, <-recorder.Events)
+		}
+		if diff := cmp.Diff(s.wantEvents, events); diff != "" {
+			t.Errorf("%s: events differ (-want +got):\n%s", s.name, diff)
+		}
+		if got := testutil.ToFloat64(metric.KubeHardwareConflicts.WithLabelValues(addressMAC)); got != s.wantMAC {
+			t.Errorf("%s: mac conflicts = %v, want %v", s.name, got, s.wantMAC)
+		}
+		if got := testutil.ToFloat64(metric.KubeHardwareConflicts.WithLabelValues(addressIP)); got != 0 {
+			t.Errorf("%s: ip conflicts = %v, want 0", s.name, got)
+		}
+	}
+}
+
+// conflictHardware returns Hardware in the default namespace with an interface that has mac and ip.
+// It was created age after the start of 2024.
+func conflictHardware(name, mac, ip string, age time.Duration) v1alpha1.Hardware {
+	hw := v1alpha1.Hardware{
+		ObjectMeta: v1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			CreationTimestamp: v1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(age)),
+		},
+		Spec: v1alpha1.HardwareSpec{
+			Interfaces: []v1alpha1.Interface{{DHCP: &v1alpha1.DHCP{MAC: mac}}},
+		},
+	}
+	if ip != "" {
+		hw.Spec.Interfaces[0].DHCP.IP = &v1alpha1.IP{Address: ip}
+	}
+
+	return hw
+}
@@ -51,3 +51,28 @@ func GetIPs(h *v1alpha1.Hardware) []string {
 	}
 	return ips
 }
+
+// Annotations on a Hardware object that hold the relay agent information (DHCP option 82) of the switch port
+// the hardware is connected to.
+const (
+	// RelayCircuitIDAnnotation holds the relay agent circuit ID, DHCP option 82.1.
+	RelayCircuitIDAnnotation = "smee.tinkerbell.org/relay-circuit-id"
+	// RelayRemoteIDAnnotation holds the relay agent remote ID, DHCP option 82.2. It is optional.
+	RelayRemoteIDAnnotation = "smee.tinkerbell.org/relay-remote-id"
+)
+
+// RelayCircuitIDIndex is an index used with a controller-runtime client to lookup hardware by relay agent circuit ID.
+const RelayCircuitIDIndex = ".Metadata.Annotations.RelayCircuitID"
+
+// RelayCircuitIDs returns the relay agent circuit ID of a Hardware object.
+func RelayCircuitIDs(obj client.Object) []string {
+	hw, ok := obj.(*v1alpha1.Hardware)
+	if !ok {
+		return nil
+	}
+	if id := hw.Annotations[RelayCircuitIDAnnotation]; id != "" {
+		return []string{id}
+	}
+
+	return nil
+}
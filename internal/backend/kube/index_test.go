@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/tinkerbell/tink/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -90,3 +91,24 @@ func TestIPAddrs(t *testing.T) {
 		})
 	}
 }
+
+func TestRelayCircuitIDs(t *testing.T) {
+	tests := map[string]struct {
+		hw   client.Object
+		want []string
+	}{
+		"not a v1alpha1.Hardware object": {hw: &v1alpha1.Workflow{}, want: nil},
+		"circuit id": {hw: &v1alpha1.Hardware{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{RelayCircuitIDAnnotation: "Ethernet1/1"}},
+		}, want: []string{"Ethernet1/1"}},
+		"no annotations": {hw: &v1alpha1.Hardware{}, want: nil},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := RelayCircuitIDs(tc.hw)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected circuit IDs (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
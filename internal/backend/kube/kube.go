@@ -8,6 +8,7 @@ import (
 	"net"
 	"net/netip"
 	"net/url"
+	"sync"
 
 	"github.com/ccoveille/go-safecast"
 	"github.com/tinkerbell/smee/internal/dhcp/data"
@@ -15,6 +16,8 @@ import (
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/codes"
 	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/cluster"
 )
@@ -23,16 +26,36 @@ const tracerName = "github.com/tinkerbell/smee/dhcp"
 
 // Backend is a backend implementation that uses the Tinkerbell CRDs to get DHCP data.
 type Backend struct {
+	// ConflictPolicy decides which Hardware a lookup returns when more than one Hardware has the MAC or IP address.
+	// The zero value is ConflictPolicyRefuse.
+	ConflictPolicy ConflictPolicy
+
 	cluster cluster.Cluster
+	// requireWorkflows is set by RequireWorkflows.
+	requireWorkflows bool
+	workflowMu       sync.Mutex // protects onWorkflowChange
+	// onWorkflowChange are the functions registered with OnChange, that are called when a Workflow changes.
+	onWorkflowChange []func(macs ...net.HardwareAddr)
+	// namespaces are the namespaces of the cache, all namespaces when it is empty.
+	namespaces []string
+	readiness  readiness
 }
 
 // NewBackend returns a controller-runtime cluster.Cluster with the Tinkerbell runtime
 // scheme registered, and indexers for:
 // * Hardware by MAC address
 // * Hardware by IP address
+// * Hardware by relay agent circuit ID
 //
 // Callers must instantiate the client-side cache by calling Start() before use.
 func NewBackend(conf *rest.Config, opts ...cluster.Option) (*Backend, error) {
+	b := &Backend{}
+	opts = append(opts, func(o *cluster.Options) {
+		for ns := range o.Cache.DefaultNamespaces {
+			b.namespaces = append(b.namespaces, ns)
+		}
+		o.Cache.DefaultWatchErrorHandler = b.readiness.watchErrorHandler
+	})
 	c, err := cluster.New(conf, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new cluster config: %w", err)
@@ -46,12 +69,84 @@ func NewBackend(conf *rest.Config, opts ...cluster.Option) (*Backend, error) {
 		return nil, fmt.Errorf("failed to setup indexer(.spec.interfaces.dhcp.ip.address): %w", err)
 	}
 
-	return &Backend{cluster: c}, nil
+	if err := c.GetFieldIndexer().IndexField(context.Background(), &v1alpha1.Hardware{}, RelayCircuitIDIndex, RelayCircuitIDs); err != nil {
+		return nil, fmt.Errorf("failed to setup indexer(relay circuit id): %w", err)
+	}
+
+	b.cluster = c
+
+	return b, nil
 }
 
-// Start starts the client-side cache.
+// Start starts the client-side cache. It blocks until ctx is canceled or the cache fails.
+// After the cache failed, Ready reports the error.
 func (b *Backend) Start(ctx context.Context) error {
-	return b.cluster.Start(ctx)
+	err := b.cluster.Start(ctx)
+	if err != nil {
+		b.readiness.failed(err)
+	}
+
+	return err
+}
+
+// OnChange implements the handler.ChangeNotifier interface. f is called with the MAC addresses of the Hardware objects
+// that are added, updated or deleted, and when workflows are required, of the Hardware of the Workflows that change.
+func (b *Backend) OnChange(f func(macs ...net.HardwareAddr)) {
+	inf, err := b.cluster.GetCache().GetInformer(context.Background(), &v1alpha1.Hardware{}, cache.BlockUntilSynced(false))
+	if err != nil {
+		// Without the informer, f is never called and a cache in front of the backend only expires.
+		return
+	}
+	notify := func(objs ...any) {
+		var macs []net.HardwareAddr
+		for _, obj := range objs {
+			hw, ok := obj.(*v1alpha1.Hardware)
+			if !ok {
+				// A deleted object whose final state is unknown, any hardware may have changed.
+				f()
+				return
+			}
+			for _, m := range GetMACs(hw) {
+				if mac, err := net.ParseMAC(m); err == nil {
+					macs = append(macs, mac)
+				}
+			}
+		}
+		if len(macs) > 0 {
+			f(macs...)
+		}
+	}
+	_, _ = inf.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) { notify(obj) },
+		UpdateFunc: func(oldObj, newObj any) {
+			if specChanged(oldObj, newObj) {
+				notify(oldObj, newObj)
+			}
+		},
+		DeleteFunc: func(obj any) { notify(obj) },
+	})
+
+	if b.requireWorkflows {
+		b.workflowMu.Lock()
+		defer b.workflowMu.Unlock()
+		b.onWorkflowChange = append(b.onWorkflowChange, f)
+	}
+}
+
+// specChanged reports whether an update of a Hardware object changed its spec. The generation of a Hardware object
+// only changes with its spec, not with its metadata or status, for example the annotations that record the boot
+// progress of a machine. Updates of objects that are not Hardware are reported as changed.
+func specChanged(oldObj, newObj any) bool {
+	o, ok := oldObj.(*v1alpha1.Hardware)
+	if !ok {
+		return true
+	}
+	n, ok := newObj.(*v1alpha1.Hardware)
+	if !ok {
+		return true
+	}
+
+	return o.Generation != n.Generation
 }
 
 // GetByMac implements the handler.BackendReader interface and returns DHCP and netboot data based on a mac address.
@@ -74,28 +169,34 @@ func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHC
 		return nil, nil, err
 	}
 
-	if len(hardwareList.Items) > 1 {
-		err := fmt.Errorf("got %d hardware objects for mac %s, expected only 1", len(hardwareList.Items), mac)
+	hw, err := b.ConflictPolicy.resolve(hardwareList.Items, addressMAC, mac.String())
+	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
 
 		return nil, nil, err
 	}
 
 	i := v1alpha1.Interface{}
-	for _, iface := range hardwareList.Items[0].Spec.Interfaces {
+	for _, iface := range hw.Spec.Interfaces {
 		if iface.DHCP.MAC == mac.String() {
 			i = iface
 			break
 		}
 	}
 
-	d, n, err := transform(i, hardwareList.Items[0].Spec.Metadata)
+	d, n, err := transform(i, hw.Spec.Metadata)
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
 
 		return nil, nil, err
 	}
 
+	if err := b.gateOnWorkflows(ctx, hw, n); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, err
+	}
+
 	span.SetAttributes(d.EncodeToAttributes()...)
 	span.SetAttributes(n.EncodeToAttributes()...)
 	span.SetStatus(codes.Ok, "")
@@ -123,28 +224,99 @@ func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Net
 		return nil, nil, err
 	}
 
-	if len(hardwareList.Items) > 1 {
-		err := fmt.Errorf("got %d hardware objects for ip: %s, expected only 1", len(hardwareList.Items), ip)
+	hw, err := b.ConflictPolicy.resolve(hardwareList.Items, addressIP, ip.String())
+	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
 
 		return nil, nil, err
 	}
 
 	i := v1alpha1.Interface{}
-	for _, iface := range hardwareList.Items[0].Spec.Interfaces {
+	for _, iface := range hw.Spec.Interfaces {
 		if iface.DHCP.IP.Address == ip.String() {
 			i = iface
 			break
 		}
 	}
 
-	d, n, err := transform(i, hardwareList.Items[0].Spec.Metadata)
+	d, n, err := transform(i, hw.Spec.Metadata)
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
 
 		return nil, nil, err
 	}
 
+	if err := b.gateOnWorkflows(ctx, hw, n); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, err
+	}
+
+	span.SetAttributes(d.EncodeToAttributes()...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// GetByRelayAgent implements the handler.RelayAgentReader interface and returns DHCP and netboot data based on
+// the relay agent information of a request. Hardware is matched by its RelayCircuitIDAnnotation and, when set,
+// its RelayRemoteIDAnnotation. The data of the first interface with DHCP data is returned.
+func (b *Backend) GetByRelayAgent(ctx context.Context, ra data.RelayAgent) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.kube.GetByRelayAgent")
+	defer span.End()
+	hardwareList := &v1alpha1.HardwareList{}
+
+	if err := b.cluster.GetClient().List(ctx, hardwareList, &client.MatchingFields{RelayCircuitIDIndex: ra.CircuitID}); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, fmt.Errorf("failed listing hardware for circuit id (%v): %w", ra.CircuitID, err)
+	}
+
+	var hws []v1alpha1.Hardware
+	for _, hw := range hardwareList.Items {
+		r := data.RelayAgent{CircuitID: hw.Annotations[RelayCircuitIDAnnotation], RemoteID: hw.Annotations[RelayRemoteIDAnnotation]}
+		if r.Matches(ra) {
+			hws = append(hws, hw)
+		}
+	}
+
+	if len(hws) == 0 {
+		err := hardwareNotFoundError{}
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, err
+	}
+
+	if len(hws) > 1 {
+		err := fmt.Errorf("got %d hardware objects for circuit id %q and remote id %q, expected only 1", len(hws), ra.CircuitID, ra.RemoteID)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, err
+	}
+
+	i := v1alpha1.Interface{}
+	for _, iface := range hws[0].Spec.Interfaces {
+		if iface.DHCP != nil {
+			i = iface
+			break
+		}
+	}
+
+	d, n, err := transform(i, hws[0].Spec.Metadata)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, err
+	}
+
+	if err := b.gateOnWorkflows(ctx, &hws[0], n); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, err
+	}
+
 	span.SetAttributes(d.EncodeToAttributes()...)
 	span.SetAttributes(n.EncodeToAttributes()...)
 	span.SetStatus(codes.Ok, "")
@@ -154,7 +326,7 @@ func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Net
 
 // toDHCPData converts a v1alpha1.DHCP to a data.DHCP data structure.
 // if required fields are missing, an error is returned.
-// Required fields: v1alpha1.Interface.DHCP.MAC, v1alpha1.Interface.DHCP.IP.Address, v1alpha1.Interface.DHCP.IP.Netmask.
+// Required fields: v1alpha1.Interface.DHCP.MAC, v1alpha1.Interface.DHCP.IP.Address.
 func toDHCPData(h *v1alpha1.DHCP) (*data.DHCP, error) {
 	if h == nil {
 		return nil, errors.New("no DHCP data")
@@ -172,12 +344,14 @@ func toDHCPData(h *v1alpha1.DHCP) (*data.DHCP, error) {
 		if d.IPAddress, err = netip.ParseAddr(h.IP.Address); err != nil {
 			return nil, err
 		}
-		// Netmask is required
-		sm := net.ParseIP(h.IP.Netmask)
-		if sm == nil {
-			return nil, errors.New("no netmask")
+		// Netmask is optional, it can come from the subnet defaults of the DHCP server, but must be valid if present
+		if h.IP.Netmask != "" {
+			sm := net.ParseIP(h.IP.Netmask)
+			if sm == nil {
+				return nil, errors.New("invalid netmask")
+			}
+			d.SubnetMask = net.IPMask(sm.To4())
 		}
-		d.SubnetMask = net.IPMask(sm.To4())
 	} else {
 		return nil, errors.New("no IP data")
 	}
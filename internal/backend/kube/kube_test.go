@@ -81,7 +81,14 @@ func TestToDHCPData(t *testing.T) {
 			shouldErr: true,
 		},
 		"no subnet": {
-			in:        &v1alpha1.DHCP{MAC: "aa:bb:cc:dd:ee:ff", IP: &v1alpha1.IP{Address: "192.168.2.4"}},
+			in: &v1alpha1.DHCP{MAC: "aa:bb:cc:dd:ee:ff", IP: &v1alpha1.IP{Address: "192.168.2.4"}},
+			want: &data.DHCP{
+				MACAddress: net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff},
+				IPAddress:  netip.MustParseAddr("192.168.2.4"),
+			},
+		},
+		"bad subnet": {
+			in:        &v1alpha1.DHCP{MAC: "aa:bb:cc:dd:ee:ff", IP: &v1alpha1.IP{Address: "192.168.2.4", Netmask: "bad"}},
 			shouldErr: true,
 		},
 		"v1alpha1.IP == nil": {
@@ -274,6 +281,7 @@ func TestGetByMac(t *testing.T) {
 		wantNetboot *data.Netboot
 		shouldErr   bool
 		failToList  bool
+		policy      ConflictPolicy
 	}{
 		"empty hardware list":    {shouldErr: true},
 		"more than one hardware": {shouldErr: true, hwObject: []v1alpha1.Hardware{hwObject1, hwObject2}},
@@ -299,6 +307,25 @@ func TestGetByMac(t *testing.T) {
 			},
 			Facility: "onprem",
 		}},
+		"more than one hardware, oldest wins": {policy: ConflictPolicyOldest, hwObject: []v1alpha1.Hardware{hwObject2, hwObject1}, wantDHCP: &data.DHCP{
+			MACAddress:     net.HardwareAddr{0x3c, 0xec, 0xef, 0x4c, 0x4f, 0x54},
+			IPAddress:      netip.MustParseAddr("172.16.10.100"),
+			SubnetMask:     []byte{0xff, 0xff, 0xff, 0x00},
+			DefaultGateway: netip.MustParseAddr("255.255.255.0"),
+			NameServers: []net.IP{
+				{0x1, 0x1, 0x1, 0x1},
+			},
+			Hostname:  "sm01",
+			LeaseTime: 86400,
+			Arch:      "x86_64",
+		}, wantNetboot: &data.Netboot{
+			AllowNetboot: true,
+			IPXEScriptURL: &url.URL{
+				Scheme: "http",
+				Host:   "netboot.xyz",
+			},
+			Facility: "onprem",
+		}},
 	}
 
 	for name, tc := range tests {
@@ -346,6 +373,7 @@ func TestGetByMac(t *testing.T) {
 			if err != nil {
 				t.Fatal(err)
 			}
+			b.ConflictPolicy = tc.policy
 
 			go b.Start(context.Background())
 			gotDHCP, gotNetboot, err := b.GetByMac(context.Background(), net.HardwareAddr{0x3c, 0xec, 0xef, 0x4c, 0x4f, 0x54})
@@ -365,6 +393,94 @@ func TestGetByMac(t *testing.T) {
 	}
 }
 
+func TestGetByRelayAgent(t *testing.T) {
+	relayed := func(hw v1alpha1.Hardware, name, circuitID, remoteID string) v1alpha1.Hardware {
+		hw = *hw.DeepCopy()
+		hw.Name = name
+		hw.Annotations = map[string]string{RelayCircuitIDAnnotation: circuitID}
+		if remoteID != "" {
+			hw.Annotations[RelayRemoteIDAnnotation] = remoteID
+		}
+		return hw
+	}
+	tests := map[string]struct {
+		hwObject  []v1alpha1.Hardware
+		relay     data.RelayAgent
+		wantMAC   net.HardwareAddr
+		shouldErr bool
+	}{
+		"no hardware": {relay: data.RelayAgent{CircuitID: "Ethernet1/1"}, shouldErr: true},
+		"circuit id": {
+			hwObject: []v1alpha1.Hardware{relayed(hwObject1, "machine1", "Ethernet1/1", "")},
+			relay:    data.RelayAgent{CircuitID: "Ethernet1/1", RemoteID: "switch01"},
+			wantMAC:  net.HardwareAddr{0x3c, 0xec, 0xef, 0x4c, 0x4f, 0x54},
+		},
+		"circuit and remote id": {
+			hwObject: []v1alpha1.Hardware{relayed(hwObject1, "machine1", "Ethernet1/1", "switch01"), relayed(hwObject1, "machine2", "Ethernet1/1", "switch02")},
+			relay:    data.RelayAgent{CircuitID: "Ethernet1/1", RemoteID: "switch01"},
+			wantMAC:  net.HardwareAddr{0x3c, 0xec, 0xef, 0x4c, 0x4f, 0x54},
+		},
+		"remote id mismatch": {
+			hwObject:  []v1alpha1.Hardware{relayed(hwObject1, "machine1", "Ethernet1/1", "switch02")},
+			relay:     data.RelayAgent{CircuitID: "Ethernet1/1", RemoteID: "switch01"},
+			shouldErr: true,
+		},
+		"more than one hardware": {
+			hwObject:  []v1alpha1.Hardware{relayed(hwObject1, "machine1", "Ethernet1/1", ""), relayed(hwObject1, "machine2", "Ethernet1/1", "")},
+			relay:     data.RelayAgent{CircuitID: "Ethernet1/1"},
+			shouldErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			rs := runtime.NewScheme()
+			if err := scheme.AddToScheme(rs); err != nil {
+				t.Fatal(err)
+			}
+			if err := v1alpha1.AddToScheme(rs); err != nil {
+				t.Fatal(err)
+			}
+
+			ct := fake.NewClientBuilder().WithScheme(rs).WithIndex(&v1alpha1.Hardware{}, RelayCircuitIDIndex, RelayCircuitIDs)
+			for i := range tc.hwObject {
+				ct = ct.WithObjects(&tc.hwObject[i])
+			}
+			cl := ct.Build()
+
+			fn := func(o *cluster.Options) {
+				o.NewClient = func(*rest.Config, client.Options) (client.Client, error) {
+					return cl, nil
+				}
+				o.MapperProvider = func(*rest.Config, *http.Client) (meta.RESTMapper, error) {
+					return cl.RESTMapper(), nil
+				}
+				o.NewCache = func(*rest.Config, cache.Options) (cache.Cache, error) {
+					return &informertest.FakeInformers{Scheme: cl.Scheme()}, nil
+				}
+			}
+			b, err := NewBackend(new(rest.Config), fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d, _, err := b.GetByRelayAgent(context.Background(), tc.relay)
+			if tc.shouldErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.wantMAC, d.MACAddress); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
 var hwObject1 = v1alpha1.Hardware{
 	TypeMeta: v1.TypeMeta{
 		Kind:       "Hardware",
@@ -588,3 +704,27 @@ var badNetbootObject2 = v1alpha1.Hardware{
 		},
 	},
 }
+
+func TestSpecChanged(t *testing.T) {
+	hw := func(generation int64, annotations map[string]string) *v1alpha1.Hardware {
+		return &v1alpha1.Hardware{ObjectMeta: v1.ObjectMeta{Name: "machine1", Generation: generation, Annotations: annotations}}
+	}
+	tests := map[string]struct {
+		oldObj any
+		newObj any
+		want   bool
+	}{
+		"spec changed":        {oldObj: hw(1, nil), newObj: hw(2, nil), want: true},
+		"annotations changed": {oldObj: hw(1, nil), newObj: hw(1, map[string]string{"progress": "dhcp-ack"})},
+		"no change":           {oldObj: hw(1, nil), newObj: hw(1, nil)},
+		"not a hardware":      {oldObj: "machine1", newObj: hw(1, nil), want: true},
+		"new is not hardware": {oldObj: hw(1, nil), newObj: nil, want: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := specChanged(tt.oldObj, tt.newObj); got != tt.want {
+				t.Errorf("specChanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// LeaderElection is the configuration for leader election on a coordination.k8s.io/v1 Lease.
+type LeaderElection struct {
+	// Namespace is the namespace of the Lease.
+	Namespace string
+	// Name is the name of the Lease.
+	Name string
+	// Identity is the unique identity of this replica, written to the Lease when it is the leader.
+	Identity string
+	// LeaseDuration is how long standby replicas wait before taking over a Lease that is not renewed.
+	// Defaults to 15 seconds.
+	LeaseDuration time.Duration
+	// RenewDeadline is how long the leader retries renewing the Lease before it gives up leadership.
+	// Defaults to 10 seconds.
+	RenewDeadline time.Duration
+	// RetryPeriod is how long replicas wait between attempts to acquire or renew the Lease.
+	// Defaults to 2 seconds.
+	RetryPeriod time.Duration
+	// OnLeaderChange is called with true when this replica becomes the leader and with false when it stops being the leader.
+	OnLeaderChange func(leader bool)
+}
+
+func (l *LeaderElection) setDefaults() {
+	if l.LeaseDuration == 0 {
+		l.LeaseDuration = 15 * time.Second
+	}
+	if l.RenewDeadline == 0 {
+		l.RenewDeadline = 10 * time.Second
+	}
+	if l.RetryPeriod == 0 {
+		l.RetryPeriod = 2 * time.Second
+	}
+	if l.OnLeaderChange == nil {
+		l.OnLeaderChange = func(bool) {}
+	}
+}
+
+// RunLeaderElection takes part in leader election on a Lease with the credentials of the backend's cluster.
+// Leadership is released when ctx is canceled. When leadership is lost, this replica rejoins the election.
+// It blocks until ctx is canceled.
+func (b *Backend) RunLeaderElection(ctx context.Context, l LeaderElection) error {
+	if l.Namespace == "" || l.Name == "" || l.Identity == "" {
+		return errors.New("leader election requires a lease namespace, name and identity")
+	}
+	l.setDefaults()
+
+	lock, err := resourcelock.NewFromKubeconfig(resourcelock.LeasesResourceLock, l.Namespace, l.Name, resourcelock.ResourceLockConfig{Identity: l.Identity}, b.cluster.GetConfig(), l.RenewDeadline)
+	if err != nil {
+		return fmt.Errorf("failed to create lease lock: %w", err)
+	}
+	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   l.LeaseDuration,
+		RenewDeadline:   l.RenewDeadline,
+		RetryPeriod:     l.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            l.Name,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) { l.OnLeaderChange(true) },
+			OnStoppedLeading: func() { l.OnLeaderChange(false) },
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create leader elector: %w", err)
+	}
+
+	// Run returns when leadership is lost, so run it again until ctx is canceled.
+	for ctx.Err() == nil {
+		le.Run(ctx)
+	}
+
+	return nil
+}
+
+// Membership is the configuration for tracking which replicas are running by the coordination.k8s.io/v1 Leases
+// they hold. Every replica holds a Lease of its own, and a replica whose Lease is not renewed is not running.
+type Membership struct {
+	// Namespace is the namespace of the Leases.
+	Namespace string
+	// Names are the names of the Leases, one per replica.
+	Names []string
+	// Index is the index in Names of the Lease of this replica.
+	Index int
+	// Identity is the unique identity of this replica, written to its Lease.
+	Identity string
+	// CheckPeriod is how often the Leases of the other replicas are read. Defaults to 2 seconds.
+	CheckPeriod time.Duration
+	// OnChange is called with whether each replica holds its Lease, in the order of Names, when that changes.
+	// It is called with nil when the Leases cannot be read.
+	OnChange func(held []bool)
+}
+
+// RunMembership holds the Lease of this replica and reports which replicas hold their Lease to m.OnChange.
+// The Lease of this replica is released when ctx is canceled. It blocks until ctx is canceled.
+func (b *Backend) RunMembership(ctx context.Context, m Membership) error {
+	if m.Namespace == "" || m.Identity == "" || m.Index < 0 || m.Index >= len(m.Names) {
+		return errors.New("membership requires a lease namespace, identity and a lease name for this replica")
+	}
+	if m.CheckPeriod == 0 {
+		m.CheckPeriod = 2 * time.Second
+	}
+	if m.OnChange == nil {
+		m.OnChange = func([]bool) {}
+	}
+	cs, err := kubernetes.NewForConfig(b.cluster.GetConfig())
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	le := LeaderElection{Namespace: m.Namespace, Name: m.Names[m.Index], Identity: m.Identity}
+	errs := make(chan error, 1)
+	go func() { errs <- b.RunLeaderElection(ctx, le) }()
+
+	t := time.NewTicker(m.CheckPeriod)
+	defer t.Stop()
+	var last []bool
+	reported := false
+	for {
+		var held []bool
+		if leases, err := getLeases(ctx, cs, m.Namespace, m.Names); err == nil {
+			held = heldLeases(leases, time.Now())
+		}
+		if !reported || !slices.Equal(held, last) {
+			m.OnChange(held)
+			last, reported = held, true
+		}
+
+		select {
+		case <-ctx.Done():
+			return <-errs
+		case err := <-errs:
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+// getLeases returns the Leases with the given names in namespace. Leases that do not exist are nil.
+func getLeases(ctx context.Context, cs kubernetes.Interface, namespace string, names []string) ([]*coordinationv1.Lease, error) {
+	leases := make([]*coordinationv1.Lease, len(names))
+	for i, name := range names {
+		l, err := cs.CoordinationV1().Leases(namespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		leases[i] = l
+	}
+
+	return leases, nil
+}
+
+// heldLeases reports whether each Lease has a holder that renewed it within its lease duration at now.
+// A nil Lease does not exist and is not held.
+func heldLeases(leases []*coordinationv1.Lease, now time.Time) []bool {
+	held := make([]bool, len(leases))
+	for i, l := range leases {
+		if l == nil || l.Spec.HolderIdentity == nil || *l.Spec.HolderIdentity == "" || l.Spec.RenewTime == nil || l.Spec.LeaseDurationSeconds == nil {
+			continue
+		}
+		expires := l.Spec.RenewTime.Add(time.Duration(*l.Spec.LeaseDurationSeconds) * time.Second)
+		held[i] = expires.After(now)
+	}
+
+	return held
+}
@@ -0,0 +1,95 @@
+package kube
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRunLeaderElectionInvalid(t *testing.T) {
+	tests := map[string]LeaderElection{
+		"no namespace": {Name: "smee-dhcp", Identity: "smee-0"},
+		"no name":      {Namespace: "tink-system", Identity: "smee-0"},
+		"no identity":  {Namespace: "tink-system", Name: "smee-dhcp"},
+	}
+	for name, le := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := &Backend{}
+			if err := b.RunLeaderElection(context.Background(), le); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestLeaderElectionDefaults(t *testing.T) {
+	le := LeaderElection{RetryPeriod: time.Second}
+	le.setDefaults()
+	want := LeaderElection{LeaseDuration: 15 * time.Second, RenewDeadline: 10 * time.Second, RetryPeriod: time.Second}
+	if diff := cmp.Diff(want, le, cmpopts.IgnoreFields(LeaderElection{}, "OnLeaderChange")); diff != "" {
+		t.Fatal(diff)
+	}
+	if le.OnLeaderChange == nil {
+		t.Fatal("OnLeaderChange is nil")
+	}
+}
+
+func TestRunMembershipInvalid(t *testing.T) {
+	tests := map[string]Membership{
+		"no namespace":    {Names: []string{"smee-dhcp-0"}, Identity: "smee-0"},
+		"no identity":     {Namespace: "tink-system", Names: []string{"smee-dhcp-0"}},
+		"no lease names":  {Namespace: "tink-system", Identity: "smee-0"},
+		"index too large": {Namespace: "tink-system", Names: []string{"smee-dhcp-0"}, Index: 1, Identity: "smee-0"},
+	}
+	for name, m := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := &Backend{}
+			if err := b.RunMembership(context.Background(), m); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestHeldLeases(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	leaseDuration := int32(15)
+	lease := func(holder string, renewed time.Duration) *coordinationv1.Lease {
+		return &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: "smee-dhcp-0", Namespace: "tink-system"},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       ptr(holder),
+				RenewTime:            &metav1.MicroTime{Time: now.Add(-renewed)},
+				LeaseDurationSeconds: &leaseDuration,
+			},
+		}
+	}
+	leases := []*coordinationv1.Lease{
+		lease("smee-0", time.Second),
+		lease("smee-1", time.Minute),
+		lease("", time.Second),
+		nil,
+		{Spec: coordinationv1.LeaseSpec{HolderIdentity: ptr("smee-4")}},
+	}
+	want := []bool{true, false, false, false, false}
+	if diff := cmp.Diff(want, heldLeases(leases, now)); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestGetLeases(t *testing.T) {
+	cs := fake.NewClientset(&coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Name: "smee-dhcp-1", Namespace: "tink-system"}})
+	got, err := getLeases(context.Background(), cs, "tink-system", []string{"smee-dhcp-0", "smee-dhcp-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != nil || got[1] == nil || got[1].Name != "smee-dhcp-1" {
+		t.Fatalf("getLeases() = %v, want only smee-dhcp-1", got)
+	}
+}
@@ -0,0 +1,171 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
+	"github.com/tinkerbell/tink/api/v1alpha1"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ProgressAnnotationPrefix is the prefix of the annotations on a Hardware object that hold its boot progress.
+// smee.tinkerbell.org/last-<stage> is the RFC 3339 time the stage was last reached,
+// and smee.tinkerbell.org/last-<stage>-detail describes it, for example with the name of the iPXE binary that was served.
+const ProgressAnnotationPrefix = "smee.tinkerbell.org/last-"
+
+// progressQueueSize is the number of progress records that wait to be written. Records are dropped when it is full.
+const progressQueueSize = 256
+
+// ProgressWriter writes the boot progress of machines to the annotations of their Hardware objects.
+// It implements the handler.ProgressRecorder interface.
+//
+// The v1alpha1 Hardware status only has the state that is owned by Tink, so the progress is written to annotations.
+// Writes are rate limited, both per Hardware and stage and in total, so that the retransmits of a DHCP client and the
+// thousands of range requests of an ISO mount do not flood the API server.
+type ProgressWriter struct {
+	client  client.Client
+	policy  ConflictPolicy
+	log     logr.Logger
+	limiter *rate.Limiter
+	// interval is the minimum time between two writes of the same stage of a machine.
+	interval time.Duration
+	queue    chan progress
+
+	mu sync.Mutex // protects last
+	// last is when a stage of a machine was last queued, by the machine's mac or IP address and the stage.
+	last map[string]progress
+	// now returns the current time, it is replaced in tests.
+	now func() time.Time
+}
+
+// progress is a stage a machine reached.
+type progress struct {
+	mac    net.HardwareAddr
+	ip     net.IP
+	stage  handler.Stage
+	detail string
+	time   time.Time
+}
+
+// NewProgressWriter returns a ProgressWriter that writes the boot progress to the Hardware of the backend.
+// The same stage of a machine is written at most once per interval, unless its detail changes, and there are at most
+// qps writes per second in total. Callers must call Start to write the progress.
+func (b *Backend) NewProgressWriter(interval time.Duration, qps float64, log logr.Logger) *ProgressWriter {
+	return newProgressWriter(b.cluster.GetClient(), b.ConflictPolicy, interval, qps, log)
+}
+
+func newProgressWriter(c client.Client, policy ConflictPolicy, interval time.Duration, qps float64, log logr.Logger) *ProgressWriter {
+	burst := int(qps)
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &ProgressWriter{
+		client:   c,
+		policy:   policy,
+		log:      log,
+		limiter:  rate.NewLimiter(rate.Limit(qps), burst),
+		interval: interval,
+		queue:    make(chan progress, progressQueueSize),
+		last:     make(map[string]progress),
+	}
+}
+
+// RecordProgress implements the handler.ProgressRecorder interface. The progress is queued to be written by Start.
+func (w *ProgressWriter) RecordProgress(_ context.Context, mac net.HardwareAddr, ip net.IP, stage handler.Stage, detail string) {
+	p := progress{mac: mac, ip: ip, stage: stage, detail: detail, time: w.timeNow()}
+	key := ip.String() + "/" + string(stage)
+	if mac != nil {
+		key = mac.String() + "/" + string(stage)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if l, ok := w.last[key]; ok && l.detail == detail && p.time.Sub(l.time) < w.interval {
+		return
+	}
+	select {
+	case w.queue <- p:
+		w.last[key] = p
+	default:
+		w.log.V(1).Info("dropping boot progress, too many writes are queued", "mac", mac, "ip", ip, "stage", stage)
+	}
+	if len(w.last) > progressQueueSize*16 {
+		for k, l := range w.last {
+			if p.time.Sub(l.time) >= w.interval {
+				delete(w.last, k)
+			}
+		}
+	}
+}
+
+// Start writes the queued progress until ctx is done.
+func (w *ProgressWriter) Start(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case p := <-w.queue:
+			if err := w.limiter.Wait(ctx); err != nil {
+				return
+			}
+			if err := w.write(ctx, p); err != nil {
+				w.log.Error(err, "failed to write boot progress", "mac", p.mac, "ip", p.ip, "stage", p.stage)
+			}
+		}
+	}
+}
+
+// write patches the annotations of the Hardware of the machine with p. Machines without Hardware are ignored.
+func (w *ProgressWriter) write(ctx context.Context, p progress) error {
+	list := &v1alpha1.HardwareList{}
+	address, value, index := addressMAC, p.mac.String(), MACAddrIndex
+	if p.mac == nil {
+		address, value, index = addressIP, p.ip.String(), IPAddrIndex
+	}
+	if err := w.client.List(ctx, list, client.MatchingFields{index: value}); err != nil {
+		return err
+	}
+	if len(list.Items) == 0 {
+		return nil
+	}
+	hw, err := w.policy.resolve(list.Items, address, value)
+	if err != nil {
+		return err
+	}
+
+	// A JSON merge patch only changes these annotations, so it does not conflict with other writers of the Hardware.
+	// A null detail removes the annotation.
+	annotations := map[string]*string{
+		ProgressAnnotationPrefix + string(p.stage):             ptr(p.time.UTC().Format(time.RFC3339)),
+		ProgressAnnotationPrefix + string(p.stage) + "-detail": nil,
+	}
+	if p.detail != "" {
+		annotations[ProgressAnnotationPrefix+string(p.stage)+"-detail"] = &p.detail
+	}
+	patch, err := json.Marshal(map[string]any{"metadata": map[string]any{"annotations": annotations}})
+	if err != nil {
+		return err
+	}
+
+	return w.client.Patch(ctx, hw, client.RawPatch(types.MergePatchType, patch))
+}
+
+func (w *ProgressWriter) timeNow() time.Time {
+	if w.now != nil {
+		return w.now()
+	}
+
+	return time.Now()
+}
+
+func ptr(s string) *string {
+	return &s
+}
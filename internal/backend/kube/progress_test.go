@@ -0,0 +1,92 @@
+package kube
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
+	"github.com/tinkerbell/tink/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRecordProgress(t *testing.T) {
+	mac := net.HardwareAddr{0x3c, 0xec, 0xef, 0x4c, 0x4f, 0x54}
+	type record struct {
+		after  time.Duration
+		stage  handler.Stage
+		detail string
+	}
+	tests := map[string]struct {
+		records   []record
+		wantQueue int
+	}{
+		"one stage":           {records: []record{{stage: handler.StageDHCPOffer}}, wantQueue: 1},
+		"retransmits":         {records: []record{{stage: handler.StageDHCPOffer}, {after: time.Second, stage: handler.StageDHCPOffer}}, wantQueue: 1},
+		"after the interval":  {records: []record{{stage: handler.StageISO}, {after: time.Minute, stage: handler.StageISO}}, wantQueue: 2},
+		"different stages":    {records: []record{{stage: handler.StageDHCPOffer}, {stage: handler.StageDHCPAck}, {stage: handler.StageTFTP}}, wantQueue: 3},
+		"different detail":    {records: []record{{stage: handler.StageTFTP, detail: "undionly.kpxe"}, {stage: handler.StageTFTP, detail: "snp.efi"}}, wantQueue: 2},
+		"same detail ignored": {records: []record{{stage: handler.StageTFTP, detail: "snp.efi"}, {after: time.Second, stage: handler.StageTFTP, detail: "snp.efi"}}, wantQueue: 1},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := newProgressWriter(nil, ConflictPolicyRefuse, time.Minute, 5, logr.Discard())
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			w.now = func() time.Time { return now }
+			for _, r := range tt.records {
+				now = now.Add(r.after)
+				w.RecordProgress(context.Background(), mac, nil, r.stage, r.detail)
+			}
+			if got := len(w.queue); got != tt.wantQueue {
+				t.Errorf("queued = %d, want %d", got, tt.wantQueue)
+			}
+		})
+	}
+}
+
+func TestWriteProgress(t *testing.T) {
+	rs := runtime.NewScheme()
+	if err := scheme.AddToScheme(rs); err != nil {
+		t.Fatal(err)
+	}
+	if err := v1alpha1.AddToScheme(rs); err != nil {
+		t.Fatal(err)
+	}
+	hw := conflictHardware("sm01", "3c:ec:ef:4c:4f:54", "172.16.10.100", 0)
+	hw.Annotations = map[string]string{"owner": "team-a", ProgressAnnotationPrefix + "tftp-detail": "undionly.kpxe"}
+	cl := fake.NewClientBuilder().WithScheme(rs).WithIndex(&v1alpha1.Hardware{}, MACAddrIndex, MACAddrs).WithIndex(&v1alpha1.Hardware{}, IPAddrIndex, IPAddrs).WithObjects(&hw).Build()
+
+	w := newProgressWriter(cl, ConflictPolicyRefuse, time.Minute, 5, logr.Discard())
+	ctx := context.Background()
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	writes := []progress{
+		{mac: net.HardwareAddr{0x3c, 0xec, 0xef, 0x4c, 0x4f, 0x54}, stage: handler.StageDHCPAck, detail: "snp.efi", time: at},
+		{ip: net.IPv4(172, 16, 10, 100), stage: handler.StageTFTP, time: at.Add(time.Second)},
+		{mac: net.HardwareAddr{0x3c, 0xec, 0xef, 0x4c, 0x4f, 0x55}, stage: handler.StageDHCPAck, time: at},
+	}
+	for _, p := range writes {
+		if err := w.write(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := &v1alpha1.Hardware{}
+	if err := cl.Get(ctx, client.ObjectKeyFromObject(&hw), got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"owner":                               "team-a",
+		ProgressAnnotationPrefix + "dhcp-ack": "2024-01-01T00:00:00Z",
+		ProgressAnnotationPrefix + "dhcp-ack-detail": "snp.efi",
+		ProgressAnnotationPrefix + "tftp":            "2024-01-01T00:00:01Z",
+	}
+	if diff := cmp.Diff(want, got.Annotations); diff != "" {
+		t.Error(diff)
+	}
+}
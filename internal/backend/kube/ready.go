@@ -0,0 +1,125 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tinkerbell/tink/api/v1alpha1"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// probeTimeout is how long Ready waits for the Kubernetes API after a watch failed.
+const probeTimeout = 5 * time.Second
+
+// WaitForCacheSync waits until the informer cache has synced the Hardware, and the Workflows when they are required.
+// It returns false when ctx is done first. Start must be called to sync the cache.
+func (b *Backend) WaitForCacheSync(ctx context.Context) bool {
+	return b.cluster.GetCache().WaitForCacheSync(ctx)
+}
+
+// Ready implements the handler.ReadinessChecker interface. The backend is not ready while its informer cache has not
+// synced or is stopped, and while it is disconnected from the Kubernetes API, that is after a list or watch of the
+// cache failed and the Kubernetes API does not answer a list of Hardware either.
+func (b *Backend) Ready(ctx context.Context) error {
+	informers := map[string]syncer{}
+	objs := map[string]client.Object{"hardware": &v1alpha1.Hardware{}}
+	if b.requireWorkflows {
+		objs["workflow"] = &v1alpha1.Workflow{}
+	}
+	for name, obj := range objs {
+		inf, err := b.cluster.GetCache().GetInformer(ctx, obj, cache.BlockUntilSynced(false))
+		if err != nil {
+			return fmt.Errorf("failed to get the %s informer: %w", name, err)
+		}
+		informers[name] = inf
+	}
+
+	return b.readiness.check(ctx, informers, b.probe)
+}
+
+// probe lists a Hardware in each of the namespaces of the cache, bypassing the cache.
+func (b *Backend) probe(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	namespaces := b.namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+	for _, ns := range namespaces {
+		if err := b.cluster.GetAPIReader().List(ctx, &v1alpha1.HardwareList{}, client.InNamespace(ns), client.Limit(1)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncer is the part of an informer that tells whether its cache is current.
+type syncer interface {
+	HasSynced() bool
+	IsStopped() bool
+}
+
+// readiness tracks the failures of the lists and watches of the informer cache.
+type readiness struct {
+	mu sync.Mutex // protects watchErr and startErr
+	// watchErr is the last failure of a list or watch, until the Kubernetes API answers a probe.
+	watchErr error
+	// startErr is the error the cache stopped with.
+	startErr error
+}
+
+// failed records that the cache stopped with err. The backend is not ready from then on.
+func (r *readiness) failed(err error) {
+	r.mu.Lock()
+	r.startErr = err
+	r.mu.Unlock()
+}
+
+// watchErrorHandler records err and passes it to the default handler of client-go, which logs it.
+func (r *readiness) watchErrorHandler(ctx context.Context, rf *toolscache.Reflector, err error) {
+	r.mu.Lock()
+	r.watchErr = err
+	r.mu.Unlock()
+	toolscache.DefaultWatchErrorHandler(ctx, rf, err)
+}
+
+// check returns an error when one of informers is not synced or is stopped, or when a list or watch failed and probe
+// fails too. A successful probe clears the failure, as the informers then reconnect.
+func (r *readiness) check(ctx context.Context, informers map[string]syncer, probe func(context.Context) error) error {
+	r.mu.Lock()
+	startErr := r.startErr
+	r.mu.Unlock()
+	if startErr != nil {
+		return fmt.Errorf("the cache stopped: %w", startErr)
+	}
+	for name, inf := range informers {
+		if inf.IsStopped() {
+			return fmt.Errorf("the %s cache is stopped", name)
+		}
+		if !inf.HasSynced() {
+			return fmt.Errorf("the %s cache has not synced", name)
+		}
+	}
+
+	r.mu.Lock()
+	watchErr := r.watchErr
+	r.mu.Unlock()
+	if watchErr == nil {
+		return nil
+	}
+	if err := probe(ctx); err != nil {
+		return fmt.Errorf("the cache is disconnected from the Kubernetes API, the last list or watch failed: %w", watchErr)
+	}
+	r.mu.Lock()
+	if r.watchErr == watchErr {
+		r.watchErr = nil
+	}
+	r.mu.Unlock()
+
+	return nil
+}
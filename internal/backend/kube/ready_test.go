@@ -0,0 +1,57 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type fakeInformer struct {
+	synced  bool
+	stopped bool
+}
+
+func (f fakeInformer) HasSynced() bool { return f.synced }
+
+func (f fakeInformer) IsStopped() bool { return f.stopped }
+
+func TestReadinessCheck(t *testing.T) {
+	tests := map[string]struct {
+		informers     map[string]syncer
+		watchErr      error
+		startErr      error
+		probeErr      error
+		wantErr       bool
+		wantCleared   bool
+		wantNotProbed bool
+	}{
+		"ready":                   {informers: map[string]syncer{"hardware": fakeInformer{synced: true}}, wantNotProbed: true},
+		"not synced":              {informers: map[string]syncer{"hardware": fakeInformer{synced: true}, "workflow": fakeInformer{}}, wantErr: true, wantNotProbed: true},
+		"stopped":                 {informers: map[string]syncer{"hardware": fakeInformer{synced: true, stopped: true}}, wantErr: true, wantNotProbed: true},
+		"watch failed, reachable": {informers: map[string]syncer{"hardware": fakeInformer{synced: true}}, watchErr: errors.New("connection refused"), wantCleared: true},
+		"disconnected":            {informers: map[string]syncer{"hardware": fakeInformer{synced: true}}, watchErr: errors.New("connection refused"), probeErr: errors.New("connection refused"), wantErr: true},
+		"cache failed":            {informers: map[string]syncer{"hardware": fakeInformer{synced: true}}, startErr: errors.New("failed to start the informer"), wantErr: true, wantNotProbed: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := &readiness{watchErr: tt.watchErr}
+			if tt.startErr != nil {
+				r.failed(tt.startErr)
+			}
+			probed := false
+			err := r.check(context.Background(), tt.informers, func(context.Context) error {
+				probed = true
+				return tt.probeErr
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if probed == tt.wantNotProbed {
+				t.Errorf("probed = %v, want %v", probed, !tt.wantNotProbed)
+			}
+			if tt.wantCleared && r.watchErr != nil {
+				t.Errorf("watch error = %v, want it cleared", r.watchErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package kube
+
+import (
+	"fmt"
+
+	"github.com/tinkerbell/tink/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+)
+
+// Scope limits the Hardware objects that the backend sees, so that several instances can serve different subsets
+// of the Hardware in a cluster. Only the Hardware in the scope is in the informer cache, so Hardware outside of it
+// is never found by a lookup.
+type Scope struct {
+	// Namespaces are the namespaces that Hardware is watched in. All namespaces are watched when it is empty.
+	Namespaces []string
+	// LabelSelector selects the Hardware by its labels, for example "tenant=a,env!=dev".
+	LabelSelector string
+	// FieldSelector selects the Hardware by its fields, for example "metadata.name!=spare".
+	// Custom resources only support the metadata.name and metadata.namespace fields.
+	FieldSelector string
+}
+
+// ClusterOption returns the option for NewBackend that limits the informer cache to the scope.
+// An error is returned when a selector is not valid.
+func (s Scope) ClusterOption() (cluster.Option, error) {
+	var hw cache.ByObject
+	if s.LabelSelector != "" {
+		sel, err := labels.Parse(s.LabelSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label selector %q: %w", s.LabelSelector, err)
+		}
+		hw.Label = sel
+	}
+	if s.FieldSelector != "" {
+		sel, err := fields.ParseSelector(s.FieldSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid field selector %q: %w", s.FieldSelector, err)
+		}
+		hw.Field = sel
+	}
+
+	return func(o *cluster.Options) {
+		if len(s.Namespaces) > 0 {
+			o.Cache.DefaultNamespaces = make(map[string]cache.Config, len(s.Namespaces))
+			for _, ns := range s.Namespaces {
+				o.Cache.DefaultNamespaces[ns] = cache.Config{}
+			}
+		}
+		if hw.Label != nil || hw.Field != nil {
+			if o.Cache.ByObject == nil {
+				o.Cache.ByObject = map[client.Object]cache.ByObject{}
+			}
+			o.Cache.ByObject[&v1alpha1.Hardware{}] = hw
+		}
+	}, nil
+}
@@ -0,0 +1,99 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/tinkerbell/tink/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+)
+
+func TestScope(t *testing.T) {
+	tests := map[string]struct {
+		scope          Scope
+		wantNamespaces []string
+		// matches and notMatches are the labels and fields of Hardware that is and is not in the scope.
+		matches    map[string]string
+		notMatches map[string]string
+		wantErr    bool
+	}{
+		"all hardware": {},
+		"namespaces": {
+			scope:          Scope{Namespaces: []string{"tenant-a", "tenant-b"}},
+			wantNamespaces: []string{"tenant-a", "tenant-b"},
+		},
+		"label selector": {
+			scope:      Scope{LabelSelector: "tenant=a,env!=dev"},
+			matches:    map[string]string{"tenant": "a", "env": "prod"},
+			notMatches: map[string]string{"tenant": "a", "env": "dev"},
+		},
+		"field selector": {
+			scope:      Scope{FieldSelector: "metadata.name!=spare"},
+			matches:    map[string]string{"metadata.name": "sm01"},
+			notMatches: map[string]string{"metadata.name": "spare"},
+		},
+		"invalid label selector": {
+			scope:   Scope{LabelSelector: "tenant in (a"},
+			wantErr: true,
+		},
+		"invalid field selector": {
+			scope:   Scope{FieldSelector: "metadata.name"},
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			opt, err := tt.scope.ClusterOption()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ClusterOption() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			o := &cluster.Options{}
+			opt(o)
+
+			var namespaces []string
+			for ns := range o.Cache.DefaultNamespaces {
+				namespaces = append(namespaces, ns)
+			}
+			if diff := cmp.Diff(tt.wantNamespaces, namespaces, cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+				t.Error(diff)
+			}
+
+			var hw *cache.ByObject
+			for obj, bo := range o.Cache.ByObject {
+				if _, ok := obj.(*v1alpha1.Hardware); ok {
+					hw = &bo
+				}
+			}
+			if tt.matches == nil {
+				if hw != nil {
+					t.Errorf("Hardware is selected by %+v, want all Hardware", hw)
+				}
+				return
+			}
+			if hw == nil {
+				t.Fatal("Hardware is not selected")
+			}
+			if !selects(hw, tt.matches) {
+				t.Errorf("%v is not in the scope", tt.matches)
+			}
+			if selects(hw, tt.notMatches) {
+				t.Errorf("%v is in the scope", tt.notMatches)
+			}
+		})
+	}
+}
+
+func selects(bo *cache.ByObject, set map[string]string) bool {
+	if bo.Label != nil && !bo.Label.Matches(labels.Set(set)) {
+		return false
+	}
+
+	return bo.Field == nil || bo.Field.Matches(fields.Set(set))
+}
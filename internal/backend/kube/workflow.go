@@ -0,0 +1,127 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/tink/api/v1alpha1"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WorkflowHardwareIndex is an index used with a controller-runtime client to lookup workflows by the name of their Hardware.
+const WorkflowHardwareIndex = ".Spec.HardwareRef"
+
+// WorkflowHardwareRefs returns the name of the Hardware of a Workflow object.
+func WorkflowHardwareRefs(obj client.Object) []string {
+	wf, ok := obj.(*v1alpha1.Workflow)
+	if !ok || wf.Spec.HardwareRef == "" {
+		return nil
+	}
+
+	return []string{wf.Spec.HardwareRef}
+}
+
+// RequireWorkflows makes lookups tell a machine to boot from its local disk instead of the OSIE when its Hardware
+// allows netboot but has no pending Workflow, so that a machine does not reinstall itself after a reboot when its
+// allowPXE was not turned off. Changes of Workflows are reported to OnChange, as they change whether the Hardware
+// boots the OSIE. It must be called before Start.
+func (b *Backend) RequireWorkflows(log logr.Logger) error {
+	if err := b.cluster.GetFieldIndexer().IndexField(context.Background(), &v1alpha1.Workflow{}, WorkflowHardwareIndex, WorkflowHardwareRefs); err != nil {
+		return fmt.Errorf("failed to setup indexer(workflow hardware ref): %w", err)
+	}
+	inf, err := b.cluster.GetCache().GetInformer(context.Background(), &v1alpha1.Workflow{}, cache.BlockUntilSynced(false))
+	if err != nil {
+		return fmt.Errorf("failed to get the workflow informer: %w", err)
+	}
+	notify := func(obj any) {
+		macs, err := workflowMACs(context.Background(), b.cluster.GetClient(), obj)
+		if err != nil {
+			log.Error(err, "failed to get the hardware of a workflow, its cached lookups are not invalidated")
+			return
+		}
+		if len(macs) > 0 {
+			b.notifyWorkflowChange(macs...)
+		}
+	}
+	if _, err := inf.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    notify,
+		UpdateFunc: func(_, newObj any) { notify(newObj) },
+		DeleteFunc: notify,
+	}); err != nil {
+		return fmt.Errorf("failed to add the workflow event handler: %w", err)
+	}
+	b.requireWorkflows = true
+
+	return nil
+}
+
+// gateOnWorkflows sets n.LocalBoot when workflows are required and hw allows netboot but has no pending Workflow.
+func (b *Backend) gateOnWorkflows(ctx context.Context, hw *v1alpha1.Hardware, n *data.Netboot) error {
+	if !b.requireWorkflows || !n.AllowNetboot {
+		return nil
+	}
+	pending, err := pendingWorkflow(ctx, b.cluster.GetClient(), hw)
+	if err != nil {
+		return err
+	}
+	n.LocalBoot = !pending
+
+	return nil
+}
+
+// pendingWorkflow reports whether hw has a Workflow in its namespace that is waiting for the machine to boot the OSIE.
+// Those are the Workflows that are being prepared or are pending, and new Workflows that have no state yet.
+// Running and finished Workflows do not need the OSIE to be booted again.
+func pendingWorkflow(ctx context.Context, c client.Reader, hw *v1alpha1.Hardware) (bool, error) {
+	list := &v1alpha1.WorkflowList{}
+	if err := c.List(ctx, list, client.InNamespace(hw.Namespace), client.MatchingFields{WorkflowHardwareIndex: hw.Name}); err != nil {
+		return false, fmt.Errorf("failed listing workflows for hardware %s/%s: %w", hw.Namespace, hw.Name, err)
+	}
+	for _, wf := range list.Items {
+		switch wf.Status.State {
+		case "", v1alpha1.WorkflowStatePreparing, v1alpha1.WorkflowStatePending:
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// notifyWorkflowChange calls the functions registered with OnChange with the MAC addresses of the Hardware of a Workflow
+// that changed.
+func (b *Backend) notifyWorkflowChange(macs ...net.HardwareAddr) {
+	b.workflowMu.Lock()
+	defer b.workflowMu.Unlock()
+	for _, f := range b.onWorkflowChange {
+		f(macs...)
+	}
+}
+
+// workflowMACs returns the MAC addresses of the Hardware of a Workflow object, read with c.
+// It returns no MAC addresses when obj is not a Workflow or the Workflow has no Hardware.
+func workflowMACs(ctx context.Context, c client.Reader, obj any) ([]net.HardwareAddr, error) {
+	if d, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = d.Obj
+	}
+	wf, ok := obj.(*v1alpha1.Workflow)
+	if !ok || wf.Spec.HardwareRef == "" {
+		return nil, nil
+	}
+	hw := &v1alpha1.Hardware{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: wf.Namespace, Name: wf.Spec.HardwareRef}, hw); err != nil {
+		return nil, fmt.Errorf("failed to get hardware %s/%s of workflow %s: %w", wf.Namespace, wf.Spec.HardwareRef, wf.Name, err)
+	}
+	var macs []net.HardwareAddr
+	for _, m := range GetMACs(hw) {
+		if mac, err := net.ParseMAC(m); err == nil {
+			macs = append(macs, mac)
+		}
+	}
+
+	return macs, nil
+}
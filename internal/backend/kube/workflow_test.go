@@ -0,0 +1,105 @@
+package kube
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/tink/api/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPendingWorkflow(t *testing.T) {
+	tests := map[string]struct {
+		workflows []v1alpha1.Workflow
+		want      bool
+	}{
+		"no workflow":       {},
+		"pending workflow":  {workflows: []v1alpha1.Workflow{workflow("default", "sm01", v1alpha1.WorkflowStatePending)}, want: true},
+		"preparing":         {workflows: []v1alpha1.Workflow{workflow("default", "sm01", v1alpha1.WorkflowStatePreparing)}, want: true},
+		"new workflow":      {workflows: []v1alpha1.Workflow{workflow("default", "sm01", "")}, want: true},
+		"running workflow":  {workflows: []v1alpha1.Workflow{workflow("default", "sm01", v1alpha1.WorkflowStateRunning)}},
+		"finished workflow": {workflows: []v1alpha1.Workflow{workflow("default", "sm01", v1alpha1.WorkflowStateSuccess), workflow("default", "sm01", v1alpha1.WorkflowStateFailed)}},
+		"other hardware":    {workflows: []v1alpha1.Workflow{workflow("default", "sm02", v1alpha1.WorkflowStatePending)}},
+		"other namespace":   {workflows: []v1alpha1.Workflow{workflow("tenant-a", "sm01", v1alpha1.WorkflowStatePending)}},
+		"one of many": {workflows: []v1alpha1.Workflow{
+			workflow("default", "sm01", v1alpha1.WorkflowStateSuccess),
+			workflow("default", "sm01", v1alpha1.WorkflowStatePending),
+		}, want: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rs := runtime.NewScheme()
+			if err := scheme.AddToScheme(rs); err != nil {
+				t.Fatal(err)
+			}
+			if err := v1alpha1.AddToScheme(rs); err != nil {
+				t.Fatal(err)
+			}
+			var objs []client.Object
+			for i := range tt.workflows {
+				tt.workflows[i].Name = tt.workflows[i].Name + "-" + string(rune('a'+i))
+				objs = append(objs, &tt.workflows[i])
+			}
+			cl := fake.NewClientBuilder().WithScheme(rs).WithIndex(&v1alpha1.Workflow{}, WorkflowHardwareIndex, WorkflowHardwareRefs).WithObjects(objs...).Build()
+
+			hw := &v1alpha1.Hardware{ObjectMeta: v1.ObjectMeta{Name: "sm01", Namespace: "default"}}
+			got, err := pendingWorkflow(context.Background(), cl, hw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("pendingWorkflow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkflowMACs(t *testing.T) {
+	wf := workflow("default", "machine1", v1alpha1.WorkflowStatePending)
+	missing := workflow("default", "machine2", v1alpha1.WorkflowStatePending)
+	tests := map[string]struct {
+		obj     any
+		want    []net.HardwareAddr
+		wantErr bool
+	}{
+		"workflow":         {obj: &wf, want: []net.HardwareAddr{{0x3c, 0xec, 0xef, 0x4c, 0x4f, 0x54}}},
+		"deleted workflow": {obj: toolscache.DeletedFinalStateUnknown{Obj: &wf}, want: []net.HardwareAddr{{0x3c, 0xec, 0xef, 0x4c, 0x4f, 0x54}}},
+		"no hardware ref":  {obj: &v1alpha1.Workflow{}},
+		"not a workflow":   {obj: &hwObject1},
+		"missing hardware": {obj: &missing, wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rs := runtime.NewScheme()
+			if err := v1alpha1.AddToScheme(rs); err != nil {
+				t.Fatal(err)
+			}
+			hw := hwObject1.DeepCopy()
+			cl := fake.NewClientBuilder().WithScheme(rs).WithObjects(hw).Build()
+
+			got, err := workflowMACs(context.Background(), cl, tt.obj)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("workflowMACs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+// workflow returns a Workflow in namespace for the Hardware hw, in state.
+func workflow(namespace, hw string, state v1alpha1.WorkflowState) v1alpha1.Workflow {
+	return v1alpha1.Workflow{
+		ObjectMeta: v1.ObjectMeta{Name: "wf-" + hw, Namespace: namespace},
+		Spec:       v1alpha1.WorkflowSpec{HardwareRef: hw},
+		Status:     v1alpha1.WorkflowStatus{State: state},
+	}
+}
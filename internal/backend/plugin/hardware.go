@@ -0,0 +1,111 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+
+	pb "github.com/tinkerbell/smee/internal/backend/plugin/v1"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+)
+
+// translate converts the hardware from a plugin to data.DHCP and data.Netboot.
+// If required fields are missing or fields are not valid, an error is returned.
+func translate(hw *pb.Hardware) (*data.DHCP, *data.Netboot, error) {
+	h := hw.GetDhcp()
+	nb := hw.GetNetboot()
+	d := new(data.DHCP)
+	n := new(data.Netboot)
+
+	var err error
+	// mac address, required
+	if d.MACAddress, err = net.ParseMAC(h.GetMacAddress()); err != nil {
+		return nil, nil, fmt.Errorf("invalid mac_address: %w", err)
+	}
+
+	// ip address, required
+	if d.IPAddress, err = netip.ParseAddr(h.GetIpAddress()); err != nil {
+		return nil, nil, fmt.Errorf("invalid ip_address: %w", err)
+	}
+
+	// subnet mask, optional, it can come from the subnet defaults of the DHCP server
+	if h.GetSubnetMask() != "" {
+		sm := net.ParseIP(h.GetSubnetMask()).To4()
+		if sm == nil {
+			return nil, nil, errors.New("invalid subnet_mask")
+		}
+		d.SubnetMask = net.IPMask(sm)
+	}
+
+	// default gateway, optional
+	if h.GetDefaultGateway() != "" {
+		if d.DefaultGateway, err = netip.ParseAddr(h.GetDefaultGateway()); err != nil {
+			return nil, nil, fmt.Errorf("invalid default_gateway: %w", err)
+		}
+	}
+
+	// name servers, optional
+	if d.NameServers, err = parseIPs(h.GetNameServers()); err != nil {
+		return nil, nil, fmt.Errorf("invalid name_servers: %w", err)
+	}
+
+	// broadcast address, optional
+	if h.GetBroadcastAddress() != "" {
+		if d.BroadcastAddress, err = netip.ParseAddr(h.GetBroadcastAddress()); err != nil {
+			return nil, nil, fmt.Errorf("invalid broadcast_address: %w", err)
+		}
+	}
+
+	// ntp servers, optional
+	if d.NTPServers, err = parseIPs(h.GetNtpServers()); err != nil {
+		return nil, nil, fmt.Errorf("invalid ntp_servers: %w", err)
+	}
+
+	d.Hostname = h.GetHostname()
+	d.DomainName = h.GetDomainName()
+	d.VLANID = h.GetVlanId()
+	// lease time, optional, it can come from the subnet defaults of the DHCP server
+	d.LeaseTime = h.GetLeaseTime()
+	d.Arch = h.GetArch()
+	d.DomainSearch = slices.Clone(h.GetDomainSearch())
+	d.Disabled = h.GetDisabled()
+
+	n.AllowNetboot = nb.GetAllowNetboot()
+
+	// ipxe script url is optional but if provided, it must be a valid url
+	if nb.GetIpxeScriptUrl() != "" {
+		if n.IPXEScriptURL, err = url.ParseRequestURI(nb.GetIpxeScriptUrl()); err != nil {
+			return nil, nil, fmt.Errorf("invalid ipxe_script_url: %w", err)
+		}
+	}
+	n.IPXEScript = nb.GetIpxeScript()
+	n.Console = nb.GetConsole()
+	n.Facility = nb.GetFacility()
+
+	// osie base url is optional but if provided, it must be a valid url
+	if nb.GetOsie().GetBaseUrl() != "" {
+		if n.OSIE.BaseURL, err = url.Parse(nb.GetOsie().GetBaseUrl()); err != nil {
+			return nil, nil, fmt.Errorf("invalid osie base_url: %w", err)
+		}
+	}
+	n.OSIE.Kernel = nb.GetOsie().GetKernel()
+	n.OSIE.Initrd = nb.GetOsie().GetInitrd()
+
+	return d, n, nil
+}
+
+func parseIPs(s []string) ([]net.IP, error) {
+	var ips []net.IP
+	for _, e := range s {
+		ip := net.ParseIP(e)
+		if ip == nil {
+			return nil, fmt.Errorf("%q is not an IP address", e)
+		}
+		ips = append(ips, ip)
+	}
+
+	return ips, nil
+}
@@ -0,0 +1,351 @@
+// Package plugin is a backend that gets DHCP and netboot data from a backend plugin.
+//
+// A backend plugin is a gRPC server that implements the Backend service in v1/backend.proto, in any language.
+// It usually runs as a sidecar that listens on a Unix socket. Lookups are served from a local cache that is fed by the
+// Watch stream of the plugin. While the stream is not synced, or when the plugin does not implement Watch,
+// lookups are GetByMac and GetByIP calls to the plugin.
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	pb "github.com/tinkerbell/smee/internal/backend/plugin/v1"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	_ "google.golang.org/grpc/health" // enables client side health checking.
+	"google.golang.org/grpc/status"
+)
+
+const tracerName = "github.com/tinkerbell/smee/dhcp"
+
+// serviceConfig enables client side health checking of the plugin with the gRPC health checking protocol.
+// The connection is not used while the plugin reports that it is not serving.
+// Plugins that do not implement the health service are treated as healthy.
+const serviceConfig = `{
+	"loadBalancingConfig": [{"round_robin": {}}],
+	"healthCheckConfig": {"serviceName": ""}
+}`
+
+// Backoff of restarting the Watch stream after it fails.
+const (
+	minWatchBackoff = time.Second
+	maxWatchBackoff = 30 * time.Second
+)
+
+var errMultipleHardware = errors.New("multiple hardware found")
+
+// Backend gets DHCP and netboot data from a backend plugin.
+type Backend struct {
+	// Timeout is the deadline of GetByMac and GetByIP calls to the plugin. 0 means no deadline.
+	Timeout time.Duration
+	// Log is the logger to be used in the plugin backend.
+	Log logr.Logger
+
+	conn   *grpc.ClientConn
+	client pb.BackendClient
+
+	cacheMu sync.RWMutex // protects synced, byMAC, byIP and onChange
+	// synced is true while the Watch stream is open and has sent all hardware. Lookups are served from the cache only then.
+	synced bool
+	byMAC  map[string]*pb.Hardware
+	byIP   map[netip.Addr][]string // IP address to MAC addresses
+	// onChange are called when hardware changes in the cache, or when lookups switch between the cache and the plugin.
+	onChange []func(...net.HardwareAddr)
+}
+
+// NewBackend returns a Backend for the plugin at target, for example unix:///var/run/smee/backend.sock.
+// The connection is not encrypted, plugins are expected to run on the same host.
+// Start must be called to fill the cache.
+func NewBackend(target string, timeout time.Duration, l logr.Logger) (*Backend, error) {
+	conn, err := grpc.NewClient(target,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(serviceConfig),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create plugin client: %w", err)
+	}
+
+	return &Backend{
+		Timeout: timeout,
+		Log:     l,
+		conn:    conn,
+		client:  pb.NewBackendClient(conn),
+	}, nil
+}
+
+// Start watches the plugin for changes to fill the cache, until ctx is canceled. The connection is closed when it returns.
+// The Watch stream is restarted with a backoff when it fails. When the plugin does not implement Watch, it is not restarted.
+func (b *Backend) Start(ctx context.Context) {
+	defer b.conn.Close()
+	backoff := minWatchBackoff
+	for {
+		synced, err := b.watch(ctx)
+		b.setSynced(false)
+		if ctx.Err() != nil {
+			return
+		}
+		if status.Code(err) == grpccodes.Unimplemented {
+			b.Log.Info("plugin does not implement Watch, lookups are not cached")
+			<-ctx.Done()
+			return
+		}
+		if synced {
+			backoff = minWatchBackoff
+		}
+		b.Log.Info("plugin watch stream failed, lookups are not cached until it is restarted", "err", err, "retryIn", backoff.String())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxWatchBackoff)
+	}
+}
+
+// watch fills the cache from a Watch stream until the stream fails. synced reports whether the stream was synced.
+func (b *Backend) watch(ctx context.Context) (synced bool, err error) {
+	stream, err := b.client.Watch(ctx, &pb.WatchRequest{})
+	if err != nil {
+		return false, err
+	}
+	byMAC := map[string]*pb.Hardware{}
+	for {
+		e, err := stream.Recv()
+		if err != nil {
+			return synced, err
+		}
+		if e.GetType() == pb.WatchEvent_TYPE_SYNCED {
+			if !synced {
+				b.sync(byMAC)
+				synced = true
+				b.Log.Info("plugin watch stream synced", "hardware", len(byMAC))
+			}
+			continue
+		}
+		mac, err := net.ParseMAC(e.GetHardware().GetDhcp().GetMacAddress())
+		if err != nil {
+			b.Log.Info("ignoring watch event with an invalid MAC address", "type", e.GetType().String(), "err", err)
+			continue
+		}
+		switch e.GetType() {
+		case pb.WatchEvent_TYPE_ADDED, pb.WatchEvent_TYPE_MODIFIED:
+			if !synced {
+				byMAC[mac.String()] = e.GetHardware()
+				continue
+			}
+			b.put(mac.String(), e.GetHardware())
+		case pb.WatchEvent_TYPE_DELETED:
+			if !synced {
+				delete(byMAC, mac.String())
+				continue
+			}
+			b.put(mac.String(), nil)
+		default:
+			b.Log.Info("ignoring watch event with an unknown type", "type", e.GetType().String())
+		}
+	}
+}
+
+// sync replaces the cache with the hardware in byMAC and serves lookups from it.
+func (b *Backend) sync(byMAC map[string]*pb.Hardware) {
+	b.cacheMu.Lock()
+	defer b.cacheMu.Unlock()
+	b.byMAC = make(map[string]*pb.Hardware, len(byMAC))
+	b.byIP = make(map[netip.Addr][]string, len(byMAC))
+	for mac, hw := range byMAC {
+		b.putLocked(mac, hw)
+	}
+	b.synced = true
+	b.notifyLocked()
+}
+
+func (b *Backend) setSynced(synced bool) {
+	b.cacheMu.Lock()
+	defer b.cacheMu.Unlock()
+	if b.synced != synced {
+		b.synced = synced
+		b.notifyLocked()
+	}
+}
+
+// OnChange implements the handler.ChangeNotifier interface.
+// f is called for every change of hardware from the Watch stream, and when the stream is synced or fails.
+func (b *Backend) OnChange(f func(macs ...net.HardwareAddr)) {
+	b.cacheMu.Lock()
+	defer b.cacheMu.Unlock()
+	b.onChange = append(b.onChange, f)
+}
+
+func (b *Backend) notifyLocked(macs ...net.HardwareAddr) {
+	for _, f := range b.onChange {
+		f(macs...)
+	}
+}
+
+// put adds, replaces or, when hw is nil, removes the hardware with mac in the cache.
+func (b *Backend) put(mac string, hw *pb.Hardware) {
+	b.cacheMu.Lock()
+	defer b.cacheMu.Unlock()
+	b.putLocked(mac, hw)
+	if m, err := net.ParseMAC(mac); err == nil {
+		b.notifyLocked(m)
+	}
+}
+
+func (b *Backend) putLocked(mac string, hw *pb.Hardware) {
+	if old, ok := b.byMAC[mac]; ok {
+		if ip, err := netip.ParseAddr(old.GetDhcp().GetIpAddress()); err == nil {
+			ip = ip.Unmap()
+			macs := b.byIP[ip]
+			for i, m := range macs {
+				if m == mac {
+					macs = append(macs[:i:i], macs[i+1:]...)
+					break
+				}
+			}
+			if len(macs) == 0 {
+				delete(b.byIP, ip)
+			} else {
+				b.byIP[ip] = macs
+			}
+		}
+		delete(b.byMAC, mac)
+	}
+	if hw == nil {
+		return
+	}
+	b.byMAC[mac] = hw
+	if ip, err := netip.ParseAddr(hw.GetDhcp().GetIpAddress()); err == nil {
+		b.byIP[ip.Unmap()] = append(b.byIP[ip.Unmap()], mac)
+	}
+}
+
+// GetByMac implements the handler.BackendReader interface and returns DHCP and netboot data based on a mac address.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.plugin.GetByMac")
+	defer span.End()
+
+	hw, err := b.getByMac(ctx, mac)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, err
+	}
+	d, n, err := translate(hw)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, err
+	}
+
+	span.SetAttributes(d.EncodeToAttributes()...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// GetByIP implements the handler.BackendReader interface and returns DHCP and netboot data based on an IP address.
+func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.plugin.GetByIP")
+	defer span.End()
+
+	hw, err := b.getByIP(ctx, ip)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, err
+	}
+	d, n, err := translate(hw)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, err
+	}
+
+	span.SetAttributes(d.EncodeToAttributes()...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+func (b *Backend) getByMac(ctx context.Context, mac net.HardwareAddr) (*pb.Hardware, error) {
+	b.cacheMu.RLock()
+	if b.synced {
+		defer b.cacheMu.RUnlock()
+		hw, ok := b.byMAC[mac.String()]
+		if !ok {
+			return nil, handler.NotFoundError{Key: mac.String()}
+		}
+		return hw, nil
+	}
+	b.cacheMu.RUnlock()
+
+	ctx, cancel := b.withTimeout(ctx)
+	defer cancel()
+	hw, err := b.client.GetByMac(ctx, &pb.GetByMacRequest{MacAddress: mac.String()})
+	if err != nil {
+		return nil, callError(err, mac.String())
+	}
+
+	return hw, nil
+}
+
+func (b *Backend) getByIP(ctx context.Context, ip net.IP) (*pb.Hardware, error) {
+	b.cacheMu.RLock()
+	if b.synced {
+		defer b.cacheMu.RUnlock()
+		addr, _ := netip.AddrFromSlice(ip)
+		macs := b.byIP[addr.Unmap()]
+		switch len(macs) {
+		case 0:
+			return nil, handler.NotFoundError{Key: ip.String()}
+		case 1:
+			return b.byMAC[macs[0]], nil
+		default:
+			return nil, fmt.Errorf("%w: ip %s: %v", errMultipleHardware, ip, macs)
+		}
+	}
+	b.cacheMu.RUnlock()
+
+	ctx, cancel := b.withTimeout(ctx)
+	defer cancel()
+	hw, err := b.client.GetByIP(ctx, &pb.GetByIPRequest{IpAddress: ip.String()})
+	if err != nil {
+		return nil, callError(err, ip.String())
+	}
+
+	return hw, nil
+}
+
+func (b *Backend) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if b.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, b.Timeout)
+}
+
+// callError converts the NOT_FOUND status code of a call to the plugin to a not found error.
+func callError(err error, key string) error {
+	if status.Code(err) == grpccodes.NotFound {
+		return handler.NotFoundError{Key: key}
+	}
+
+	return fmt.Errorf("plugin lookup of %s failed: %w", key, err)
+}
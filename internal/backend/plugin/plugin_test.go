@@ -0,0 +1,276 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	pb "github.com/tinkerbell/smee/internal/backend/plugin/v1"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// server is a backend plugin for tests.
+type server struct {
+	pb.UnimplementedBackendServer
+	hardware map[string]*pb.Hardware
+	// events are sent on the Watch stream after the initial hardware, when set. Watch is not implemented when it is nil.
+	events chan *pb.WatchEvent
+	delay  time.Duration
+	calls  atomic.Int32
+}
+
+func (s *server) GetByMac(ctx context.Context, r *pb.GetByMacRequest) (*pb.Hardware, error) {
+	s.calls.Add(1)
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(s.delay):
+	}
+	hw, ok := s.hardware[r.GetMacAddress()]
+	if !ok {
+		return nil, status.Error(grpccodes.NotFound, "not found")
+	}
+
+	return hw, nil
+}
+
+func (s *server) GetByIP(_ context.Context, r *pb.GetByIPRequest) (*pb.Hardware, error) {
+	s.calls.Add(1)
+	for _, hw := range s.hardware {
+		if hw.GetDhcp().GetIpAddress() == r.GetIpAddress() {
+			return hw, nil
+		}
+	}
+
+	return nil, status.Error(grpccodes.NotFound, "not found")
+}
+
+func (s *server) Watch(_ *pb.WatchRequest, stream grpc.ServerStreamingServer[pb.WatchEvent]) error {
+	if s.events == nil {
+		return status.Error(grpccodes.Unimplemented, "not implemented")
+	}
+	for _, hw := range s.hardware {
+		if err := stream.Send(&pb.WatchEvent{Type: pb.WatchEvent_TYPE_ADDED, Hardware: hw}); err != nil {
+			return err
+		}
+	}
+	if err := stream.Send(&pb.WatchEvent{Type: pb.WatchEvent_TYPE_SYNCED}); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e, ok := <-s.events:
+			if !ok {
+				return status.Error(grpccodes.Unavailable, "stream closed")
+			}
+			if err := stream.Send(e); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func hardware(mac, ip string) *pb.Hardware {
+	return &pb.Hardware{
+		Dhcp:    &pb.DHCP{MacAddress: mac, IpAddress: ip, SubnetMask: "255.255.255.0", Hostname: "sandbox"},
+		Netboot: &pb.Netboot{AllowNetboot: true, Facility: "onprem", Osie: &pb.OSIE{Kernel: "vmlinuz-x86_64"}},
+	}
+}
+
+// serve runs s on a Unix socket and returns a Backend for it.
+func serve(t *testing.T, s *server, timeout time.Duration) *Backend {
+	t.Helper()
+	sock := filepath.Join(t.TempDir(), "backend.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs := grpc.NewServer()
+	pb.RegisterBackendServer(gs, s)
+	go gs.Serve(l) //nolint:errcheck // Serve returns when the server is stopped.
+	t.Cleanup(gs.Stop)
+
+	b, err := NewBackend("unix://"+sock, timeout, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.Start(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return b
+}
+
+func waitSynced(t *testing.T, b *Backend, want bool) {
+	t.Helper()
+	for range 200 {
+		b.cacheMu.RLock()
+		synced := b.synced
+		b.cacheMu.RUnlock()
+		if synced == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("synced != %v", want)
+}
+
+func TestGetByMac(t *testing.T) {
+	tests := map[string]struct {
+		mac          net.HardwareAddr
+		delay        time.Duration
+		wantDHCP     *data.DHCP
+		wantNetboot  *data.Netboot
+		wantNotFound bool
+		wantErr      bool
+	}{
+		"found": {
+			mac: net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67},
+			wantDHCP: &data.DHCP{
+				MACAddress: net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67},
+				IPAddress:  netip.MustParseAddr("192.168.2.153"),
+				SubnetMask: net.IPv4Mask(255, 255, 255, 0),
+				Hostname:   "sandbox",
+			},
+			wantNetboot: &data.Netboot{AllowNetboot: true, Facility: "onprem", OSIE: data.OSIE{Kernel: "vmlinuz-x86_64"}},
+		},
+		"not found": {
+			mac:          net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x68},
+			wantNotFound: true,
+			wantErr:      true,
+		},
+		"deadline exceeded": {
+			mac:     net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67},
+			delay:   time.Second,
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := &server{
+				hardware: map[string]*pb.Hardware{"08:00:27:29:4e:67": hardware("08:00:27:29:4e:67", "192.168.2.153")},
+				delay:    tt.delay,
+			}
+			b := serve(t, s, 100*time.Millisecond)
+			d, n, err := b.GetByMac(context.Background(), tt.mac)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetByMac() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var nf interface{ NotFound() bool }
+			if got := errors.As(err, &nf) && nf.NotFound(); got != tt.wantNotFound {
+				t.Errorf("NotFound() = %v, want %v", got, tt.wantNotFound)
+			}
+			if diff := cmp.Diff(tt.wantDHCP, d, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+				t.Error(diff)
+			}
+			if diff := cmp.Diff(tt.wantNetboot, n); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestGetByIP(t *testing.T) {
+	s := &server{hardware: map[string]*pb.Hardware{"08:00:27:29:4e:67": hardware("08:00:27:29:4e:67", "192.168.2.153")}}
+	b := serve(t, s, time.Second)
+	d, _, err := b.GetByIP(context.Background(), net.IPv4(192, 168, 2, 153))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67}, d.MACAddress); diff != "" {
+		t.Fatal(diff)
+	}
+	if _, _, err := b.GetByIP(context.Background(), net.IPv4(192, 168, 2, 154)); err == nil {
+		t.Fatal("GetByIP() error = nil, want not found")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	s := &server{
+		hardware: map[string]*pb.Hardware{
+			"08:00:27:29:4e:67": hardware("08:00:27:29:4e:67", "192.168.2.153"),
+			"08:00:27:29:4e:68": hardware("08:00:27:29:4e:68", "192.168.2.154"),
+		},
+		events: make(chan *pb.WatchEvent),
+	}
+	b := serve(t, s, time.Second)
+	changes := make(chan []net.HardwareAddr, 100)
+	b.OnChange(func(macs ...net.HardwareAddr) { changes <- macs })
+	waitSynced(t, b, true)
+
+	mac1 := net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67}
+	mac2 := net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x68}
+	if _, _, err := b.GetByMac(context.Background(), mac1); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := b.GetByIP(context.Background(), net.IPv4(192, 168, 2, 154)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := b.GetByMac(context.Background(), net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x69}); err == nil {
+		t.Fatal("GetByMac() error = nil, want not found")
+	}
+
+	// Move the IP of the first hardware to the second and delete the first.
+	s.events <- &pb.WatchEvent{Type: pb.WatchEvent_TYPE_MODIFIED, Hardware: hardware("08:00:27:29:4e:68", "192.168.2.153")}
+	s.events <- &pb.WatchEvent{Type: pb.WatchEvent_TYPE_DELETED, Hardware: &pb.Hardware{Dhcp: &pb.DHCP{MacAddress: "08:00:27:29:4e:67"}}}
+	// The events are applied in order, so once the first hardware is gone the IP has moved.
+	for range 200 {
+		if _, _, err := b.GetByMac(context.Background(), mac1); err != nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	d, _, err := b.GetByIP(context.Background(), net.IPv4(192, 168, 2, 153))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(mac2, d.MACAddress); diff != "" {
+		t.Fatal(diff)
+	}
+	if _, _, err := b.GetByIP(context.Background(), net.IPv4(192, 168, 2, 154)); err == nil {
+		t.Fatal("GetByIP() error = nil, want not found")
+	}
+	if got := s.calls.Load(); got != 0 {
+		t.Errorf("calls = %d, want lookups to be served from the cache", got)
+	}
+	// The stream may be synced before OnChange is called, so only the changes of the events are checked.
+	var changed []net.HardwareAddr
+	for len(changed) < 2 {
+		if macs := <-changes; len(macs) > 0 {
+			changed = append(changed, macs...)
+		}
+	}
+	if diff := cmp.Diff([]net.HardwareAddr{mac2, mac1}, changed); diff != "" {
+		t.Error(diff)
+	}
+
+	// The plugin is called while the stream is down.
+	close(s.events)
+	waitSynced(t, b, false)
+	if _, _, err := b.GetByMac(context.Background(), mac1); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.calls.Load(); got != 1 {
+		t.Errorf("calls = %d, want 1", got)
+	}
+}
@@ -0,0 +1,769 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.29.3
+// source: internal/backend/plugin/v1/backend.proto
+
+package v1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Type is the type of a change.
+type WatchEvent_Type int32
+
+const (
+	WatchEvent_TYPE_UNSPECIFIED WatchEvent_Type = 0
+	// The hardware was added, or is part of the initial list of hardware.
+	WatchEvent_TYPE_ADDED WatchEvent_Type = 1
+	// The hardware was changed.
+	WatchEvent_TYPE_MODIFIED WatchEvent_Type = 2
+	// The hardware was deleted. Only the MAC address of the hardware has to be set.
+	WatchEvent_TYPE_DELETED WatchEvent_Type = 3
+	// All hardware that exists when Watch was called has been sent. The event has no hardware.
+	WatchEvent_TYPE_SYNCED WatchEvent_Type = 4
+)
+
+// Enum value maps for WatchEvent_Type.
+var (
+	WatchEvent_Type_name = map[int32]string{
+		0: "TYPE_UNSPECIFIED",
+		1: "TYPE_ADDED",
+		2: "TYPE_MODIFIED",
+		3: "TYPE_DELETED",
+		4: "TYPE_SYNCED",
+	}
+	WatchEvent_Type_value = map[string]int32{
+		"TYPE_UNSPECIFIED": 0,
+		"TYPE_ADDED":       1,
+		"TYPE_MODIFIED":    2,
+		"TYPE_DELETED":     3,
+		"TYPE_SYNCED":      4,
+	}
+)
+
+func (x WatchEvent_Type) Enum() *WatchEvent_Type {
+	p := new(WatchEvent_Type)
+	*p = x
+	return p
+}
+
+func (x WatchEvent_Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (WatchEvent_Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_internal_backend_plugin_v1_backend_proto_enumTypes[0].Descriptor()
+}
+
+func (WatchEvent_Type) Type() protoreflect.EnumType {
+	return &file_internal_backend_plugin_v1_backend_proto_enumTypes[0]
+}
+
+func (x WatchEvent_Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use WatchEvent_Type.Descriptor instead.
+func (WatchEvent_Type) EnumDescriptor() ([]byte, []int) {
+	return file_internal_backend_plugin_v1_backend_proto_rawDescGZIP(), []int{3, 0}
+}
+
+// GetByMacRequest is the request of GetByMac.
+type GetByMacRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The MAC address to look up, in the format 08:00:27:29:4e:67.
+	MacAddress    string `protobuf:"bytes,1,opt,name=mac_address,json=macAddress,proto3" json:"mac_address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetByMacRequest) Reset() {
+	*x = GetByMacRequest{}
+	mi := &file_internal_backend_plugin_v1_backend_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetByMacRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetByMacRequest) ProtoMessage() {}
+
+func (x *GetByMacRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_plugin_v1_backend_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetByMacRequest.ProtoReflect.Descriptor instead.
+func (*GetByMacRequest) Descriptor() ([]byte, []int) {
+	return file_internal_backend_plugin_v1_backend_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetByMacRequest) GetMacAddress() string {
+	if x != nil {
+		return x.MacAddress
+	}
+	return ""
+}
+
+// GetByIPRequest is the request of GetByIP.
+type GetByIPRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The IP address to look up.
+	IpAddress     string `protobuf:"bytes,1,opt,name=ip_address,json=ipAddress,proto3" json:"ip_address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetByIPRequest) Reset() {
+	*x = GetByIPRequest{}
+	mi := &file_internal_backend_plugin_v1_backend_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetByIPRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetByIPRequest) ProtoMessage() {}
+
+func (x *GetByIPRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_plugin_v1_backend_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetByIPRequest.ProtoReflect.Descriptor instead.
+func (*GetByIPRequest) Descriptor() ([]byte, []int) {
+	return file_internal_backend_plugin_v1_backend_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetByIPRequest) GetIpAddress() string {
+	if x != nil {
+		return x.IpAddress
+	}
+	return ""
+}
+
+// WatchRequest is the request of Watch.
+type WatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	mi := &file_internal_backend_plugin_v1_backend_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_plugin_v1_backend_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_internal_backend_plugin_v1_backend_proto_rawDescGZIP(), []int{2}
+}
+
+// WatchEvent is a change to the hardware of a backend.
+type WatchEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The type of the change.
+	Type WatchEvent_Type `protobuf:"varint,1,opt,name=type,proto3,enum=smee.backend.v1.WatchEvent_Type" json:"type,omitempty"`
+	// The hardware that changed.
+	Hardware      *Hardware `protobuf:"bytes,2,opt,name=hardware,proto3" json:"hardware,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchEvent) Reset() {
+	*x = WatchEvent{}
+	mi := &file_internal_backend_plugin_v1_backend_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchEvent) ProtoMessage() {}
+
+func (x *WatchEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_plugin_v1_backend_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchEvent.ProtoReflect.Descriptor instead.
+func (*WatchEvent) Descriptor() ([]byte, []int) {
+	return file_internal_backend_plugin_v1_backend_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *WatchEvent) GetType() WatchEvent_Type {
+	if x != nil {
+		return x.Type
+	}
+	return WatchEvent_TYPE_UNSPECIFIED
+}
+
+func (x *WatchEvent) GetHardware() *Hardware {
+	if x != nil {
+		return x.Hardware
+	}
+	return nil
+}
+
+// Hardware is the DHCP and netboot data of a single network interface.
+type Hardware struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Dhcp          *DHCP                  `protobuf:"bytes,1,opt,name=dhcp,proto3" json:"dhcp,omitempty"`
+	Netboot       *Netboot               `protobuf:"bytes,2,opt,name=netboot,proto3" json:"netboot,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Hardware) Reset() {
+	*x = Hardware{}
+	mi := &file_internal_backend_plugin_v1_backend_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Hardware) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Hardware) ProtoMessage() {}
+
+func (x *Hardware) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_plugin_v1_backend_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Hardware.ProtoReflect.Descriptor instead.
+func (*Hardware) Descriptor() ([]byte, []int) {
+	return file_internal_backend_plugin_v1_backend_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Hardware) GetDhcp() *DHCP {
+	if x != nil {
+		return x.Dhcp
+	}
+	return nil
+}
+
+func (x *Hardware) GetNetboot() *Netboot {
+	if x != nil {
+		return x.Netboot
+	}
+	return nil
+}
+
+// DHCP is the data used in DHCP responses. mac_address and ip_address are required.
+type DHCP struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// chaddr DHCP header.
+	MacAddress string `protobuf:"bytes,1,opt,name=mac_address,json=macAddress,proto3" json:"mac_address,omitempty"`
+	// yiaddr DHCP header.
+	IpAddress string `protobuf:"bytes,2,opt,name=ip_address,json=ipAddress,proto3" json:"ip_address,omitempty"`
+	// DHCP option 1.
+	SubnetMask string `protobuf:"bytes,3,opt,name=subnet_mask,json=subnetMask,proto3" json:"subnet_mask,omitempty"`
+	// DHCP option 3.
+	DefaultGateway string `protobuf:"bytes,4,opt,name=default_gateway,json=defaultGateway,proto3" json:"default_gateway,omitempty"`
+	// DHCP option 6.
+	NameServers []string `protobuf:"bytes,5,rep,name=name_servers,json=nameServers,proto3" json:"name_servers,omitempty"`
+	// DHCP option 12.
+	Hostname string `protobuf:"bytes,6,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	// DHCP option 15.
+	DomainName string `protobuf:"bytes,7,opt,name=domain_name,json=domainName,proto3" json:"domain_name,omitempty"`
+	// DHCP option 28.
+	BroadcastAddress string `protobuf:"bytes,8,opt,name=broadcast_address,json=broadcastAddress,proto3" json:"broadcast_address,omitempty"`
+	// DHCP option 42.
+	NtpServers []string `protobuf:"bytes,9,rep,name=ntp_servers,json=ntpServers,proto3" json:"ntp_servers,omitempty"`
+	// DHCP option 43.116.
+	VlanId string `protobuf:"bytes,10,opt,name=vlan_id,json=vlanId,proto3" json:"vlan_id,omitempty"`
+	// DHCP option 51, in seconds. Defaults to the subnet lease time.
+	LeaseTime uint32 `protobuf:"varint,11,opt,name=lease_time,json=leaseTime,proto3" json:"lease_time,omitempty"`
+	// DHCP option 93.
+	Arch string `protobuf:"bytes,12,opt,name=arch,proto3" json:"arch,omitempty"`
+	// DHCP option 119.
+	DomainSearch []string `protobuf:"bytes,13,rep,name=domain_search,json=domainSearch,proto3" json:"domain_search,omitempty"`
+	// If true, no DHCP response is sent.
+	Disabled      bool `protobuf:"varint,14,opt,name=disabled,proto3" json:"disabled,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DHCP) Reset() {
+	*x = DHCP{}
+	mi := &file_internal_backend_plugin_v1_backend_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DHCP) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DHCP) ProtoMessage() {}
+
+func (x *DHCP) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_plugin_v1_backend_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DHCP.ProtoReflect.Descriptor instead.
+func (*DHCP) Descriptor() ([]byte, []int) {
+	return file_internal_backend_plugin_v1_backend_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DHCP) GetMacAddress() string {
+	if x != nil {
+		return x.MacAddress
+	}
+	return ""
+}
+
+func (x *DHCP) GetIpAddress() string {
+	if x != nil {
+		return x.IpAddress
+	}
+	return ""
+}
+
+func (x *DHCP) GetSubnetMask() string {
+	if x != nil {
+		return x.SubnetMask
+	}
+	return ""
+}
+
+func (x *DHCP) GetDefaultGateway() string {
+	if x != nil {
+		return x.DefaultGateway
+	}
+	return ""
+}
+
+func (x *DHCP) GetNameServers() []string {
+	if x != nil {
+		return x.NameServers
+	}
+	return nil
+}
+
+func (x *DHCP) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *DHCP) GetDomainName() string {
+	if x != nil {
+		return x.DomainName
+	}
+	return ""
+}
+
+func (x *DHCP) GetBroadcastAddress() string {
+	if x != nil {
+		return x.BroadcastAddress
+	}
+	return ""
+}
+
+func (x *DHCP) GetNtpServers() []string {
+	if x != nil {
+		return x.NtpServers
+	}
+	return nil
+}
+
+func (x *DHCP) GetVlanId() string {
+	if x != nil {
+		return x.VlanId
+	}
+	return ""
+}
+
+func (x *DHCP) GetLeaseTime() uint32 {
+	if x != nil {
+		return x.LeaseTime
+	}
+	return 0
+}
+
+func (x *DHCP) GetArch() string {
+	if x != nil {
+		return x.Arch
+	}
+	return ""
+}
+
+func (x *DHCP) GetDomainSearch() []string {
+	if x != nil {
+		return x.DomainSearch
+	}
+	return nil
+}
+
+func (x *DHCP) GetDisabled() bool {
+	if x != nil {
+		return x.Disabled
+	}
+	return false
+}
+
+// Netboot is the data used to netboot a client.
+type Netboot struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// If true, the client is sent netboot options in DHCP responses.
+	AllowNetboot bool `protobuf:"varint,1,opt,name=allow_netboot,json=allowNetboot,proto3" json:"allow_netboot,omitempty"`
+	// Overrides the URL of the iPXE script.
+	IpxeScriptUrl string `protobuf:"bytes,2,opt,name=ipxe_script_url,json=ipxeScriptUrl,proto3" json:"ipxe_script_url,omitempty"`
+	// Overrides the iPXE script.
+	IpxeScript string `protobuf:"bytes,3,opt,name=ipxe_script,json=ipxeScript,proto3" json:"ipxe_script,omitempty"`
+	Console    string `protobuf:"bytes,4,opt,name=console,proto3" json:"console,omitempty"`
+	// Used in the default iPXE script.
+	Facility      string `protobuf:"bytes,5,opt,name=facility,proto3" json:"facility,omitempty"`
+	Osie          *OSIE  `protobuf:"bytes,6,opt,name=osie,proto3" json:"osie,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Netboot) Reset() {
+	*x = Netboot{}
+	mi := &file_internal_backend_plugin_v1_backend_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Netboot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Netboot) ProtoMessage() {}
+
+func (x *Netboot) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_plugin_v1_backend_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Netboot.ProtoReflect.Descriptor instead.
+func (*Netboot) Descriptor() ([]byte, []int) {
+	return file_internal_backend_plugin_v1_backend_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Netboot) GetAllowNetboot() bool {
+	if x != nil {
+		return x.AllowNetboot
+	}
+	return false
+}
+
+func (x *Netboot) GetIpxeScriptUrl() string {
+	if x != nil {
+		return x.IpxeScriptUrl
+	}
+	return ""
+}
+
+func (x *Netboot) GetIpxeScript() string {
+	if x != nil {
+		return x.IpxeScript
+	}
+	return ""
+}
+
+func (x *Netboot) GetConsole() string {
+	if x != nil {
+		return x.Console
+	}
+	return ""
+}
+
+func (x *Netboot) GetFacility() string {
+	if x != nil {
+		return x.Facility
+	}
+	return ""
+}
+
+func (x *Netboot) GetOsie() *OSIE {
+	if x != nil {
+		return x.Osie
+	}
+	return nil
+}
+
+// OSIE is the location of the OS installation environment.
+type OSIE struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The URL where the kernel and initrd are located.
+	BaseUrl string `protobuf:"bytes,1,opt,name=base_url,json=baseUrl,proto3" json:"base_url,omitempty"`
+	// The name of the kernel file.
+	Kernel string `protobuf:"bytes,2,opt,name=kernel,proto3" json:"kernel,omitempty"`
+	// The name of the initrd file.
+	Initrd        string `protobuf:"bytes,3,opt,name=initrd,proto3" json:"initrd,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OSIE) Reset() {
+	*x = OSIE{}
+	mi := &file_internal_backend_plugin_v1_backend_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OSIE) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OSIE) ProtoMessage() {}
+
+func (x *OSIE) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_backend_plugin_v1_backend_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OSIE.ProtoReflect.Descriptor instead.
+func (*OSIE) Descriptor() ([]byte, []int) {
+	return file_internal_backend_plugin_v1_backend_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *OSIE) GetBaseUrl() string {
+	if x != nil {
+		return x.BaseUrl
+	}
+	return ""
+}
+
+func (x *OSIE) GetKernel() string {
+	if x != nil {
+		return x.Kernel
+	}
+	return ""
+}
+
+func (x *OSIE) GetInitrd() string {
+	if x != nil {
+		return x.Initrd
+	}
+	return ""
+}
+
+var File_internal_backend_plugin_v1_backend_proto protoreflect.FileDescriptor
+
+const file_internal_backend_plugin_v1_backend_proto_rawDesc = "" +
+	"\n" +
+	"(internal/backend/plugin/v1/backend.proto\x12\x0fsmee.backend.v1\"2\n" +
+	"\x0fGetByMacRequest\x12\x1f\n" +
+	"\vmac_address\x18\x01 \x01(\tR\n" +
+	"macAddress\"/\n" +
+	"\x0eGetByIPRequest\x12\x1d\n" +
+	"\n" +
+	"ip_address\x18\x01 \x01(\tR\tipAddress\"\x0e\n" +
+	"\fWatchRequest\"\xdd\x01\n" +
+	"\n" +
+	"WatchEvent\x124\n" +
+	"\x04type\x18\x01 \x01(\x0e2 .smee.backend.v1.WatchEvent.TypeR\x04type\x125\n" +
+	"\bhardware\x18\x02 \x01(\v2\x19.smee.backend.v1.HardwareR\bhardware\"b\n" +
+	"\x04Type\x12\x14\n" +
+	"\x10TYPE_UNSPECIFIED\x10\x00\x12\x0e\n" +
+	"\n" +
+	"TYPE_ADDED\x10\x01\x12\x11\n" +
+	"\rTYPE_MODIFIED\x10\x02\x12\x10\n" +
+	"\fTYPE_DELETED\x10\x03\x12\x0f\n" +
+	"\vTYPE_SYNCED\x10\x04\"i\n" +
+	"\bHardware\x12)\n" +
+	"\x04dhcp\x18\x01 \x01(\v2\x15.smee.backend.v1.DHCPR\x04dhcp\x122\n" +
+	"\anetboot\x18\x02 \x01(\v2\x18.smee.backend.v1.NetbootR\anetboot\"\xcb\x03\n" +
+	"\x04DHCP\x12\x1f\n" +
+	"\vmac_address\x18\x01 \x01(\tR\n" +
+	"macAddress\x12\x1d\n" +
+	"\n" +
+	"ip_address\x18\x02 \x01(\tR\tipAddress\x12\x1f\n" +
+	"\vsubnet_mask\x18\x03 \x01(\tR\n" +
+	"subnetMask\x12'\n" +
+	"\x0fdefault_gateway\x18\x04 \x01(\tR\x0edefaultGateway\x12!\n" +
+	"\fname_servers\x18\x05 \x03(\tR\vnameServers\x12\x1a\n" +
+	"\bhostname\x18\x06 \x01(\tR\bhostname\x12\x1f\n" +
+	"\vdomain_name\x18\a \x01(\tR\n" +
+	"domainName\x12+\n" +
+	"\x11broadcast_address\x18\b \x01(\tR\x10broadcastAddress\x12\x1f\n" +
+	"\vntp_servers\x18\t \x03(\tR\n" +
+	"ntpServers\x12\x17\n" +
+	"\avlan_id\x18\n" +
+	" \x01(\tR\x06vlanId\x12\x1d\n" +
+	"\n" +
+	"lease_time\x18\v \x01(\rR\tleaseTime\x12\x12\n" +
+	"\x04arch\x18\f \x01(\tR\x04arch\x12#\n" +
+	"\rdomain_search\x18\r \x03(\tR\fdomainSearch\x12\x1a\n" +
+	"\bdisabled\x18\x0e \x01(\bR\bdisabled\"\xd8\x01\n" +
+	"\aNetboot\x12#\n" +
+	"\rallow_netboot\x18\x01 \x01(\bR\fallowNetboot\x12&\n" +
+	"\x0fipxe_script_url\x18\x02 \x01(\tR\ripxeScriptUrl\x12\x1f\n" +
+	"\vipxe_script\x18\x03 \x01(\tR\n" +
+	"ipxeScript\x12\x18\n" +
+	"\aconsole\x18\x04 \x01(\tR\aconsole\x12\x1a\n" +
+	"\bfacility\x18\x05 \x01(\tR\bfacility\x12)\n" +
+	"\x04osie\x18\x06 \x01(\v2\x15.smee.backend.v1.OSIER\x04osie\"Q\n" +
+	"\x04OSIE\x12\x19\n" +
+	"\bbase_url\x18\x01 \x01(\tR\abaseUrl\x12\x16\n" +
+	"\x06kernel\x18\x02 \x01(\tR\x06kernel\x12\x16\n" +
+	"\x06initrd\x18\x03 \x01(\tR\x06initrd2\xe0\x01\n" +
+	"\aBackend\x12G\n" +
+	"\bGetByMac\x12 .smee.backend.v1.GetByMacRequest\x1a\x19.smee.backend.v1.Hardware\x12E\n" +
+	"\aGetByIP\x12\x1f.smee.backend.v1.GetByIPRequest\x1a\x19.smee.backend.v1.Hardware\x12E\n" +
+	"\x05Watch\x12\x1d.smee.backend.v1.WatchRequest\x1a\x1b.smee.backend.v1.WatchEvent0\x01B:Z8github.com/tinkerbell/smee/internal/backend/plugin/v1;v1b\x06proto3"
+
+var (
+	file_internal_backend_plugin_v1_backend_proto_rawDescOnce sync.Once
+	file_internal_backend_plugin_v1_backend_proto_rawDescData []byte
+)
+
+func file_internal_backend_plugin_v1_backend_proto_rawDescGZIP() []byte {
+	file_internal_backend_plugin_v1_backend_proto_rawDescOnce.Do(func() {
+		file_internal_backend_plugin_v1_backend_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_internal_backend_plugin_v1_backend_proto_rawDesc), len(file_internal_backend_plugin_v1_backend_proto_rawDesc)))
+	})
+	return file_internal_backend_plugin_v1_backend_proto_rawDescData
+}
+
+var file_internal_backend_plugin_v1_backend_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_internal_backend_plugin_v1_backend_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_internal_backend_plugin_v1_backend_proto_goTypes = []any{
+	(WatchEvent_Type)(0),    // 0: smee.backend.v1.WatchEvent.Type
+	(*GetByMacRequest)(nil), // 1: smee.backend.v1.GetByMacRequest
+	(*GetByIPRequest)(nil),  // 2: smee.backend.v1.GetByIPRequest
+	(*WatchRequest)(nil),    // 3: smee.backend.v1.WatchRequest
+	(*WatchEvent)(nil),      // 4: smee.backend.v1.WatchEvent
+	(*Hardware)(nil),        // 5: smee.backend.v1.Hardware
+	(*DHCP)(nil),            // 6: smee.backend.v1.DHCP
+	(*Netboot)(nil),         // 7: smee.backend.v1.Netboot
+	(*OSIE)(nil),            // 8: smee.backend.v1.OSIE
+}
+var file_internal_backend_plugin_v1_backend_proto_depIdxs = []int32{
+	0, // 0: smee.backend.v1.WatchEvent.type:type_name -> smee.backend.v1.WatchEvent.Type
+	5, // 1: smee.backend.v1.WatchEvent.hardware:type_name -> smee.backend.v1.Hardware
+	6, // 2: smee.backend.v1.Hardware.dhcp:type_name -> smee.backend.v1.DHCP
+	7, // 3: smee.backend.v1.Hardware.netboot:type_name -> smee.backend.v1.Netboot
+	8, // 4: smee.backend.v1.Netboot.osie:type_name -> smee.backend.v1.OSIE
+	1, // 5: smee.backend.v1.Backend.GetByMac:input_type -> smee.backend.v1.GetByMacRequest
+	2, // 6: smee.backend.v1.Backend.GetByIP:input_type -> smee.backend.v1.GetByIPRequest
+	3, // 7: smee.backend.v1.Backend.Watch:input_type -> smee.backend.v1.WatchRequest
+	5, // 8: smee.backend.v1.Backend.GetByMac:output_type -> smee.backend.v1.Hardware
+	5, // 9: smee.backend.v1.Backend.GetByIP:output_type -> smee.backend.v1.Hardware
+	4, // 10: smee.backend.v1.Backend.Watch:output_type -> smee.backend.v1.WatchEvent
+	8, // [8:11] is the sub-list for method output_type
+	5, // [5:8] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_internal_backend_plugin_v1_backend_proto_init() }
+func file_internal_backend_plugin_v1_backend_proto_init() {
+	if File_internal_backend_plugin_v1_backend_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_backend_plugin_v1_backend_proto_rawDesc), len(file_internal_backend_plugin_v1_backend_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_internal_backend_plugin_v1_backend_proto_goTypes,
+		DependencyIndexes: file_internal_backend_plugin_v1_backend_proto_depIdxs,
+		EnumInfos:         file_internal_backend_plugin_v1_backend_proto_enumTypes,
+		MessageInfos:      file_internal_backend_plugin_v1_backend_proto_msgTypes,
+	}.Build()
+	File_internal_backend_plugin_v1_backend_proto = out.File
+	file_internal_backend_plugin_v1_backend_proto_goTypes = nil
+	file_internal_backend_plugin_v1_backend_proto_depIdxs = nil
+}
@@ -0,0 +1,137 @@
+// Package record converts hardware records whose fields are strings, as REST documents and SQL rows have them,
+// to data.DHCP and data.Netboot.
+package record
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+)
+
+// Hardware is the DHCP and netboot data of a network interface. MACAddress and IPAddress are required.
+type Hardware struct {
+	MACAddress       string   // chaddr DHCP header.
+	IPAddress        string   // yiaddr DHCP header.
+	SubnetMask       string   // DHCP option 1.
+	DefaultGateway   string   // DHCP option 3.
+	NameServers      []string // DHCP option 6.
+	Hostname         string   // DHCP option 12.
+	DomainName       string   // DHCP option 15.
+	BroadcastAddress string   // DHCP option 28.
+	NTPServers       []string // DHCP option 42.
+	VLANID           string   // DHCP option 43.116.
+	LeaseTime        uint32   // DHCP option 51.
+	Arch             string   // DHCP option 93.
+	DomainSearch     []string // DHCP option 119.
+	Disabled         bool     // If true, no DHCP response should be sent.
+
+	AllowNetboot  bool // If true, the client will be provided netboot options in the DHCP offer/ack.
+	IPXEScriptURL string
+	IPXEScript    string
+	Console       string
+	Facility      string
+	OSIEBaseURL   string
+	OSIEKernel    string
+	OSIEInitrd    string
+}
+
+// Translate converts h to data.DHCP and data.Netboot.
+// If required fields are missing or fields are not valid, an error is returned.
+func (h Hardware) Translate() (*data.DHCP, *data.Netboot, error) {
+	d := new(data.DHCP)
+	n := new(data.Netboot)
+
+	var err error
+	// mac address, required
+	if d.MACAddress, err = net.ParseMAC(h.MACAddress); err != nil {
+		return nil, nil, fmt.Errorf("invalid mac address: %w", err)
+	}
+
+	// ip address, required
+	if d.IPAddress, err = netip.ParseAddr(h.IPAddress); err != nil {
+		return nil, nil, fmt.Errorf("invalid ip address: %w", err)
+	}
+
+	// subnet mask, optional, it can come from the subnet defaults of the DHCP server
+	if h.SubnetMask != "" {
+		sm := net.ParseIP(h.SubnetMask).To4()
+		if sm == nil {
+			return nil, nil, errors.New("invalid subnet mask")
+		}
+		d.SubnetMask = net.IPMask(sm)
+	}
+
+	// default gateway, optional
+	if h.DefaultGateway != "" {
+		if d.DefaultGateway, err = netip.ParseAddr(h.DefaultGateway); err != nil {
+			return nil, nil, fmt.Errorf("invalid default gateway: %w", err)
+		}
+	}
+
+	// name servers, optional
+	if d.NameServers, err = parseIPs(h.NameServers); err != nil {
+		return nil, nil, fmt.Errorf("invalid name servers: %w", err)
+	}
+
+	// broadcast address, optional
+	if h.BroadcastAddress != "" {
+		if d.BroadcastAddress, err = netip.ParseAddr(h.BroadcastAddress); err != nil {
+			return nil, nil, fmt.Errorf("invalid broadcast address: %w", err)
+		}
+	}
+
+	// ntp servers, optional
+	if d.NTPServers, err = parseIPs(h.NTPServers); err != nil {
+		return nil, nil, fmt.Errorf("invalid ntp servers: %w", err)
+	}
+
+	d.Hostname = h.Hostname
+	d.DomainName = h.DomainName
+	d.VLANID = h.VLANID
+	// lease time, optional, it can come from the subnet defaults of the DHCP server
+	d.LeaseTime = h.LeaseTime
+	d.Arch = h.Arch
+	d.DomainSearch = slices.Clone(h.DomainSearch)
+	d.Disabled = h.Disabled
+
+	n.AllowNetboot = h.AllowNetboot
+
+	// ipxe script url is optional but if provided, it must be a valid url
+	if h.IPXEScriptURL != "" {
+		if n.IPXEScriptURL, err = url.ParseRequestURI(h.IPXEScriptURL); err != nil {
+			return nil, nil, fmt.Errorf("invalid ipxe script url: %w", err)
+		}
+	}
+	n.IPXEScript = h.IPXEScript
+	n.Console = h.Console
+	n.Facility = h.Facility
+
+	// osie base url is optional but if provided, it must be a valid url
+	if h.OSIEBaseURL != "" {
+		if n.OSIE.BaseURL, err = url.Parse(h.OSIEBaseURL); err != nil {
+			return nil, nil, fmt.Errorf("invalid osie base url: %w", err)
+		}
+	}
+	n.OSIE.Kernel = h.OSIEKernel
+	n.OSIE.Initrd = h.OSIEInitrd
+
+	return d, n, nil
+}
+
+func parseIPs(s []string) ([]net.IP, error) {
+	var ips []net.IP
+	for _, e := range s {
+		ip := net.ParseIP(e)
+		if ip == nil {
+			return nil, fmt.Errorf("%q is not an IP address", e)
+		}
+		ips = append(ips, ip)
+	}
+
+	return ips, nil
+}
@@ -0,0 +1,98 @@
+package record
+
+import (
+	"net"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := map[string]struct {
+		hw          Hardware
+		wantDHCP    *data.DHCP
+		wantNetboot *data.Netboot
+		wantErr     bool
+	}{
+		"all fields": {
+			hw: Hardware{
+				MACAddress:     "08:00:27:29:4e:67",
+				IPAddress:      "192.168.2.153",
+				SubnetMask:     "255.255.255.0",
+				DefaultGateway: "192.168.2.1",
+				NameServers:    []string{"1.1.1.1"},
+				Hostname:       "sandbox",
+				LeaseTime:      86400,
+				DomainSearch:   []string{"example.com"},
+				AllowNetboot:   true,
+				IPXEScriptURL:  "http://boot.netboot.xyz",
+				OSIEBaseURL:    "http://10.1.1.1:8080",
+				OSIEKernel:     "vmlinuz-x86_64",
+			},
+			wantDHCP: &data.DHCP{
+				MACAddress:     net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67},
+				IPAddress:      netip.MustParseAddr("192.168.2.153"),
+				SubnetMask:     net.IPv4Mask(255, 255, 255, 0),
+				DefaultGateway: netip.MustParseAddr("192.168.2.1"),
+				NameServers:    []net.IP{net.ParseIP("1.1.1.1")},
+				Hostname:       "sandbox",
+				LeaseTime:      86400,
+				DomainSearch:   []string{"example.com"},
+			},
+			wantNetboot: &data.Netboot{
+				AllowNetboot:  true,
+				IPXEScriptURL: &url.URL{Scheme: "http", Host: "boot.netboot.xyz"},
+				OSIE: data.OSIE{
+					BaseURL: &url.URL{Scheme: "http", Host: "10.1.1.1:8080"},
+					Kernel:  "vmlinuz-x86_64",
+				},
+			},
+		},
+		"required fields only": {
+			hw: Hardware{MACAddress: "08:00:27:29:4e:67", IPAddress: "192.168.2.153"},
+			wantDHCP: &data.DHCP{
+				MACAddress: net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67},
+				IPAddress:  netip.MustParseAddr("192.168.2.153"),
+			},
+			wantNetboot: &data.Netboot{},
+		},
+		"missing mac address": {
+			hw:      Hardware{IPAddress: "192.168.2.153"},
+			wantErr: true,
+		},
+		"invalid ip address": {
+			hw:      Hardware{MACAddress: "08:00:27:29:4e:67", IPAddress: "not an ip"},
+			wantErr: true,
+		},
+		"invalid subnet mask": {
+			hw:      Hardware{MACAddress: "08:00:27:29:4e:67", IPAddress: "192.168.2.153", SubnetMask: "ffff::"},
+			wantErr: true,
+		},
+		"invalid name server": {
+			hw:      Hardware{MACAddress: "08:00:27:29:4e:67", IPAddress: "192.168.2.153", NameServers: []string{"dns"}},
+			wantErr: true,
+		},
+		"invalid ipxe script url": {
+			hw:      Hardware{MACAddress: "08:00:27:29:4e:67", IPAddress: "192.168.2.153", IPXEScriptURL: "boot.ipxe"},
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			d, n, err := tt.hw.Translate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Translate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.wantDHCP, d, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+				t.Error(diff)
+			}
+			if diff := cmp.Diff(tt.wantNetboot, n); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package rest
+
+import (
+	"github.com/tinkerbell/smee/internal/backend/record"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+)
+
+// translate converts a Hardware document to data.DHCP and data.Netboot.
+// If required fields are missing or fields are not valid, an error is returned.
+func (h *Hardware) translate() (*data.DHCP, *data.Netboot, error) {
+	return record.Hardware{
+		MACAddress:       h.DHCP.MACAddress,
+		IPAddress:        h.DHCP.IPAddress,
+		SubnetMask:       h.DHCP.SubnetMask,
+		DefaultGateway:   h.DHCP.DefaultGateway,
+		NameServers:      h.DHCP.NameServers,
+		Hostname:         h.DHCP.Hostname,
+		DomainName:       h.DHCP.DomainName,
+		BroadcastAddress: h.DHCP.BroadcastAddress,
+		NTPServers:       h.DHCP.NTPServers,
+		VLANID:           h.DHCP.VLANID,
+		LeaseTime:        h.DHCP.LeaseTime,
+		Arch:             h.DHCP.Arch,
+		DomainSearch:     h.DHCP.DomainSearch,
+		Disabled:         h.DHCP.Disabled,
+		AllowNetboot:     h.Netboot.AllowNetboot,
+		IPXEScriptURL:    h.Netboot.IPXEScriptURL,
+		IPXEScript:       h.Netboot.IPXEScript,
+		Console:          h.Netboot.Console,
+		Facility:         h.Netboot.Facility,
+		OSIEBaseURL:      h.Netboot.OSIE.BaseURL,
+		OSIEKernel:       h.Netboot.OSIE.Kernel,
+		OSIEInitrd:       h.Netboot.OSIE.Initrd,
+	}.Translate()
+}
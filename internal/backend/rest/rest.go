@@ -0,0 +1,287 @@
+// Package rest is a backend that gets DHCP and netboot data from a REST endpoint, for example an inventory system or CMDB.
+//
+// Lookups are GET requests to the URL of the backend with a "mac" or an "ip" query parameter:
+//
+//	GET <url>?mac=08:00:27:29:4e:67
+//	GET <url>?ip=192.168.2.153
+//
+// The endpoint responds with 200 and a Hardware JSON document, or with 404 when there is no hardware for the MAC or IP address.
+// Requests that fail, or that the endpoint responds to with 429 or a 5xx status, are retried.
+package rest
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const tracerName = "github.com/tinkerbell/smee/dhcp"
+
+// maxResponseSize is the maximum size of a response body that is read.
+const maxResponseSize = 1 << 20
+
+// Config is the configuration of the backend.
+type Config struct {
+	// URL is the endpoint that lookups are sent to.
+	URL string
+	// Token is sent as a bearer token in the Authorization header of every request, when set.
+	Token string
+	// Timeout is the timeout of a single request. 0 means no timeout.
+	Timeout time.Duration
+	// Retries is the number of times a request is retried.
+	Retries int
+	// RetryDelay is the delay before the first retry. It is doubled for every retry after it.
+	RetryDelay time.Duration
+	// CAFile is the file with the PEM encoded CA certificates used to verify the endpoint. The system CAs are used when it is not set.
+	CAFile string
+	// CertFile and KeyFile are the files with the PEM encoded client certificate and key used to authenticate to the endpoint.
+	CertFile string
+	KeyFile  string
+	// InsecureSkipVerify disables verification of the endpoint's certificate.
+	InsecureSkipVerify bool
+}
+
+// Backend gets DHCP and netboot data from a REST endpoint.
+type Backend struct {
+	// URL is the endpoint that lookups are sent to.
+	URL *url.URL
+	// Client is the HTTP client used for requests.
+	Client *http.Client
+	// Token is sent as a bearer token in the Authorization header of every request, when set.
+	Token string
+	// Retries is the number of times a request is retried.
+	Retries int
+	// RetryDelay is the delay before the first retry. It is doubled for every retry after it.
+	RetryDelay time.Duration
+	// Log is the logger to be used in the REST backend.
+	Log logr.Logger
+}
+
+// Hardware is the JSON document that the endpoint responds with.
+type Hardware struct {
+	DHCP    DHCP    `json:"dhcp"`
+	Netboot Netboot `json:"netboot"`
+}
+
+// DHCP is the DHCP data of a Hardware document. macAddress and ipAddress are required.
+type DHCP struct {
+	MACAddress       string   `json:"macAddress"`       // chaddr DHCP header.
+	IPAddress        string   `json:"ipAddress"`        // yiaddr DHCP header.
+	SubnetMask       string   `json:"subnetMask"`       // DHCP option 1.
+	DefaultGateway   string   `json:"defaultGateway"`   // DHCP option 3.
+	NameServers      []string `json:"nameServers"`      // DHCP option 6.
+	Hostname         string   `json:"hostname"`         // DHCP option 12.
+	DomainName       string   `json:"domainName"`       // DHCP option 15.
+	BroadcastAddress string   `json:"broadcastAddress"` // DHCP option 28.
+	NTPServers       []string `json:"ntpServers"`       // DHCP option 42.
+	VLANID           string   `json:"vlanID"`           // DHCP option 43.116.
+	LeaseTime        uint32   `json:"leaseTime"`        // DHCP option 51. Defaults to the subnet lease time.
+	Arch             string   `json:"arch"`             // DHCP option 93.
+	DomainSearch     []string `json:"domainSearch"`     // DHCP option 119.
+	Disabled         bool     `json:"disabled"`         // If true, no DHCP response should be sent.
+}
+
+// Netboot is the netboot data of a Hardware document.
+type Netboot struct {
+	AllowNetboot  bool   `json:"allowNetboot"`  // If true, the client will be provided netboot options in the DHCP offer/ack.
+	IPXEScriptURL string `json:"ipxeScriptURL"` // Overrides a default value that is passed into DHCP on startup.
+	IPXEScript    string `json:"ipxeScript"`    // Overrides a default value that is passed into DHCP on startup.
+	Console       string `json:"console"`
+	Facility      string `json:"facility"`
+	OSIE          OSIE   `json:"osie"`
+}
+
+// OSIE is the OS installation environment data of a Hardware document.
+type OSIE struct {
+	BaseURL string `json:"baseURL"` // The URL where the kernel and initrd are located.
+	Kernel  string `json:"kernel"`  // The name of the kernel file.
+	Initrd  string `json:"initrd"`  // The name of the initrd file.
+}
+
+// NewBackend returns a Backend for the configuration.
+func NewBackend(c Config, l logr.Logger) (*Backend, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid URL %q: scheme must be http or https", c.URL)
+	}
+	tc, err := tlsConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = tc
+
+	return &Backend{
+		URL:        u,
+		Client:     &http.Client{Transport: t, Timeout: c.Timeout},
+		Token:      c.Token,
+		Retries:    c.Retries,
+		RetryDelay: c.RetryDelay,
+		Log:        l,
+	}, nil
+}
+
+func tlsConfig(c Config) (*tls.Config, error) {
+	tc := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.InsecureSkipVerify, //nolint:gosec // It is up to the user to decide.
+	}
+	if c.CAFile != "" {
+		b, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		tc.RootCAs = x509.NewCertPool()
+		if !tc.RootCAs.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in CA file %q", c.CAFile)
+		}
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+
+	return tc, nil
+}
+
+// GetByMac implements the handler.BackendReader interface and returns DHCP and netboot data based on a mac address.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.rest.GetByMac")
+	defer span.End()
+
+	d, n, err := b.get(ctx, "mac", mac.String())
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, err
+	}
+
+	span.SetAttributes(d.EncodeToAttributes()...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// GetByIP implements the handler.BackendReader interface and returns DHCP and netboot data based on an IP address.
+func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.rest.GetByIP")
+	defer span.End()
+
+	d, n, err := b.get(ctx, "ip", ip.String())
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, err
+	}
+
+	span.SetAttributes(d.EncodeToAttributes()...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// get requests the hardware from the endpoint and translates it.
+func (b *Backend) get(ctx context.Context, param, value string) (*data.DHCP, *data.Netboot, error) {
+	hw, err := b.fetch(ctx, param, value)
+	if err != nil {
+		return nil, nil, err
+	}
+	if hw == nil {
+		return nil, nil, handler.NotFoundError{Key: param + "=" + value}
+	}
+
+	return hw.translate()
+}
+
+// fetch requests the hardware from the endpoint, retrying requests that fail.
+// The hardware is nil when the endpoint responds that there is none.
+func (b *Backend) fetch(ctx context.Context, param, value string) (*Hardware, error) {
+	u := *b.URL
+	q := u.Query()
+	q.Set(param, value)
+	u.RawQuery = q.Encode()
+
+	delay := b.RetryDelay
+	for attempt := 0; ; attempt++ {
+		hw, retry, err := b.do(ctx, u.String())
+		if err == nil {
+			return hw, nil
+		}
+		if !retry || attempt >= b.Retries {
+			return nil, fmt.Errorf("failed to get hardware for %s %s: %w", param, value, err)
+		}
+		b.Log.V(1).Info("retrying request", "url", u.String(), "attempt", attempt+1, "err", err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// do does a single request. retry reports whether the request can be retried.
+// The hardware is nil when the endpoint responds with 404.
+func (b *Backend) do(ctx context.Context, u string) (hw *Hardware, retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if b.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.Token)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := b.Client.Do(req)
+	if err != nil {
+		return nil, ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, true, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return nil, true, fmt.Errorf("unexpected status: %s", resp.Status)
+	default:
+		return nil, false, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	hw = new(Hardware)
+	if err := json.Unmarshal(body, hw); err != nil {
+		return nil, false, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return hw, false, nil
+}
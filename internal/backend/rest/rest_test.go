@@ -0,0 +1,328 @@
+package rest
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/subnet"
+)
+
+const hardware = `{
+  "dhcp": {
+    "macAddress": "08:00:27:29:4e:67",
+    "ipAddress": "192.168.2.153",
+    "subnetMask": "255.255.255.0",
+    "defaultGateway": "192.168.2.1",
+    "nameServers": ["1.1.1.1"],
+    "hostname": "sandbox",
+    "leaseTime": 86400,
+    "arch": "x86_64"
+  },
+  "netboot": {
+    "allowNetboot": true,
+    "ipxeScriptURL": "http://boot.netboot.xyz",
+    "facility": "onprem",
+    "osie": {"baseURL": "http://10.1.1.1:8080", "kernel": "vmlinuz-x86_64", "initrd": "initramfs-x86_64"}
+  }
+}`
+
+func TestGetByMac(t *testing.T) {
+	tests := map[string]struct {
+		handler     func(attempt int32) (int, string)
+		retries     int
+		wantDHCP    *data.DHCP
+		wantNetboot *data.Netboot
+		wantErr     bool
+		notFound    bool
+		wantCalls   int32
+	}{
+		"found": {
+			handler: func(int32) (int, string) { return http.StatusOK, hardware },
+			wantDHCP: &data.DHCP{
+				MACAddress:     net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67},
+				IPAddress:      netip.MustParseAddr("192.168.2.153"),
+				SubnetMask:     net.IPv4Mask(255, 255, 255, 0),
+				DefaultGateway: netip.MustParseAddr("192.168.2.1"),
+				NameServers:    []net.IP{net.ParseIP("1.1.1.1")},
+				Hostname:       "sandbox",
+				LeaseTime:      86400,
+				Arch:           "x86_64",
+			},
+			wantNetboot: &data.Netboot{
+				AllowNetboot:  true,
+				IPXEScriptURL: &url.URL{Scheme: "http", Host: "boot.netboot.xyz"},
+				Facility:      "onprem",
+				OSIE: data.OSIE{
+					BaseURL: &url.URL{Scheme: "http", Host: "10.1.1.1:8080"},
+					Kernel:  "vmlinuz-x86_64",
+					Initrd:  "initramfs-x86_64",
+				},
+			},
+			wantCalls: 1,
+		},
+		"not found": {
+			handler:   func(int32) (int, string) { return http.StatusNotFound, "" },
+			retries:   2,
+			wantErr:   true,
+			notFound:  true,
+			wantCalls: 1,
+		},
+		"retried": {
+			handler: func(attempt int32) (int, string) {
+				if attempt < 3 {
+					return http.StatusServiceUnavailable, ""
+				}
+				return http.StatusOK, hardware
+			},
+			retries:   2,
+			wantCalls: 3,
+		},
+		"retries exhausted": {
+			handler:   func(int32) (int, string) { return http.StatusInternalServerError, "" },
+			retries:   2,
+			wantErr:   true,
+			wantCalls: 3,
+		},
+		"not retried": {
+			handler:   func(int32) (int, string) { return http.StatusBadRequest, "" },
+			retries:   2,
+			wantErr:   true,
+			wantCalls: 1,
+		},
+		"invalid json": {
+			handler:   func(int32) (int, string) { return http.StatusOK, "{" },
+			wantErr:   true,
+			wantCalls: 1,
+		},
+		"invalid hardware": {
+			handler:   func(int32) (int, string) { return http.StatusOK, `{"dhcp": {"macAddress": "08:00:27:29:4e:67"}}` },
+			wantErr:   true,
+			wantCalls: 1,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := calls.Add(1)
+				if got := r.URL.Query().Get("mac"); got != "08:00:27:29:4e:67" {
+					t.Errorf("mac = %q, want 08:00:27:29:4e:67", got)
+				}
+				code, body := tt.handler(n)
+				w.WriteHeader(code)
+				_, _ = w.Write([]byte(body))
+			}))
+			defer srv.Close()
+
+			b, err := NewBackend(Config{URL: srv.URL, Retries: tt.retries, RetryDelay: time.Millisecond}, logr.Discard())
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, n, err := b.GetByMac(context.Background(), net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetByMac() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var nf interface{ NotFound() bool }
+			if got := errors.As(err, &nf) && nf.NotFound(); got != tt.notFound {
+				t.Errorf("NotFound() = %v, want %v", got, tt.notFound)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+			if tt.wantDHCP != nil {
+				if diff := cmp.Diff(tt.wantDHCP, d, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+					t.Error(diff)
+				}
+			}
+			if tt.wantNetboot != nil {
+				if diff := cmp.Diff(tt.wantNetboot, n); diff != "" {
+					t.Error(diff)
+				}
+			}
+		})
+	}
+}
+
+func TestGetByIP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("ip"); got != "192.168.2.153" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if got := r.URL.Query().Get("site"); got != "lab" {
+			t.Errorf("site = %q, want lab", got)
+		}
+		_, _ = w.Write([]byte(hardware))
+	}))
+	defer srv.Close()
+
+	b, err := NewBackend(Config{URL: srv.URL + "/hardware?site=lab"}, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _, err := b.GetByIP(context.Background(), net.IPv4(192, 168, 2, 153))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.IPAddress != netip.MustParseAddr("192.168.2.153") {
+		t.Errorf("IPAddress = %v, want 192.168.2.153", d.IPAddress)
+	}
+}
+
+func TestSubnetLeaseTime(t *testing.T) {
+	s := subnet.Subnet{
+		Prefix:  netip.MustParsePrefix("192.168.2.0/24"),
+		Options: data.DHCP{LeaseTime: 3600},
+	}
+	tests := map[string]struct {
+		hardware string
+		want     uint32
+	}{
+		"lease time from the subnet": {
+			hardware: `{"dhcp": {"macAddress": "08:00:27:29:4e:67", "ipAddress": "192.168.2.153"}}`,
+			want:     3600,
+		},
+		"lease time from the hardware": {
+			hardware: hardware,
+			want:     86400,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(tt.hardware))
+			}))
+			defer srv.Close()
+
+			b, err := NewBackend(Config{URL: srv.URL}, logr.Discard())
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, _, err := b.GetByMac(context.Background(), net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.Apply(d)
+			if d.LeaseTime != tt.want {
+				t.Errorf("LeaseTime = %d, want %d", d.LeaseTime, tt.want)
+			}
+		})
+	}
+}
+
+func TestToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(hardware))
+	}))
+	defer srv.Close()
+
+	tests := map[string]struct {
+		token   string
+		wantErr bool
+	}{
+		"valid token":   {token: "secret"},
+		"invalid token": {token: "wrong", wantErr: true},
+		"no token":      {wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b, err := NewBackend(Config{URL: srv.URL, Token: tt.token}, logr.Discard())
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _, err = b.GetByMac(context.Background(), net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetByMac() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	b, err := NewBackend(Config{URL: srv.URL, Timeout: 10 * time.Millisecond}, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := b.GetByMac(context.Background(), net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67}); err == nil {
+		t.Fatal("GetByMac() error = nil, want timeout")
+	}
+}
+
+func TestTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(hardware))
+	}))
+	defer srv.Close()
+
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		config  Config
+		wantErr bool
+	}{
+		"ca file":              {config: Config{CAFile: ca}},
+		"insecure skip verify": {config: Config{InsecureSkipVerify: true}},
+		"unknown ca":           {wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.config.URL = srv.URL
+			b, err := NewBackend(tt.config, logr.Discard())
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _, err = b.GetByMac(context.Background(), net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetByMac() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewBackendErrors(t *testing.T) {
+	tests := map[string]Config{
+		"invalid url":       {URL: "://"},
+		"invalid scheme":    {URL: "ftp://10.1.1.1"},
+		"ca file not found": {URL: "https://10.1.1.1", CAFile: "not-found.pem"},
+		"ca file not pem":   {URL: "https://10.1.1.1", CAFile: "rest_test.go"},
+		"key not found":     {URL: "https://10.1.1.1", CertFile: "not-found.pem", KeyFile: "not-found.pem"},
+	}
+	for name, c := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewBackend(c, logr.Discard()); err == nil {
+				t.Fatal("NewBackend() error = nil, want error")
+			}
+		})
+	}
+}
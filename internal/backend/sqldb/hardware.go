@@ -0,0 +1,102 @@
+package sqldb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tinkerbell/smee/internal/backend/record"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+)
+
+// columns are the columns of a lookup, in the order of the fields scanned by hardware.scan.
+const columns = `i.mac_address, i.ip_address, i.subnet_mask, i.default_gateway, i.name_servers, m.hostname, i.domain_name,
+	i.broadcast_address, i.ntp_servers, i.vlan_id, i.lease_time, m.arch, i.domain_search, i.disabled, m.facility,
+	COALESCE(n.allow_netboot, FALSE), COALESCE(n.ipxe_script_url, ''), COALESCE(n.ipxe_script, ''), COALESCE(n.console, ''),
+	COALESCE(n.osie_base_url, ''), COALESCE(n.osie_kernel, ''), COALESCE(n.osie_initrd, '')
+FROM interfaces i
+JOIN machines m ON m.id = i.machine_id
+LEFT JOIN netboot n ON n.machine_id = m.id`
+
+// hardware is a row of a lookup: an interface with the data of its machine and the netboot settings of the machine.
+type hardware struct {
+	MACAddress       string
+	IPAddress        string
+	SubnetMask       string
+	DefaultGateway   string
+	NameServers      string
+	Hostname         string
+	DomainName       string
+	BroadcastAddress string
+	NTPServers       string
+	VLANID           string
+	LeaseTime        int64
+	Arch             string
+	DomainSearch     string
+	Disabled         bool
+	Facility         string
+	AllowNetboot     bool
+	IPXEScriptURL    string
+	IPXEScript       string
+	Console          string
+	OSIEBaseURL      string
+	OSIEKernel       string
+	OSIEInitrd       string
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (h *hardware) scan(s scanner) error {
+	return s.Scan(
+		&h.MACAddress, &h.IPAddress, &h.SubnetMask, &h.DefaultGateway, &h.NameServers, &h.Hostname, &h.DomainName,
+		&h.BroadcastAddress, &h.NTPServers, &h.VLANID, &h.LeaseTime, &h.Arch, &h.DomainSearch, &h.Disabled, &h.Facility,
+		&h.AllowNetboot, &h.IPXEScriptURL, &h.IPXEScript, &h.Console,
+		&h.OSIEBaseURL, &h.OSIEKernel, &h.OSIEInitrd,
+	)
+}
+
+// translate converts a row to data.DHCP and data.Netboot.
+// If required columns are empty or columns are not valid, an error is returned.
+func (h *hardware) translate() (*data.DHCP, *data.Netboot, error) {
+	if h.LeaseTime < 0 || h.LeaseTime > 1<<32-1 {
+		return nil, nil, fmt.Errorf("invalid lease_time: %d", h.LeaseTime)
+	}
+
+	return record.Hardware{
+		MACAddress:       h.MACAddress,
+		IPAddress:        h.IPAddress,
+		SubnetMask:       h.SubnetMask,
+		DefaultGateway:   h.DefaultGateway,
+		NameServers:      split(h.NameServers),
+		Hostname:         h.Hostname,
+		DomainName:       h.DomainName,
+		BroadcastAddress: h.BroadcastAddress,
+		NTPServers:       split(h.NTPServers),
+		VLANID:           h.VLANID,
+		LeaseTime:        uint32(h.LeaseTime),
+		Arch:             h.Arch,
+		DomainSearch:     split(h.DomainSearch),
+		Disabled:         h.Disabled,
+		AllowNetboot:     h.AllowNetboot,
+		IPXEScriptURL:    h.IPXEScriptURL,
+		IPXEScript:       h.IPXEScript,
+		Console:          h.Console,
+		Facility:         h.Facility,
+		OSIEBaseURL:      h.OSIEBaseURL,
+		OSIEKernel:       h.OSIEKernel,
+		OSIEInitrd:       h.OSIEInitrd,
+	}.Translate()
+}
+
+// split splits a comma separated list. Empty elements are ignored.
+func split(s string) []string {
+	var l []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			l = append(l, e)
+		}
+	}
+
+	return l
+}
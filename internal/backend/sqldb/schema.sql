@@ -0,0 +1,42 @@
+-- Schema of the SQL backend. It works with PostgreSQL and SQLite.
+--
+-- A machine has one or more network interfaces. Every interface gets its own DHCP lease,
+-- and netboots with the netboot settings of its machine.
+
+CREATE TABLE IF NOT EXISTS machines (
+    id       TEXT PRIMARY KEY,
+    hostname TEXT NOT NULL DEFAULT '', -- DHCP option 12.
+    arch     TEXT NOT NULL DEFAULT '', -- DHCP option 93, for example x86_64 or aarch64.
+    facility TEXT NOT NULL DEFAULT ''
+);
+
+-- Lists are comma separated, for example '8.8.8.8,1.1.1.1'.
+CREATE TABLE IF NOT EXISTS interfaces (
+    mac_address       TEXT PRIMARY KEY, -- chaddr DHCP header, lowercase and colon separated, for example '08:00:27:29:4e:67'.
+    machine_id        TEXT NOT NULL REFERENCES machines (id) ON DELETE CASCADE,
+    ip_address        TEXT NOT NULL,                -- yiaddr DHCP header.
+    subnet_mask       TEXT NOT NULL DEFAULT '',     -- DHCP option 1.
+    default_gateway   TEXT NOT NULL DEFAULT '',     -- DHCP option 3.
+    name_servers      TEXT NOT NULL DEFAULT '',     -- DHCP option 6.
+    domain_name       TEXT NOT NULL DEFAULT '',     -- DHCP option 15.
+    broadcast_address TEXT NOT NULL DEFAULT '',     -- DHCP option 28.
+    ntp_servers       TEXT NOT NULL DEFAULT '',     -- DHCP option 42.
+    vlan_id           TEXT NOT NULL DEFAULT '',     -- DHCP option 43.116.
+    lease_time        INTEGER NOT NULL DEFAULT 0,   -- DHCP option 51, in seconds. 0 is the subnet lease time.
+    domain_search     TEXT NOT NULL DEFAULT '',     -- DHCP option 119.
+    disabled          BOOLEAN NOT NULL DEFAULT FALSE -- If true, no DHCP response is sent.
+);
+
+CREATE INDEX IF NOT EXISTS interfaces_ip_address ON interfaces (ip_address);
+
+-- Machines without netboot settings do not netboot.
+CREATE TABLE IF NOT EXISTS netboot (
+    machine_id      TEXT PRIMARY KEY REFERENCES machines (id) ON DELETE CASCADE,
+    allow_netboot   BOOLEAN NOT NULL DEFAULT FALSE,
+    ipxe_script_url TEXT NOT NULL DEFAULT '', -- Overrides the default iPXE script.
+    ipxe_script     TEXT NOT NULL DEFAULT '', -- Overrides the default iPXE script.
+    console         TEXT NOT NULL DEFAULT '',
+    osie_base_url   TEXT NOT NULL DEFAULT '', -- The URL where the OSIE kernel and initrd are located.
+    osie_kernel     TEXT NOT NULL DEFAULT '',
+    osie_initrd     TEXT NOT NULL DEFAULT ''
+);
@@ -0,0 +1,189 @@
+// Package sqldb is a backend that gets DHCP and netboot data from a SQL database with database/sql.
+//
+// The database has the tables in schema.sql: machines, their network interfaces and their netboot settings.
+// Lookups by MAC and IP address are prepared statements that use $1 placeholders, as PostgreSQL and SQLite do.
+// The driver must be registered with database/sql by the program, for example by importing github.com/jackc/pgx/v5/stdlib.
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const tracerName = "github.com/tinkerbell/smee/dhcp"
+
+// Schema is the schema of the database. It creates the tables when they do not exist.
+//
+//go:embed schema.sql
+var Schema string
+
+var errMultipleHardware = errors.New("multiple hardware found")
+
+// Config is the configuration of the backend.
+type Config struct {
+	// Driver is the name of the database/sql driver, for example pgx.
+	Driver string
+	// DSN is the data source name, for example postgres://smee@localhost:5432/smee.
+	DSN string
+	// MaxOpenConns is the maximum number of open connections in the pool. 0 means no limit.
+	MaxOpenConns int
+	// MaxIdleConns is the maximum number of idle connections in the pool.
+	MaxIdleConns int
+	// ConnMaxLifetime is how long a connection is reused. 0 means no limit.
+	ConnMaxLifetime time.Duration
+	// Timeout is the timeout of a single lookup. 0 means no timeout.
+	Timeout time.Duration
+}
+
+// Backend gets DHCP and netboot data from a SQL database.
+type Backend struct {
+	// Timeout is the timeout of a single lookup. 0 means no timeout.
+	Timeout time.Duration
+	// Log is the logger to be used in the SQL backend.
+	Log logr.Logger
+
+	db     *sql.DB
+	byMAC  *sql.Stmt
+	byIP   *sql.Stmt
+	closed sync.Once
+}
+
+// NewBackend connects to the database and prepares the lookup statements.
+// An error is returned when the database cannot be reached or does not have the tables of the schema.
+// Close must be called to close the connections.
+func NewBackend(ctx context.Context, c Config, l logr.Logger) (*Backend, error) {
+	db, err := sql.Open(c.Driver, c.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+
+	b := &Backend{
+		Timeout: c.Timeout,
+		Log:     l,
+		db:      db,
+	}
+	if b.byMAC, err = db.PrepareContext(ctx, "SELECT "+columns+" WHERE i.mac_address = $1"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to prepare MAC address lookup: %w", err)
+	}
+	if b.byIP, err = db.PrepareContext(ctx, "SELECT "+columns+" WHERE i.ip_address = $1"); err != nil {
+		b.byMAC.Close()
+		db.Close()
+		return nil, fmt.Errorf("failed to prepare IP address lookup: %w", err)
+	}
+	// Some drivers, like SQLite's, only check the tables of a statement when it runs.
+	if _, err := b.query(ctx, b.byMAC, ""); err != nil {
+		b.Close()
+		return nil, fmt.Errorf("failed to look up hardware in the database: %w", err)
+	}
+
+	return b, nil
+}
+
+// Close closes the prepared statements and the connections to the database.
+func (b *Backend) Close() error {
+	var err error
+	b.closed.Do(func() {
+		err = errors.Join(b.byMAC.Close(), b.byIP.Close(), b.db.Close())
+	})
+
+	return err
+}
+
+// GetByMac implements the handler.BackendReader interface and returns DHCP and netboot data based on a mac address.
+func (b *Backend) GetByMac(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.sql.GetByMac")
+	defer span.End()
+
+	d, n, err := b.get(ctx, b.byMAC, "mac", mac.String())
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, err
+	}
+
+	span.SetAttributes(d.EncodeToAttributes()...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// GetByIP implements the handler.BackendReader interface and returns DHCP and netboot data based on an IP address.
+func (b *Backend) GetByIP(ctx context.Context, ip net.IP) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "backend.sql.GetByIP")
+	defer span.End()
+
+	d, n, err := b.get(ctx, b.byIP, "ip", ip.String())
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, err
+	}
+
+	span.SetAttributes(d.EncodeToAttributes()...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return d, n, nil
+}
+
+// get looks up the hardware with stmt in the database and translates it.
+func (b *Backend) get(ctx context.Context, stmt *sql.Stmt, param, value string) (*data.DHCP, *data.Netboot, error) {
+	hw, err := b.query(ctx, stmt, value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get hardware for %s %s: %w", param, value, err)
+	}
+	if hw == nil {
+		return nil, nil, handler.NotFoundError{Key: param + "=" + value}
+	}
+
+	return hw.translate()
+}
+
+// query runs stmt with value. The hardware is nil when there is no row for value.
+func (b *Backend) query(ctx context.Context, stmt *sql.Stmt, value string) (*hardware, error) {
+	if b.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.Timeout)
+		defer cancel()
+	}
+	rows, err := stmt.QueryContext(ctx, value)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hw *hardware
+	for rows.Next() {
+		if hw != nil {
+			return nil, errMultipleHardware
+		}
+		hw = new(hardware)
+		if err := hw.scan(rows); err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return hw, nil
+}
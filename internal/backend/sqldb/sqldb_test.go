@@ -0,0 +1,211 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"net/netip"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	_ "modernc.org/sqlite"
+)
+
+const fixtures = `
+INSERT INTO machines (id, hostname, arch, facility) VALUES
+	('sandbox', 'sandbox', 'x86_64', 'onprem'),
+	('no-netboot', 'no-netboot', '', ''),
+	('invalid', '', '', '');
+INSERT INTO interfaces (mac_address, machine_id, ip_address, subnet_mask, default_gateway, name_servers, lease_time, domain_search) VALUES
+	('08:00:27:29:4e:67', 'sandbox', '192.168.2.153', '255.255.255.0', '192.168.2.1', '1.1.1.1, 8.8.8.8', 86400, 'example.com'),
+	('08:00:27:29:4e:68', 'sandbox', '192.168.2.154', '', '', '', 0, ''),
+	('08:00:27:29:4e:69', 'no-netboot', '192.168.2.155', '', '', '', 0, ''),
+	('08:00:27:29:4e:6a', 'invalid', 'not an ip', '', '', '', 0, ''),
+	('08:00:27:29:4e:6b', 'invalid', '192.168.2.156', '', '', '', 0, ''),
+	('08:00:27:29:4e:6c', 'invalid', '192.168.2.156', '', '', '', 0, '');
+INSERT INTO netboot (machine_id, allow_netboot, ipxe_script_url, osie_base_url, osie_kernel, osie_initrd) VALUES
+	('sandbox', TRUE, 'http://boot.netboot.xyz', 'http://10.1.1.1:8080', 'vmlinuz-x86_64', 'initramfs-x86_64');
+`
+
+// newDB creates an SQLite database with the schema and fixtures and returns a Backend for it.
+func newDB(t *testing.T, c Config) (*Backend, *sql.DB) {
+	t.Helper()
+	c.Driver = "sqlite"
+	c.DSN = "file:" + filepath.Join(t.TempDir(), "smee.db") + "?_pragma=foreign_keys(1)"
+	db, err := sql.Open(c.Driver, c.DSN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(Schema); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(fixtures); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := NewBackend(context.Background(), c, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := b.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return b, db
+}
+
+func TestGetByMac(t *testing.T) {
+	tests := map[string]struct {
+		mac          net.HardwareAddr
+		wantDHCP     *data.DHCP
+		wantNetboot  *data.Netboot
+		wantErr      bool
+		wantNotFound bool
+	}{
+		"found": {
+			mac: net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67},
+			wantDHCP: &data.DHCP{
+				MACAddress:     net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67},
+				IPAddress:      netip.MustParseAddr("192.168.2.153"),
+				SubnetMask:     net.IPv4Mask(255, 255, 255, 0),
+				DefaultGateway: netip.MustParseAddr("192.168.2.1"),
+				NameServers:    []net.IP{net.ParseIP("1.1.1.1"), net.ParseIP("8.8.8.8")},
+				Hostname:       "sandbox",
+				LeaseTime:      86400,
+				Arch:           "x86_64",
+				DomainSearch:   []string{"example.com"},
+			},
+			wantNetboot: &data.Netboot{
+				AllowNetboot:  true,
+				IPXEScriptURL: &url.URL{Scheme: "http", Host: "boot.netboot.xyz"},
+				Facility:      "onprem",
+				OSIE: data.OSIE{
+					BaseURL: &url.URL{Scheme: "http", Host: "10.1.1.1:8080"},
+					Kernel:  "vmlinuz-x86_64",
+					Initrd:  "initramfs-x86_64",
+				},
+			},
+		},
+		"second interface": {
+			mac: net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x68},
+			wantDHCP: &data.DHCP{
+				MACAddress: net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x68},
+				IPAddress:  netip.MustParseAddr("192.168.2.154"),
+				Hostname:   "sandbox",
+				Arch:       "x86_64",
+			},
+			wantNetboot: &data.Netboot{
+				AllowNetboot:  true,
+				IPXEScriptURL: &url.URL{Scheme: "http", Host: "boot.netboot.xyz"},
+				Facility:      "onprem",
+				OSIE: data.OSIE{
+					BaseURL: &url.URL{Scheme: "http", Host: "10.1.1.1:8080"},
+					Kernel:  "vmlinuz-x86_64",
+					Initrd:  "initramfs-x86_64",
+				},
+			},
+		},
+		"no netboot settings": {
+			mac: net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x69},
+			wantDHCP: &data.DHCP{
+				MACAddress: net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x69},
+				IPAddress:  netip.MustParseAddr("192.168.2.155"),
+				Hostname:   "no-netboot",
+			},
+			wantNetboot: &data.Netboot{},
+		},
+		"not found": {
+			mac:          net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x70},
+			wantErr:      true,
+			wantNotFound: true,
+		},
+		"invalid ip address": {
+			mac:     net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x6a},
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b, _ := newDB(t, Config{})
+			d, n, err := b.GetByMac(context.Background(), tt.mac)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetByMac() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var nf interface{ NotFound() bool }
+			if got := errors.As(err, &nf) && nf.NotFound(); got != tt.wantNotFound {
+				t.Errorf("NotFound() = %v, want %v", got, tt.wantNotFound)
+			}
+			if diff := cmp.Diff(tt.wantDHCP, d, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+				t.Error(diff)
+			}
+			if diff := cmp.Diff(tt.wantNetboot, n); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestGetByIP(t *testing.T) {
+	tests := map[string]struct {
+		ip           net.IP
+		wantMAC      net.HardwareAddr
+		wantErr      bool
+		wantNotFound bool
+	}{
+		"found": {
+			ip:      net.IPv4(192, 168, 2, 154),
+			wantMAC: net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x68},
+		},
+		"not found": {
+			ip:           net.IPv4(192, 168, 2, 200),
+			wantErr:      true,
+			wantNotFound: true,
+		},
+		"multiple hardware": {
+			ip:      net.IPv4(192, 168, 2, 156),
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			b, _ := newDB(t, Config{})
+			d, _, err := b.GetByIP(context.Background(), tt.ip)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetByIP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var nf interface{ NotFound() bool }
+			if got := errors.As(err, &nf) && nf.NotFound(); got != tt.wantNotFound {
+				t.Errorf("NotFound() = %v, want %v", got, tt.wantNotFound)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.wantMAC, d.MACAddress); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestNewBackendErrors(t *testing.T) {
+	tests := map[string]Config{
+		"unknown driver": {Driver: "unknown"},
+		"no schema":      {Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "empty.db")},
+	}
+	for name, c := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewBackend(context.Background(), c, logr.Discard()); err == nil {
+				t.Fatal("NewBackend() error = nil, want an error")
+			}
+		})
+	}
+}
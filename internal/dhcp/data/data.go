@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
 	"go.opentelemetry.io/otel/attribute"
 )
 
@@ -21,6 +22,16 @@ type Packet struct {
 	Md *Metadata
 }
 
+// Packet6 holds the data that is passed to a DHCPv6 handler.
+type Packet6 struct {
+	// Peer is the address of the client or relay agent that sent the DHCPv6 message.
+	Peer net.Addr
+	// Pkt is the DHCPv6 message. It is either a *dhcpv6.Message or a *dhcpv6.RelayMessage.
+	Pkt dhcpv6.DHCPv6
+	// Md is the metadata that was passed to the DHCPv6 server.
+	Md *Metadata
+}
+
 // Metadata holds metadata about the DHCP packet that was received.
 type Metadata struct {
 	// IfName is the name of the interface that the DHCP message was received on.
@@ -48,9 +59,27 @@ type DHCP struct {
 	Disabled         bool             // If true, no DHCP response should be sent.
 }
 
+// RelayAgent holds the relay agent information (DHCP option 82) that a relay agent adds to a client's request.
+type RelayAgent struct {
+	CircuitID string // DHCP option 82.1, usually identifies the switch port the request was received on.
+	RemoteID  string // DHCP option 82.2, usually identifies the switch the request was received on.
+}
+
+// Matches reports whether the relay agent information of a request matches r.
+// The circuit ID of r must be set and equal to the circuit ID of the request.
+// The remote ID is only compared when it is set in r.
+func (r RelayAgent) Matches(req RelayAgent) bool {
+	if r.CircuitID == "" || r.CircuitID != req.CircuitID {
+		return false
+	}
+
+	return r.RemoteID == "" || r.RemoteID == req.RemoteID
+}
+
 // Netboot holds info used in netbooting a client.
 type Netboot struct {
 	AllowNetboot  bool     // If true, the client will be provided netboot options in the DHCP offer/ack.
+	LocalBoot     bool     // If true, the iPXE script exits iPXE so that the client boots from its local disk instead of the OSIE.
 	IPXEScriptURL *url.URL // Overrides a default value that is passed into DHCP on startup.
 	IPXEScript    string   // Overrides a default value that is passed into DHCP on startup.
 	Console       string
@@ -106,3 +106,24 @@ func TestNetbootEncodeToAttributes(t *testing.T) {
 		})
 	}
 }
+
+func TestRelayAgentMatches(t *testing.T) {
+	tests := map[string]struct {
+		record RelayAgent
+		req    RelayAgent
+		want   bool
+	}{
+		"circuit and remote id":     {record: RelayAgent{CircuitID: "1/1", RemoteID: "sw1"}, req: RelayAgent{CircuitID: "1/1", RemoteID: "sw1"}, want: true},
+		"circuit id only":           {record: RelayAgent{CircuitID: "1/1"}, req: RelayAgent{CircuitID: "1/1", RemoteID: "sw1"}, want: true},
+		"different remote id":       {record: RelayAgent{CircuitID: "1/1", RemoteID: "sw1"}, req: RelayAgent{CircuitID: "1/1", RemoteID: "sw2"}},
+		"different circuit id":      {record: RelayAgent{CircuitID: "1/1"}, req: RelayAgent{CircuitID: "1/2"}},
+		"record without circuit id": {record: RelayAgent{RemoteID: "sw1"}, req: RelayAgent{RemoteID: "sw1"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.record.Matches(tt.req); got != tt.want {
+				t.Fatalf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
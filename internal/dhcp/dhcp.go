@@ -12,6 +12,7 @@ import (
 
 	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/insomniacslk/dhcp/iana"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
 )
 
 const (
@@ -151,6 +152,21 @@ func Arch(d *dhcpv4.DHCPv4) iana.Arch {
 	return a
 }
 
+// RelayAgent returns the circuit ID and remote ID from the relay agent information (DHCP option 82) of a request.
+// The second return value is false if the request has no circuit ID.
+func RelayAgent(d *dhcpv4.DHCPv4) (data.RelayAgent, bool) {
+	opts := d.RelayAgentInfo()
+	if opts == nil {
+		return data.RelayAgent{}, false
+	}
+	r := data.RelayAgent{
+		CircuitID: string(opts.Get(dhcpv4.AgentCircuitIDSubOption)),
+		RemoteID:  string(opts.Get(dhcpv4.AgentRemoteIDSubOption)),
+	}
+
+	return r, r.CircuitID != ""
+}
+
 func (i Info) IPXEBinaryFrom() string {
 	bin, found := ArchToBootFile[i.Arch]
 	if !found {
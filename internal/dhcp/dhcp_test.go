@@ -12,6 +12,7 @@ import (
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/insomniacslk/dhcp/iana"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
 )
 
 const (
@@ -107,6 +108,43 @@ func TestArch(t *testing.T) {
 	}
 }
 
+func TestRelayAgent(t *testing.T) {
+	tests := map[string]struct {
+		pkt    *dhcpv4.DHCPv4
+		want   data.RelayAgent
+		wantOK bool
+	}{
+		"circuit and remote id": {
+			pkt: &dhcpv4.DHCPv4{Options: dhcpv4.OptionsFromList(dhcpv4.OptRelayAgentInfo(
+				dhcpv4.OptGeneric(dhcpv4.AgentCircuitIDSubOption, []byte("Ethernet1/1")),
+				dhcpv4.OptGeneric(dhcpv4.AgentRemoteIDSubOption, []byte("switch01")),
+			))},
+			want:   data.RelayAgent{CircuitID: "Ethernet1/1", RemoteID: "switch01"},
+			wantOK: true,
+		},
+		"no circuit id": {
+			pkt: &dhcpv4.DHCPv4{Options: dhcpv4.OptionsFromList(dhcpv4.OptRelayAgentInfo(
+				dhcpv4.OptGeneric(dhcpv4.AgentRemoteIDSubOption, []byte("switch01")),
+			))},
+			want: data.RelayAgent{RemoteID: "switch01"},
+		},
+		"not relayed": {
+			pkt: &dhcpv4.DHCPv4{},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := RelayAgent(tt.pkt)
+			if ok != tt.wantOK {
+				t.Fatalf("RelayAgent() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
 func TestIsNetbootClient(t *testing.T) {
 	tests := map[string]struct {
 		input *dhcpv4.DHCPv4
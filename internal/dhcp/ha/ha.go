@@ -0,0 +1,170 @@
+// Package ha makes sure that only one of several Smee replicas answers a DHCP client.
+//
+// Two modes are supported. With leader election, only the replica that holds a lease answers DHCP messages
+// and the other replicas are on standby. With load balancing, clients are split between all replicas by a hash
+// of their client identifier, as described in RFC 3074, so every replica answers a share of the clients. The
+// clients of a replica that is not running are split between the replicas that are.
+package ha
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync/atomic"
+
+	"github.com/go-logr/logr"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/server"
+	"golang.org/x/net/ipv4"
+)
+
+// Responder decides whether a replica answers a DHCP message.
+type Responder interface {
+	Responsible(pkt *dhcpv4.DHCPv4) bool
+}
+
+// Handler is a server.Handler that only passes DHCP messages to Next that the Responder is responsible for.
+type Handler struct {
+	// Next handles the DHCP messages this replica is responsible for.
+	Next server.Handler
+	// Responder decides which DHCP messages this replica is responsible for.
+	Responder Responder
+	// Log is used to log the DHCP messages that are ignored.
+	Log logr.Logger
+}
+
+// Handle passes a DHCP message to Next when this replica is responsible for it.
+func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, d data.Packet) {
+	if d.Pkt == nil || !h.Responder.Responsible(d.Pkt) {
+		h.Log.V(1).Info("Ignoring packet: another replica is responsible for the client", "mac", clientHWAddr(d.Pkt))
+		return
+	}
+	h.Next.Handle(ctx, conn, d)
+}
+
+// Leader is a Responder that is responsible for all DHCP messages while it is the leader.
+// The zero value is not the leader.
+type Leader struct {
+	leader atomic.Bool
+}
+
+// SetLeader sets whether this replica is the leader.
+func (l *Leader) SetLeader(leader bool) {
+	l.leader.Store(leader)
+}
+
+// IsLeader reports whether this replica is the leader.
+func (l *Leader) IsLeader() bool {
+	return l.leader.Load()
+}
+
+// Responsible reports whether this replica is the leader.
+func (l *Leader) Responsible(_ *dhcpv4.DHCPv4) bool {
+	return l.IsLeader()
+}
+
+// LoadBalancer is a Responder that splits clients between replicas by the RFC 3074 hash of their client identifier.
+// The 256 hash buckets are split in contiguous, equally sized ranges, one per replica. The buckets of a replica
+// that is not running are split between the running replicas, see SetRunning.
+type LoadBalancer struct {
+	// Replicas is the number of replicas.
+	Replicas int
+	// Index is the zero based index of this replica.
+	Index int
+
+	running atomic.Pointer[[]int]
+}
+
+// NewLoadBalancer returns a LoadBalancer for the replica with the given index.
+func NewLoadBalancer(replicas, index int) (*LoadBalancer, error) {
+	if replicas < 1 || replicas > 256 {
+		return nil, fmt.Errorf("replicas must be between 1 and 256, got %d", replicas)
+	}
+	if index < 0 || index >= replicas {
+		return nil, fmt.Errorf("index must be between 0 and %d, got %d", replicas-1, index)
+	}
+
+	return &LoadBalancer{Replicas: replicas, Index: index}, nil
+}
+
+// SetRunning sets whether each replica, by index, is running. When running is nil, has the wrong length or no
+// replica is running, every replica is assumed to be running, so that each only answers its own share of the clients.
+func (l *LoadBalancer) SetRunning(running []bool) {
+	var idx []int
+	for i, r := range running {
+		if r {
+			idx = append(idx, i)
+		}
+	}
+	if len(running) != l.Replicas || len(idx) == 0 {
+		l.running.Store(nil)
+		return
+	}
+	l.running.Store(&idx)
+}
+
+// Responsible reports whether the hash bucket of the client belongs to this replica.
+func (l *LoadBalancer) Responsible(pkt *dhcpv4.DHCPv4) bool {
+	return l.replica(Hash(ClientID(pkt))) == l.Index
+}
+
+// replica returns the index of the replica that answers the clients in the hash bucket.
+func (l *LoadBalancer) replica(bucket uint8) int {
+	owner := int(bucket) * l.Replicas / 256
+	running := l.running.Load()
+	if running == nil || slices.Contains(*running, owner) {
+		return owner
+	}
+
+	return (*running)[int(bucket)%len(*running)]
+}
+
+// ClientID returns the key that is hashed to select the replica for a client.
+// That is the client identifier option (61) when present and the client hardware address otherwise.
+func ClientID(pkt *dhcpv4.DHCPv4) []byte {
+	if id := pkt.GetOneOption(dhcpv4.OptionClientIdentifier); len(id) > 0 {
+		return id
+	}
+
+	return pkt.ClientHWAddr
+}
+
+// Hash returns the RFC 3074 hash bucket of key.
+func Hash(key []byte) uint8 {
+	h := uint8(len(key)) //nolint:gosec // The length only seeds the hash, truncation is intended.
+	for _, b := range key {
+		h = loadbMxTbl[h^b]
+	}
+
+	return h
+}
+
+func clientHWAddr(pkt *dhcpv4.DHCPv4) string {
+	if pkt == nil {
+		return ""
+	}
+
+	return pkt.ClientHWAddr.String()
+}
+
+// loadbMxTbl is the permutation table of the Pearson hash from RFC 3074, section 6.
+var loadbMxTbl = [256]uint8{
+	251, 175, 119, 215, 81, 14, 79, 191, 103, 49, 181, 143, 186, 157, 0,
+	232, 31, 32, 55, 60, 152, 58, 17, 237, 174, 70, 160, 144, 220, 90, 57,
+	223, 59, 3, 18, 140, 111, 166, 203, 196, 134, 243, 124, 95, 222, 179,
+	197, 65, 180, 48, 36, 15, 107, 46, 233, 130, 165, 30, 123, 161, 209, 23,
+	97, 16, 40, 91, 219, 61, 100, 10, 210, 109, 250, 127, 22, 138, 29, 108,
+	244, 67, 207, 9, 178, 204, 74, 98, 126, 249, 167, 116, 34, 77, 193,
+	200, 121, 5, 20, 113, 71, 35, 128, 13, 182, 94, 25, 226, 227, 199, 75,
+	27, 41, 245, 230, 224, 43, 225, 177, 26, 155, 150, 212, 142, 218, 115,
+	241, 73, 88, 105, 39, 114, 62, 255, 192, 201, 145, 214, 168, 158, 221,
+	148, 154, 122, 12, 84, 82, 163, 44, 139, 228, 236, 205, 242, 217, 11,
+	187, 146, 159, 64, 86, 239, 195, 42, 106, 198, 118, 112, 184, 172, 87,
+	2, 173, 117, 176, 229, 247, 253, 137, 185, 99, 164, 102, 147, 45, 66,
+	231, 52, 141, 211, 194, 206, 246, 238, 56, 110, 78, 248, 63, 240, 189,
+	93, 92, 51, 53, 183, 19, 171, 72, 50, 33, 104, 101, 69, 8, 252, 83, 120,
+	76, 135, 85, 54, 202, 125, 188, 213, 96, 235, 136, 208, 162, 129, 190,
+	132, 156, 38, 47, 1, 7, 254, 24, 4, 216, 131, 89, 21, 28, 133, 37, 153,
+	149, 80, 170, 68, 6, 169, 234, 151,
+}
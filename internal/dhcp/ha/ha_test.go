@@ -0,0 +1,177 @@
+package ha
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"golang.org/x/net/ipv4"
+)
+
+type counter struct {
+	n int
+}
+
+func (c *counter) Handle(_ context.Context, _ *ipv4.PacketConn, _ data.Packet) {
+	c.n++
+}
+
+func TestLoadBalancerTable(t *testing.T) {
+	seen := map[uint8]bool{}
+	for _, v := range loadbMxTbl {
+		if seen[v] {
+			t.Fatalf("%d is in the table more than once", v)
+		}
+		seen[v] = true
+	}
+}
+
+func TestLoadBalancer(t *testing.T) {
+	tests := map[string]struct {
+		replicas int
+	}{
+		"one replica":    {replicas: 1},
+		"two replicas":   {replicas: 2},
+		"three replicas": {replicas: 3},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var lbs []*LoadBalancer
+			for i := range tt.replicas {
+				lb, err := NewLoadBalancer(tt.replicas, i)
+				if err != nil {
+					t.Fatal(err)
+				}
+				lbs = append(lbs, lb)
+			}
+			served := make([]int, tt.replicas)
+			for i := range 1024 {
+				pkt := &dhcpv4.DHCPv4{ClientHWAddr: net.HardwareAddr{0, 0, 0, 0, byte(i >> 8), byte(i)}}
+				n := 0
+				for j, lb := range lbs {
+					if lb.Responsible(pkt) {
+						n++
+						served[j]++
+					}
+				}
+				if n != 1 {
+					t.Fatalf("%d replicas are responsible for %v, want 1", n, pkt.ClientHWAddr)
+				}
+			}
+			for i, n := range served {
+				if n == 0 {
+					t.Errorf("replica %d is not responsible for any client", i)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadBalancerFailover(t *testing.T) {
+	tests := map[string]struct {
+		running []bool
+		// wantServed are the replicas that answer clients.
+		wantServed []int
+	}{
+		"all running":         {running: []bool{true, true, true}, wantServed: []int{0, 1, 2}},
+		"one down":            {running: []bool{true, false, true}, wantServed: []int{0, 2}},
+		"only one running":    {running: []bool{false, false, true}, wantServed: []int{2}},
+		"unknown":             {wantServed: []int{0, 1, 2}},
+		"none running":        {running: []bool{false, false, false}, wantServed: []int{0, 1, 2}},
+		"wrong replica count": {running: []bool{true, false}, wantServed: []int{0, 1, 2}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var lbs []*LoadBalancer
+			for i := range 3 {
+				lb, err := NewLoadBalancer(3, i)
+				if err != nil {
+					t.Fatal(err)
+				}
+				lb.SetRunning(tt.running)
+				lbs = append(lbs, lb)
+			}
+			served := map[int]bool{}
+			for i := range 1024 {
+				pkt := &dhcpv4.DHCPv4{ClientHWAddr: net.HardwareAddr{0, 0, 0, 0, byte(i >> 8), byte(i)}}
+				n := 0
+				for j, lb := range lbs {
+					if lb.Responsible(pkt) {
+						n++
+						served[j] = true
+					}
+				}
+				if n != 1 {
+					t.Fatalf("%d replicas are responsible for %v, want 1", n, pkt.ClientHWAddr)
+				}
+			}
+			var got []int
+			for i := range 3 {
+				if served[i] {
+					got = append(got, i)
+				}
+			}
+			if diff := cmp.Diff(tt.wantServed, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestNewLoadBalancer(t *testing.T) {
+	tests := map[string]struct {
+		replicas int
+		index    int
+		wantErr  bool
+	}{
+		"valid":             {replicas: 2, index: 1},
+		"no replicas":       {replicas: 0, wantErr: true},
+		"too many replicas": {replicas: 257, wantErr: true},
+		"index too large":   {replicas: 2, index: 2, wantErr: true},
+		"negative index":    {replicas: 2, index: -1, wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewLoadBalancer(tt.replicas, tt.index); (err != nil) != tt.wantErr {
+				t.Fatalf("NewLoadBalancer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientID(t *testing.T) {
+	mac := net.HardwareAddr{0, 1, 2, 3, 4, 5}
+	pkt := &dhcpv4.DHCPv4{ClientHWAddr: mac}
+	if got := ClientID(pkt); string(got) != string(mac) {
+		t.Errorf("ClientID() = %v, want %v", got, mac)
+	}
+	pkt.UpdateOption(dhcpv4.OptClientIdentifier([]byte{1, 2, 3}))
+	if got := ClientID(pkt); string(got) != string([]byte{1, 2, 3}) {
+		t.Errorf("ClientID() = %v, want the client identifier option", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	l := &Leader{}
+	next := &counter{}
+	h := &Handler{Next: next, Responder: l, Log: logr.Discard()}
+	pkt := data.Packet{Pkt: &dhcpv4.DHCPv4{ClientHWAddr: net.HardwareAddr{0, 1, 2, 3, 4, 5}}}
+
+	h.Handle(context.Background(), nil, pkt)
+	if next.n != 0 {
+		t.Fatalf("standby replica handled %d packets, want 0", next.n)
+	}
+	l.SetLeader(true)
+	h.Handle(context.Background(), nil, pkt)
+	if next.n != 1 {
+		t.Fatalf("leader handled %d packets, want 1", next.n)
+	}
+	h.Handle(context.Background(), nil, data.Packet{})
+	if next.n != 1 {
+		t.Fatalf("leader handled a packet without a DHCP message")
+	}
+}
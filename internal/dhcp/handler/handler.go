@@ -3,8 +3,10 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"net"
 
+	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/tinkerbell/smee/internal/dhcp/data"
 )
 
@@ -17,3 +19,81 @@ type BackendReader interface {
 	GetByMac(context.Context, net.HardwareAddr) (*data.DHCP, *data.Netboot, error)
 	GetByIP(context.Context, net.IP) (*data.DHCP, *data.Netboot, error)
 }
+
+// NotFoundError is returned by a BackendReader that has no hardware for a lookup, so that callers can fall back to
+// another backend, to a pool address or to not answering.
+type NotFoundError struct {
+	// Key is what was looked up, for example a mac or IP address.
+	Key string
+}
+
+// NotFound reports that the backend has no hardware for the lookup.
+func (NotFoundError) NotFound() bool { return true }
+
+func (e NotFoundError) Error() string {
+	if e.Key == "" {
+		return "hardware not found"
+	}
+
+	return "hardware not found: " + e.Key
+}
+
+// IsNotFound reports whether err, or an error it wraps, is from a backend that has no hardware for a lookup.
+// Any error with a NotFound() bool method that returns true matches, not only NotFoundError.
+func IsNotFound(err error) bool {
+	var nf interface{ NotFound() bool }
+
+	return errors.As(err, &nf) && nf.NotFound()
+}
+
+// RelayAgentReader is an optional interface that backends implement to get data based on the
+// relay agent information (DHCP option 82) of a request.
+//
+// This allows a client to be matched by the switch port it is connected to instead of by its mac address,
+// for example after a NIC has been replaced.
+type RelayAgentReader interface {
+	GetByRelayAgent(context.Context, data.RelayAgent) (*data.DHCP, *data.Netboot, error)
+}
+
+// ChangeNotifier is an optional interface that backends implement to tell a cache in front of them that their data changed,
+// so that the cache does not serve stale data until it expires.
+type ChangeNotifier interface {
+	// OnChange registers f to be called when the data of the hardware with the mac addresses changes.
+	// f is called without mac addresses when the data of any hardware may have changed.
+	OnChange(f func(macs ...net.HardwareAddr))
+}
+
+// ReadinessChecker is an optional interface that backends implement to tell whether they can answer lookups,
+// for example when they serve them from a cache that must be synced and connected to its source first.
+type ReadinessChecker interface {
+	// Ready returns an error that describes why the backend is not ready, or nil when it is.
+	Ready(context.Context) error
+}
+
+// Stage is a step in the boot of a machine that is served by smee.
+type Stage string
+
+// Stages of the boot of a machine, in the order they usually happen.
+const (
+	StageDHCPOffer  Stage = "dhcp-offer"
+	StageDHCPAck    Stage = "dhcp-ack"
+	StageTFTP       Stage = "tftp"
+	StageIPXEScript Stage = "ipxe-script"
+	StageISO        Stage = "iso"
+)
+
+// ProgressRecorder records how far the boot of a machine got, so that operators can see when a machine was last served.
+type ProgressRecorder interface {
+	// RecordProgress records that the machine with the mac address reached stage. When mac is nil, the machine is
+	// identified by its IP address. detail describes the stage, for example the name of the iPXE binary that was served.
+	// It must not block the handler that calls it.
+	RecordProgress(ctx context.Context, mac net.HardwareAddr, ip net.IP, stage Stage, detail string)
+}
+
+// Simulator is an optional interface that DHCP handlers implement to show what they would answer a request,
+// for example to debug a machine that does not boot.
+type Simulator interface {
+	// Simulate returns the reply to p without sending it and without changing any state, like leases.
+	// It returns an error that describes why no reply is sent when there is none.
+	Simulate(ctx context.Context, p data.Packet) (*dhcpv4.DHCPv4, error)
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type otherNotFound struct{ found bool }
+
+func (e otherNotFound) NotFound() bool { return !e.found }
+
+func (otherNotFound) Error() string { return "other" }
+
+func TestIsNotFound(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want bool
+	}{
+		"nil":                   {},
+		"not found":             {err: NotFoundError{Key: "00:00:5e:00:53:01"}, want: true},
+		"wrapped not found":     {err: fmt.Errorf("file backend: %w", NotFoundError{}), want: true},
+		"other not found error": {err: otherNotFound{}, want: true},
+		"NotFound() is false":   {err: otherNotFound{found: true}},
+		"other error":           {err: errors.New("connection refused")},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := IsNotFound(tt.err); got != tt.want {
+				t.Errorf("IsNotFound() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/tinkerbell/smee/internal/dhcp/data"
 	"github.com/tinkerbell/smee/internal/dhcp/handler"
 	oteldhcp "github.com/tinkerbell/smee/internal/dhcp/otel"
+	"github.com/tinkerbell/smee/internal/dhcp/subnet"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -62,6 +63,13 @@ type Handler struct {
 	// AutoProxyEnabled is used to determine if the proxyDHCP handler should do any Backend calls or not.
 	// When enabled no Backend calls are made and responses are sent to all valid network boot clients.
 	AutoProxyEnabled bool
+
+	// Subnets, when set, provides per subnet netboot configuration.
+	// The subnet is selected by the link a request was received from.
+	Subnets *subnet.Registry
+
+	// Progress, when set, records the ProxyDHCP offers and acknowledgements that are sent to clients.
+	Progress handler.ProgressRecorder
 }
 
 // Netboot holds the netboot configuration details used in running a DHCP server.
@@ -120,47 +128,84 @@ func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, dp data.Pac
 
 	defer span.End()
 
-	// We ignore the error here because:
-	// 1. it's only non-nil if the generation of a transaction id (XID) fails.
-	// 2. We always use the clients transaction id (XID) in responses. See dhcpv4.WithReply().
-	reply, _ := dhcpv4.NewReplyFromRequest(dp.Pkt)
-
-	if dp.Pkt.OpCode != dhcpv4.OpcodeBootRequest { // TODO(jacobweinstock): dont understand this, found it in an example here: https://github.com/insomniacslk/dhcp/blob/c51060810aaab9c8a0bd1b0fcbf72bc0b91e6427/dhcpv4/server4/server_test.go#L31
-		log.V(1).Info("Ignoring packet", "OpCode", dp.Pkt.OpCode)
-		span.SetStatus(codes.Ok, "Ignoring packet: OpCode not BootRequest")
+	reply, err := h.reply(ctx, dp.Pkt)
+	if err != nil {
+		log.V(1).Info("Ignoring packet", "error", err.Error())
+		span.SetStatus(codes.Ok, err.Error())
 
 		return
 	}
+	i := dhcp.NewInfo(dp.Pkt)
 
-	if err := setMessageType(reply, dp.Pkt.MessageType()); err != nil {
-		log.V(1).Info("Ignoring packet", "error", err.Error())
-		span.SetStatus(codes.Ok, err.Error())
+	log.Info(
+		"received DHCP packet",
+		"type", dp.Pkt.MessageType().String(),
+		"clientType", i.ClientTypeFrom().String(),
+		"userClass", i.UserClassFrom().String(),
+	)
+
+	dst := replyDestination(dp.Peer, dp.Pkt.GatewayIPAddr)
+	cm := &ipv4.ControlMessage{}
+	if dp.Md != nil {
+		cm.IfIndex = dp.Md.IfIndex
+	}
+	log = log.WithValues(
+		"destination", dst.String(),
+		"bootFileName", reply.BootFileName,
+		"nextServer", reply.ServerIPAddr.String(),
+		"messageType", reply.MessageType().String(),
+		"serverHostname", reply.ServerHostName,
+	)
+	// send the DHCP packet
+	if _, err := conn.WriteTo(reply.ToBytes(), cm, dst); err != nil {
+		log.Error(err, "failed to send ProxyDHCP response")
+		span.SetStatus(codes.Error, err.Error())
 
 		return
 	}
+	log.Info("Sent ProxyDHCP response")
+	h.recordProgress(ctx, reply)
+	span.SetAttributes(h.encodeToAttributes(reply, "reply")...)
+	span.SetStatus(codes.Ok, "sent DHCP response")
+}
+
+// Simulate implements the handler.Simulator interface. It returns the ProxyDHCP reply to p without sending it.
+func (h *Handler) Simulate(ctx context.Context, p data.Packet) (*dhcpv4.DHCPv4, error) {
+	if p.Pkt == nil {
+		return nil, errors.New("packet is nil")
+	}
+
+	return h.reply(ctx, p.Pkt)
+}
+
+// reply returns the ProxyDHCP reply to pkt, or an IgnorePacketError when pkt is not answered.
+func (h *Handler) reply(ctx context.Context, pkt *dhcpv4.DHCPv4) (*dhcpv4.DHCPv4, error) {
+	// We ignore the error here because:
+	// 1. it's only non-nil if the generation of a transaction id (XID) fails.
+	// 2. We always use the clients transaction id (XID) in responses. See dhcpv4.WithReply().
+	reply, _ := dhcpv4.NewReplyFromRequest(pkt)
+
+	if pkt.OpCode != dhcpv4.OpcodeBootRequest { // TODO(jacobweinstock): dont understand this, found it in an example here: https://github.com/insomniacslk/dhcp/blob/c51060810aaab9c8a0bd1b0fcbf72bc0b91e6427/dhcpv4/server4/server_test.go#L31
+		return nil, IgnorePacketError{PacketType: pkt.MessageType(), Details: fmt.Sprintf("OpCode %s is not BootRequest", pkt.OpCode)}
+	}
+
+	if err := setMessageType(reply, pkt.MessageType()); err != nil {
+		return nil, err
+	}
 
 	// Set option 97
-	reply.UpdateOption(dhcpv4.OptGeneric(dhcpv4.OptionClientMachineIdentifier, dp.Pkt.GetOneOption(dhcpv4.OptionClientMachineIdentifier)))
+	reply.UpdateOption(dhcpv4.OptGeneric(dhcpv4.OptionClientMachineIdentifier, pkt.GetOneOption(dhcpv4.OptionClientMachineIdentifier)))
 
-	i := dhcp.NewInfo(dp.Pkt)
+	i := dhcp.NewInfo(pkt)
 
 	if !h.Netboot.Enabled {
-		log.V(1).Info("Ignoring packet: netboot is not enabled")
-		span.SetStatus(codes.Ok, "Ignoring packet: netboot is not enabled")
-
-		return
+		return nil, IgnorePacketError{PacketType: pkt.MessageType(), Details: "netboot is not enabled"}
 	}
 	if err := i.IsNetbootClient; err != nil {
-		log.V(1).Info("Ignoring packet: not from a PXE enabled client", "error", err.Error())
-		span.SetStatus(codes.Ok, fmt.Sprintf("Ignoring packet: not from a PXE enabled client: %s", err.Error()))
-
-		return
+		return nil, IgnorePacketError{PacketType: pkt.MessageType(), Details: fmt.Sprintf("not from a PXE enabled client: %s", err)}
 	}
 	if i.IPXEBinary == "" {
-		log.V(1).Info("Ignoring packet: no iPXE binary was able to be determined")
-		span.SetStatus(codes.Ok, "Ignoring packet: no iPXE binary was able to be determined")
-
-		return
+		return nil, IgnorePacketError{PacketType: pkt.MessageType(), Details: "no iPXE binary was able to be determined"}
 	}
 
 	// Set option 43
@@ -173,7 +218,8 @@ func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, dp data.Pac
 
 	// Set option 54, without this the pxe client will try to broadcast a request message to port 4011 for the ipxe binary. only found to be needed for PXEClient but not prohibitive for HTTPClient.
 	// probably will want this to be the public IP of the proxyDHCP server
-	ns := i.NextServer(h.Netboot.IPXEBinServerHTTP, h.Netboot.IPXEBinServerTFTP)
+	nb := h.netboot(pkt)
+	ns := i.NextServer(nb.IPXEBinServerHTTP, nb.IPXEBinServerTFTP)
 	reply.UpdateOption(dhcpv4.OptServerIdentifier(ns))
 	// add the siaddr (IP address of next server) dhcp packet header to a given packet pkt.
 	// see https://datatracker.ietf.org/doc/html/rfc2131#section-2
@@ -183,57 +229,72 @@ func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, dp data.Pac
 	// set sname header
 	// see https://datatracker.ietf.org/doc/html/rfc2131#section-2
 	reply.ServerHostName = ns.String()
-	// setSNAME(reply, dp.Pkt.GetOneOption(dhcpv4.OptionClassIdentifier), h.Netboot.IPXEBinServerTFTP.Addr().AsSlice(), net.ParseIP(h.Netboot.IPXEBinServerHTTP.Hostname()))
+	// setSNAME(reply, pkt.GetOneOption(dhcpv4.OptionClassIdentifier), h.Netboot.IPXEBinServerTFTP.Addr().AsSlice(), net.ParseIP(h.Netboot.IPXEBinServerHTTP.Hostname()))
 
 	// set bootfile header
-	reply.BootFileName = i.Bootfile("", h.Netboot.IPXEScriptURL(dp.Pkt), h.Netboot.IPXEBinServerHTTP, h.Netboot.IPXEBinServerTFTP)
+	reply.BootFileName = i.Bootfile("", nb.IPXEScriptURL(pkt), nb.IPXEBinServerHTTP, nb.IPXEBinServerTFTP)
 
 	if !h.AutoProxyEnabled {
 		// check the backend, if PXE is NOT allowed, set the boot file name to "/<mac address>/not-allowed"
-		_, n, err := h.Backend.GetByMac(ctx, dp.Pkt.ClientHWAddr)
-		if err != nil || (n != nil && !n.AllowNetboot) {
-			l := log.V(1)
-			if err != nil {
-				l = l.WithValues("error", err.Error())
-			}
-			if n != nil {
-				l = l.WithValues("netbootAllowed", n.AllowNetboot)
-			}
-			l.Info("Ignoring packet")
-			span.SetStatus(codes.Ok, "netboot not allowed")
-			return
+		_, n, err := h.readBackend(ctx, pkt)
+		if err != nil {
+			return nil, IgnorePacketError{PacketType: pkt.MessageType(), Details: fmt.Sprintf("netboot not allowed: %s", err)}
+		}
+		if n != nil && !n.AllowNetboot {
+			return nil, IgnorePacketError{PacketType: pkt.MessageType(), Details: "netboot not allowed"}
 		}
 	}
 
-	log.Info(
-		"received DHCP packet",
-		"type", dp.Pkt.MessageType().String(),
-		"clientType", i.ClientTypeFrom().String(),
-		"userClass", i.UserClassFrom().String(),
-	)
+	return reply, nil
+}
 
-	dst := replyDestination(dp.Peer, dp.Pkt.GatewayIPAddr)
-	cm := &ipv4.ControlMessage{}
-	if dp.Md != nil {
-		cm.IfIndex = dp.Md.IfIndex
+// recordProgress records that an offer or acknowledgement was sent to the client of reply, with its boot file name.
+func (h *Handler) recordProgress(ctx context.Context, reply *dhcpv4.DHCPv4) {
+	if h.Progress == nil {
+		return
 	}
-	log = log.WithValues(
-		"destination", dst.String(),
-		"bootFileName", reply.BootFileName,
-		"nextServer", reply.ServerIPAddr.String(),
-		"messageType", reply.MessageType().String(),
-		"serverHostname", reply.ServerHostName,
-	)
-	// send the DHCP packet
-	if _, err := conn.WriteTo(reply.ToBytes(), cm, dst); err != nil {
-		log.Error(err, "failed to send ProxyDHCP response")
-		span.SetStatus(codes.Error, err.Error())
+	switch reply.MessageType() {
+	case dhcpv4.MessageTypeOffer:
+		h.Progress.RecordProgress(ctx, reply.ClientHWAddr, nil, handler.StageDHCPOffer, reply.BootFileName)
+	case dhcpv4.MessageTypeAck:
+		h.Progress.RecordProgress(ctx, reply.ClientHWAddr, nil, handler.StageDHCPAck, reply.BootFileName)
+	}
+}
 
-		return
+// netboot returns the netboot configuration for a DHCP message, with the overrides of the subnet of the
+// link the message was received from.
+func (h *Handler) netboot(pkt *dhcpv4.DHCPv4) Netboot {
+	nb := h.Netboot
+	s, ok := h.Subnets.Select(pkt, netip.Addr{})
+	if !ok {
+		return nb
 	}
-	log.Info("Sent ProxyDHCP response")
-	span.SetAttributes(h.encodeToAttributes(reply, "reply")...)
-	span.SetStatus(codes.Ok, "sent DHCP response")
+	if s.Netboot.IPXEBinServerTFTP.IsValid() {
+		nb.IPXEBinServerTFTP = s.Netboot.IPXEBinServerTFTP
+	}
+	if s.Netboot.IPXEBinServerHTTP != nil {
+		nb.IPXEBinServerHTTP = s.Netboot.IPXEBinServerHTTP
+	}
+	if s.Netboot.IPXEScriptURL != nil {
+		nb.IPXEScriptURL = s.Netboot.IPXEScriptURL
+	}
+
+	return nb
+}
+
+// readBackend reads the data for a client from the backend.
+// When the request has relay agent information and the backend can match on it, that is tried first.
+// Otherwise, or when nothing matches, the data is read by the client mac address.
+func (h *Handler) readBackend(ctx context.Context, pkt *dhcpv4.DHCPv4) (*data.DHCP, *data.Netboot, error) {
+	if rr, ok := h.Backend.(handler.RelayAgentReader); ok {
+		if ra, ok := dhcp.RelayAgent(pkt); ok {
+			if d, n, err := rr.GetByRelayAgent(ctx, ra); err == nil {
+				return d, n, nil
+			}
+		}
+	}
+
+	return h.Backend.GetByMac(ctx, pkt.ClientHWAddr)
 }
 
 // encodeToAttributes takes a DHCP packet and returns opentelemetry key/value attributes.
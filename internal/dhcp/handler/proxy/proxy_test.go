@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+)
+
+func TestSimulateRelayAgentInfo(t *testing.T) {
+	relayed := dhcpv4.OptRelayAgentInfo(
+		dhcpv4.OptGeneric(dhcpv4.AgentCircuitIDSubOption, []byte("Ethernet1/1")),
+		dhcpv4.OptGeneric(dhcpv4.AgentRemoteIDSubOption, []byte("switch01")),
+	)
+	tests := map[string]struct {
+		opt82 *dhcpv4.Option
+	}{
+		"relayed":     {opt82: &relayed},
+		"not relayed": {},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{
+				IPAddr: netip.MustParseAddr("192.168.1.1"),
+				Log:    logr.Discard(),
+				Netboot: Netboot{
+					IPXEBinServerTFTP: netip.MustParseAddrPort("192.168.1.1:69"),
+					IPXEBinServerHTTP: &url.URL{Scheme: "http", Host: "192.168.1.1:8080"},
+					IPXEScriptURL: func(*dhcpv4.DHCPv4) *url.URL {
+						return &url.URL{Scheme: "http", Host: "192.168.1.1", Path: "/auto.ipxe"}
+					},
+					Enabled: true,
+				},
+				AutoProxyEnabled: true,
+			}
+			req := &dhcpv4.DHCPv4{
+				OpCode:        dhcpv4.OpcodeBootRequest,
+				ClientHWAddr:  net.HardwareAddr{0x08, 0x00, 0x27, 0x29, 0x4e, 0x67},
+				TransactionID: dhcpv4.TransactionID{0x01, 0x02, 0x03, 0x04},
+				GatewayIPAddr: net.IP{192, 168, 2, 1},
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptMessageType(dhcpv4.MessageTypeDiscover),
+					dhcpv4.OptClassIdentifier("PXEClient:Arch:00007:UNDI:003001"),
+					dhcpv4.OptClientArch(iana.EFI_X86_64),
+					dhcpv4.OptGeneric(dhcpv4.OptionClientNetworkInterfaceIdentifier, []byte{1, 3, 1}),
+				),
+			}
+			if tt.opt82 != nil {
+				req.UpdateOption(*tt.opt82)
+			}
+
+			got, err := h.Simulate(context.Background(), data.Packet{Pkt: req})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// From RFC 3046, section 2.2: the server copies the relay agent information option into the reply.
+			if diff := cmp.Diff(req.Options.Get(dhcpv4.OptionRelayAgentInformation), got.Options.Get(dhcpv4.OptionRelayAgentInformation)); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
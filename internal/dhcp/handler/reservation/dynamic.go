@@ -0,0 +1,76 @@
+package reservation
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+)
+
+// poolOffer allocates an address from the pool for a client without a host reservation.
+func (h *Handler) poolOffer(pkt *dhcpv4.DHCPv4) (*data.DHCP, *data.Netboot, error) {
+	l, err := h.Pool.Offer(pkt.ClientHWAddr, toAddr(pkt.RequestedIPAddress()))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return h.Pool.DHCP(l), &data.Netboot{AllowNetboot: true}, nil
+}
+
+// poolAck binds the requested address in the pool for a client without a host reservation.
+func (h *Handler) poolAck(pkt *dhcpv4.DHCPv4) (*data.DHCP, *data.Netboot, error) {
+	l, err := h.Pool.Ack(pkt.ClientHWAddr, requestedIP(pkt))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return h.Pool.DHCP(l), &data.Netboot{AllowNetboot: true}, nil
+}
+
+// isSelected reports whether a DHCPREQUEST of a client without a host reservation is for this server.
+// A client in the SELECTING state sets option 54 to the server it accepted an offer from.
+// Requests without option 54 (INIT-REBOOT, RENEWING, REBINDING) are for any server.
+// Host reservations are acknowledged whichever server was selected, every server hands out the reserved address.
+func (h *Handler) isSelected(pkt *dhcpv4.DHCPv4) bool {
+	sid := pkt.ServerIdentifier()
+	if sid == nil || sid.IsUnspecified() {
+		return true
+	}
+
+	return toAddr(sid) == h.IPAddr
+}
+
+// nak returns a DHCPNAK reply for a request.
+func (h *Handler) nak(pkt *dhcpv4.DHCPv4) *dhcpv4.DHCPv4 {
+	mods := []dhcpv4.Modifier{
+		dhcpv4.WithMessageType(dhcpv4.MessageTypeNak),
+		dhcpv4.WithGeneric(dhcpv4.OptionServerIdentifier, h.IPAddr.AsSlice()),
+	}
+	// From RFC 2131, section 4.3.2: if giaddr is set, the server sets the broadcast bit
+	// so that the relay agent broadcasts the DHCPNAK to the client.
+	if !pkt.GatewayIPAddr.IsUnspecified() && pkt.GatewayIPAddr != nil {
+		mods = append(mods, dhcpv4.WithBroadcast(true))
+	}
+	// The error is ignored for the same reasons as in updateMsg.
+	reply, _ := dhcpv4.NewReplyFromRequest(pkt, mods...)
+
+	return reply
+}
+
+// requestedIP returns the address a client is asking for.
+// This is option 50 in the SELECTING and INIT-REBOOT states and ciaddr in the RENEWING and REBINDING states.
+func requestedIP(pkt *dhcpv4.DHCPv4) netip.Addr {
+	if ip := pkt.RequestedIPAddress(); ip != nil && !ip.IsUnspecified() {
+		return toAddr(ip)
+	}
+
+	return toAddr(pkt.ClientIPAddr)
+}
+
+// toAddr converts a net.IP to a netip.Addr. An invalid netip.Addr is returned if ip is not valid.
+func toAddr(ip net.IP) netip.Addr {
+	a, _ := netip.AddrFromSlice(ip)
+
+	return a.Unmap()
+}
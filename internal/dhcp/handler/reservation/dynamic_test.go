@@ -0,0 +1,196 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/pool"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/nettest"
+)
+
+func TestHandlePool(t *testing.T) {
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	tests := map[string]struct {
+		req      *dhcpv4.DHCPv4
+		wantType dhcpv4.MessageType
+		wantIP   net.IP
+		wantErr  error
+	}{
+		"discover": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: mac,
+				Options:      dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeDiscover)),
+			},
+			wantType: dhcpv4.MessageTypeOffer,
+			wantIP:   net.IP{192, 168, 2, 10},
+		},
+		"request in pool": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: mac,
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptMessageType(dhcpv4.MessageTypeRequest),
+					dhcpv4.OptServerIdentifier(net.IP{127, 0, 0, 1}),
+					dhcpv4.OptRequestedIPAddress(net.IP{192, 168, 2, 20}),
+				),
+			},
+			wantType: dhcpv4.MessageTypeAck,
+			wantIP:   net.IP{192, 168, 2, 20},
+		},
+		"request outside pool": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: mac,
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptMessageType(dhcpv4.MessageTypeRequest),
+					dhcpv4.OptRequestedIPAddress(net.IP{10, 0, 0, 20}),
+				),
+			},
+			wantType: dhcpv4.MessageTypeNak,
+			wantIP:   net.IP{0, 0, 0, 0},
+		},
+		"request for another server": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: mac,
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptMessageType(dhcpv4.MessageTypeRequest),
+					dhcpv4.OptServerIdentifier(net.IP{127, 0, 0, 2}),
+					dhcpv4.OptRequestedIPAddress(net.IP{192, 168, 2, 20}),
+				),
+			},
+			wantErr: errBadBackend,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := pool.New(pool.Config{
+				Prefix:  netip.MustParsePrefix("192.168.2.0/24"),
+				Exclude: []pool.Range{{Start: netip.MustParseAddr("192.168.2.1"), End: netip.MustParseAddr("192.168.2.9")}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := Handler{
+				Backend: &mockBackend{hardwareNotFound: true},
+				IPAddr:  netip.MustParseAddr("127.0.0.1"),
+				Pool:    p,
+			}
+			conn, err := nettest.NewLocalPacketListener("udp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			pc, err := net.ListenPacket("udp4", ":0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pc.Close()
+			peer := &net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: pc.LocalAddr().(*net.UDPAddr).Port}
+
+			s.Handle(context.Background(), ipv4.NewPacketConn(conn), data.Packet{Peer: peer, Pkt: tt.req, Md: &data.Metadata{IfName: "lo"}})
+
+			msg, err := client(pc)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("client() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.wantType, msg.MessageType()); diff != "" {
+				t.Fatal(diff)
+			}
+			if diff := cmp.Diff(tt.wantIP, msg.YourIPAddr.To4()); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestRequestedIP(t *testing.T) {
+	tests := map[string]struct {
+		req  *dhcpv4.DHCPv4
+		want netip.Addr
+	}{
+		"option 50": {
+			req:  &dhcpv4.DHCPv4{ClientIPAddr: net.IPv4zero, Options: dhcpv4.OptionsFromList(dhcpv4.OptRequestedIPAddress(net.IP{192, 168, 2, 20}))},
+			want: netip.MustParseAddr("192.168.2.20"),
+		},
+		"ciaddr": {
+			req:  &dhcpv4.DHCPv4{ClientIPAddr: net.IP{192, 168, 2, 30}, Options: dhcpv4.Options{}},
+			want: netip.MustParseAddr("192.168.2.30"),
+		},
+		"none": {
+			req:  &dhcpv4.DHCPv4{Options: dhcpv4.Options{}},
+			want: netip.Addr{},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := requestedIP(tt.req); got != tt.want {
+				t.Fatalf("requestedIP() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReleaseAndDecline(t *testing.T) {
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	p, err := pool.New(pool.Config{Prefix: netip.MustParsePrefix("192.168.2.0/30")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Handler{Backend: &mockBackend{hardwareNotFound: true}, IPAddr: netip.MustParseAddr("127.0.0.1"), Pool: p}
+	conn, err := nettest.NewLocalPacketListener("udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	peer := &net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: 68}
+	if _, err := p.Ack(mac, netip.MustParseAddr("192.168.2.1")); err != nil {
+		t.Fatal(err)
+	}
+
+	release := &dhcpv4.DHCPv4{
+		OpCode:       dhcpv4.OpcodeBootRequest,
+		ClientHWAddr: mac,
+		ClientIPAddr: net.IP{192, 168, 2, 1},
+		Options:      dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeRelease)),
+	}
+	s.Handle(context.Background(), ipv4.NewPacketConn(conn), data.Packet{Peer: peer, Pkt: release})
+	if got := p.Leases(); len(got) != 0 {
+		t.Fatalf("expected no leases after release, got %v", got)
+	}
+
+	if _, err := p.Ack(mac, netip.MustParseAddr("192.168.2.1")); err != nil {
+		t.Fatal(err)
+	}
+	decline := &dhcpv4.DHCPv4{
+		OpCode:       dhcpv4.OpcodeBootRequest,
+		ClientHWAddr: mac,
+		Options: dhcpv4.OptionsFromList(
+			dhcpv4.OptMessageType(dhcpv4.MessageTypeDecline),
+			dhcpv4.OptRequestedIPAddress(net.IP{192, 168, 2, 1}),
+		),
+	}
+	s.Handle(context.Background(), ipv4.NewPacketConn(conn), data.Packet{Peer: peer, Pkt: decline})
+	l, err := p.Offer(mac, netip.Addr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := netip.MustParseAddr("192.168.2.2"); l.IP != want {
+		t.Fatalf("offered %v after decline, want %v", l.IP, want)
+	}
+	if l.Expires.Before(time.Now()) {
+		t.Fatalf("offer already expired: %v", l.Expires)
+	}
+}
@@ -10,7 +10,10 @@ import (
 	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/tinkerbell/smee/internal/dhcp"
 	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
+	"github.com/tinkerbell/smee/internal/dhcp/lease"
 	oteldhcp "github.com/tinkerbell/smee/internal/dhcp/otel"
+	"github.com/tinkerbell/smee/internal/dhcp/pool"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -70,11 +73,18 @@ func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, p data.Pack
 	defer span.End()
 
 	var reply *dhcpv4.DHCPv4
+	var fromPool bool
+	// replaced is the mac address of the previous NIC of a client that was matched by relay agent information.
+	var replaced net.HardwareAddr
 	switch mt := p.Pkt.MessageType(); mt {
 	case dhcpv4.MessageTypeDiscover:
-		d, n, err := h.readBackend(ctx, p.Pkt.ClientHWAddr)
+		d, n, err := h.lookup(ctx, p.Pkt)
+		if err != nil && handler.IsNotFound(err) && h.Pool != nil {
+			d, n, err = h.poolOffer(p.Pkt)
+			fromPool = true
+		}
 		if err != nil {
-			if hardwareNotFound(err) {
+			if handler.IsNotFound(err) {
 				span.SetStatus(codes.Ok, "no reservation found")
 				return
 			}
@@ -90,12 +100,61 @@ func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, p data.Pack
 			return
 		}
 		log.Info("received DHCP packet", "type", p.Pkt.MessageType().String())
-		reply = h.updateMsg(ctx, p.Pkt, d, n, dhcpv4.MessageTypeOffer)
+		replaced = replacedMAC(p.Pkt, d)
+		reply = h.withSubnet(p.Pkt, d).updateMsg(ctx, p.Pkt, d, n, dhcpv4.MessageTypeOffer)
 		log = log.WithValues("type", dhcpv4.MessageTypeOffer.String())
 	case dhcpv4.MessageTypeRequest:
-		d, n, err := h.readBackend(ctx, p.Pkt.ClientHWAddr)
+		d, n, err := h.lookup(ctx, p.Pkt)
+		if err != nil && handler.IsNotFound(err) && h.Pool != nil {
+			if !h.isSelected(p.Pkt) {
+				// The client accepted an offer from another DHCP server, so the pool offer can be given to someone else.
+				h.Pool.Release(p.Pkt.ClientHWAddr, requestedIP(p.Pkt))
+				log.Info("client selected another DHCP server, no response sent", "type", p.Pkt.MessageType().String())
+				span.SetStatus(codes.Ok, "client selected another server")
+
+				return
+			}
+			d, n, err = h.poolAck(p.Pkt)
+			fromPool = true
+			if errors.Is(err, pool.ErrUnavailable) {
+				log.Info("requested address is not available", "type", p.Pkt.MessageType().String(), "requestedIP", requestedIP(p.Pkt).String())
+				reply = h.nak(p.Pkt)
+				log = log.WithValues("type", dhcpv4.MessageTypeNak.String())
+				break
+			}
+		}
+		if err != nil {
+			if handler.IsNotFound(err) {
+				span.SetStatus(codes.Ok, "no reservation found")
+				return
+			}
+			log.Info("error reading from backend", "error", err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return
+		}
+		if d.Disabled {
+			log.Info("DHCP is disabled for this MAC address, no response sent", "type", p.Pkt.MessageType().String())
+			span.SetStatus(codes.Ok, "disabled DHCP response")
+
+			return
+		}
+		log.Info("received DHCP packet", "type", p.Pkt.MessageType().String())
+		// From RFC 2131, section 4.3.2: if the requested address is incorrect the server
+		// MUST respond with a DHCPNAK so that the client restarts the configuration process.
+		if rip := requestedIP(p.Pkt); rip.IsValid() && !rip.IsUnspecified() && rip != d.IPAddress {
+			log.Info("requested address does not match the reservation", "requestedIP", rip.String(), "reservedIP", d.IPAddress.String())
+			reply = h.nak(p.Pkt)
+			log = log.WithValues("type", dhcpv4.MessageTypeNak.String())
+			break
+		}
+		replaced = replacedMAC(p.Pkt, d)
+		reply = h.withSubnet(p.Pkt, d).updateMsg(ctx, p.Pkt, d, n, dhcpv4.MessageTypeAck)
+		log = log.WithValues("type", dhcpv4.MessageTypeAck.String())
+	case dhcpv4.MessageTypeInform:
+		d, n, err := h.lookup(ctx, p.Pkt)
 		if err != nil {
-			if hardwareNotFound(err) {
+			if handler.IsNotFound(err) {
 				span.SetStatus(codes.Ok, "no reservation found")
 				return
 			}
@@ -111,16 +170,42 @@ func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, p data.Pack
 			return
 		}
 		log.Info("received DHCP packet", "type", p.Pkt.MessageType().String())
-		reply = h.updateMsg(ctx, p.Pkt, d, n, dhcpv4.MessageTypeAck)
+		reply = h.withSubnet(p.Pkt, d).informMsg(ctx, p.Pkt, d, n)
 		log = log.WithValues("type", dhcpv4.MessageTypeAck.String())
 	case dhcpv4.MessageTypeRelease:
-		// Since the design of this DHCP server is that all IP addresses are
-		// Host reservations, when a client releases an address, the server
+		if h.Leases != nil {
+			if err := h.Leases.Delete(ctx, p.Pkt.ClientHWAddr); err != nil {
+				log.Error(err, "failed to remove lease")
+			}
+		}
+		if h.Pool != nil && h.Pool.Release(p.Pkt.ClientHWAddr, toAddr(p.Pkt.ClientIPAddr)) {
+			log.Info("released pool lease", "type", p.Pkt.MessageType().String(), "ipAddress", p.Pkt.ClientIPAddr.String())
+			span.SetStatus(codes.Ok, "released pool lease")
+
+			return
+		}
+		// Host reservations are not affected by a release, so the server
 		// doesn't have anything to do. This case is included for clarity of this
 		// design decision.
 		log.Info("received DHCP release packet, no response required, all IPs are host reservations", "type", p.Pkt.MessageType().String())
 		span.SetStatus(codes.Ok, "received release, no response required")
 
+		return
+	case dhcpv4.MessageTypeDecline:
+		// A decline means the client found the address already in use on the network.
+		// For a host reservation this needs someone to fix the network or the reservation,
+		// so the decline is flagged in the lease store and logged as an error.
+		ip := requestedIP(p.Pkt)
+		if h.Pool != nil && h.Pool.Decline(p.Pkt.ClientHWAddr, ip) {
+			log.Info("declined pool lease, address will not be offered for a while", "type", p.Pkt.MessageType().String(), "ipAddress", ip.String())
+		} else {
+			log.Error(errors.New("address declined by client"), "the reserved address is in use by another device", "type", p.Pkt.MessageType().String(), "ipAddress", ip.String())
+		}
+		if err := h.recordDecline(ctx, p.Pkt, ifName); err != nil {
+			log.Error(err, "failed to record declined lease", "ipAddress", ip.String())
+		}
+		span.SetStatus(codes.Ok, "received decline, no response required")
+
 		return
 	default:
 		log.Info("received unknown message type", "type", p.Pkt.MessageType().String())
@@ -129,6 +214,21 @@ func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, p data.Pack
 		return
 	}
 
+	if err := h.recordLease(ctx, p.Pkt, reply, ifName, replaced); err != nil {
+		if errors.Is(err, lease.ErrConflict) {
+			if fromPool {
+				// The pool only knows about its own leases, so take the address out of
+				// the pool to make sure the client is offered a different one next time.
+				h.Pool.Decline(p.Pkt.ClientHWAddr, toAddr(reply.YourIPAddr))
+			}
+			log.Error(err, "not sending DHCP response, the address is leased to another client", "ipAddress", reply.YourIPAddr.String())
+			span.SetStatus(codes.Error, err.Error())
+
+			return
+		}
+		log.Error(err, "failed to record lease", "ipAddress", reply.YourIPAddr.String())
+	}
+
 	if bf := reply.BootFileName; bf != "" {
 		log = log.WithValues("bootFileName", bf)
 	}
@@ -151,10 +251,56 @@ func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, p data.Pack
 	}
 
 	log.Info("sent DHCP response")
+	h.recordProgress(ctx, reply)
 	span.SetAttributes(h.encodeToAttributes(reply, "reply")...)
 	span.SetStatus(codes.Ok, "sent DHCP response")
 }
 
+// Simulate implements the handler.Simulator interface. It returns the offer to a DHCPDISCOVER,
+// or the acknowledgement of a DHCPREQUEST, of a client with a host reservation without sending it.
+// Leases and pool addresses are not changed, so clients without a host reservation are not simulated.
+func (h *Handler) Simulate(ctx context.Context, p data.Packet) (*dhcpv4.DHCPv4, error) {
+	h.setDefaults()
+	if p.Pkt == nil {
+		return nil, errors.New("packet is nil")
+	}
+	var msgType dhcpv4.MessageType
+	switch mt := p.Pkt.MessageType(); mt {
+	case dhcpv4.MessageTypeDiscover:
+		msgType = dhcpv4.MessageTypeOffer
+	case dhcpv4.MessageTypeRequest:
+		msgType = dhcpv4.MessageTypeAck
+	default:
+		return nil, fmt.Errorf("simulating a %s message is not supported", mt)
+	}
+
+	d, n, err := h.lookup(ctx, p.Pkt)
+	if err != nil {
+		if handler.IsNotFound(err) && h.Pool != nil {
+			return nil, fmt.Errorf("no host reservation found, the client would be offered an address from the pool: %w", err)
+		}
+		return nil, err
+	}
+	if d.Disabled {
+		return nil, errors.New("DHCP is disabled for this MAC address, no response would be sent")
+	}
+
+	return h.withSubnet(p.Pkt, d).updateMsg(ctx, p.Pkt, d, n, msgType), nil
+}
+
+// recordProgress records that an offer or acknowledgement was sent to the client of reply, with its boot file name.
+func (h *Handler) recordProgress(ctx context.Context, reply *dhcpv4.DHCPv4) {
+	if h.Progress == nil {
+		return
+	}
+	switch reply.MessageType() {
+	case dhcpv4.MessageTypeOffer:
+		h.Progress.RecordProgress(ctx, reply.ClientHWAddr, nil, handler.StageDHCPOffer, reply.BootFileName)
+	case dhcpv4.MessageTypeAck:
+		h.Progress.RecordProgress(ctx, reply.ClientHWAddr, nil, handler.StageDHCPAck, reply.BootFileName)
+	}
+}
+
 // replyDestination determines the destination address for the DHCP reply.
 // If the giaddr is set, then the reply should be sent to the giaddr.
 // Otherwise, the reply should be sent to the direct peer.
@@ -214,6 +360,17 @@ func (h *Handler) updateMsg(ctx context.Context, pkt *dhcpv4.DHCPv4, d *data.DHC
 	return reply
 }
 
+// informMsg handles creating a DHCPACK for a DHCPINFORM with the data from the backend.
+// From RFC 2131, section 4.3.5: the client already has an address, so the server MUST NOT
+// set yiaddr or a lease time in the reply.
+func (h *Handler) informMsg(ctx context.Context, pkt *dhcpv4.DHCPv4, d *data.DHCP, n *data.Netboot) *dhcpv4.DHCPv4 {
+	reply := h.updateMsg(ctx, pkt, d, n, dhcpv4.MessageTypeAck)
+	reply.YourIPAddr = net.IPv4zero
+	reply.Options.Del(dhcpv4.OptionIPAddressLeaseTime)
+
+	return reply
+}
+
 // encodeToAttributes takes a DHCP packet and returns opentelemetry key/value attributes.
 func (h *Handler) encodeToAttributes(d *dhcpv4.DHCPv4, namespace string) []attribute.KeyValue {
 	h.setDefaults()
@@ -221,12 +378,3 @@ func (h *Handler) encodeToAttributes(d *dhcpv4.DHCPv4, namespace string) []attri
 
 	return a.Encode(d, namespace, oteldhcp.AllEncoders()...)
 }
-
-// hardwareNotFound returns true if the error is from a hardware record not being found.
-func hardwareNotFound(err error) bool {
-	type hardwareNotFound interface {
-		NotFound() bool
-	}
-	te, ok := err.(hardwareNotFound)
-	return ok && te.NotFound()
-}
@@ -19,7 +19,10 @@ import (
 	"github.com/insomniacslk/dhcp/iana"
 	"github.com/insomniacslk/dhcp/rfc1035label"
 	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
+	"github.com/tinkerbell/smee/internal/dhcp/lease"
 	"github.com/tinkerbell/smee/internal/dhcp/otel"
+	"github.com/tinkerbell/smee/internal/dhcp/pool"
 	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/net/ipv4"
 	"golang.org/x/net/nettest"
@@ -251,7 +254,7 @@ func TestHandle(t *testing.T) {
 				OpCode:       dhcpv4.OpcodeBootRequest,
 				ClientHWAddr: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
 				Options: dhcpv4.OptionsFromList(
-					dhcpv4.OptMessageType(dhcpv4.MessageTypeInform),
+					dhcpv4.OptMessageType(dhcpv4.MessageTypeOffer),
 				),
 			},
 			wantErr: errBadBackend,
@@ -349,6 +352,137 @@ func TestHandle(t *testing.T) {
 	}
 }
 
+func TestHandleInformRequestDecline(t *testing.T) {
+	tests := map[string]struct {
+		req          *dhcpv4.DHCPv4
+		wantType     dhcpv4.MessageType
+		wantYIAddr   net.IP
+		wantLease    bool
+		wantDeclined bool
+		wantErr      error
+	}{
+		"inform": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+				ClientIPAddr: net.IP{192, 168, 1, 100},
+				Options:      dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeInform)),
+			},
+			wantType:   dhcpv4.MessageTypeAck,
+			wantYIAddr: net.IPv4zero.To4(),
+		},
+		"request for reserved address": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptMessageType(dhcpv4.MessageTypeRequest),
+					dhcpv4.OptRequestedIPAddress(net.IP{192, 168, 1, 100}),
+				),
+			},
+			wantType:   dhcpv4.MessageTypeAck,
+			wantYIAddr: net.IP{192, 168, 1, 100},
+			wantLease:  true,
+		},
+		"request for another address": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptMessageType(dhcpv4.MessageTypeRequest),
+					dhcpv4.OptRequestedIPAddress(net.IP{192, 168, 1, 101}),
+				),
+			},
+			wantType:   dhcpv4.MessageTypeNak,
+			wantYIAddr: net.IPv4zero.To4(),
+		},
+		"renew with another address": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+				ClientIPAddr: net.IP{192, 168, 1, 101},
+				Options:      dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeRequest)),
+			},
+			wantType:   dhcpv4.MessageTypeNak,
+			wantYIAddr: net.IPv4zero.To4(),
+		},
+		"request of a reservation for another server": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptMessageType(dhcpv4.MessageTypeRequest),
+					dhcpv4.OptServerIdentifier(net.IP{127, 0, 0, 2}),
+					dhcpv4.OptRequestedIPAddress(net.IP{192, 168, 1, 100}),
+				),
+			},
+			wantType:   dhcpv4.MessageTypeAck,
+			wantYIAddr: net.IP{192, 168, 1, 100},
+			wantLease:  true,
+		},
+		"decline": {
+			req: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptMessageType(dhcpv4.MessageTypeDecline),
+					dhcpv4.OptRequestedIPAddress(net.IP{192, 168, 1, 100}),
+				),
+			},
+			wantErr:      errBadBackend,
+			wantDeclined: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := lease.NewMemory()
+			s := Handler{
+				Backend: &mockBackend{},
+				IPAddr:  netip.MustParseAddr("127.0.0.1"),
+				Leases:  store,
+			}
+			conn, err := nettest.NewLocalPacketListener("udp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			pc, err := net.ListenPacket("udp4", ":0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pc.Close()
+			peer := &net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: pc.LocalAddr().(*net.UDPAddr).Port}
+
+			s.Handle(context.Background(), ipv4.NewPacketConn(conn), data.Packet{Peer: peer, Pkt: tt.req, Md: &data.Metadata{IfName: "lo"}})
+
+			msg, err := client(pc)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("client() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil {
+				if diff := cmp.Diff(tt.wantType, msg.MessageType()); diff != "" {
+					t.Fatal(diff)
+				}
+				if diff := cmp.Diff(tt.wantYIAddr, msg.YourIPAddr.To4()); diff != "" {
+					t.Fatal(diff)
+				}
+				if tt.wantType != dhcpv4.MessageTypeAck || msg.YourIPAddr.IsUnspecified() {
+					if lt := msg.Options.Get(dhcpv4.OptionIPAddressLeaseTime); lt != nil {
+						t.Fatalf("unexpected lease time option: %v", lt)
+					}
+				}
+			}
+			l, err := store.Get(context.Background(), tt.req.ClientHWAddr)
+			if gotLease := err == nil && l.State == lease.StateAcked; gotLease != tt.wantLease {
+				t.Fatalf("lease recorded = %v, want %v", gotLease, tt.wantLease)
+			}
+			if gotDeclined := err == nil && l.State == lease.StateDeclined; gotDeclined != tt.wantDeclined {
+				t.Fatalf("decline recorded = %v, want %v", gotDeclined, tt.wantDeclined)
+			}
+		})
+	}
+}
+
 func client(pc net.PacketConn) (*dhcpv4.DHCPv4, error) {
 	buf := make([]byte, 1024)
 	pc.SetReadDeadline(time.Now().Add(time.Millisecond * 100))
@@ -363,6 +497,96 @@ func client(pc net.PacketConn) (*dhcpv4.DHCPv4, error) {
 	return msg, nil
 }
 
+// progressRecorder records the stages and details of the progress it is given.
+type progressRecorder struct {
+	records []string
+}
+
+func (p *progressRecorder) RecordProgress(_ context.Context, mac net.HardwareAddr, _ net.IP, stage handler.Stage, detail string) {
+	p.records = append(p.records, fmt.Sprintf("%s %s %s", mac, stage, detail))
+}
+
+func TestRecordProgress(t *testing.T) {
+	tests := map[string]struct {
+		reply *dhcpv4.DHCPv4
+		want  []string
+	}{
+		"offer": {
+			reply: &dhcpv4.DHCPv4{ClientHWAddr: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}, BootFileName: "snp.efi", Options: dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeOffer))},
+			want:  []string{"01:02:03:04:05:06 dhcp-offer snp.efi"},
+		},
+		"ack": {
+			reply: &dhcpv4.DHCPv4{ClientHWAddr: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}, Options: dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeAck))},
+			want:  []string{"01:02:03:04:05:06 dhcp-ack "},
+		},
+		"nak": {
+			reply: &dhcpv4.DHCPv4{ClientHWAddr: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}, Options: dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeNak))},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := &progressRecorder{}
+			h := &Handler{Progress: p}
+			h.recordProgress(context.Background(), tt.reply)
+			if diff := cmp.Diff(tt.want, p.records); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+	// Without a recorder, nothing is recorded.
+	(&Handler{}).recordProgress(context.Background(), tests["offer"].reply)
+}
+
+func TestSimulate(t *testing.T) {
+	tests := map[string]struct {
+		backend  *mockBackend
+		pool     bool
+		msgType  dhcpv4.MessageType
+		wantType dhcpv4.MessageType
+		wantErr  bool
+	}{
+		"discover":             {backend: &mockBackend{allowNetboot: true}, msgType: dhcpv4.MessageTypeDiscover, wantType: dhcpv4.MessageTypeOffer},
+		"request":              {backend: &mockBackend{allowNetboot: true}, msgType: dhcpv4.MessageTypeRequest, wantType: dhcpv4.MessageTypeAck},
+		"release":              {backend: &mockBackend{allowNetboot: true}, msgType: dhcpv4.MessageTypeRelease, wantErr: true},
+		"no reservation":       {backend: &mockBackend{hardwareNotFound: true}, msgType: dhcpv4.MessageTypeDiscover, wantErr: true},
+		"no reservation, pool": {backend: &mockBackend{hardwareNotFound: true}, pool: true, msgType: dhcpv4.MessageTypeDiscover, wantErr: true},
+		"backend error":        {backend: &mockBackend{err: errBadBackend}, msgType: dhcpv4.MessageTypeDiscover, wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{
+				Backend: tt.backend,
+				IPAddr:  netip.MustParseAddr("127.0.0.1"),
+				Leases:  lease.NewMemory(),
+			}
+			if tt.pool {
+				h.Pool = &pool.Pool{}
+			}
+			pkt, err := dhcpv4.New(dhcpv4.WithHwAddr(net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}), dhcpv4.WithMessageType(tt.msgType))
+			if err != nil {
+				t.Fatal(err)
+			}
+			reply, err := h.Simulate(context.Background(), data.Packet{Pkt: pkt})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Simulate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if reply.MessageType() != tt.wantType {
+				t.Errorf("Simulate() message type = %s, want %s", reply.MessageType(), tt.wantType)
+			}
+			if got := reply.YourIPAddr.String(); got != "192.168.1.100" {
+				t.Errorf("Simulate() yiaddr = %s, want 192.168.1.100", got)
+			}
+			// Nothing is leased by a simulation.
+			if l, err := h.Leases.Get(context.Background(), pkt.ClientHWAddr); err == nil {
+				t.Errorf("Simulate() recorded lease %v", l)
+			}
+		})
+	}
+}
+
 func TestUpdateMsg(t *testing.T) {
 	type args struct {
 		m       *dhcpv4.DHCPv4
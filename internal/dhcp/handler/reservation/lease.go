@@ -0,0 +1,77 @@
+package reservation
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/smee/internal/dhcp/lease"
+)
+
+// recordLease records the address in an offer or acknowledgement in the lease store.
+// Other replies are ignored.
+// replaced is the mac address of the previous NIC of the client, or nil. The address belongs to the switch port
+// the client is connected to, so the lease of the previous NIC is moved to the client when it is acknowledged.
+// Until then the lease of the previous NIC is kept, and offers are not recorded.
+func (h *Handler) recordLease(ctx context.Context, pkt, reply *dhcpv4.DHCPv4, ifName string, replaced net.HardwareAddr) error {
+	if h.Leases == nil {
+		return nil
+	}
+	var s lease.State
+	switch reply.MessageType() {
+	case dhcpv4.MessageTypeOffer:
+		s = lease.StateOffered
+	case dhcpv4.MessageTypeAck:
+		s = lease.StateAcked
+	default:
+		return nil
+	}
+	ip := toAddr(reply.YourIPAddr)
+	if !ip.IsValid() || ip.IsUnspecified() {
+		return nil
+	}
+	if replaced != nil {
+		if s == lease.StateOffered {
+			return nil
+		}
+		if err := h.Leases.Delete(ctx, replaced); err != nil {
+			return fmt.Errorf("failed to remove lease of the previous mac address %v: %w", replaced, err)
+		}
+	}
+
+	return h.Leases.Put(ctx, newLease(pkt, ip, ifName, s, time.Now().Add(reply.IPAddressLeaseTime(0))))
+}
+
+// recordDecline flags the address in a decline in the lease store.
+// The declined lease is not active, so it does not stop the address being leased again.
+func (h *Handler) recordDecline(ctx context.Context, pkt *dhcpv4.DHCPv4, ifName string) error {
+	if h.Leases == nil {
+		return nil
+	}
+	ip := requestedIP(pkt)
+	if !ip.IsValid() || ip.IsUnspecified() {
+		return nil
+	}
+
+	return h.Leases.Put(ctx, newLease(pkt, ip, ifName, lease.StateDeclined, time.Now()))
+}
+
+// newLease returns a lease for a client message.
+func newLease(pkt *dhcpv4.DHCPv4, ip netip.Addr, ifName string, s lease.State, expires time.Time) lease.Lease {
+	l := lease.Lease{
+		MAC:       pkt.ClientHWAddr,
+		IP:        ip,
+		XID:       pkt.TransactionID.String(),
+		Interface: ifName,
+		State:     s,
+		Expires:   expires,
+	}
+	if gi := toAddr(pkt.GatewayIPAddr); gi.IsValid() && !gi.IsUnspecified() {
+		l.GatewayIP = gi
+	}
+
+	return l
+}
@@ -0,0 +1,105 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/lease"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/nettest"
+)
+
+func TestHandleLeases(t *testing.T) {
+	tests := map[string]struct {
+		existing  *lease.Lease
+		msgType   dhcpv4.MessageType
+		wantErr   error
+		wantState lease.State
+	}{
+		"discover records offer": {
+			msgType:   dhcpv4.MessageTypeDiscover,
+			wantState: lease.StateOffered,
+		},
+		"request records ack": {
+			msgType:   dhcpv4.MessageTypeRequest,
+			wantState: lease.StateAcked,
+		},
+		"address leased to another client": {
+			existing: &lease.Lease{
+				MAC:     net.HardwareAddr{0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f},
+				IP:      netip.MustParseAddr("192.168.1.100"),
+				State:   lease.StateAcked,
+				Expires: time.Now().Add(time.Hour),
+			},
+			msgType: dhcpv4.MessageTypeDiscover,
+			wantErr: errBadBackend,
+		},
+		"expired lease of another client": {
+			existing: &lease.Lease{
+				MAC:     net.HardwareAddr{0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f},
+				IP:      netip.MustParseAddr("192.168.1.100"),
+				State:   lease.StateAcked,
+				Expires: time.Now().Add(-time.Hour),
+			},
+			msgType:   dhcpv4.MessageTypeRequest,
+			wantState: lease.StateAcked,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := lease.NewMemory()
+			if tt.existing != nil {
+				if err := store.Put(context.Background(), *tt.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s := Handler{
+				Backend: &mockBackend{},
+				IPAddr:  netip.MustParseAddr("127.0.0.1"),
+				Leases:  store,
+			}
+			conn, err := nettest.NewLocalPacketListener("udp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			pc, err := net.ListenPacket("udp4", ":0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pc.Close()
+			peer := &net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: pc.LocalAddr().(*net.UDPAddr).Port}
+			req := &dhcpv4.DHCPv4{
+				OpCode:        dhcpv4.OpcodeBootRequest,
+				ClientHWAddr:  []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+				TransactionID: dhcpv4.TransactionID{0x01, 0x02, 0x03, 0x04},
+				Options:       dhcpv4.OptionsFromList(dhcpv4.OptMessageType(tt.msgType)),
+			}
+
+			s.Handle(context.Background(), ipv4.NewPacketConn(conn), data.Packet{Peer: peer, Pkt: req, Md: &data.Metadata{IfName: "lo"}})
+
+			if _, err := client(pc); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("client() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			got, err := store.Get(context.Background(), req.ClientHWAddr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.State != tt.wantState || got.IP != netip.MustParseAddr("192.168.1.100") || got.Interface != "lo" || got.XID != "0x01020304" {
+				t.Fatalf("unexpected lease: %+v", got)
+			}
+			if !got.Expires.After(time.Now()) {
+				t.Fatalf("lease already expired: %v", got.Expires)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package reservation
+
+import (
+	"context"
+	"net"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/smee/internal/dhcp"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// lookup reads the data for a client from the backend.
+// When the request has relay agent information and the backend can match on it, that is tried first,
+// so that a client is provisioned by the switch port it is connected to, even if its NIC was replaced.
+// Otherwise, or when nothing matches, the data is read by the client mac address.
+func (h *Handler) lookup(ctx context.Context, pkt *dhcpv4.DHCPv4) (*data.DHCP, *data.Netboot, error) {
+	h.setDefaults()
+	rr, ok := h.Backend.(handler.RelayAgentReader)
+	if !ok {
+		return h.readBackend(ctx, pkt.ClientHWAddr)
+	}
+	ra, ok := dhcp.RelayAgent(pkt)
+	if !ok {
+		return h.readBackend(ctx, pkt.ClientHWAddr)
+	}
+	log := h.Log.WithValues("mac", pkt.ClientHWAddr.String(), "xid", pkt.TransactionID.String(), "circuitID", ra.CircuitID, "remoteID", ra.RemoteID)
+
+	d, n, err := h.readRelayAgent(ctx, rr, ra)
+	if err != nil {
+		if !handler.IsNotFound(err) {
+			log.Info("error reading from backend by relay agent information, reading by mac address", "error", err)
+		}
+		return h.readBackend(ctx, pkt.ClientHWAddr)
+	}
+	if replacedMAC(pkt, d) != nil {
+		log.Info("relay agent information matched a record with a different mac address", "recordMAC", d.MACAddress.String())
+	}
+
+	return d, n, nil
+}
+
+// replacedMAC returns the mac address of the record d when it is not the mac address of the client of pkt,
+// for example when d was matched by relay agent information after the NIC of the client was replaced.
+// It returns nil when d has the mac address of the client or no mac address.
+func replacedMAC(pkt *dhcpv4.DHCPv4, d *data.DHCP) net.HardwareAddr {
+	if d == nil || len(d.MACAddress) == 0 || d.MACAddress.String() == pkt.ClientHWAddr.String() {
+		return nil
+	}
+
+	return d.MACAddress
+}
+
+// readRelayAgent encapsulates the backend read by relay agent information and opentelemetry handling.
+func (h *Handler) readRelayAgent(ctx context.Context, rr handler.RelayAgentReader, ra data.RelayAgent) (*data.DHCP, *data.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "Hardware data get by relay agent")
+	defer span.End()
+	span.SetAttributes(attribute.String("DHCP.RelayAgent.CircuitID", ra.CircuitID), attribute.String("DHCP.RelayAgent.RemoteID", ra.RemoteID))
+
+	d, n, err := rr.GetByRelayAgent(ctx, ra)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, err
+	}
+
+	span.SetAttributes(d.EncodeToAttributes()...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "done reading from backend")
+
+	return d, n, nil
+}
@@ -0,0 +1,160 @@
+package reservation
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/lease"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/nettest"
+)
+
+// relayBackend is a mockBackend that also matches records by relay agent information.
+type relayBackend struct {
+	mockBackend
+	relay data.RelayAgent
+}
+
+func (r *relayBackend) GetByRelayAgent(_ context.Context, ra data.RelayAgent) (*data.DHCP, *data.Netboot, error) {
+	if !r.relay.Matches(ra) {
+		return nil, nil, hwNotFoundError{}
+	}
+	d := &data.DHCP{
+		MACAddress:     net.HardwareAddr{0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f},
+		IPAddress:      netip.MustParseAddr("192.168.1.200"),
+		SubnetMask:     []byte{255, 255, 255, 0},
+		DefaultGateway: netip.MustParseAddr("192.168.1.1"),
+		LeaseTime:      60,
+	}
+
+	return d, &data.Netboot{}, nil
+}
+
+func TestHandleRelayAgent(t *testing.T) {
+	relayed := dhcpv4.OptRelayAgentInfo(
+		dhcpv4.OptGeneric(dhcpv4.AgentCircuitIDSubOption, []byte("Ethernet1/1")),
+		dhcpv4.OptGeneric(dhcpv4.AgentRemoteIDSubOption, []byte("switch01")),
+	)
+	tests := map[string]struct {
+		relay    data.RelayAgent
+		opt82    *dhcpv4.Option
+		msgType  dhcpv4.MessageType
+		existing *lease.Lease
+		wantIP   net.IP
+		// wantLeaseMAC is the mac address that the address is leased to after the reply.
+		wantLeaseMAC net.HardwareAddr
+	}{
+		"matched by circuit and remote id": {
+			relay:  data.RelayAgent{CircuitID: "Ethernet1/1", RemoteID: "switch01"},
+			opt82:  &relayed,
+			wantIP: net.IP{192, 168, 1, 200},
+		},
+		"matched by circuit id": {
+			relay:  data.RelayAgent{CircuitID: "Ethernet1/1"},
+			opt82:  &relayed,
+			wantIP: net.IP{192, 168, 1, 200},
+		},
+		"no match falls back to mac": {
+			relay:  data.RelayAgent{CircuitID: "Ethernet1/2"},
+			opt82:  &relayed,
+			wantIP: net.IP{192, 168, 1, 100},
+		},
+		"not relayed": {
+			relay:  data.RelayAgent{CircuitID: "Ethernet1/1"},
+			wantIP: net.IP{192, 168, 1, 100},
+		},
+		"lease of replaced NIC is kept on offer": {
+			relay: data.RelayAgent{CircuitID: "Ethernet1/1"},
+			opt82: &relayed,
+			existing: &lease.Lease{
+				MAC:     net.HardwareAddr{0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f},
+				IP:      netip.MustParseAddr("192.168.1.200"),
+				State:   lease.StateAcked,
+				Expires: time.Now().Add(time.Hour),
+			},
+			wantIP:       net.IP{192, 168, 1, 200},
+			wantLeaseMAC: net.HardwareAddr{0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f},
+		},
+		"lease of replaced NIC is moved on ack": {
+			relay:   data.RelayAgent{CircuitID: "Ethernet1/1"},
+			opt82:   &relayed,
+			msgType: dhcpv4.MessageTypeRequest,
+			existing: &lease.Lease{
+				MAC:     net.HardwareAddr{0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f},
+				IP:      netip.MustParseAddr("192.168.1.200"),
+				State:   lease.StateAcked,
+				Expires: time.Now().Add(time.Hour),
+			},
+			wantIP:       net.IP{192, 168, 1, 200},
+			wantLeaseMAC: net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := lease.NewMemory()
+			if tt.existing != nil {
+				if err := store.Put(context.Background(), *tt.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s := Handler{
+				Backend: &relayBackend{relay: tt.relay},
+				IPAddr:  netip.MustParseAddr("127.0.0.1"),
+				Leases:  store,
+			}
+			conn, err := nettest.NewLocalPacketListener("udp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			pc, err := net.ListenPacket("udp4", ":0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pc.Close()
+			peer := &net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: pc.LocalAddr().(*net.UDPAddr).Port}
+			msgType := tt.msgType
+			if msgType == dhcpv4.MessageTypeNone {
+				msgType = dhcpv4.MessageTypeDiscover
+			}
+			req := &dhcpv4.DHCPv4{
+				OpCode:        dhcpv4.OpcodeBootRequest,
+				ClientHWAddr:  []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+				TransactionID: dhcpv4.TransactionID{0x01, 0x02, 0x03, 0x04},
+				Options:       dhcpv4.OptionsFromList(dhcpv4.OptMessageType(msgType)),
+			}
+			if tt.opt82 != nil {
+				req.UpdateOption(*tt.opt82)
+			}
+
+			s.Handle(context.Background(), ipv4.NewPacketConn(conn), data.Packet{Peer: peer, Pkt: req})
+
+			got, err := client(pc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.YourIPAddr.Equal(tt.wantIP) {
+				t.Fatalf("yiaddr = %v, want %v", got.YourIPAddr, tt.wantIP)
+			}
+			// From RFC 3046, section 2.2: the server copies the relay agent information option into the reply.
+			if diff := cmp.Diff(req.Options.Get(dhcpv4.OptionRelayAgentInformation), got.Options.Get(dhcpv4.OptionRelayAgentInformation)); diff != "" {
+				t.Fatal(diff)
+			}
+			if tt.wantLeaseMAC != nil {
+				l, err := store.GetByIP(context.Background(), netip.MustParseAddr("192.168.1.200"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if l.MAC.String() != tt.wantLeaseMAC.String() {
+					t.Fatalf("lease mac = %v, want %v", l.MAC, tt.wantLeaseMAC)
+				}
+			}
+		})
+	}
+}
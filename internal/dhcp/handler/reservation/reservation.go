@@ -9,11 +9,16 @@ import (
 	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/tinkerbell/smee/internal/dhcp"
 	"github.com/tinkerbell/smee/internal/dhcp/handler"
+	"github.com/tinkerbell/smee/internal/dhcp/lease"
+	"github.com/tinkerbell/smee/internal/dhcp/pool"
+	"github.com/tinkerbell/smee/internal/dhcp/subnet"
 )
 
 // Handler holds the configuration details for the running the DHCP server.
 type Handler struct {
 	// Backend is the backend to use for getting DHCP data.
+	// If the backend also implements handler.RelayAgentReader, requests with relay agent information (DHCP option 82)
+	// are matched by their circuit ID and remote ID before their mac address.
 	Backend handler.BackendReader
 
 	// IPAddr is the IP address to use in DHCP responses.
@@ -36,6 +41,22 @@ type Handler struct {
 
 	// SyslogAddr is the address to send syslog messages to. DHCP Option 7.
 	SyslogAddr netip.Addr
+
+	// Pool, when set, is used to allocate addresses to clients that do not have a host reservation in the backend.
+	// Clients with a pool lease are always allowed to netboot.
+	Pool *pool.Pool
+
+	// Leases, when set, records every address that is offered or acknowledged.
+	// No response is sent when the address in a response is leased to a different client,
+	// for example when two host reservations hold the same IP address.
+	Leases lease.Store
+
+	// Subnets, when set, provides per subnet defaults for the DHCP options a backend record does not have,
+	// and per subnet netboot configuration. The subnet is selected by the link a request was received from.
+	Subnets *subnet.Registry
+
+	// Progress, when set, records the DHCP offers and acknowledgements that are sent to clients.
+	Progress handler.ProgressRecorder
 }
 
 // Netboot holds the netboot configuration details used in running a DHCP server.
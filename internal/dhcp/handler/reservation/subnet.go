@@ -0,0 +1,34 @@
+package reservation
+
+import (
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+)
+
+// withSubnet applies the defaults of the subnet of a DHCP message to d.
+// It returns a copy of the handler with the netboot configuration of the subnet,
+// or h itself when no subnet applies.
+func (h *Handler) withSubnet(pkt *dhcpv4.DHCPv4, d *data.DHCP) *Handler {
+	s, ok := h.Subnets.Select(pkt, d.IPAddress)
+	if !ok {
+		return h
+	}
+	if d.IPAddress.IsValid() && !s.Prefix.Contains(d.IPAddress) {
+		h.Log.Info("address is not in the subnet of the link the request was received from, subnet defaults not applied", "mac", pkt.ClientHWAddr.String(), "ipAddress", d.IPAddress.String(), "subnet", s.Prefix.String())
+		return h
+	}
+	s.Apply(d)
+
+	sh := *h
+	if s.Netboot.IPXEBinServerTFTP.IsValid() {
+		sh.Netboot.IPXEBinServerTFTP = s.Netboot.IPXEBinServerTFTP
+	}
+	if s.Netboot.IPXEBinServerHTTP != nil {
+		sh.Netboot.IPXEBinServerHTTP = s.Netboot.IPXEBinServerHTTP
+	}
+	if s.Netboot.IPXEScriptURL != nil {
+		sh.Netboot.IPXEScriptURL = s.Netboot.IPXEScriptURL
+	}
+
+	return &sh
+}
@@ -0,0 +1,110 @@
+package reservation
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/subnet"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/nettest"
+)
+
+// ipOnlyBackend returns records that only have an IP address.
+type ipOnlyBackend struct {
+	mockBackend
+}
+
+func (ipOnlyBackend) GetByMac(_ context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	return &data.DHCP{MACAddress: mac, IPAddress: netip.MustParseAddr("192.168.2.10")}, &data.Netboot{}, nil
+}
+
+func TestHandleSubnet(t *testing.T) {
+	reg, err := subnet.NewRegistry(subnet.Subnet{
+		Prefix: netip.MustParsePrefix("192.168.2.0/24"),
+		Options: data.DHCP{
+			DefaultGateway: netip.MustParseAddr("192.168.2.1"),
+			NameServers:    []net.IP{{1, 1, 1, 1}},
+			LeaseTime:      3600,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		opt118     []byte
+		wantRouter []net.IP
+		wantMask   net.IPMask
+		wantDNS    []net.IP
+		wantLease  uint32
+	}{
+		"subnet selected by option 118": {
+			opt118:     []byte{192, 168, 2, 0},
+			wantRouter: []net.IP{{192, 168, 2, 1}},
+			wantMask:   net.CIDRMask(24, 32),
+			wantDNS:    []net.IP{{1, 1, 1, 1}},
+			wantLease:  3600,
+		},
+		"subnet selected by the reserved address": {
+			wantRouter: []net.IP{{192, 168, 2, 1}},
+			wantMask:   net.CIDRMask(24, 32),
+			wantDNS:    []net.IP{{1, 1, 1, 1}},
+			wantLease:  3600,
+		},
+		"reserved address not in the selected subnet": {
+			opt118: []byte{192, 168, 3, 0},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := Handler{
+				Backend: &ipOnlyBackend{},
+				IPAddr:  netip.MustParseAddr("127.0.0.1"),
+				Subnets: reg,
+			}
+			conn, err := nettest.NewLocalPacketListener("udp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			pc, err := net.ListenPacket("udp4", ":0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pc.Close()
+			peer := &net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: pc.LocalAddr().(*net.UDPAddr).Port}
+			req := &dhcpv4.DHCPv4{
+				OpCode:        dhcpv4.OpcodeBootRequest,
+				ClientHWAddr:  []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+				TransactionID: dhcpv4.TransactionID{0x01, 0x02, 0x03, 0x04},
+				Options:       dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeDiscover)),
+			}
+			if tt.opt118 != nil {
+				req.UpdateOption(dhcpv4.OptGeneric(dhcpv4.OptionSubnetSelection, tt.opt118))
+			}
+
+			s.Handle(context.Background(), ipv4.NewPacketConn(conn), data.Packet{Peer: peer, Pkt: req})
+
+			got, err := client(pc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.wantRouter, got.Router()); diff != "" {
+				t.Error(diff)
+			}
+			if diff := cmp.Diff(tt.wantMask, got.SubnetMask()); diff != "" {
+				t.Error(diff)
+			}
+			if diff := cmp.Diff(tt.wantDNS, got.DNS()); diff != "" {
+				t.Error(diff)
+			}
+			if got := got.IPAddressLeaseTime(0).Seconds(); uint32(got) != tt.wantLease {
+				t.Errorf("lease time = %v, want %v", got, tt.wantLease)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package reservation6
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/go-logr/logr"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/ipv6"
+)
+
+const tracerName = "github.com/tinkerbell/smee"
+
+// errIgnore is returned when a message should not be responded to.
+var errIgnore = errors.New("ignoring message")
+
+// setDefaults will update the Handler struct to have default values so as
+// to avoid panic for nil pointers and such.
+func (h *Handler) setDefaults() {
+	if h.Backend == nil {
+		h.Backend = noop{}
+	}
+	if h.Log.GetSink() == nil {
+		h.Log = logr.Discard()
+	}
+}
+
+// Handle responds to DHCPv6 messages with DHCPv6 server options.
+func (h *Handler) Handle(ctx context.Context, conn *ipv6.PacketConn, p data.Packet6) {
+	h.setDefaults()
+	if p.Pkt == nil {
+		h.Log.Error(errors.New("incoming packet is nil"), "not able to respond when the incoming packet is nil")
+		return
+	}
+	upeer, ok := p.Peer.(*net.UDPAddr)
+	if !ok {
+		h.Log.Error(errors.New("peer is not a UDP connection"), "not able to respond when the peer is not a UDP connection")
+		return
+	}
+	if upeer == nil {
+		h.Log.Error(errors.New("peer is nil"), "not able to respond when the peer is nil")
+		return
+	}
+	if conn == nil {
+		h.Log.Error(errors.New("connection is nil"), "not able to respond when the connection is nil")
+		return
+	}
+
+	var ifName string
+	if p.Md != nil {
+		ifName = p.Md.IfName
+	}
+	msg, err := p.Pkt.GetInnerMessage()
+	if err != nil {
+		h.Log.Info("unable to get the inner DHCPv6 message", "error", err, "interface", ifName)
+		return
+	}
+	mac, err := dhcpv6.ExtractMAC(p.Pkt)
+	if err != nil {
+		h.Log.Info("unable to determine the client link-layer address", "error", err, "interface", ifName, "xid", msg.TransactionID.String())
+		return
+	}
+
+	log := h.Log.WithValues("mac", mac.String(), "xid", msg.TransactionID.String(), "interface", ifName)
+	tracer := otel.Tracer(tracerName)
+	var span trace.Span
+	ctx, span = tracer.Start(
+		ctx,
+		fmt.Sprintf("DHCPv6 Packet Received: %v", msg.Type().String()),
+		trace.WithAttributes(attribute.String("DHCPv6.request", msg.Summary())),
+		trace.WithAttributes(attribute.String("DHCPv6.peer", p.Peer.String())),
+		trace.WithAttributes(attribute.String("DHCPv6.server.ifname", ifName)),
+	)
+	defer span.End()
+
+	d, n, err := h.readBackend(ctx, mac)
+	if err != nil {
+		if handler.IsNotFound(err) {
+			span.SetStatus(codes.Ok, "no reservation found")
+			return
+		}
+		log.Info("error reading from backend", "error", err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return
+	}
+	if d.Disabled {
+		log.Info("DHCP is disabled for this MAC address, no response sent", "type", msg.Type().String())
+		span.SetStatus(codes.Ok, "disabled DHCP response")
+
+		return
+	}
+	log.Info("received DHCPv6 packet", "type", msg.Type().String())
+
+	reply, err := h.updateMsg(ctx, msg, mac, d, n)
+	if err != nil {
+		log.Info("no response sent", "type", msg.Type().String(), "reason", err.Error())
+		span.SetStatus(codes.Ok, err.Error())
+
+		return
+	}
+	log = log.WithValues("type", reply.Type().String())
+	if bf := reply.Options.BootFileURL(); bf != "" {
+		log = log.WithValues("bootFileURL", bf)
+	}
+
+	var resp dhcpv6.DHCPv6 = reply
+	if relay, ok := p.Pkt.(*dhcpv6.RelayMessage); ok {
+		resp, err = dhcpv6.NewRelayReplFromRelayForw(relay, reply)
+		if err != nil {
+			log.Error(err, "failed to create relay reply")
+			span.SetStatus(codes.Error, err.Error())
+
+			return
+		}
+	}
+
+	log = log.WithValues("destination", upeer.String())
+	cm := &ipv6.ControlMessage{}
+	if p.Md != nil {
+		cm.IfIndex = p.Md.IfIndex
+	}
+	if _, err := conn.WriteTo(resp.ToBytes(), cm, upeer); err != nil {
+		log.Error(err, "failed to send DHCPv6")
+		span.SetStatus(codes.Error, err.Error())
+
+		return
+	}
+
+	log.Info("sent DHCPv6 response")
+	span.SetAttributes(attribute.String("DHCPv6.reply", reply.Summary()))
+	span.SetStatus(codes.Ok, "sent DHCPv6 response")
+}
+
+// updateMsg creates a reply for a DHCPv6 message with the data from the backend.
+// An error is returned when the message should not be responded to.
+func (h *Handler) updateMsg(ctx context.Context, m *dhcpv6.Message, mac net.HardwareAddr, d *data.DHCP, n *data.Netboot) (*dhcpv6.Message, error) {
+	h.setDefaults()
+	mods := []dhcpv6.Modifier{dhcpv6.WithServerID(h.ServerID)}
+
+	// From RFC 8415, section 16: servers MUST discard messages that include a
+	// Server Identifier option that does not match their own DUID.
+	if sid := m.Options.ServerID(); sid != nil && h.ServerID != nil && !sid.Equal(h.ServerID) {
+		return nil, fmt.Errorf("%w: server identifier does not match", errIgnore)
+	}
+
+	switch m.Type() {
+	case dhcpv6.MessageTypeSolicit:
+		mods = append(mods, h.setDHCPOpts(ctx, m, d)...)
+		if h.Netboot.Enabled && isNetbootClient(m) == nil {
+			mods = append(mods, h.setNetworkBootOpts(ctx, m, mac, n))
+		}
+		if m.GetOneOption(dhcpv6.OptionRapidCommit) != nil {
+			return dhcpv6.NewReplyFromMessage(m, mods...)
+		}
+		return dhcpv6.NewAdvertiseFromSolicit(m, mods...)
+	case dhcpv6.MessageTypeRequest, dhcpv6.MessageTypeRenew:
+		if m.Options.ServerID() == nil {
+			return nil, fmt.Errorf("%w: %v without a server identifier", errIgnore, m.Type())
+		}
+		fallthrough
+	case dhcpv6.MessageTypeRebind, dhcpv6.MessageTypeInformationRequest:
+		mods = append(mods, h.setDHCPOpts(ctx, m, d)...)
+		if h.Netboot.Enabled && isNetbootClient(m) == nil {
+			mods = append(mods, h.setNetworkBootOpts(ctx, m, mac, n))
+		}
+		return dhcpv6.NewReplyFromMessage(m, mods...)
+	case dhcpv6.MessageTypeConfirm:
+		mods = append(mods, dhcpv6.WithOption(confirmStatus(m, d.IPAddress)))
+		return dhcpv6.NewReplyFromMessage(m, mods...)
+	case dhcpv6.MessageTypeRelease:
+		// Since the design of this DHCPv6 server is that all IP addresses are
+		// host reservations, when a client releases an address, the server
+		// doesn't have anything to do other than acknowledging the release.
+		mods = append(mods, dhcpv6.WithOption(&dhcpv6.OptStatusCode{StatusCode: iana.StatusSuccess, StatusMessage: "released"}))
+		return dhcpv6.NewReplyFromMessage(m, mods...)
+	default:
+		return nil, fmt.Errorf("%w: unsupported message type %v", errIgnore, m.Type())
+	}
+}
+
+// confirmStatus returns the Status Code option for a reply to a Confirm message.
+// Success is returned when all addresses in the Confirm match the reserved address, otherwise NotOnLink.
+func confirmStatus(m *dhcpv6.Message, reserved netip.Addr) *dhcpv6.OptStatusCode {
+	for _, ia := range m.Options.IANA() {
+		for _, a := range ia.Options.Addresses() {
+			addr, ok := netip.AddrFromSlice(a.IPv6Addr)
+			if !ok || addr.Unmap() != reserved.Unmap() {
+				return &dhcpv6.OptStatusCode{StatusCode: iana.StatusNotOnLink, StatusMessage: "address is not reserved for this client"}
+			}
+		}
+	}
+
+	return &dhcpv6.OptStatusCode{StatusCode: iana.StatusSuccess, StatusMessage: "all addresses still on link"}
+}
+
+// readBackend encapsulates the backend read and opentelemetry handling.
+func (h *Handler) readBackend(ctx context.Context, mac net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	h.setDefaults()
+
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "Hardware data get")
+	defer span.End()
+
+	d, n, err := h.Backend.GetByMac(ctx, mac)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, err
+	}
+
+	span.SetAttributes(d.EncodeToAttributes()...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "done reading from backend")
+
+	return d, n, nil
+}
@@ -0,0 +1,247 @@
+package reservation6
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/tinkerbell/smee/internal/dhcp"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+)
+
+var (
+	clientMAC = net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	serverID  = &dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}}
+)
+
+func testHandler() *Handler {
+	return &Handler{
+		ServerID: serverID,
+		Netboot: Netboot{
+			IPXEBinServerTFTP: netip.MustParseAddrPort("[2001:db8::1]:69"),
+			IPXEBinServerHTTP: &url.URL{Scheme: "http", Host: "[2001:db8::1]:8080", Path: "/ipxe/"},
+			IPXEScriptURL: func(net.HardwareAddr) *url.URL {
+				return &url.URL{Scheme: "http", Host: "[2001:db8::1]:8080", Path: "/auto.ipxe"}
+			},
+			Enabled: true,
+		},
+	}
+}
+
+func testData() (*data.DHCP, *data.Netboot) {
+	return &data.DHCP{
+		MACAddress:   clientMAC,
+		IPAddress:    netip.MustParseAddr("2001:db8::100"),
+		NameServers:  []net.IP{net.ParseIP("2001:4860:4860::8888"), net.ParseIP("8.8.8.8")},
+		DomainSearch: []string{"example.com"},
+		LeaseTime:    3600,
+	}, &data.Netboot{
+		AllowNetboot: true,
+	}
+}
+
+func solicit(t *testing.T, mods ...dhcpv6.Modifier) *dhcpv6.Message {
+	t.Helper()
+	mods = append([]dhcpv6.Modifier{dhcpv6.WithIAID([4]byte{1, 2, 3, 4})}, mods...)
+	m, err := dhcpv6.NewSolicit(clientMAC, mods...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func netbootMods(vendorClass string, userClass string) []dhcpv6.Modifier {
+	mods := []dhcpv6.Modifier{
+		dhcpv6.WithArchType(iana.EFI_X86_64),
+		dhcpv6.WithRequestedOptions(dhcpv6.OptionBootfileURL, dhcpv6.OptionBootfileParam),
+	}
+	if vendorClass != "" {
+		mods = append(mods, dhcpv6.WithOption(&dhcpv6.OptVendorClass{EnterpriseNumber: 343, Data: [][]byte{[]byte(vendorClass)}}))
+	}
+	if userClass != "" {
+		mods = append(mods, dhcpv6.WithUserClass([]byte(userClass)))
+	}
+
+	return mods
+}
+
+func TestUpdateMsg(t *testing.T) {
+	tests := map[string]struct {
+		msg          func(*testing.T) *dhcpv6.Message
+		wantType     dhcpv6.MessageType
+		wantAddr     net.IP
+		wantBootfile string
+		wantErr      error
+	}{
+		"solicit without netboot": {
+			msg:      func(t *testing.T) *dhcpv6.Message { return solicit(t) },
+			wantType: dhcpv6.MessageTypeAdvertise,
+			wantAddr: net.ParseIP("2001:db8::100"),
+		},
+		"solicit with rapid commit": {
+			msg:      func(t *testing.T) *dhcpv6.Message { return solicit(t, dhcpv6.WithRapidCommit) },
+			wantType: dhcpv6.MessageTypeReply,
+			wantAddr: net.ParseIP("2001:db8::100"),
+		},
+		"solicit from PXE client": {
+			msg: func(t *testing.T) *dhcpv6.Message {
+				return solicit(t, netbootMods("PXEClient:Arch:00007:UNDI:003000", "")...)
+			},
+			wantType:     dhcpv6.MessageTypeAdvertise,
+			wantAddr:     net.ParseIP("2001:db8::100"),
+			wantBootfile: "tftp://[2001:db8::1]:69/00:01:02:03:04:05/ipxe.efi",
+		},
+		"solicit from HTTP client": {
+			msg: func(t *testing.T) *dhcpv6.Message {
+				return solicit(t, netbootMods("HTTPClient:Arch:00016:UNDI:003001", "")...)
+			},
+			wantType:     dhcpv6.MessageTypeAdvertise,
+			wantAddr:     net.ParseIP("2001:db8::100"),
+			wantBootfile: "http://[2001:db8::1]:8080/ipxe/00:01:02:03:04:05/ipxe.efi",
+		},
+		"solicit from Tinkerbell iPXE": {
+			msg: func(t *testing.T) *dhcpv6.Message {
+				return solicit(t, netbootMods("", "Tinkerbell")...)
+			},
+			wantType:     dhcpv6.MessageTypeAdvertise,
+			wantAddr:     net.ParseIP("2001:db8::100"),
+			wantBootfile: "http://[2001:db8::1]:8080/auto.ipxe",
+		},
+		"request without server id": {
+			msg: func(t *testing.T) *dhcpv6.Message {
+				m := solicit(t)
+				m.MessageType = dhcpv6.MessageTypeRequest
+				return m
+			},
+			wantErr: errIgnore,
+		},
+		"request for another server": {
+			msg: func(t *testing.T) *dhcpv6.Message {
+				m := solicit(t, dhcpv6.WithServerID(&dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: clientMAC}))
+				m.MessageType = dhcpv6.MessageTypeRequest
+				return m
+			},
+			wantErr: errIgnore,
+		},
+		"request": {
+			msg: func(t *testing.T) *dhcpv6.Message {
+				m := solicit(t, dhcpv6.WithServerID(serverID))
+				m.MessageType = dhcpv6.MessageTypeRequest
+				return m
+			},
+			wantType: dhcpv6.MessageTypeReply,
+			wantAddr: net.ParseIP("2001:db8::100"),
+		},
+		"information request": {
+			msg: func(t *testing.T) *dhcpv6.Message {
+				m := solicit(t)
+				m.MessageType = dhcpv6.MessageTypeInformationRequest
+				return m
+			},
+			wantType: dhcpv6.MessageTypeReply,
+		},
+		"advertise is ignored": {
+			msg: func(t *testing.T) *dhcpv6.Message {
+				m := solicit(t)
+				m.MessageType = dhcpv6.MessageTypeAdvertise
+				return m
+			},
+			wantErr: errIgnore,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := testHandler()
+			d, n := testData()
+			got, err := h.updateMsg(context.Background(), tt.msg(t), clientMAC, d, n)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("updateMsg() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got.Type() != tt.wantType {
+				t.Fatalf("message type = %v, want %v", got.Type(), tt.wantType)
+			}
+			if !got.Options.ServerID().Equal(serverID) {
+				t.Fatalf("server id = %v, want %v", got.Options.ServerID(), serverID)
+			}
+			var gotAddr net.IP
+			if ia := got.Options.OneIANA(); ia != nil {
+				if a := ia.Options.OneAddress(); a != nil {
+					gotAddr = a.IPv6Addr
+					if a.ValidLifetime != time.Hour {
+						t.Fatalf("valid lifetime = %v, want %v", a.ValidLifetime, time.Hour)
+					}
+				}
+			}
+			if !gotAddr.Equal(tt.wantAddr) {
+				t.Fatalf("address = %v, want %v", gotAddr, tt.wantAddr)
+			}
+			if diff := cmp.Diff(tt.wantBootfile, got.Options.BootFileURL()); diff != "" {
+				t.Fatal(diff)
+			}
+			if diff := cmp.Diff([]net.IP{net.ParseIP("2001:4860:4860::8888")}, got.Options.DNS()); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestUpdateMsgNoIPv6Reservation(t *testing.T) {
+	h := testHandler()
+	d, n := testData()
+	d.IPAddress = netip.MustParseAddr("192.168.2.100")
+	got, err := h.updateMsg(context.Background(), solicit(t), clientMAC, d, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ia := got.Options.OneIANA()
+	if ia == nil {
+		t.Fatal("expected an IA_NA option")
+	}
+	if s := ia.Options.Status(); s == nil || s.StatusCode != iana.StatusNoAddrsAvail {
+		t.Fatalf("status = %v, want %v", s, iana.StatusNoAddrsAvail)
+	}
+}
+
+func TestConfirmStatus(t *testing.T) {
+	tests := map[string]struct {
+		addr string
+		want iana.StatusCode
+	}{
+		"on link":     {addr: "2001:db8::100", want: iana.StatusSuccess},
+		"not on link": {addr: "2001:db8::200", want: iana.StatusNotOnLink},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := solicit(t, dhcpv6.WithIANA(dhcpv6.OptIAAddress{IPv6Addr: net.ParseIP(tt.addr)}))
+			m.MessageType = dhcpv6.MessageTypeConfirm
+			got := confirmStatus(m, netip.MustParseAddr("2001:db8::100"))
+			if got.StatusCode != tt.want {
+				t.Fatalf("confirmStatus() = %v, want %v", got.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewInfo(t *testing.T) {
+	m := solicit(t, netbootMods("HTTPClient:Arch:00016:UNDI:003001", "iPXE")...)
+	want := info{
+		Mac:        clientMAC,
+		Arch:       iana.EFI_X86_64,
+		UserClass:  dhcp.IPXE,
+		ClientType: dhcp.HTTPClient,
+		IPXEBinary: "ipxe.efi",
+	}
+	if diff := cmp.Diff(want, newInfo(m, clientMAC)); diff != "" {
+		t.Fatal(diff)
+	}
+}
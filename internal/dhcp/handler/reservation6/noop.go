@@ -0,0 +1,22 @@
+package reservation6
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+)
+
+// noop is a backend that always returns an error.
+type noop struct{}
+
+// GetByMac returns an error.
+func (h noop) GetByMac(_ context.Context, _ net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	return nil, nil, errors.New("no backend specified, please specify a backend")
+}
+
+// GetByIP returns an error.
+func (h noop) GetByIP(_ context.Context, _ net.IP) (*data.DHCP, *data.Netboot, error) {
+	return nil, nil, errors.New("no backend specified, please specify a backend")
+}
@@ -0,0 +1,193 @@
+package reservation6
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/tinkerbell/smee/internal/dhcp"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/otel"
+)
+
+// info holds details about a DHCPv6 request that are used for network booting.
+type info struct {
+	Mac        net.HardwareAddr
+	Arch       iana.Arch
+	UserClass  dhcp.UserClass
+	ClientType dhcp.ClientType
+	IPXEBinary string
+}
+
+// newInfo populates an info struct from a DHCPv6 message.
+func newInfo(m *dhcpv6.Message, mac net.HardwareAddr) info {
+	i := info{Mac: mac, Arch: iana.Arch(255)} // unknown arch
+	for _, a := range m.Options.ArchTypes() {
+		if !strings.Contains(a.String(), "unknown") {
+			i.Arch = a
+			break
+		}
+	}
+	for _, uc := range m.Options.UserClasses() {
+		switch {
+		case bytes.HasPrefix(uc, []byte(dhcp.Tinkerbell)):
+			i.UserClass = dhcp.Tinkerbell
+		case bytes.HasPrefix(uc, []byte(dhcp.IPXE)) && i.UserClass == "":
+			i.UserClass = dhcp.IPXE
+		case i.UserClass == "":
+			i.UserClass = dhcp.UserClass(uc)
+		}
+	}
+	for _, vc := range m.Options.VendorClasses() {
+		for _, d := range vc.Data {
+			switch {
+			case bytes.HasPrefix(d, []byte(dhcp.HTTPClient)):
+				i.ClientType = dhcp.HTTPClient
+			case bytes.HasPrefix(d, []byte(dhcp.PXEClient)) && i.ClientType == "":
+				i.ClientType = dhcp.PXEClient
+			}
+		}
+	}
+	i.IPXEBinary = dhcp.ArchToBootFile[i.Arch]
+
+	return i
+}
+
+// isNetbootClient returns nil if the client is a valid netboot client. Otherwise it returns an error.
+//
+// A valid DHCPv6 netboot client will have the following in its request:
+// 1. option 61 (Client System Architecture Type) is set.
+// 2. option 59 (Bootfile URL) is requested in option 6 (Option Request).
+//
+// See: https://www.rfc-editor.org/rfc/rfc5970.html
+func isNetbootClient(m *dhcpv6.Message) error {
+	if len(m.Options.ArchTypes()) == 0 {
+		return fmt.Errorf("option 61 not set")
+	}
+	if !m.IsOptionRequested(dhcpv6.OptionBootfileURL) {
+		return fmt.Errorf("option 59 not requested")
+	}
+
+	return nil
+}
+
+// setDHCPOpts takes a client DHCPv6 message and data (typically from a backend) and creates a slice of DHCPv6 message modifiers.
+func (h *Handler) setDHCPOpts(_ context.Context, m *dhcpv6.Message, d *data.DHCP) []dhcpv6.Modifier {
+	var mods []dhcpv6.Modifier
+	var ns []net.IP
+	for _, s := range d.NameServers {
+		if s.To4() == nil && s.To16() != nil {
+			ns = append(ns, s)
+		}
+	}
+	if len(ns) > 0 {
+		mods = append(mods, dhcpv6.WithDNS(ns...))
+	}
+	if len(d.DomainSearch) > 0 {
+		mods = append(mods, dhcpv6.WithDomainSearchList(d.DomainSearch...))
+	}
+	if ia := m.Options.OneIANA(); ia != nil && m.Type() != dhcpv6.MessageTypeInformationRequest {
+		mods = append(mods, withIANA(ia.IaId, d))
+	}
+
+	return mods
+}
+
+// withIANA returns a modifier that sets an IA_NA option (3) with the reserved IPv6 address.
+// If the reservation does not hold an IPv6 address, the IA_NA holds a NoAddrsAvail status code.
+func withIANA(iaid [4]byte, d *data.DHCP) dhcpv6.Modifier {
+	return func(msg dhcpv6.DHCPv6) {
+		ia := &dhcpv6.OptIANA{IaId: iaid}
+		if d.IPAddress.Is6() && !d.IPAddress.Is4In6() {
+			lt := time.Duration(d.LeaseTime) * time.Second
+			ia.T1 = lt / 2
+			ia.T2 = lt * 4 / 5
+			ia.Options.Add(&dhcpv6.OptIAAddress{
+				IPv6Addr:          net.IP(d.IPAddress.AsSlice()),
+				PreferredLifetime: lt,
+				ValidLifetime:     lt,
+			})
+		} else {
+			ia.Options.Add(&dhcpv6.OptStatusCode{
+				StatusCode:    iana.StatusNoAddrsAvail,
+				StatusMessage: "no IPv6 address reserved for this client",
+			})
+		}
+		msg.UpdateOption(ia)
+	}
+}
+
+// setNetworkBootOpts returns a modifier that sets the Bootfile URL option (59) and the
+// Bootfile Parameters option (60).
+//
+// See: https://www.rfc-editor.org/rfc/rfc5970.html
+func (h *Handler) setNetworkBootOpts(ctx context.Context, m *dhcpv6.Message, mac net.HardwareAddr, n *data.Netboot) dhcpv6.Modifier {
+	return func(d dhcpv6.DHCPv6) {
+		if !n.AllowNetboot {
+			return
+		}
+		i := newInfo(m, mac)
+		if i.IPXEBinary == "" {
+			return
+		}
+		var ipxeScript *url.URL
+		// If the global IPXEScriptURL is set, use that.
+		if h.Netboot.IPXEScriptURL != nil {
+			ipxeScript = h.Netboot.IPXEScriptURL(mac)
+		}
+		// If the IPXE script URL is set on the hardware record, use that.
+		if n.IPXEScriptURL != nil {
+			ipxeScript = n.IPXEScriptURL
+		}
+		if tp := otel.TraceparentStringFromContext(ctx); h.OTELEnabled && tp != "" {
+			i.IPXEBinary = fmt.Sprintf("%s-%v", i.IPXEBinary, tp)
+		}
+		bootfile := i.bootfileURL(h.Netboot.UserClass, ipxeScript, h.Netboot.IPXEBinServerHTTP, h.Netboot.IPXEBinServerTFTP)
+		if bootfile == "" {
+			return
+		}
+		d.UpdateOption(dhcpv6.OptBootFileURL(bootfile))
+		// When chainloading an iPXE binary, pass the iPXE script URL as a parameter
+		// so that boot loaders that honor option 60 can find the script.
+		if ipxeScript != nil && bootfile != ipxeScript.String() {
+			d.UpdateOption(dhcpv6.OptBootFileParam(ipxeScript.String()))
+		}
+		if i.ClientType == dhcp.HTTPClient {
+			d.UpdateOption(&dhcpv6.OptVendorClass{EnterpriseNumber: 343, Data: [][]byte{[]byte(dhcp.HTTPClient)}})
+		}
+	}
+}
+
+// bootfileURL returns the value for the Bootfile URL option (59).
+// Unlike DHCPv4 there is no next server header in DHCPv6, so the returned value is always a full URL.
+func (i info) bootfileURL(customUC dhcp.UserClass, ipxeScript, ipxeHTTPBinServer *url.URL, ipxeTFTPBinServer netip.AddrPort) string {
+	paths := []string{i.IPXEBinary}
+	if i.Mac != nil {
+		paths = append([]string{i.Mac.String()}, paths...)
+	}
+
+	switch { // order matters here.
+	case i.UserClass == dhcp.Tinkerbell, (customUC != "" && i.UserClass == customUC): // this case gets us out of an ipxe boot loop.
+		if ipxeScript != nil {
+			return ipxeScript.String()
+		}
+	case i.ClientType == dhcp.HTTPClient: // Check the client type from option 16.
+		if ipxeHTTPBinServer != nil {
+			return ipxeHTTPBinServer.JoinPath(paths...).String()
+		}
+	default:
+		if ipxeTFTPBinServer.IsValid() {
+			t := url.URL{Scheme: "tftp", Host: ipxeTFTPBinServer.String()}
+			return t.JoinPath(paths...).String()
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,54 @@
+// Package reservation6 is the handler for responding to DHCPv6 messages with only host reservations.
+package reservation6
+
+import (
+	"net"
+	"net/netip"
+	"net/url"
+
+	"github.com/go-logr/logr"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/tinkerbell/smee/internal/dhcp"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
+)
+
+// Handler holds the configuration details for the running the DHCPv6 server.
+type Handler struct {
+	// Backend is the backend to use for getting DHCP data.
+	Backend handler.BackendReader
+
+	// ServerID is the DUID used in the Server Identifier option (2) of all responses.
+	// Clients use it to address this server in Request, Renew and Release messages.
+	ServerID dhcpv6.DUID
+
+	// Log is used to log messages.
+	// `logr.Discard()` can be used if no logging is desired.
+	Log logr.Logger
+
+	// Netboot configuration
+	Netboot Netboot
+
+	// OTELEnabled is used to determine if netboot options include otel naming.
+	// When true, the netboot filename will be appended with otel information.
+	// For example, the filename will be "snp.efi-00-23b1e307bb35484f535a1f772c06910e-d887dc3912240434-01".
+	// <original filename>-00-<trace id>-<span id>-<trace flags>
+	OTELEnabled bool
+}
+
+// Netboot holds the netboot configuration details used in running a DHCPv6 server.
+type Netboot struct {
+	// iPXE binary server IP:Port serving via TFTP. This must be an IPv6 address.
+	IPXEBinServerTFTP netip.AddrPort
+
+	// IPXEBinServerHTTP is the URL to the IPXE binary server serving via HTTP(s).
+	IPXEBinServerHTTP *url.URL
+
+	// IPXEScriptURL is the URL to the IPXE script to use.
+	IPXEScriptURL func(net.HardwareAddr) *url.URL
+
+	// Enabled is whether to enable sending netboot DHCPv6 options.
+	Enabled bool
+
+	// UserClass (for network booting) allows a custom DHCPv6 option 15 to be used to break out of an iPXE loop.
+	UserClass dhcp.UserClass
+}
@@ -0,0 +1,208 @@
+package lease
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+)
+
+// compactMin is the number of records appended to the lease file before it is compacted,
+// as long as the file has more records than twice the number of leases.
+const compactMin = 1024
+
+// File is a Store that holds leases in memory and appends every change to a file, one JSON record per line.
+// Only acknowledged and declined leases are synced to disk, offers are recovered by the client retrying.
+// The file is compacted when it is loaded and when it has grown to many records per lease: expired leases
+// are purged and the remaining leases are written to a new file that replaces the old one atomically,
+// so a crash never leaves a partially written lease database.
+type File struct {
+	path string
+	mem  *Memory
+	// log is the lease file opened for appending, it is nil until the first change.
+	log *os.File
+	// records is the number of records in the lease file.
+	records int
+}
+
+// record is a line of the lease file. It is a lease that is recorded, or the MAC address of a lease that is deleted.
+type record struct {
+	Lease  *Lease `json:"lease,omitempty"`
+	Delete string `json:"delete,omitempty"`
+}
+
+// NewFile returns a Store backed by the file at path. Existing leases in the file are loaded.
+// The file is created on the first change if it does not exist.
+func NewFile(path string) (*File, error) {
+	f := &File{path: path, mem: NewMemory()}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return f, nil
+		}
+		return nil, fmt.Errorf("failed to read lease file: %w", err)
+	}
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; s.Scan(); n++ {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+		var r record
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("failed to parse lease file %v line %d: %w", path, n, err)
+		}
+		f.replay(r)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read lease file: %w", err)
+	}
+
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+	if err := f.compact(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// replay applies a record of the lease file to the leases in memory.
+func (f *File) replay(r record) {
+	if r.Delete != "" {
+		if mac, err := net.ParseMAC(r.Delete); err == nil {
+			f.mem.delete(mac)
+		}
+		return
+	}
+	if r.Lease == nil {
+		return
+	}
+	// Conflicts can only come from a hand edited file, the last lease for an address wins.
+	if other, ok := f.mem.byIP[r.Lease.IP]; ok {
+		f.mem.delete(f.mem.byMAC[other].MAC)
+	}
+	_ = f.mem.put(*r.Lease)
+}
+
+// Put records a lease, replacing any existing lease for the same MAC address.
+func (f *File) Put(_ context.Context, l Lease) error {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+
+	if f.mem.keep(l) {
+		return nil
+	}
+	if err := f.mem.put(l); err != nil {
+		return err
+	}
+
+	return f.append(record{Lease: &l}, l.State != StateOffered)
+}
+
+// Get returns the lease for a MAC address.
+func (f *File) Get(ctx context.Context, mac net.HardwareAddr) (Lease, error) {
+	return f.mem.Get(ctx, mac)
+}
+
+// GetByIP returns the lease for an address.
+func (f *File) GetByIP(ctx context.Context, ip netip.Addr) (Lease, error) {
+	return f.mem.GetByIP(ctx, ip)
+}
+
+// Delete removes the lease for a MAC address.
+func (f *File) Delete(_ context.Context, mac net.HardwareAddr) error {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+
+	if _, ok := f.mem.byMAC[mac.String()]; !ok {
+		return nil
+	}
+	f.mem.delete(mac)
+
+	return f.append(record{Delete: mac.String()}, true)
+}
+
+// List returns all leases ordered by address.
+func (f *File) List(ctx context.Context) ([]Lease, error) {
+	return f.mem.List(ctx)
+}
+
+// append writes r to the end of the lease file and syncs it to disk if sync is set.
+// The file is compacted instead when it has grown to many records per lease.
+// The caller must hold the lock.
+func (f *File) append(r record, sync bool) error {
+	if f.records >= compactMin && f.records > 2*len(f.mem.byMAC) {
+		return f.compact()
+	}
+	if f.log == nil {
+		l, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to open lease file: %w", err)
+		}
+		f.log = l
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode lease: %w", err)
+	}
+	if _, err := f.log.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to write lease file: %w", err)
+	}
+	f.records++
+	if sync {
+		if err := f.log.Sync(); err != nil {
+			return fmt.Errorf("failed to sync lease file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// compact purges the expired leases and replaces the lease file with a file that has a record for each remaining lease.
+// The caller must hold the lock.
+func (f *File) compact() error {
+	f.mem.purge()
+	var buf bytes.Buffer
+	ls := f.mem.list()
+	for i := range ls {
+		b, err := json.Marshal(record{Lease: &ls[i]})
+		if err != nil {
+			return fmt.Errorf("failed to encode leases: %w", err)
+		}
+		buf.Write(append(b, '\n'))
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary lease file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary lease file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temporary lease file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary lease file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("failed to replace lease file: %w", err)
+	}
+	// The open file is the one that was replaced, the next change opens the new file.
+	if f.log != nil {
+		f.log.Close()
+		f.log = nil
+	}
+	f.records = len(ls)
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package lease
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-logr/logr"
+)
+
+// HandlerFunc returns an http.HandlerFunc that serves all leases in the Store as JSON.
+func HandlerFunc(s Store, log logr.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		ls, err := s.List(r.Context())
+		if err != nil {
+			log.Error(err, "listing leases")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ls); err != nil {
+			log.Error(err, "marshaling leases json")
+		}
+	}
+}
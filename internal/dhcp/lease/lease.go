@@ -0,0 +1,118 @@
+// Package lease records the addresses that DHCP handlers hand out to clients.
+package lease
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/netip"
+	"time"
+)
+
+// ErrConflict is returned when an address is already leased to a different client.
+var ErrConflict = errors.New("address is leased to another client")
+
+// State is the DHCP message that a lease was last recorded for.
+type State string
+
+const (
+	// StateOffered is a lease recorded from a DHCPOFFER.
+	StateOffered State = "offered"
+	// StateAcked is a lease recorded from a DHCPACK.
+	StateAcked State = "acked"
+	// StateDeclined is a lease recorded from a DHCPDECLINE.
+	// The client found the address already in use on the network.
+	StateDeclined State = "declined"
+)
+
+// Lease is an address handed out to a client.
+type Lease struct {
+	// MAC is the client hardware address.
+	MAC net.HardwareAddr
+	// IP is the address handed out to the client.
+	IP netip.Addr
+	// XID is the transaction ID of the DHCP message the lease was recorded for.
+	XID string
+	// Interface is the name of the interface the DHCP message was received on.
+	Interface string
+	// GatewayIP is the giaddr of the DHCP message, if the message was relayed.
+	GatewayIP netip.Addr
+	// State is the DHCP message type the lease was recorded for.
+	State State
+	// Expires is the time the lease expires.
+	Expires time.Time
+}
+
+// Active reports whether the lease has not expired at the given time.
+func (l Lease) Active(now time.Time) bool {
+	return l.Expires.After(now)
+}
+
+type jsonLease struct {
+	MAC       string     `json:"mac"`
+	IP        netip.Addr `json:"ip"`
+	XID       string     `json:"xid,omitempty"`
+	Interface string     `json:"interface,omitempty"`
+	GatewayIP netip.Addr `json:"giaddr,omitzero"`
+	State     State      `json:"state"`
+	Expires   time.Time  `json:"expires"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (l Lease) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonLease{
+		MAC:       l.MAC.String(),
+		IP:        l.IP,
+		XID:       l.XID,
+		Interface: l.Interface,
+		GatewayIP: l.GatewayIP,
+		State:     l.State,
+		Expires:   l.Expires,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (l *Lease) UnmarshalJSON(b []byte) error {
+	var j jsonLease
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	mac, err := net.ParseMAC(j.MAC)
+	if err != nil {
+		return err
+	}
+	*l = Lease{
+		MAC:       mac,
+		IP:        j.IP,
+		XID:       j.XID,
+		Interface: j.Interface,
+		GatewayIP: j.GatewayIP,
+		State:     j.State,
+		Expires:   j.Expires,
+	}
+
+	return nil
+}
+
+// Store is the interface for recording leases.
+type Store interface {
+	// Put records a lease, replacing any existing lease for the same MAC address.
+	// An offer of the address of an active acknowledged lease for the same MAC address does not replace it.
+	// ErrConflict is returned if the address has an active lease for a different MAC address.
+	Put(context.Context, Lease) error
+	// Get returns the lease for a MAC address.
+	Get(context.Context, net.HardwareAddr) (Lease, error)
+	// GetByIP returns the lease for an address.
+	GetByIP(context.Context, netip.Addr) (Lease, error)
+	// Delete removes the lease for a MAC address.
+	Delete(context.Context, net.HardwareAddr) error
+	// List returns all leases ordered by address.
+	List(context.Context) ([]Lease, error)
+}
+
+type notFoundError struct{}
+
+func (notFoundError) NotFound() bool { return true }
+
+func (notFoundError) Error() string { return "lease not found" }
@@ -0,0 +1,289 @@
+package lease
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+)
+
+var (
+	mac1 = net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+	mac2 = net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x02}
+	now  = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func testLease(mac net.HardwareAddr, ip string, expires time.Time) Lease {
+	return Lease{
+		MAC:       mac,
+		IP:        netip.MustParseAddr(ip),
+		XID:       "0x01020304",
+		Interface: "eth0",
+		GatewayIP: netip.MustParseAddr("192.168.2.1"),
+		State:     StateAcked,
+		Expires:   expires,
+	}
+}
+
+var leaseCmp = cmp.Comparer(func(a, b netip.Addr) bool { return a == b })
+
+func TestStore(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(*testing.T) Store {
+			m := NewMemory()
+			m.clock = func() time.Time { return now }
+			return m
+		},
+		"file": func(t *testing.T) Store {
+			f, err := NewFile(filepath.Join(t.TempDir(), "leases.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.mem.clock = func() time.Time { return now }
+			return f
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newStore(t)
+
+			l1 := testLease(mac1, "192.168.2.10", now.Add(time.Hour))
+			if err := s.Put(ctx, l1); err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.Get(ctx, mac1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(l1, got, leaseCmp); diff != "" {
+				t.Fatal(diff)
+			}
+
+			// An offer of the bound address does not replace the acknowledged lease.
+			offer := testLease(mac1, "192.168.2.10", now.Add(time.Minute))
+			offer.State = StateOffered
+			if err := s.Put(ctx, offer); err != nil {
+				t.Fatal(err)
+			}
+			got, err = s.Get(ctx, mac1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(l1, got, leaseCmp); diff != "" {
+				t.Fatal(diff)
+			}
+
+			// Another MAC cannot take an active lease.
+			if err := s.Put(ctx, testLease(mac2, "192.168.2.10", now.Add(time.Hour))); !errors.Is(err, ErrConflict) {
+				t.Fatalf("Put() error = %v, want %v", err, ErrConflict)
+			}
+
+			// The same MAC moving to a new address frees the old one.
+			l1 = testLease(mac1, "192.168.2.11", now.Add(time.Hour))
+			if err := s.Put(ctx, l1); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.GetByIP(ctx, netip.MustParseAddr("192.168.2.10")); !isNotFound(err) {
+				t.Fatalf("GetByIP() error = %v, want not found", err)
+			}
+			if err := s.Put(ctx, testLease(mac2, "192.168.2.10", now.Add(time.Hour))); err != nil {
+				t.Fatal(err)
+			}
+
+			list, err := s.List(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []Lease{testLease(mac2, "192.168.2.10", now.Add(time.Hour)), l1}
+			if diff := cmp.Diff(want, list, leaseCmp); diff != "" {
+				t.Fatal(diff)
+			}
+
+			if err := s.Delete(ctx, mac1); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Get(ctx, mac1); !isNotFound(err) {
+				t.Fatalf("Get() error = %v, want not found", err)
+			}
+		})
+	}
+}
+
+func TestMemoryExpiredLeaseCanBeTaken(t *testing.T) {
+	m := NewMemory()
+	m.clock = func() time.Time { return now }
+	ctx := context.Background()
+
+	if err := m.Put(ctx, testLease(mac1, "192.168.2.10", now.Add(-time.Second))); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Put(ctx, testLease(mac2, "192.168.2.10", now.Add(time.Hour))); err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.GetByIP(ctx, netip.MustParseAddr("192.168.2.10"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.MAC.String() != mac2.String() {
+		t.Fatalf("GetByIP() = %v, want %v", got.MAC, mac2)
+	}
+}
+
+func TestFilePersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "leases.json")
+	f, err := NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := testLease(mac1, "192.168.2.10", time.Now().Add(time.Hour))
+	if err := f.Put(ctx, l); err != nil {
+		t.Fatal(err)
+	}
+
+	f2, err := NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := f2.Get(ctx, mac1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(l, got, leaseCmp); diff != "" {
+		t.Fatal(diff)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the lease file, got %v", entries)
+	}
+}
+
+func TestFilePurgesExpiredLeases(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "leases.json")
+	f, err := NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	active := testLease(mac1, "192.168.2.10", time.Now().Add(time.Hour))
+	for _, l := range []Lease{active, testLease(mac2, "192.168.2.11", time.Now().Add(-time.Hour))} {
+		if err := f.Put(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f2, err := NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := f2.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]Lease{active}, got, leaseCmp); diff != "" {
+		t.Fatal(diff)
+	}
+	if f2.records != 1 {
+		t.Fatalf("lease file has %d records after loading, want 1", f2.records)
+	}
+}
+
+func TestFileCompacts(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "leases.json")
+	f, err := NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := testLease(mac1, "192.168.2.10", time.Now().Add(time.Hour))
+	l.State = StateOffered
+	for range compactMin + 10 {
+		if err := f.Put(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(b, []byte("\n")); lines != f.records || lines > compactMin {
+		t.Fatalf("lease file has %d lines, %d records, want at most %d", lines, f.records, compactMin)
+	}
+
+	f2, err := NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := f2.Get(ctx, mac1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(l, got, leaseCmp); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestNewFileInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leases.json")
+	if err := os.WriteFile(path, []byte("not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFile(path); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestHandlerFunc(t *testing.T) {
+	m := NewMemory()
+	if err := m.Put(context.Background(), testLease(mac1, "192.168.2.10", now)); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	HandlerFunc(m, logr.Discard())(w, httptest.NewRequest(http.MethodGet, "/leases", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v", w.Code, http.StatusOK)
+	}
+	var got []map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]any{{
+		"mac":       "00:00:00:00:00:01",
+		"ip":        "192.168.2.10",
+		"xid":       "0x01020304",
+		"interface": "eth0",
+		"giaddr":    "192.168.2.1",
+		"state":     "acked",
+		"expires":   "2024-01-01T00:00:00Z",
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatal(diff)
+	}
+
+	w = httptest.NewRecorder()
+	HandlerFunc(m, logr.Discard())(w, httptest.NewRequest(http.MethodPost, "/leases", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %v, want %v", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func isNotFound(err error) bool {
+	nf, ok := err.(interface{ NotFound() bool })
+	return ok && nf.NotFound()
+}
@@ -0,0 +1,148 @@
+package lease
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory is a Store that holds leases in memory.
+type Memory struct {
+	mu    sync.RWMutex
+	byMAC map[string]Lease
+	byIP  map[netip.Addr]string
+	clock func() time.Time
+}
+
+// NewMemory returns an empty in-memory Store.
+func NewMemory() *Memory {
+	return &Memory{
+		byMAC: map[string]Lease{},
+		byIP:  map[netip.Addr]string{},
+		clock: time.Now,
+	}
+}
+
+// Put records a lease, replacing any existing lease for the same MAC address.
+func (m *Memory) Put(_ context.Context, l Lease) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.keep(l) {
+		return nil
+	}
+
+	return m.put(l)
+}
+
+// keep reports whether l is an offer of the address that is bound to the client by an active acknowledged lease.
+// The acknowledged lease is kept, so that its state and expiry are not lost to an offer to a client that restarted
+// or is reusing its address.
+func (m *Memory) keep(l Lease) bool {
+	if l.State != StateOffered {
+		return false
+	}
+	old, ok := m.byMAC[l.MAC.String()]
+
+	return ok && old.State == StateAcked && old.IP == l.IP && old.Active(m.clock())
+}
+
+func (m *Memory) put(l Lease) error {
+	mac := l.MAC.String()
+	if other, ok := m.byIP[l.IP]; ok && other != mac {
+		if existing := m.byMAC[other]; existing.IP == l.IP && existing.Active(m.clock()) {
+			return fmt.Errorf("%w: %v is leased to %v until %v", ErrConflict, l.IP, other, existing.Expires.Format(time.RFC3339))
+		}
+	}
+	if old, ok := m.byMAC[mac]; ok && old.IP != l.IP && m.byIP[old.IP] == mac {
+		delete(m.byIP, old.IP)
+	}
+	m.byMAC[mac] = l
+	m.byIP[l.IP] = mac
+
+	return nil
+}
+
+// Get returns the lease for a MAC address.
+func (m *Memory) Get(_ context.Context, mac net.HardwareAddr) (Lease, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	l, ok := m.byMAC[mac.String()]
+	if !ok {
+		return Lease{}, notFoundError{}
+	}
+
+	return l, nil
+}
+
+// GetByIP returns the lease for an address.
+func (m *Memory) GetByIP(_ context.Context, ip netip.Addr) (Lease, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	mac, ok := m.byIP[ip]
+	if !ok {
+		return Lease{}, notFoundError{}
+	}
+
+	return m.byMAC[mac], nil
+}
+
+// Delete removes the lease for a MAC address.
+func (m *Memory) Delete(_ context.Context, mac net.HardwareAddr) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.delete(mac)
+
+	return nil
+}
+
+func (m *Memory) delete(mac net.HardwareAddr) {
+	l, ok := m.byMAC[mac.String()]
+	if !ok {
+		return
+	}
+	delete(m.byMAC, mac.String())
+	if m.byIP[l.IP] == mac.String() {
+		delete(m.byIP, l.IP)
+	}
+}
+
+// purge removes the expired leases.
+func (m *Memory) purge() {
+	now := m.clock()
+	for _, l := range m.byMAC {
+		if !l.Active(now) {
+			m.delete(l.MAC)
+		}
+	}
+}
+
+// List returns all leases ordered by address.
+func (m *Memory) List(_ context.Context) ([]Lease, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.list(), nil
+}
+
+func (m *Memory) list() []Lease {
+	ls := make([]Lease, 0, len(m.byMAC))
+	for _, l := range m.byMAC {
+		ls = append(ls, l)
+	}
+	sort.Slice(ls, func(i, j int) bool {
+		if ls[i].IP == ls[j].IP {
+			return ls[i].MAC.String() < ls[j].MAC.String()
+		}
+		return ls[i].IP.Less(ls[j].IP)
+	})
+
+	return ls
+}
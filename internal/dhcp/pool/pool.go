@@ -0,0 +1,340 @@
+// Package pool allocates IPv4 addresses to DHCP clients from a configured range and tracks their leases in memory.
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+)
+
+const (
+	// defaultLeaseTime is used when Config.LeaseTime is not set.
+	defaultLeaseTime = time.Hour
+	// defaultOfferTime is used when Config.OfferTime is not set.
+	defaultOfferTime = time.Minute
+	// defaultDeclineTime is used when Config.DeclineTime is not set.
+	defaultDeclineTime = 10 * time.Minute
+)
+
+var (
+	// ErrExhausted is returned when there are no free addresses left in the pool.
+	ErrExhausted = errors.New("no free addresses in pool")
+	// ErrUnavailable is returned when a client asks for an address that cannot be leased to it.
+	ErrUnavailable = errors.New("address not available")
+)
+
+// State is the state of a lease.
+type State string
+
+const (
+	// StateOffered is an address that has been offered to a client but not yet requested.
+	StateOffered State = "offered"
+	// StateBound is an address that has been acknowledged to a client.
+	StateBound State = "bound"
+)
+
+// Lease is an address allocated to a client.
+type Lease struct {
+	MAC     net.HardwareAddr
+	IP      netip.Addr
+	State   State
+	Expires time.Time
+}
+
+// Range is an inclusive range of IPv4 addresses.
+type Range struct {
+	Start netip.Addr
+	End   netip.Addr
+}
+
+// Contains reports whether ip is in the range.
+func (r Range) Contains(ip netip.Addr) bool {
+	return r.Start.Compare(ip) <= 0 && ip.Compare(r.End) <= 0
+}
+
+// ParseRange parses a single address ("192.168.2.1") or an inclusive range of addresses ("192.168.2.1-192.168.2.20").
+func ParseRange(s string) (Range, error) {
+	start, end, found := strings.Cut(strings.TrimSpace(s), "-")
+	sa, err := netip.ParseAddr(strings.TrimSpace(start))
+	if err != nil {
+		return Range{}, fmt.Errorf("invalid range %q: %w", s, err)
+	}
+	r := Range{Start: sa, End: sa}
+	if found {
+		ea, err := netip.ParseAddr(strings.TrimSpace(end))
+		if err != nil {
+			return Range{}, fmt.Errorf("invalid range %q: %w", s, err)
+		}
+		r.End = ea
+	}
+	if !r.Start.Is4() || !r.End.Is4() {
+		return Range{}, fmt.Errorf("invalid range %q: only IPv4 addresses are supported", s)
+	}
+	if r.End.Less(r.Start) {
+		return Range{}, fmt.Errorf("invalid range %q: end is before start", s)
+	}
+
+	return r, nil
+}
+
+// Config holds the configuration of a Pool.
+type Config struct {
+	// Prefix is the IPv4 subnet that addresses are allocated from.
+	// The network and broadcast addresses are never allocated.
+	Prefix netip.Prefix
+
+	// Exclude holds addresses in Prefix that are never allocated, for example statically assigned ranges.
+	// Options.DefaultGateway is always excluded.
+	Exclude []Range
+
+	// LeaseTime is how long an acknowledged address is leased to a client.
+	LeaseTime time.Duration
+
+	// OfferTime is how long an offered address is held for a client before it can be offered to another client.
+	OfferTime time.Duration
+
+	// DeclineTime is how long an address that a client declined is kept out of the pool.
+	DeclineTime time.Duration
+
+	// Options are the DHCP options sent to every client with a pool lease.
+	// MACAddress, IPAddress, SubnetMask and LeaseTime are set per lease.
+	Options data.DHCP
+}
+
+// Pool allocates addresses from a Config and tracks the leases.
+// It is safe for concurrent use.
+type Pool struct {
+	cfg   Config
+	rng   Range
+	clock func() time.Time
+
+	mu       sync.Mutex
+	leases   map[netip.Addr]*Lease
+	byMAC    map[string]netip.Addr
+	declined map[netip.Addr]time.Time
+}
+
+// New returns a Pool for the given configuration.
+func New(c Config) (*Pool, error) {
+	if !c.Prefix.IsValid() || !c.Prefix.Addr().Is4() {
+		return nil, fmt.Errorf("invalid pool prefix %q: must be an IPv4 CIDR", c.Prefix)
+	}
+	if c.Prefix.Bits() > 30 {
+		return nil, fmt.Errorf("invalid pool prefix %q: must hold at least 2 host addresses", c.Prefix)
+	}
+	c.Prefix = c.Prefix.Masked()
+	if c.LeaseTime <= 0 {
+		c.LeaseTime = defaultLeaseTime
+	}
+	if c.OfferTime <= 0 {
+		c.OfferTime = defaultOfferTime
+	}
+	if c.DeclineTime <= 0 {
+		c.DeclineTime = defaultDeclineTime
+	}
+	if c.Options.DefaultGateway.IsValid() {
+		c.Exclude = append(c.Exclude, Range{Start: c.Options.DefaultGateway, End: c.Options.DefaultGateway})
+	}
+
+	return &Pool{
+		cfg:      c,
+		rng:      Range{Start: c.Prefix.Addr().Next(), End: broadcast(c.Prefix).Prev()},
+		clock:    time.Now,
+		leases:   map[netip.Addr]*Lease{},
+		byMAC:    map[string]netip.Addr{},
+		declined: map[netip.Addr]time.Time{},
+	}, nil
+}
+
+// Offer returns an address for mac that is held for Config.OfferTime.
+// The address of an existing lease for mac is preferred, then the requested address, then the first free address.
+func (p *Pool) Offer(mac net.HardwareAddr, requested netip.Addr) (Lease, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.clock()
+
+	if l, ok := p.current(mac); ok && p.allocatable(l.IP, mac, now) {
+		if l.State == StateOffered || l.Expires.Before(now) {
+			l.State = StateOffered
+			l.Expires = now.Add(p.cfg.OfferTime)
+		}
+		return *l, nil
+	}
+
+	ip := requested
+	if !p.allocatable(ip, mac, now) {
+		var err error
+		if ip, err = p.next(now); err != nil {
+			return Lease{}, err
+		}
+	}
+
+	return p.assign(mac, ip, StateOffered, now.Add(p.cfg.OfferTime)), nil
+}
+
+// Ack binds ip to mac for Config.LeaseTime. It is used for both initial requests and renewals.
+// ErrUnavailable is returned when ip is not in the pool or is leased to a different client.
+func (p *Pool) Ack(mac net.HardwareAddr, ip netip.Addr) (Lease, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.clock()
+
+	if !p.allocatable(ip, mac, now) {
+		return Lease{}, fmt.Errorf("%w: %v", ErrUnavailable, ip)
+	}
+
+	return p.assign(mac, ip, StateBound, now.Add(p.cfg.LeaseTime)), nil
+}
+
+// Release removes the lease of ip if it is held by mac.
+func (p *Pool) Release(mac net.HardwareAddr, ip netip.Addr) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	l, ok := p.leases[ip]
+	if !ok || l.MAC.String() != mac.String() {
+		return false
+	}
+	p.remove(l)
+
+	return true
+}
+
+// Decline removes the lease of ip if it is held by mac and keeps ip out of the pool for Config.DeclineTime.
+// Clients decline an address when they find that it is already in use on the network.
+func (p *Pool) Decline(mac net.HardwareAddr, ip netip.Addr) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	l, ok := p.leases[ip]
+	if !ok || l.MAC.String() != mac.String() {
+		return false
+	}
+	p.remove(l)
+	p.declined[ip] = p.clock().Add(p.cfg.DeclineTime)
+
+	return true
+}
+
+// Leases returns all leases that have not expired, ordered by address.
+func (p *Pool) Leases() []Lease {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.clock()
+
+	ls := make([]Lease, 0, len(p.leases))
+	for _, l := range p.leases {
+		if l.Expires.After(now) {
+			ls = append(ls, *l)
+		}
+	}
+	sort.Slice(ls, func(i, j int) bool { return ls[i].IP.Less(ls[j].IP) })
+
+	return ls
+}
+
+// DHCP returns the DHCP data for a lease.
+func (p *Pool) DHCP(l Lease) *data.DHCP {
+	d := p.cfg.Options
+	d.MACAddress = l.MAC
+	d.IPAddress = l.IP
+	d.SubnetMask = net.CIDRMask(p.cfg.Prefix.Bits(), 32)
+	d.LeaseTime = uint32(p.cfg.LeaseTime.Seconds())
+
+	return &d
+}
+
+// current returns the lease held by mac, if any. Expired leases are returned so that clients keep their address when possible.
+func (p *Pool) current(mac net.HardwareAddr) (*Lease, bool) {
+	ip, ok := p.byMAC[mac.String()]
+	if !ok {
+		return nil, false
+	}
+
+	return p.leases[ip], true
+}
+
+// allocatable reports whether ip can be leased to mac.
+func (p *Pool) allocatable(ip netip.Addr, mac net.HardwareAddr, now time.Time) bool {
+	if !ip.IsValid() || !p.rng.Contains(ip) {
+		return false
+	}
+	for _, r := range p.cfg.Exclude {
+		if r.Contains(ip) {
+			return false
+		}
+	}
+	if until, ok := p.declined[ip]; ok {
+		if until.After(now) {
+			return false
+		}
+		delete(p.declined, ip)
+	}
+	if l, ok := p.leases[ip]; ok && l.MAC.String() != mac.String() && l.Expires.After(now) {
+		return false
+	}
+
+	return true
+}
+
+// next returns the first free address. Addresses that have never been leased are preferred over
+// expired leases so that returning clients are likely to get their previous address back.
+func (p *Pool) next(now time.Time) (netip.Addr, error) {
+	var expired netip.Addr
+	for ip := p.rng.Start; p.rng.Contains(ip); ip = ip.Next() {
+		if !p.allocatable(ip, nil, now) {
+			continue
+		}
+		if _, ok := p.leases[ip]; !ok {
+			return ip, nil
+		}
+		if !expired.IsValid() {
+			expired = ip
+		}
+	}
+	if expired.IsValid() {
+		return expired, nil
+	}
+
+	return netip.Addr{}, ErrExhausted
+}
+
+// assign records a lease of ip for mac, replacing any other lease held by mac or for ip.
+func (p *Pool) assign(mac net.HardwareAddr, ip netip.Addr, s State, expires time.Time) Lease {
+	if l, ok := p.current(mac); ok && l.IP != ip {
+		p.remove(l)
+	}
+	if l, ok := p.leases[ip]; ok && l.MAC.String() != mac.String() {
+		p.remove(l)
+	}
+	l := &Lease{MAC: mac, IP: ip, State: s, Expires: expires}
+	p.leases[ip] = l
+	p.byMAC[mac.String()] = ip
+
+	return *l
+}
+
+// remove deletes a lease.
+func (p *Pool) remove(l *Lease) {
+	delete(p.leases, l.IP)
+	if p.byMAC[l.MAC.String()] == l.IP {
+		delete(p.byMAC, l.MAC.String())
+	}
+}
+
+// broadcast returns the last address of an IPv4 prefix.
+func broadcast(p netip.Prefix) netip.Addr {
+	a := p.Masked().Addr().As4()
+	for i := p.Bits(); i < 32; i++ {
+		a[i/8] |= 1 << (7 - uint(i%8))
+	}
+
+	return netip.AddrFrom4(a)
+}
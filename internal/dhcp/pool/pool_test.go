@@ -0,0 +1,219 @@
+package pool
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+)
+
+var (
+	mac1 = net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+	mac2 = net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x02}
+	mac3 = net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x03}
+)
+
+// testPool returns a pool with a fake clock that is advanced by the returned function.
+func testPool(t *testing.T, c Config) (*Pool, func(time.Duration)) {
+	t.Helper()
+	p, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	p.clock = func() time.Time { return now }
+
+	return p, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestNew(t *testing.T) {
+	tests := map[string]struct {
+		prefix  string
+		wantErr bool
+	}{
+		"valid":      {prefix: "192.168.2.0/24"},
+		"not masked": {prefix: "192.168.2.10/24"},
+		"ipv6":       {prefix: "2001:db8::/64", wantErr: true},
+		"too small":  {prefix: "192.168.2.0/31", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(Config{Prefix: netip.MustParsePrefix(tt.prefix)})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		want    Range
+		wantErr bool
+	}{
+		"single":    {in: "192.168.2.1", want: Range{Start: netip.MustParseAddr("192.168.2.1"), End: netip.MustParseAddr("192.168.2.1")}},
+		"range":     {in: "192.168.2.1 - 192.168.2.20", want: Range{Start: netip.MustParseAddr("192.168.2.1"), End: netip.MustParseAddr("192.168.2.20")}},
+		"reversed":  {in: "192.168.2.20-192.168.2.1", wantErr: true},
+		"ipv6":      {in: "2001:db8::1", wantErr: true},
+		"not an ip": {in: "foo", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseRange(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got, cmp.Comparer(func(a, b netip.Addr) bool { return a == b })); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestOffer(t *testing.T) {
+	p, advance := testPool(t, Config{
+		Prefix:  netip.MustParsePrefix("192.168.2.0/29"),
+		Exclude: []Range{{Start: netip.MustParseAddr("192.168.2.2"), End: netip.MustParseAddr("192.168.2.3")}},
+		Options: data.DHCP{DefaultGateway: netip.MustParseAddr("192.168.2.1")},
+	})
+
+	l, err := p.Offer(mac1, netip.Addr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := netip.MustParseAddr("192.168.2.4"); l.IP != want || l.State != StateOffered {
+		t.Fatalf("Offer() = %v %v, want %v %v", l.IP, l.State, want, StateOffered)
+	}
+
+	// The same client gets the same address again.
+	if l2, _ := p.Offer(mac1, netip.MustParseAddr("192.168.2.6")); l2.IP != l.IP {
+		t.Fatalf("Offer() = %v, want %v", l2.IP, l.IP)
+	}
+
+	// A requested free address is honored.
+	l, err = p.Offer(mac2, netip.MustParseAddr("192.168.2.6"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := netip.MustParseAddr("192.168.2.6"); l.IP != want {
+		t.Fatalf("Offer() = %v, want %v", l.IP, want)
+	}
+
+	// A requested address held by another client is not.
+	l, err = p.Offer(mac3, netip.MustParseAddr("192.168.2.6"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := netip.MustParseAddr("192.168.2.5"); l.IP != want {
+		t.Fatalf("Offer() = %v, want %v", l.IP, want)
+	}
+
+	// The pool is now exhausted.
+	if _, err := p.Offer(net.HardwareAddr{0, 0, 0, 0, 0, 4}, netip.Addr{}); !errors.Is(err, ErrExhausted) {
+		t.Fatalf("Offer() error = %v, want %v", err, ErrExhausted)
+	}
+
+	// Once offers expire the addresses can be offered again.
+	advance(2 * defaultOfferTime)
+	if _, err := p.Offer(net.HardwareAddr{0, 0, 0, 0, 0, 4}, netip.Addr{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAck(t *testing.T) {
+	p, advance := testPool(t, Config{Prefix: netip.MustParsePrefix("192.168.2.0/24"), LeaseTime: time.Hour})
+	ip := netip.MustParseAddr("192.168.2.50")
+
+	l, err := p.Ack(mac1, ip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.State != StateBound {
+		t.Fatalf("state = %v, want %v", l.State, StateBound)
+	}
+	if _, err := p.Ack(mac2, ip); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Ack() error = %v, want %v", err, ErrUnavailable)
+	}
+	if _, err := p.Ack(mac2, netip.MustParseAddr("10.0.0.1")); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Ack() error = %v, want %v", err, ErrUnavailable)
+	}
+
+	// Renewal extends the lease.
+	advance(45 * time.Minute)
+	l2, err := p.Ack(mac1, ip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l2.Expires.After(l.Expires) {
+		t.Fatalf("renewed lease expires %v, want after %v", l2.Expires, l.Expires)
+	}
+
+	// After expiry another client can take the address.
+	advance(2 * time.Hour)
+	if len(p.Leases()) != 0 {
+		t.Fatalf("Leases() = %v, want none", p.Leases())
+	}
+	if _, err := p.Ack(mac2, ip); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReleaseDecline(t *testing.T) {
+	p, advance := testPool(t, Config{Prefix: netip.MustParsePrefix("192.168.2.0/30"), DeclineTime: time.Minute})
+	ip := netip.MustParseAddr("192.168.2.1")
+
+	if _, err := p.Ack(mac1, ip); err != nil {
+		t.Fatal(err)
+	}
+	if p.Release(mac2, ip) {
+		t.Fatal("Release() by another client succeeded")
+	}
+	if !p.Release(mac1, ip) {
+		t.Fatal("Release() failed")
+	}
+	if len(p.Leases()) != 0 {
+		t.Fatalf("Leases() = %v, want none", p.Leases())
+	}
+
+	if _, err := p.Ack(mac1, ip); err != nil {
+		t.Fatal(err)
+	}
+	if !p.Decline(mac1, ip) {
+		t.Fatal("Decline() failed")
+	}
+	if _, err := p.Ack(mac2, ip); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Ack() error = %v, want %v", err, ErrUnavailable)
+	}
+	advance(2 * time.Minute)
+	if _, err := p.Ack(mac2, ip); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDHCP(t *testing.T) {
+	p, _ := testPool(t, Config{
+		Prefix:    netip.MustParsePrefix("192.168.2.0/24"),
+		LeaseTime: time.Hour,
+		Options: data.DHCP{
+			DefaultGateway: netip.MustParseAddr("192.168.2.1"),
+			NameServers:    []net.IP{{1, 1, 1, 1}},
+		},
+	})
+	got := p.DHCP(Lease{MAC: mac1, IP: netip.MustParseAddr("192.168.2.10")})
+	want := &data.DHCP{
+		MACAddress:     mac1,
+		IPAddress:      netip.MustParseAddr("192.168.2.10"),
+		SubnetMask:     net.CIDRMask(24, 32),
+		DefaultGateway: netip.MustParseAddr("192.168.2.1"),
+		NameServers:    []net.IP{{1, 1, 1, 1}},
+		LeaseTime:      3600,
+	}
+	if diff := cmp.Diff(want, got, cmp.Comparer(func(a, b netip.Addr) bool { return a == b })); diff != "" {
+		t.Fatal(diff)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/insomniacslk/dhcp/dhcpv4/server4"
 	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/health"
+	"github.com/tinkerbell/smee/internal/metric"
 	"golang.org/x/net/ipv4"
 )
 
@@ -24,10 +26,26 @@ type DHCP struct {
 	Conn     net.PacketConn
 	Handlers []Handler
 	Logger   logr.Logger
+	// Workers is the number of goroutines that handle DHCP messages.
+	// When 0, every message is handled in a new goroutine per handler.
+	Workers int
+	// QueueSize is the number of DHCP messages that wait for a worker. Messages received when the queue is full are dropped.
+	QueueSize int
+	// Limiter drops DHCP messages before they are queued. Optional.
+	Limiter *Limiter
+	// Health tracks whether the server listens and when it last received a message. Optional.
+	Health *health.Listener
 }
 
 // Serve serves requests.
 func (s *DHCP) Serve(ctx context.Context) error {
+	err := s.serve(ctx)
+	s.Health.Stopped(err)
+
+	return err
+}
+
+func (s *DHCP) serve(ctx context.Context) error {
 	go func() {
 		<-ctx.Done()
 		_ = s.Close()
@@ -43,6 +61,14 @@ func (s *DHCP) Serve(ctx context.Context) error {
 	defer func() {
 		_ = nConn.Close()
 	}()
+	s.Health.Listening()
+	var queue chan data.Packet
+	if s.Workers > 0 {
+		queue = make(chan data.Packet, s.QueueSize)
+		for range s.Workers {
+			go s.work(ctx, nConn, queue)
+		}
+	}
 	for {
 		// Max UDP packet size is 65535. Max DHCPv4 packet size is 576. An ethernet frame is 1500 bytes.
 		// We use 4096 as a reasonable buffer size. dhcpv4.FromBytes will handle the rest.
@@ -57,6 +83,7 @@ func (s *DHCP) Serve(ctx context.Context) error {
 			s.Logger.Info("error reading from packet conn", "err", err)
 			return err
 		}
+		s.Health.Received()
 
 		m, err := dhcpv4.FromBytes(rbuf[:n])
 		if err != nil {
@@ -82,8 +109,43 @@ func (s *DHCP) Serve(ctx context.Context) error {
 			ifName = n.Name
 		}
 
-		for _, handler := range s.Handlers {
-			go handler.Handle(ctx, nConn, data.Packet{Peer: upeer, Pkt: m, Md: &data.Metadata{IfName: ifName, IfIndex: cm.IfIndex}})
+		if s.Limiter != nil {
+			if reason, ok := s.Limiter.Allow(m); !ok {
+				s.Logger.V(1).Info("dropping DHCP packet", "reason", reason, "mac", m.ClientHWAddr, "xid", m.TransactionID, "giaddr", m.GatewayIPAddr)
+				metric.DHCPDropped.WithLabelValues(reason).Inc()
+				continue
+			}
+		}
+
+		p := data.Packet{Peer: upeer, Pkt: m, Md: &data.Metadata{IfName: ifName, IfIndex: cm.IfIndex}}
+		if queue == nil {
+			for _, handler := range s.Handlers {
+				go handler.Handle(ctx, nConn, p)
+			}
+			continue
+		}
+		select {
+		case queue <- p:
+			metric.DHCPQueued.Inc()
+			metric.DHCPQueueLength.Set(float64(len(queue)))
+		default:
+			s.Logger.V(1).Info("dropping DHCP packet", "reason", DropQueueFull, "mac", m.ClientHWAddr, "xid", m.TransactionID, "giaddr", m.GatewayIPAddr)
+			metric.DHCPDropped.WithLabelValues(DropQueueFull).Inc()
+		}
+	}
+}
+
+// work passes the DHCP messages from queue to all handlers until ctx is canceled.
+func (s *DHCP) work(ctx context.Context, conn *ipv4.PacketConn, queue <-chan data.Packet) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case p := <-queue:
+			metric.DHCPQueueLength.Set(float64(len(queue)))
+			for _, handler := range s.Handlers {
+				handler.Handle(ctx, conn, p)
+			}
 		}
 	}
 }
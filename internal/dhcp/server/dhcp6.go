@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"net"
+
+	"github.com/go-logr/logr"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/dhcpv6/server6"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/health"
+	"golang.org/x/net/ipv6"
+)
+
+// Handler6 is a type that defines the handler function to be called every time a
+// valid DHCPv6 message is received.
+type Handler6 interface {
+	Handle(ctx context.Context, conn *ipv6.PacketConn, d data.Packet6)
+}
+
+// DHCPv6 represents a DHCPv6 server object.
+type DHCPv6 struct {
+	Conn     net.PacketConn
+	Handlers []Handler6
+	Logger   logr.Logger
+	// Interface is the network interface on which to join the All_DHCP_Relay_Agents_and_Servers
+	// multicast group (ff02::1:2). When nil, the system default interface is used.
+	Interface *net.Interface
+	// Health tracks whether the server listens and when it last received a message. Optional.
+	Health *health.Listener
+}
+
+// Serve serves DHCPv6 requests.
+func (s *DHCPv6) Serve(ctx context.Context) error {
+	err := s.serve(ctx)
+	s.Health.Stopped(err)
+
+	return err
+}
+
+func (s *DHCPv6) serve(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		_ = s.Close()
+	}()
+	s.Logger.Info("Server listening on", "addr", s.Conn.LocalAddr())
+
+	nConn := ipv6.NewPacketConn(s.Conn)
+	if err := nConn.SetControlMessage(ipv6.FlagInterface, true); err != nil {
+		s.Logger.Info("error setting control message", "err", err)
+		return err
+	}
+	group := &net.UDPAddr{IP: dhcpv6.AllDHCPRelayAgentsAndServers}
+	if err := nConn.JoinGroup(s.Interface, group); err != nil {
+		s.Logger.Info("error joining multicast group", "group", group.IP.String(), "err", err)
+		return err
+	}
+
+	defer func() {
+		_ = nConn.Close()
+	}()
+	s.Health.Listening()
+	for {
+		// The maximum size of a DHCPv6 message is bound by the IPv6 minimum MTU for
+		// non-fragmented packets, relayed messages can be larger. 4096 is a reasonable buffer size.
+		rbuf := make([]byte, 4096)
+		n, cm, peer, err := nConn.ReadFrom(rbuf)
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+			}
+			s.Logger.Info("error reading from packet conn", "err", err)
+			return err
+		}
+		s.Health.Received()
+
+		m, err := dhcpv6.FromBytes(rbuf[:n])
+		if err != nil {
+			s.Logger.Info("error parsing DHCPv6 request", "err", err)
+			continue
+		}
+
+		upeer, ok := peer.(*net.UDPAddr)
+		if !ok {
+			s.Logger.Info("not a UDP connection? Peer is", "peer", peer)
+			continue
+		}
+
+		var ifName string
+		var ifIndex int
+		if cm != nil {
+			ifIndex = cm.IfIndex
+			if n, err := net.InterfaceByIndex(cm.IfIndex); err == nil {
+				ifName = n.Name
+			}
+		}
+
+		for _, handler := range s.Handlers {
+			go handler.Handle(ctx, nConn, data.Packet6{Peer: upeer, Pkt: m, Md: &data.Metadata{IfName: ifName, IfIndex: ifIndex}})
+		}
+	}
+}
+
+// Close sends a termination request to the server, and closes the UDP listener.
+func (s *DHCPv6) Close() error {
+	return s.Conn.Close()
+}
+
+// NewServer6 initializes and returns a new DHCPv6 Server object.
+func NewServer6(ifname string, addr *net.UDPAddr, handler ...Handler6) (*DHCPv6, error) {
+	s := &DHCPv6{
+		Handlers: handler,
+		Logger:   logr.Discard(),
+	}
+
+	if ifname != "" {
+		iface, err := net.InterfaceByName(ifname)
+		if err != nil {
+			return nil, err
+		}
+		s.Interface = iface
+	}
+
+	conn, err := server6.NewIPv6UDPConn(ifname, addr)
+	if err != nil {
+		return nil, err
+	}
+	s.Conn = conn
+
+	return s, nil
+}
@@ -4,12 +4,16 @@ import (
 	"context"
 	"net"
 	"net/netip"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/go-logr/logr"
 	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/insomniacslk/dhcp/dhcpv4/nclient4"
 	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/health"
+	"github.com/tinkerbell/smee/internal/metric"
 	"golang.org/x/net/ipv4"
 	"golang.org/x/net/nettest"
 )
@@ -85,12 +89,20 @@ func dhcp(ctx context.Context) (*dhcpv4.DHCPv4, error) {
 	return c.DiscoverOffer(ctx)
 }
 
+func TestMain(m *testing.M) {
+	metric.Init()
+	os.Exit(m.Run())
+}
+
 func TestServe(t *testing.T) {
 	tests := map[string]struct {
-		h    Handler
-		addr netip.AddrPort
+		h       Handler
+		addr    netip.AddrPort
+		workers int
+		limiter *Limiter
 	}{
-		"success": {addr: netip.MustParseAddrPort("127.0.0.1:7676"), h: &mock{}},
+		"success":     {addr: netip.MustParseAddrPort("127.0.0.1:7676"), h: &mock{}},
+		"worker pool": {addr: netip.MustParseAddrPort("127.0.0.1:7676"), h: &mock{}, workers: 2, limiter: &Limiter{ClientRate: 10, ClientBurst: 10}},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -98,10 +110,16 @@ func TestServe(t *testing.T) {
 			if err != nil {
 				t.Fatal(err)
 			}
+			s.Workers, s.QueueSize, s.Limiter = tt.workers, tt.workers, tt.limiter
+			s.Health = &health.Listener{}
 			ctx, done := context.WithCancel(context.Background())
 			defer done()
 
-			go s.Serve(ctx)
+			served := make(chan struct{})
+			go func() {
+				_ = s.Serve(ctx)
+				close(served)
+			}()
 
 			// make client calls
 			d, err := dhcp(ctx)
@@ -109,8 +127,15 @@ func TestServe(t *testing.T) {
 				t.Fatal(err)
 			}
 			t.Log(d)
+			if detail, err := s.Health.Check(ctx); err != nil || !strings.Contains(detail, "last packet received") {
+				t.Errorf("health while serving = %q, %v, want a received packet", detail, err)
+			}
 
 			done()
+			<-served
+			if _, err := s.Health.Check(ctx); err == nil {
+				t.Error("health after serving = nil error, want not listening")
+			}
 		})
 	}
 }
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"golang.org/x/time/rate"
+)
+
+// Reasons a DHCP message is dropped before it is handled. They are used as the reason label of metric.DHCPDropped.
+const (
+	DropQueueFull  = "queue_full"
+	DropRetransmit = "retransmit"
+	DropClientRate = "client_rate"
+	DropRelayRate  = "relay_rate"
+)
+
+// limiterIdle is how long a client or relay agent must be idle before its state is forgotten.
+const limiterIdle = 10 * time.Minute
+
+// Limiter limits the rate of DHCP messages from a client, by hardware address, and from a relay agent, by giaddr.
+// It also drops retransmits of a message, messages with the same transaction ID and message type from a client,
+// that are received within RetransmitWindow of the first one.
+//
+// The zero value of a limit disables it. A Limiter is safe for concurrent use.
+type Limiter struct {
+	// ClientRate is the number of messages per second allowed from a client.
+	ClientRate rate.Limit
+	// ClientBurst is the number of messages a client is allowed to send at once.
+	ClientBurst int
+	// RelayRate is the number of messages per second allowed from a relay agent.
+	RelayRate rate.Limit
+	// RelayBurst is the number of messages a relay agent is allowed to send at once.
+	RelayBurst int
+	// RetransmitWindow is how long retransmits of a message are dropped.
+	RetransmitWindow time.Duration
+
+	mu        sync.Mutex
+	clients   map[string]*clientState
+	relays    map[netip.Addr]*relayState
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type clientState struct {
+	limiter *rate.Limiter
+	xid     dhcpv4.TransactionID
+	msgType dhcpv4.MessageType
+	first   time.Time
+	seen    time.Time
+}
+
+type relayState struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+// Allow reports whether a DHCP message should be handled. When it should not, the reason is returned.
+func (l *Limiter) Allow(pkt *dhcpv4.DHCPv4) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.now == nil {
+		l.now = time.Now
+	}
+	now := l.now()
+	l.sweep(now)
+
+	c, ok := l.clients[pkt.ClientHWAddr.String()]
+	if !ok {
+		if l.clients == nil {
+			l.clients = map[string]*clientState{}
+		}
+		c = &clientState{limiter: rate.NewLimiter(l.ClientRate, l.ClientBurst)}
+		l.clients[pkt.ClientHWAddr.String()] = c
+	}
+	c.seen = now
+	mt := pkt.MessageType()
+	if ok && l.RetransmitWindow > 0 && c.xid == pkt.TransactionID && c.msgType == mt && now.Sub(c.first) < l.RetransmitWindow {
+		return DropRetransmit, false
+	}
+
+	if giaddr, ok := netip.AddrFromSlice(pkt.GatewayIPAddr.To4()); ok && !giaddr.IsUnspecified() && l.RelayRate > 0 {
+		r, ok := l.relays[giaddr]
+		if !ok {
+			if l.relays == nil {
+				l.relays = map[netip.Addr]*relayState{}
+			}
+			r = &relayState{limiter: rate.NewLimiter(l.RelayRate, l.RelayBurst)}
+			l.relays[giaddr] = r
+		}
+		r.seen = now
+		if !r.limiter.AllowN(now, 1) {
+			return DropRelayRate, false
+		}
+	}
+
+	if l.ClientRate > 0 && !c.limiter.AllowN(now, 1) {
+		return DropClientRate, false
+	}
+	c.xid, c.msgType, c.first = pkt.TransactionID, mt, now
+
+	return "", true
+}
+
+// sweep forgets the clients and relay agents that have been idle for limiterIdle.
+// With any practical limit their token buckets are full again by then, so forgetting them does not change the limits.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < limiterIdle {
+		return
+	}
+	l.lastSweep = now
+	for k, c := range l.clients {
+		if now.Sub(c.seen) >= limiterIdle {
+			delete(l.clients, k)
+		}
+	}
+	for k, r := range l.relays {
+		if now.Sub(r.seen) >= limiterIdle {
+			delete(l.relays, k)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+func TestLimiter(t *testing.T) {
+	type msg struct {
+		mac        byte
+		xid        byte
+		giaddr     net.IP
+		after      time.Duration
+		wantReason string
+	}
+	tests := map[string]struct {
+		limiter *Limiter
+		msgs    []msg
+	}{
+		"no limits": {
+			limiter: &Limiter{},
+			msgs:    []msg{{mac: 1, xid: 1}, {mac: 1, xid: 1}, {mac: 1, xid: 1}},
+		},
+		"retransmit within the window": {
+			limiter: &Limiter{RetransmitWindow: 2 * time.Second},
+			msgs: []msg{
+				{mac: 1, xid: 1},
+				{mac: 1, xid: 1, after: time.Second, wantReason: DropRetransmit},
+				{mac: 2, xid: 1},
+				{mac: 1, xid: 2},
+			},
+		},
+		"retransmit after the window": {
+			limiter: &Limiter{RetransmitWindow: 2 * time.Second},
+			msgs:    []msg{{mac: 1, xid: 1}, {mac: 1, xid: 1, after: 3 * time.Second}},
+		},
+		"client rate": {
+			limiter: &Limiter{ClientRate: 1, ClientBurst: 2},
+			msgs: []msg{
+				{mac: 1, xid: 1},
+				{mac: 1, xid: 2},
+				{mac: 1, xid: 3, wantReason: DropClientRate},
+				{mac: 2, xid: 4},
+				{mac: 1, xid: 5, after: time.Second},
+			},
+		},
+		"relay rate": {
+			limiter: &Limiter{RelayRate: 1, RelayBurst: 2},
+			msgs: []msg{
+				{mac: 1, xid: 1, giaddr: net.IP{192, 168, 2, 1}},
+				{mac: 2, xid: 2, giaddr: net.IP{192, 168, 2, 1}},
+				{mac: 3, xid: 3, giaddr: net.IP{192, 168, 2, 1}, wantReason: DropRelayRate},
+				{mac: 3, xid: 4, giaddr: net.IP{192, 168, 3, 1}},
+				{mac: 3, xid: 5},
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			tt.limiter.now = func() time.Time { return now }
+			for i, m := range tt.msgs {
+				now = now.Add(m.after)
+				pkt := &dhcpv4.DHCPv4{
+					ClientHWAddr:  net.HardwareAddr{0, 0, 0, 0, 0, m.mac},
+					TransactionID: dhcpv4.TransactionID{0, 0, 0, m.xid},
+					GatewayIPAddr: m.giaddr,
+					Options:       dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeDiscover)),
+				}
+				reason, ok := tt.limiter.Allow(pkt)
+				if reason != m.wantReason || ok != (m.wantReason == "") {
+					t.Fatalf("message %d: Allow() = %q, %v, want %q", i, reason, ok, m.wantReason)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := &Limiter{ClientRate: 1, ClientBurst: 1, RelayRate: 1, RelayBurst: 1, now: func() time.Time { return now }}
+	l.Allow(&dhcpv4.DHCPv4{ClientHWAddr: net.HardwareAddr{0, 0, 0, 0, 0, 1}, GatewayIPAddr: net.IP{192, 168, 2, 1}})
+	now = now.Add(limiterIdle)
+	l.Allow(&dhcpv4.DHCPv4{ClientHWAddr: net.HardwareAddr{0, 0, 0, 0, 0, 2}})
+	if len(l.clients) != 1 || len(l.relays) != 0 {
+		t.Fatalf("got %d clients and %d relays after sweep, want 1 and 0", len(l.clients), len(l.relays))
+	}
+}
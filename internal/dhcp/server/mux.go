@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
+	"golang.org/x/net/ipv4"
+)
+
+// InterfaceMux is a Handler that passes each DHCP message to the Handler of the interface it was received on.
+// The interface is taken from data.Metadata.IfName.
+type InterfaceMux struct {
+	// Handlers maps an interface name to the Handler for DHCP messages received on that interface.
+	Handlers map[string]Handler
+	// Default handles DHCP messages received on interfaces that are not in Handlers.
+	// When nil, those messages are ignored.
+	Default Handler
+}
+
+// Handle passes a DHCP message to the Handler of the interface it was received on.
+func (m *InterfaceMux) Handle(ctx context.Context, conn *ipv4.PacketConn, d data.Packet) {
+	var ifName string
+	if d.Md != nil {
+		ifName = d.Md.IfName
+	}
+	if h, ok := m.Handlers[ifName]; ok {
+		h.Handle(ctx, conn, d)
+		return
+	}
+	if m.Default != nil {
+		m.Default.Handle(ctx, conn, d)
+	}
+}
+
+// Simulate implements the handler.Simulator interface with the Handler of the interface in data.Metadata.IfName.
+func (m *InterfaceMux) Simulate(ctx context.Context, d data.Packet) (*dhcpv4.DHCPv4, error) {
+	var ifName string
+	if d.Md != nil {
+		ifName = d.Md.IfName
+	}
+	if h, ok := m.Handlers[ifName]; ok {
+		return simulate(ctx, h, d)
+	}
+	if m.Default != nil {
+		return simulate(ctx, m.Default, d)
+	}
+
+	return nil, fmt.Errorf("DHCP messages received on interface %q are ignored", ifName)
+}
+
+// simulate returns the reply of h to d, when h implements the handler.Simulator interface.
+func simulate(ctx context.Context, h Handler, d data.Packet) (*dhcpv4.DHCPv4, error) {
+	s, ok := h.(handler.Simulator)
+	if !ok {
+		return nil, errors.New("the DHCP handler does not support simulating replies")
+	}
+
+	return s.Simulate(ctx, d)
+}
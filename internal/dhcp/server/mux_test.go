@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"golang.org/x/net/ipv4"
+)
+
+type recorder struct {
+	got []string
+}
+
+func (r *recorder) Handle(_ context.Context, _ *ipv4.PacketConn, d data.Packet) {
+	var ifName string
+	if d.Md != nil {
+		ifName = d.Md.IfName
+	}
+	r.got = append(r.got, ifName)
+}
+
+// simulator is a Handler that simulates a reply with its name as the server host name.
+type simulator struct {
+	recorder
+	name string
+}
+
+func (s *simulator) Simulate(context.Context, data.Packet) (*dhcpv4.DHCPv4, error) {
+	return &dhcpv4.DHCPv4{ServerHostName: s.name}, nil
+}
+
+func TestInterfaceMux(t *testing.T) {
+	tests := map[string]struct {
+		md          *data.Metadata
+		withDefault bool
+		wantEth1    int
+		wantDefault int
+	}{
+		"interface handler":                {md: &data.Metadata{IfName: "eth1"}, withDefault: true, wantEth1: 1},
+		"default handler":                  {md: &data.Metadata{IfName: "eth2"}, withDefault: true, wantDefault: 1},
+		"no metadata":                      {withDefault: true, wantDefault: 1},
+		"unknown interface and no default": {md: &data.Metadata{IfName: "eth2"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			eth1, def := &recorder{}, &recorder{}
+			m := &InterfaceMux{Handlers: map[string]Handler{"eth1": eth1}}
+			if tt.withDefault {
+				m.Default = def
+			}
+			m.Handle(context.Background(), nil, data.Packet{Md: tt.md})
+			if len(eth1.got) != tt.wantEth1 {
+				t.Errorf("eth1 handler called %d times, want %d", len(eth1.got), tt.wantEth1)
+			}
+			if len(def.got) != tt.wantDefault {
+				t.Errorf("default handler called %d times, want %d", len(def.got), tt.wantDefault)
+			}
+		})
+	}
+}
+
+func TestInterfaceMuxSimulate(t *testing.T) {
+	tests := map[string]struct {
+		md      *data.Metadata
+		def     Handler
+		want    string
+		wantErr bool
+	}{
+		"interface handler":                {md: &data.Metadata{IfName: "eth1"}, want: "eth1"},
+		"default handler":                  {md: &data.Metadata{IfName: "eth2"}, def: &simulator{name: "default"}, want: "default"},
+		"unknown interface and no default": {md: &data.Metadata{IfName: "eth2"}, wantErr: true},
+		"handler without simulation":       {def: &recorder{}, wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := &InterfaceMux{Handlers: map[string]Handler{"eth1": &simulator{name: "eth1"}}, Default: tt.def}
+			r := &Reloadable{}
+			r.Store(m)
+			got, err := r.Simulate(context.Background(), data.Packet{Md: tt.md})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Simulate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.ServerHostName != tt.want {
+				t.Errorf("Simulate() handled by %q, want %q", got.ServerHostName, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Reloadable is a Handler that passes DHCP messages to a Handler that can be replaced while the server runs.
+// Messages that are being handled finish on the Handler they started on. Store must be called before it handles messages.
+type Reloadable struct {
+	h atomic.Pointer[Handler]
+}
+
+// Store replaces the Handler that new DHCP messages are passed to.
+func (r *Reloadable) Store(h Handler) {
+	r.h.Store(&h)
+}
+
+// Handle passes a DHCP message to the current Handler.
+func (r *Reloadable) Handle(ctx context.Context, conn *ipv4.PacketConn, d data.Packet) {
+	(*r.h.Load()).Handle(ctx, conn, d)
+}
+
+// Simulate implements the handler.Simulator interface with the current Handler.
+func (r *Reloadable) Simulate(ctx context.Context, d data.Packet) (*dhcpv4.DHCPv4, error) {
+	return simulate(ctx, *r.h.Load(), d)
+}
+
+// Reloadable6 is a Handler6 that passes DHCPv6 messages to a Handler6 that can be replaced while the server runs.
+// Messages that are being handled finish on the Handler6 they started on. Store must be called before it handles messages.
+type Reloadable6 struct {
+	h atomic.Pointer[Handler6]
+}
+
+// Store replaces the Handler6 that new DHCPv6 messages are passed to.
+func (r *Reloadable6) Store(h Handler6) {
+	r.h.Store(&h)
+}
+
+// Handle passes a DHCPv6 message to the current Handler6.
+func (r *Reloadable6) Handle(ctx context.Context, conn *ipv6.PacketConn, d data.Packet6) {
+	(*r.h.Load()).Handle(ctx, conn, d)
+}
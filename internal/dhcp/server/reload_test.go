@@ -0,0 +1,20 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+)
+
+func TestReloadable(t *testing.T) {
+	first, second := &recorder{}, &recorder{}
+	r := &Reloadable{}
+	r.Store(first)
+	r.Handle(context.Background(), nil, data.Packet{})
+	r.Store(second)
+	r.Handle(context.Background(), nil, data.Packet{})
+	if len(first.got) != 1 || len(second.got) != 1 {
+		t.Fatalf("handlers called %d and %d times, want 1 and 1", len(first.got), len(second.got))
+	}
+}
@@ -0,0 +1,148 @@
+// Package subnet holds the per subnet defaults that DHCP handlers apply to the data from a backend.
+//
+// A subnet is selected for a DHCP message by the address of the link the client is on.
+// In order of precedence, that is the link selection sub-option of the relay agent information (RFC 3527),
+// the subnet selection option (RFC 3011) and the gateway IP address (giaddr) of a relayed message.
+// When a message has none of these, the subnet containing the IP address of the backend record is used.
+package subnet
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"sort"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+)
+
+// Subnet is an IPv4 subnet and the defaults for clients on it.
+type Subnet struct {
+	// Prefix is the subnet. The subnet mask of clients without one is taken from it.
+	Prefix netip.Prefix
+	// Options are the DHCP options for clients on the subnet.
+	// Only the options a backend record does not have are used.
+	// MACAddress, IPAddress, Hostname and Disabled are ignored.
+	Options data.DHCP
+	// Netboot overrides the netboot configuration of a handler for clients on the subnet.
+	Netboot Netboot
+}
+
+// Netboot holds the netboot configuration of a subnet. Fields that are not set are not overridden.
+type Netboot struct {
+	// IPXEBinServerTFTP is the iPXE binary server IP:Port serving via TFTP.
+	IPXEBinServerTFTP netip.AddrPort
+	// IPXEBinServerHTTP is the URL to the iPXE binary server serving via HTTP(s).
+	IPXEBinServerHTTP *url.URL
+	// IPXEScriptURL is the URL to the iPXE script to use.
+	IPXEScriptURL func(*dhcpv4.DHCPv4) *url.URL
+}
+
+// Registry holds subnets and selects the subnet for a DHCP message.
+type Registry struct {
+	subnets []Subnet
+}
+
+// NewRegistry returns a Registry of the given subnets. Subnets must be IPv4 and must not overlap.
+func NewRegistry(subnets ...Subnet) (*Registry, error) {
+	r := &Registry{}
+	for _, s := range subnets {
+		if !s.Prefix.IsValid() || !s.Prefix.Addr().Is4() {
+			return nil, fmt.Errorf("subnet %v is not an IPv4 prefix", s.Prefix)
+		}
+		s.Prefix = s.Prefix.Masked()
+		for _, o := range r.subnets {
+			if o.Prefix.Overlaps(s.Prefix) {
+				return nil, fmt.Errorf("subnet %v overlaps subnet %v", s.Prefix, o.Prefix)
+			}
+		}
+		r.subnets = append(r.subnets, s)
+	}
+	sort.Slice(r.subnets, func(i, j int) bool { return r.subnets[i].Prefix.Addr().Less(r.subnets[j].Prefix.Addr()) })
+
+	return r, nil
+}
+
+// Subnets returns all subnets ordered by address.
+func (r *Registry) Subnets() []Subnet {
+	return append([]Subnet(nil), r.subnets...)
+}
+
+// Lookup returns the subnet that contains ip.
+func (r *Registry) Lookup(ip netip.Addr) (Subnet, bool) {
+	if r == nil {
+		return Subnet{}, false
+	}
+	ip = ip.Unmap()
+	for _, s := range r.subnets {
+		if s.Prefix.Contains(ip) {
+			return s, true
+		}
+	}
+
+	return Subnet{}, false
+}
+
+// Select returns the subnet of the link a DHCP message was received from.
+// If the message does not identify its link, the subnet containing fallback is returned.
+func (r *Registry) Select(pkt *dhcpv4.DHCPv4, fallback netip.Addr) (Subnet, bool) {
+	if ip, ok := LinkAddress(pkt); ok {
+		return r.Lookup(ip)
+	}
+
+	return r.Lookup(fallback)
+}
+
+// LinkAddress returns the address that identifies the link a DHCP message was received from.
+// In order of precedence, that is the link selection sub-option of option 82, option 118 and the giaddr.
+func LinkAddress(pkt *dhcpv4.DHCPv4) (netip.Addr, bool) {
+	if rai := pkt.RelayAgentInfo(); rai != nil {
+		if ip, ok := toAddr(rai.Get(dhcpv4.LinkSelectionSubOption)); ok {
+			return ip, true
+		}
+	}
+	if ip, ok := toAddr(pkt.GetOneOption(dhcpv4.OptionSubnetSelection)); ok {
+		return ip, true
+	}
+
+	return toAddr(pkt.GatewayIPAddr)
+}
+
+// Apply sets the options of s that are not set in d.
+func (s Subnet) Apply(d *data.DHCP) {
+	if len(d.SubnetMask) == 0 {
+		d.SubnetMask = net.CIDRMask(s.Prefix.Bits(), 32)
+	}
+	if !d.DefaultGateway.IsValid() {
+		d.DefaultGateway = s.Options.DefaultGateway
+	}
+	if len(d.NameServers) == 0 {
+		d.NameServers = s.Options.NameServers
+	}
+	if d.DomainName == "" {
+		d.DomainName = s.Options.DomainName
+	}
+	if !d.BroadcastAddress.IsValid() {
+		d.BroadcastAddress = s.Options.BroadcastAddress
+	}
+	if len(d.NTPServers) == 0 {
+		d.NTPServers = s.Options.NTPServers
+	}
+	if d.LeaseTime == 0 {
+		d.LeaseTime = s.Options.LeaseTime
+	}
+	if len(d.DomainSearch) == 0 {
+		d.DomainSearch = s.Options.DomainSearch
+	}
+}
+
+// toAddr converts a 4 byte address to a netip.Addr. The unspecified address is not valid.
+func toAddr(b []byte) (netip.Addr, bool) {
+	ip, ok := netip.AddrFromSlice(net.IP(b).To4())
+	if !ok || ip.IsUnspecified() {
+		return netip.Addr{}, false
+	}
+
+	return ip, true
+}
@@ -0,0 +1,148 @@
+package subnet
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/smee/internal/dhcp/data"
+)
+
+var addrCmp = cmp.Comparer(func(a, b netip.Addr) bool { return a == b })
+
+func testRegistry(t *testing.T) *Registry {
+	t.Helper()
+	r, err := NewRegistry(
+		Subnet{Prefix: netip.MustParsePrefix("192.168.3.0/24"), Options: data.DHCP{DefaultGateway: netip.MustParseAddr("192.168.3.1")}},
+		Subnet{Prefix: netip.MustParsePrefix("192.168.2.0/24"), Options: data.DHCP{DefaultGateway: netip.MustParseAddr("192.168.2.1")}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func TestNewRegistry(t *testing.T) {
+	tests := map[string]struct {
+		prefixes []string
+		wantErr  bool
+	}{
+		"valid":       {prefixes: []string{"192.168.2.0/24", "192.168.3.0/24"}},
+		"not masked":  {prefixes: []string{"192.168.2.10/24"}},
+		"overlapping": {prefixes: []string{"192.168.2.0/24", "192.168.2.128/25"}, wantErr: true},
+		"ipv6":        {prefixes: []string{"2001:db8::/64"}, wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var subnets []Subnet
+			for _, p := range tt.prefixes {
+				subnets = append(subnets, Subnet{Prefix: netip.MustParsePrefix(p)})
+			}
+			if _, err := NewRegistry(subnets...); (err != nil) != tt.wantErr {
+				t.Fatalf("NewRegistry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	relayInfo := func(link net.IP) dhcpv4.Option {
+		return dhcpv4.OptRelayAgentInfo(dhcpv4.OptGeneric(dhcpv4.LinkSelectionSubOption, link.To4()))
+	}
+	tests := map[string]struct {
+		pkt      *dhcpv4.DHCPv4
+		fallback netip.Addr
+		want     netip.Prefix
+		wantOK   bool
+	}{
+		"giaddr": {
+			pkt:    &dhcpv4.DHCPv4{GatewayIPAddr: net.IP{192, 168, 2, 1}},
+			want:   netip.MustParsePrefix("192.168.2.0/24"),
+			wantOK: true,
+		},
+		"subnet selection over giaddr": {
+			pkt: &dhcpv4.DHCPv4{
+				GatewayIPAddr: net.IP{192, 168, 2, 1},
+				Options:       dhcpv4.OptionsFromList(dhcpv4.OptGeneric(dhcpv4.OptionSubnetSelection, []byte{192, 168, 3, 0})),
+			},
+			want:   netip.MustParsePrefix("192.168.3.0/24"),
+			wantOK: true,
+		},
+		"link selection over subnet selection": {
+			pkt: &dhcpv4.DHCPv4{
+				GatewayIPAddr: net.IP{192, 168, 3, 1},
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptGeneric(dhcpv4.OptionSubnetSelection, []byte{192, 168, 3, 0}),
+					relayInfo(net.IP{192, 168, 2, 0}),
+				),
+			},
+			want:   netip.MustParsePrefix("192.168.2.0/24"),
+			wantOK: true,
+		},
+		"not relayed uses the fallback": {
+			pkt:      &dhcpv4.DHCPv4{GatewayIPAddr: net.IPv4zero},
+			fallback: netip.MustParseAddr("192.168.3.10"),
+			want:     netip.MustParsePrefix("192.168.3.0/24"),
+			wantOK:   true,
+		},
+		"unknown link": {
+			pkt:      &dhcpv4.DHCPv4{GatewayIPAddr: net.IP{10, 0, 0, 1}},
+			fallback: netip.MustParseAddr("192.168.3.10"),
+		},
+	}
+	r := testRegistry(t)
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := r.Select(tt.pkt, tt.fallback)
+			if ok != tt.wantOK {
+				t.Fatalf("Select() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if got.Prefix != tt.want {
+				t.Fatalf("Select() = %v, want %v", got.Prefix, tt.want)
+			}
+		})
+	}
+}
+
+func TestNilRegistry(t *testing.T) {
+	var r *Registry
+	if _, ok := r.Select(&dhcpv4.DHCPv4{GatewayIPAddr: net.IP{192, 168, 2, 1}}, netip.Addr{}); ok {
+		t.Fatal("Select() on a nil registry found a subnet")
+	}
+}
+
+func TestApply(t *testing.T) {
+	s := Subnet{
+		Prefix: netip.MustParsePrefix("192.168.2.0/24"),
+		Options: data.DHCP{
+			DefaultGateway: netip.MustParseAddr("192.168.2.1"),
+			NameServers:    []net.IP{{1, 1, 1, 1}},
+			NTPServers:     []net.IP{{132, 163, 96, 2}},
+			DomainName:     "example.com",
+			DomainSearch:   []string{"example.com"},
+			LeaseTime:      3600,
+		},
+	}
+	got := &data.DHCP{
+		IPAddress:   netip.MustParseAddr("192.168.2.10"),
+		NameServers: []net.IP{{8, 8, 8, 8}},
+		LeaseTime:   60,
+	}
+	s.Apply(got)
+	want := &data.DHCP{
+		IPAddress:      netip.MustParseAddr("192.168.2.10"),
+		SubnetMask:     net.CIDRMask(24, 32),
+		DefaultGateway: netip.MustParseAddr("192.168.2.1"),
+		NameServers:    []net.IP{{8, 8, 8, 8}},
+		NTPServers:     []net.IP{{132, 163, 96, 2}},
+		DomainName:     "example.com",
+		DomainSearch:   []string{"example.com"},
+		LeaseTime:      60,
+	}
+	if diff := cmp.Diff(want, got, addrCmp); diff != "" {
+		t.Fatal(diff)
+	}
+}
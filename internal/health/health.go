@@ -0,0 +1,121 @@
+// Package health reports the health of the subsystems of smee, for the /healthz and /readyz endpoints.
+//
+// Every subsystem registers a Check with a Kind. A failing Liveness check means that smee does not work until it is
+// restarted, for example because the DHCP socket was closed. A failing Readiness check means that smee should not get
+// traffic until it passes again, for example while the backend cache is not synced. Informational checks are reported
+// so that monitoring can see them, but never fail an endpoint, for example the OpenTelemetry exporter.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// checkTimeout is how long a single check may take.
+const checkTimeout = 5 * time.Second
+
+// Check returns an error when a subsystem is not healthy. detail describes the state of the subsystem.
+type Check func(context.Context) (detail string, err error)
+
+// Kind decides which reports a failing check fails.
+type Kind int
+
+const (
+	// Liveness checks fail the liveness and the readiness report.
+	Liveness Kind = iota
+	// Readiness checks fail the readiness report.
+	Readiness
+	// Informational checks fail no report.
+	Informational
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Liveness:
+		return "liveness"
+	case Readiness:
+		return "readiness"
+	default:
+		return "informational"
+	}
+}
+
+// Registry is the set of the checks of the subsystems. The zero value has no checks and is always healthy.
+type Registry struct {
+	mu     sync.Mutex // protects checks
+	checks []entry
+}
+
+type entry struct {
+	name  string
+	kind  Kind
+	check Check
+}
+
+// Report is the result of the checks.
+type Report struct {
+	// Healthy is false when a check of the kind the report is for, or of a more severe kind, failed.
+	Healthy bool              `json:"healthy"`
+	Checks  map[string]Result `json:"checks"`
+}
+
+// Result is the result of a single check.
+type Result struct {
+	Healthy bool   `json:"healthy"`
+	Kind    string `json:"kind"`
+	Detail  string `json:"detail,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Register adds the check of a subsystem. A check that is registered with the name of another check replaces it.
+func (r *Registry) Register(name string, kind Kind, c Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, e := range r.checks {
+		if e.name == name {
+			r.checks[i] = entry{name: name, kind: kind, check: c}
+			return
+		}
+	}
+	r.checks = append(r.checks, entry{name: name, kind: kind, check: c})
+}
+
+// Report runs all checks concurrently. The report is not healthy when a check of kind or of a more severe kind fails,
+// Report(ctx, Liveness) only fails with Liveness checks, Report(ctx, Readiness) with Liveness and Readiness checks.
+// A nil Registry is healthy.
+func (r *Registry) Report(ctx context.Context, kind Kind) Report {
+	rep := Report{Healthy: true, Checks: map[string]Result{}}
+	if r == nil {
+		return rep
+	}
+	r.mu.Lock()
+	checks := append([]entry(nil), r.checks...)
+	r.mu.Unlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, e := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+			detail, err := e.check(ctx)
+			results[i] = Result{Healthy: err == nil, Kind: e.kind.String(), Detail: detail}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, e := range checks {
+		rep.Checks[e.name] = results[i]
+		if !results[i].Healthy && e.kind <= kind {
+			rep.Healthy = false
+		}
+	}
+
+	return rep
+}
@@ -0,0 +1,70 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func healthy(context.Context) (string, error) { return "ok", nil }
+
+func failing(context.Context) (string, error) { return "", errors.New("broken") }
+
+func TestReport(t *testing.T) {
+	tests := map[string]struct {
+		failing     Kind
+		wantLive    bool
+		wantReady   bool
+		wantResults map[string]Result
+	}{
+		"liveness fails both": {failing: Liveness, wantResults: map[string]Result{
+			"broken": {Kind: "liveness", Error: "broken"},
+			"ok":     {Healthy: true, Kind: "readiness", Detail: "ok"},
+		}},
+		"readiness fails readiness": {failing: Readiness, wantLive: true, wantResults: map[string]Result{
+			"broken": {Kind: "readiness", Error: "broken"},
+			"ok":     {Healthy: true, Kind: "readiness", Detail: "ok"},
+		}},
+		"informational fails none": {failing: Informational, wantLive: true, wantReady: true, wantResults: map[string]Result{
+			"broken": {Kind: "informational", Error: "broken"},
+			"ok":     {Healthy: true, Kind: "readiness", Detail: "ok"},
+		}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := &Registry{}
+			r.Register("ok", Readiness, healthy)
+			r.Register("broken", tt.failing, failing)
+
+			live := r.Report(context.Background(), Liveness)
+			if live.Healthy != tt.wantLive {
+				t.Errorf("liveness healthy = %v, want %v", live.Healthy, tt.wantLive)
+			}
+			ready := r.Report(context.Background(), Readiness)
+			if ready.Healthy != tt.wantReady {
+				t.Errorf("readiness healthy = %v, want %v", ready.Healthy, tt.wantReady)
+			}
+			if diff := cmp.Diff(tt.wantResults, ready.Checks); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestRegisterReplaces(t *testing.T) {
+	r := &Registry{}
+	r.Register("backend", Readiness, failing)
+	r.Register("backend", Readiness, healthy)
+	if rep := r.Report(context.Background(), Readiness); !rep.Healthy || len(rep.Checks) != 1 {
+		t.Errorf("Report() = %+v, want a single healthy check", rep)
+	}
+}
+
+func TestNilRegistry(t *testing.T) {
+	var r *Registry
+	if rep := r.Report(context.Background(), Readiness); !rep.Healthy {
+		t.Error("nil registry is not healthy")
+	}
+}
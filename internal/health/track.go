@@ -0,0 +1,127 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Listener tracks a server that receives packets on a socket, like the DHCP and TFTP servers.
+// It is not healthy before Listening is called and after Stopped is called. The methods of a nil Listener do nothing.
+type Listener struct {
+	mu        sync.Mutex // protects listening and err
+	listening bool
+	err       error
+	// received is the time the last packet was received, in unix nanoseconds, it is updated for every packet.
+	received atomic.Int64
+	// now returns the current time, it is replaced in tests.
+	now func() time.Time
+}
+
+// Listening records that the server listens on its socket.
+func (l *Listener) Listening() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.listening = true
+	l.err = nil
+}
+
+// Stopped records that the server stopped listening, with the error it stopped with, if any.
+func (l *Listener) Stopped(err error) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.listening = false
+	l.err = err
+}
+
+// Received records that the server received a packet.
+func (l *Listener) Received() {
+	if l == nil {
+		return
+	}
+	l.received.Store(l.timeNow().UnixNano())
+}
+
+// Check is the Check of the server. Not receiving packets is healthy, as there may be no clients,
+// the time of the last packet is its detail.
+func (l *Listener) Check(context.Context) (string, error) {
+	l.mu.Lock()
+	listening, err := l.listening, l.err
+	l.mu.Unlock()
+	if err != nil {
+		return "", fmt.Errorf("stopped listening: %w", err)
+	}
+	if !listening {
+		return "", errors.New("not listening")
+	}
+	last := l.received.Load()
+	if last == 0 {
+		return "listening, no packets received", nil
+	}
+
+	return fmt.Sprintf("listening, last packet received %v ago", l.timeNow().Sub(time.Unix(0, last)).Truncate(time.Second)), nil
+}
+
+func (l *Listener) timeNow() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+
+	return time.Now()
+}
+
+// Errors tracks a subsystem that keeps running when it fails, like an exporter. It is not healthy while the last
+// error it reported is less than Window old.
+type Errors struct {
+	// Window is how long an error makes the subsystem unhealthy.
+	Window time.Duration
+
+	mu   sync.Mutex // protects last and at
+	last error
+	at   time.Time
+	// now returns the current time, it is replaced in tests.
+	now func() time.Time
+}
+
+// Handle records err. Its signature matches the otel.ErrorHandler interface.
+func (e *Errors) Handle(err error) {
+	if err == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.last = err
+	e.at = e.timeNow()
+}
+
+// Check is the Check of the subsystem.
+func (e *Errors) Check(context.Context) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.last == nil {
+		return "no errors", nil
+	}
+	ago := e.timeNow().Sub(e.at)
+	if ago < e.Window {
+		return "", fmt.Errorf("failed %v ago: %w", ago.Truncate(time.Second), e.last)
+	}
+
+	return fmt.Sprintf("last failed %v ago", ago.Truncate(time.Second)), nil
+}
+
+func (e *Errors) timeNow() time.Time {
+	if e.now != nil {
+		return e.now()
+	}
+
+	return time.Now()
+}
@@ -0,0 +1,64 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestListener(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := &Listener{now: func() time.Time { return now }}
+	steps := []struct {
+		name       string
+		do         func()
+		wantDetail string
+		wantErr    bool
+	}{
+		{name: "not started", do: func() {}, wantErr: true},
+		{name: "listening", do: l.Listening, wantDetail: "listening, no packets received"},
+		{name: "received", do: func() {
+			l.Received()
+			now = now.Add(3 * time.Second)
+		}, wantDetail: "listening, last packet received 3s ago"},
+		{name: "stopped with an error", do: func() { l.Stopped(errors.New("use of closed network connection")) }, wantErr: true},
+		{name: "stopped", do: func() { l.Stopped(nil) }, wantErr: true},
+	}
+	for _, s := range steps {
+		s.do()
+		detail, err := l.Check(context.Background())
+		if (err != nil) != s.wantErr {
+			t.Fatalf("%s: Check() error = %v, wantErr %v", s.name, err, s.wantErr)
+		}
+		if detail != s.wantDetail {
+			t.Errorf("%s: Check() detail = %q, want %q", s.name, detail, s.wantDetail)
+		}
+	}
+
+	var nl *Listener
+	nl.Listening()
+	nl.Received()
+	nl.Stopped(nil)
+}
+
+func TestErrors(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := &Errors{Window: time.Minute, now: func() time.Time { return now }}
+	if _, err := e.Check(context.Background()); err != nil {
+		t.Fatalf("no errors: Check() error = %v", err)
+	}
+	e.Handle(errors.New("context deadline exceeded"))
+	now = now.Add(10 * time.Second)
+	if _, err := e.Check(context.Background()); err == nil {
+		t.Fatal("recent error: Check() error = nil")
+	}
+	now = now.Add(time.Minute)
+	detail, err := e.Check(context.Background())
+	if err != nil {
+		t.Fatalf("old error: Check() error = %v", err)
+	}
+	if want := "last failed 1m10s ago"; detail != want {
+		t.Errorf("old error: Check() detail = %q, want %q", detail, want)
+	}
+}
@@ -8,10 +8,12 @@ import (
 	"fmt"
 	"net/http"
 	"runtime"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/tinkerbell/smee/internal/health"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
@@ -21,6 +23,8 @@ type Config struct {
 	StartTime      time.Time
 	Logger         logr.Logger
 	TrustedProxies []string
+	// Health has the checks of the subsystems that /healthz, /readyz and /healthcheck report. Optional.
+	Health *health.Registry
 }
 
 // HandlerMapping is a map of routes to http.HandlerFuncs.
@@ -29,6 +33,18 @@ type HandlerMapping map[string]http.HandlerFunc
 // ServeHTTP sets up all the HTTP routes using a stdlib mux and starts the http
 // server, which will block. App functionality is instrumented in Prometheus and OpenTelemetry.
 func (s *Config) ServeHTTP(ctx context.Context, addr string, handlers HandlerMapping) error {
+	h, err := s.Handler(handlers)
+	if err != nil {
+		s.Logger.Error(err, "failed to create http handler")
+		return err
+	}
+
+	return s.Serve(ctx, addr, h)
+}
+
+// Handler sets up all the HTTP routes using a stdlib mux and returns the http.Handler for them.
+// App functionality is instrumented in Prometheus and OpenTelemetry.
+func (s *Config) Handler(handlers HandlerMapping) (http.Handler, error) {
 	mux := http.NewServeMux()
 	for pattern, handler := range handlers {
 		mux.Handle(otelFuncWrapper(pattern, handler))
@@ -36,35 +52,38 @@ func (s *Config) ServeHTTP(ctx context.Context, addr string, handlers HandlerMap
 
 	mux.Handle("/metrics", promhttp.Handler())
 	mux.HandleFunc("/healthcheck", s.serveHealthchecker(s.GitRev, s.StartTime))
+	mux.HandleFunc("/healthz", s.serveHealth(health.Liveness))
+	mux.HandleFunc("/readyz", s.serveHealth(health.Readiness))
 
 	// wrap the mux with an OpenTelemetry interceptor
 	otelHandler := otelhttp.NewHandler(mux, "smee-http")
 
 	// add X-Forwarded-For support if trusted proxies are configured
-	var xffHandler http.Handler
 	if len(s.TrustedProxies) > 0 {
 		xffmw, err := newXFF(xffOptions{
 			AllowedSubnets: s.TrustedProxies,
 		})
 		if err != nil {
-			s.Logger.Error(err, "failed to create new xff object")
-			panic(fmt.Errorf("failed to create new xff object: %v", err))
+			return nil, fmt.Errorf("failed to create new xff object: %w", err)
 		}
 
-		xffHandler = xffmw.Handler(&loggingMiddleware{
-			handler: otelHandler,
-			log:     s.Logger,
-		})
-	} else {
-		xffHandler = &loggingMiddleware{
+		return xffmw.Handler(&loggingMiddleware{
 			handler: otelHandler,
 			log:     s.Logger,
-		}
+		}), nil
 	}
 
+	return &loggingMiddleware{
+		handler: otelHandler,
+		log:     s.Logger,
+	}, nil
+}
+
+// Serve starts the http server for h, which will block until ctx is canceled.
+func (s *Config) Serve(ctx context.Context, addr string, h http.Handler) error {
 	server := http.Server{
 		Addr:    addr,
-		Handler: xffHandler,
+		Handler: h,
 
 		// Mitigate Slowloris attacks. 30 seconds is based on Apache's recommended 20-40
 		// recommendation. Smee doesn't really have many headers so 20s should be plenty of time.
@@ -88,17 +107,38 @@ func (s *Config) ServeHTTP(ctx context.Context, addr string, handlers HandlerMap
 	return nil
 }
 
+// Reloadable is an http.Handler that passes requests to a handler that can be replaced while the server runs.
+// Requests that are in flight finish on the handler they started on. Store must be called before it serves requests.
+type Reloadable struct {
+	h atomic.Pointer[http.Handler]
+}
+
+// Store replaces the handler that new requests are passed to.
+func (r *Reloadable) Store(h http.Handler) {
+	r.h.Store(&h)
+}
+
+// ServeHTTP passes a request to the current handler.
+func (r *Reloadable) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	(*r.h.Load()).ServeHTTP(w, req)
+}
+
 func (s *Config) serveHealthchecker(rev string, start time.Time) http.HandlerFunc {
-	return func(w http.ResponseWriter, _ *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
+		ready := s.Health.Report(r.Context(), health.Readiness)
 		res := struct {
-			GitRev     string  `json:"git_rev"`
-			Uptime     float64 `json:"uptime"`
-			Goroutines int     `json:"goroutines"`
+			GitRev     string                   `json:"git_rev"`
+			Uptime     float64                  `json:"uptime"`
+			Goroutines int                      `json:"goroutines"`
+			Ready      bool                     `json:"ready"`
+			Checks     map[string]health.Result `json:"checks"`
 		}{
 			GitRev:     rev,
 			Uptime:     time.Since(start).Seconds(),
 			Goroutines: runtime.NumGoroutine(),
+			Ready:      ready.Healthy,
+			Checks:     ready.Checks,
 		}
 		if err := json.NewEncoder(w).Encode(&res); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -107,6 +147,21 @@ func (s *Config) serveHealthchecker(rev string, start time.Time) http.HandlerFun
 	}
 }
 
+// serveHealth responds with the report of the checks for kind, with 503 Service Unavailable when it is not healthy.
+// /healthz is the liveness and /readyz the readiness of smee, /healthcheck always responds with 200 OK.
+func (s *Config) serveHealth(kind health.Kind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rep := s.Health.Report(r.Context(), kind)
+		w.Header().Set("Content-Type", "application/json")
+		if !rep.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(&rep); err != nil {
+			s.Logger.Error(err, "marshaling health json", "kind", kind.String())
+		}
+	}
+}
+
 // otelFuncWrapper takes a route and an http handler function, wraps the function
 // with otelhttp, and returns the route again and http.Handler all set for mux.Handle().
 func otelFuncWrapper(route string, h func(w http.ResponseWriter, req *http.Request)) (string, http.Handler) {
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/smee/internal/health"
+)
+
+func TestHealth(t *testing.T) {
+	tests := map[string]struct {
+		kind        health.Kind
+		wantHealthz int
+		wantReadyz  int
+	}{
+		"healthy":                  {kind: -1, wantHealthz: http.StatusOK, wantReadyz: http.StatusOK},
+		"liveness check failing":   {kind: health.Liveness, wantHealthz: http.StatusServiceUnavailable, wantReadyz: http.StatusServiceUnavailable},
+		"readiness check failing":  {kind: health.Readiness, wantHealthz: http.StatusOK, wantReadyz: http.StatusServiceUnavailable},
+		"informational check fail": {kind: health.Informational, wantHealthz: http.StatusOK, wantReadyz: http.StatusOK},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			reg := &health.Registry{}
+			reg.Register("dhcp", health.Liveness, func(context.Context) (string, error) { return "listening", nil })
+			if tt.kind >= 0 {
+				reg.Register("broken", tt.kind, func(context.Context) (string, error) { return "", errors.New("broken") })
+			}
+			s := &Config{StartTime: time.Now(), Logger: logr.Discard(), Health: reg}
+			h, err := s.Handler(HandlerMapping{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for path, want := range map[string]int{"/healthz": tt.wantHealthz, "/readyz": tt.wantReadyz, "/healthcheck": http.StatusOK} {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+				if w.Code != want {
+					t.Errorf("%s status = %d, want %d", path, w.Code, want)
+				}
+				var res struct {
+					Checks map[string]health.Result `json:"checks"`
+				}
+				if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+					t.Fatalf("%s: %v", path, err)
+				}
+				if !res.Checks["dhcp"].Healthy {
+					t.Errorf("%s: dhcp check = %+v, want healthy", path, res.Checks["dhcp"])
+				}
+			}
+		})
+	}
+}
@@ -30,10 +30,13 @@ type Handler struct {
 	IPXEScriptRetries     int
 	IPXEScriptRetryDelay  int
 	StaticIPXEEnabled     bool
+	// Progress, when set, records the iPXE scripts that are served to machines.
+	Progress handler.ProgressRecorder
 }
 
 type data struct {
 	AllowNetboot  bool // If true, the client will be provided netboot options in the DHCP offer/ack.
+	LocalBoot     bool // If true, the client is told to exit iPXE and boot from its local disk instead of the OSIE.
 	Console       string
 	MACAddress    net.HardwareAddr
 	Arch          string
@@ -68,6 +71,7 @@ func getByMac(ctx context.Context, mac net.HardwareAddr, br handler.BackendReade
 
 	return data{
 		AllowNetboot:  n.AllowNetboot,
+		LocalBoot:     n.LocalBoot,
 		Console:       "",
 		MACAddress:    d.MACAddress,
 		Arch:          d.Arch,
@@ -91,6 +95,7 @@ func getByIP(ctx context.Context, ip net.IP, br handler.BackendReader) (data, er
 
 	return data{
 		AllowNetboot:  n.AllowNetboot,
+		LocalBoot:     n.LocalBoot,
 		Console:       "",
 		MACAddress:    d.MACAddress,
 		Arch:          d.Arch,
@@ -133,6 +138,15 @@ func (h *Handler) HandlerFunc() http.HandlerFunc {
 		// Try to get the MAC address from the URL path, if not available get the source IP address.
 		if ha, err := getMAC(r.URL.Path); err == nil {
 			hw, err := getByMac(ctx, ha, h.Backend)
+			if handler.IsNotFound(err) {
+				// Hardware matched by relay agent information (the switch port) instead of by mac address, for example
+				// after a NIC was replaced, was given the IP address of its hardware record, so try that.
+				if ip, ierr := getIP(r.RemoteAddr); ierr == nil {
+					if hwByIP, ierr := getByIP(ctx, ip, h.Backend); ierr == nil {
+						hw, err = hwByIP, nil
+					}
+				}
+			}
 			if err != nil && h.StaticIPXEEnabled {
 				h.Logger.Info("serving static ipxe script", "mac", ha, "error", err)
 				h.serveStaticIPXEScript(w)
@@ -171,15 +185,7 @@ func (h *Handler) HandlerFunc() http.HandlerFunc {
 }
 
 func (h *Handler) serveStaticIPXEScript(w http.ResponseWriter) {
-	// Serve static iPXE script.
-	auto := Hook{
-		DownloadURL:       h.OSIEURL,
-		ExtraKernelParams: h.ExtraKernelParams,
-		SyslogHost:        h.PublicSyslogFQDN,
-		TinkerbellTLS:     h.TinkServerTLS,
-		TinkGRPCAuthority: h.TinkServerGRPCAddr,
-	}
-	script, err := GenerateTemplate(auto, StaticScript)
+	script, err := h.staticScript()
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		h.Logger.Error(err, "error generating the static ipxe script")
@@ -192,6 +198,37 @@ func (h *Handler) serveStaticIPXEScript(w http.ResponseWriter) {
 	}
 }
 
+// staticScript returns the iPXE script that is served to machines without hardware data when StaticIPXEEnabled is set.
+func (h *Handler) staticScript() (string, error) {
+	auto := Hook{
+		DownloadURL:       h.OSIEURL,
+		ExtraKernelParams: h.ExtraKernelParams,
+		SyslogHost:        h.PublicSyslogFQDN,
+		TinkerbellTLS:     h.TinkServerTLS,
+		TinkGRPCAuthority: h.TinkServerGRPCAddr,
+	}
+
+	return GenerateTemplate(auto, StaticScript)
+}
+
+// Render returns the name and the content of the iPXE script that is served to the machine with mac at
+// /<mac address>/auto.ipxe, without recording its boot progress. It returns an error when no script is served.
+func (h *Handler) Render(ctx context.Context, mac net.HardwareAddr) (name, script string, err error) {
+	hw, err := getByMac(ctx, mac, h.Backend)
+	if err != nil && h.StaticIPXEEnabled {
+		script, err := h.staticScript()
+		return "auto.ipxe", script, err
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if !hw.AllowNetboot {
+		return "", "", errors.New("the hardware data for this machine does not allow it to pxe")
+	}
+
+	return h.render(trace.SpanFromContext(ctx), "auto.ipxe", hw)
+}
+
 func getIP(remoteAddr string) (net.IP, error) {
 	host, _, err := net.SplitHostPort(remoteAddr)
 	if err != nil {
@@ -215,48 +252,58 @@ func getMAC(urlPath string) (net.HardwareAddr, error) {
 func (h *Handler) serveBootScript(ctx context.Context, w http.ResponseWriter, name string, hw data) {
 	span := trace.SpanFromContext(ctx)
 	span.SetAttributes(attribute.String("smee.script_name", name))
-	var script []byte
-	// check if the custom script should be used
-	if hw.IPXEScriptURL != nil || hw.IPXEScript != "" {
-		name = "custom.ipxe"
-	}
-	switch name {
-	case "auto.ipxe":
-		s, err := h.defaultScript(span, hw)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			h.Logger.Error(err, "error with default ipxe script", "script", name)
-			span.SetStatus(codes.Error, err.Error())
-
-			return
-		}
-		script = []byte(s)
-	case "custom.ipxe":
-		cs, err := h.customScript(hw)
-		if err != nil {
+	name, script, err := h.render(span, name, hw)
+	if err != nil {
+		if errors.Is(err, errScriptNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			h.Logger.Error(err, "boot script not found", "script", name)
+		} else {
 			w.WriteHeader(http.StatusInternalServerError)
-			h.Logger.Error(err, "error with custom ipxe script", "script", name)
-			span.SetStatus(codes.Error, err.Error())
-
-			return
+			h.Logger.Error(err, "error with ipxe script", "script", name)
 		}
-		script = []byte(cs)
-	default:
-		w.WriteHeader(http.StatusNotFound)
-		err := fmt.Errorf("boot script %q not found", name)
-		h.Logger.Error(err, "boot script not found", "script", name)
 		span.SetStatus(codes.Error, err.Error())
 
 		return
 	}
-	span.SetAttributes(attribute.String("ipxe-script", string(script)))
+	if name == "auto.ipxe" && hw.LocalBoot {
+		h.Logger.Info("no pending workflow, telling the machine to boot from its local disk", "mac", hw.MACAddress)
+	}
+	span.SetAttributes(attribute.String("ipxe-script", script))
 
-	if _, err := w.Write(script); err != nil {
+	if _, err := w.Write([]byte(script)); err != nil {
 		h.Logger.Error(err, "unable to write boot script", "script", name)
 		span.SetStatus(codes.Error, err.Error())
 
 		return
 	}
+	if h.Progress != nil {
+		h.Progress.RecordProgress(ctx, hw.MACAddress, nil, handler.StageIPXEScript, name)
+	}
+}
+
+// errScriptNotFound is returned by render for script names that are not served.
+var errScriptNotFound = errors.New("boot script not found")
+
+// render returns the name and the content of the boot script called name for the machine of hw.
+// The custom script is used, whatever the name, when the hardware data has one.
+func (h *Handler) render(span trace.Span, name string, hw data) (string, string, error) {
+	// check if the custom script should be used
+	if hw.IPXEScriptURL != nil || hw.IPXEScript != "" {
+		name = "custom.ipxe"
+	}
+	switch name {
+	case "auto.ipxe":
+		if hw.LocalBoot {
+			return name, ExitScript, nil
+		}
+		s, err := h.defaultScript(span, hw)
+		return name, s, err
+	case "custom.ipxe":
+		cs, err := h.customScript(hw)
+		return name, cs, err
+	default:
+		return name, "", fmt.Errorf("%w: %q", errScriptNotFound, name)
+	}
 }
 
 func (h *Handler) defaultScript(span trace.Span, hw data) (string, error) {
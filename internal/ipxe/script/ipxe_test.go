@@ -2,16 +2,28 @@ package script
 
 import (
 	"context"
+	"errors"
 	"net"
+	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"strings"
 	"testing"
 
+	"github.com/go-logr/logr"
 	"github.com/google/go-cmp/cmp"
+	dhcpdata "github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
 	"github.com/tinkerbell/smee/internal/metric"
 	"go.opentelemetry.io/otel/trace"
 )
 
+func TestMain(m *testing.M) {
+	metric.Init()
+	os.Exit(m.Run())
+}
+
 func TestCustomScript(t *testing.T) {
 	tests := map[string]struct {
 		ipxeURL    string
@@ -114,6 +126,119 @@ exit
 	}
 }
 
+func TestLocalBootScript(t *testing.T) {
+	tests := map[string]struct {
+		hw   data
+		want string
+	}{
+		"no pending workflow": {hw: data{MACAddress: net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}, LocalBoot: true}, want: ExitScript},
+		"custom script":       {hw: data{MACAddress: net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}, LocalBoot: true, IPXEScript: "#!ipxe\nautoboot"}, want: "#!ipxe\n\necho Loading custom Tinkerbell iPXE script...\n#!ipxe\nautoboot\n"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{OSIEURL: "http://127.1.1.1"}
+			w := httptest.NewRecorder()
+			h.serveBootScript(context.Background(), w, "auto.ipxe", tt.hw)
+			if diff := cmp.Diff(tt.want, w.Body.String()); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+// backend returns the same hardware data for every machine, or err.
+// Lookups by IP address return ipNetboot, when it is set.
+type backend struct {
+	netboot   *dhcpdata.Netboot
+	err       error
+	ipNetboot *dhcpdata.Netboot
+}
+
+func (b *backend) GetByMac(_ context.Context, mac net.HardwareAddr) (*dhcpdata.DHCP, *dhcpdata.Netboot, error) {
+	if b.err != nil {
+		return nil, nil, b.err
+	}
+	return &dhcpdata.DHCP{MACAddress: mac}, b.netboot, nil
+}
+
+func (b *backend) GetByIP(context.Context, net.IP) (*dhcpdata.DHCP, *dhcpdata.Netboot, error) {
+	if b.ipNetboot == nil {
+		return nil, nil, errors.New("not implemented")
+	}
+	return &dhcpdata.DHCP{MACAddress: net.HardwareAddr{0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}}, b.ipNetboot, nil
+}
+
+// progressRecorder records the boot progress of machines as "<mac> <stage> <detail>".
+type progressRecorder struct {
+	records []string
+}
+
+func (p *progressRecorder) RecordProgress(_ context.Context, mac net.HardwareAddr, _ net.IP, stage handler.Stage, detail string) {
+	p.records = append(p.records, mac.String()+" "+string(stage)+" "+detail)
+}
+
+func TestRender(t *testing.T) {
+	tests := map[string]struct {
+		backend    *backend
+		static     bool
+		wantName   string
+		wantScript string
+		wantErr    bool
+	}{
+		"local boot":          {backend: &backend{netboot: &dhcpdata.Netboot{AllowNetboot: true, LocalBoot: true}}, wantName: "auto.ipxe", wantScript: ExitScript},
+		"custom script":       {backend: &backend{netboot: &dhcpdata.Netboot{AllowNetboot: true, IPXEScript: "#!ipxe\nautoboot"}}, wantName: "custom.ipxe", wantScript: "#!ipxe\n\necho Loading custom Tinkerbell iPXE script...\n#!ipxe\nautoboot\n"},
+		"netboot not allowed": {backend: &backend{netboot: &dhcpdata.Netboot{}}, wantErr: true},
+		"no hardware":         {backend: &backend{err: errors.New("not found")}, wantErr: true},
+		"static script":       {backend: &backend{err: errors.New("not found")}, static: true, wantName: "auto.ipxe"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := &progressRecorder{}
+			h := &Handler{OSIEURL: "http://127.1.1.1", Backend: tt.backend, StaticIPXEEnabled: tt.static, Progress: p}
+			gotName, gotScript, err := h.Render(context.Background(), net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotName != tt.wantName {
+				t.Errorf("Render() name = %q, want %q", gotName, tt.wantName)
+			}
+			if tt.wantScript != "" {
+				if diff := cmp.Diff(tt.wantScript, gotScript); diff != "" {
+					t.Error(diff)
+				}
+			} else if !tt.wantErr && !strings.HasPrefix(gotScript, "#!ipxe") {
+				t.Errorf("Render() script = %q, want an iPXE script", gotScript)
+			}
+			if len(p.records) != 0 {
+				t.Errorf("Render() recorded boot progress %v", p.records)
+			}
+		})
+	}
+}
+
+func TestHandlerFuncFallsBackToIP(t *testing.T) {
+	allowed := &dhcpdata.Netboot{AllowNetboot: true, LocalBoot: true}
+	tests := map[string]struct {
+		backend *backend
+		want    int
+	}{
+		"found by mac":                       {backend: &backend{netboot: allowed}, want: http.StatusOK},
+		"not found by mac, found by ip":      {backend: &backend{err: handler.NotFoundError{}, ipNetboot: allowed}, want: http.StatusOK},
+		"backend error is not retried by ip": {backend: &backend{err: errors.New("connection refused"), ipNetboot: allowed}, want: http.StatusNotFound},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{Logger: logr.Discard(), OSIEURL: "http://127.1.1.1", Backend: tt.backend}
+			r := httptest.NewRequest(http.MethodGet, "/01:02:03:04:05:06/auto.ipxe", nil)
+			w := httptest.NewRecorder()
+			h.HandlerFunc()(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
 func TestStaticScript(t *testing.T) {
 	want := `#!ipxe
 
@@ -172,7 +297,6 @@ echo Failed to boot
 imgfree
 exit
 `
-	metric.Init()
 	h := &Handler{
 		OSIEURL:            "http://127.0.0.1",
 		ExtraKernelParams:  []string{"k=v", "k2=v2"},
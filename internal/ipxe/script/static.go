@@ -59,3 +59,11 @@ echo Failed to boot
 imgfree
 exit
 `
+
+// ExitScript is the iPXE script used when the hardware of a machine has no pending workflow.
+// Exiting iPXE returns to the firmware, which boots the next device in the boot order, usually the local disk.
+var ExitScript = `#!ipxe
+
+echo No pending Tinkerbell workflow for ${mac}, booting from the next boot device...
+exit
+`
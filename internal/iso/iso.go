@@ -15,10 +15,9 @@ import (
 	"path/filepath"
 	"strings"
 
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
-
 	"github.com/go-logr/logr"
 	"github.com/tinkerbell/smee/internal/dhcp/data"
+	"github.com/tinkerbell/smee/internal/dhcp/handler"
 	"github.com/tinkerbell/smee/internal/iso/internal"
 )
 
@@ -51,6 +50,8 @@ type Handler struct {
 	TinkServerTLS      bool
 	TinkServerGRPCAddr string
 	StaticIPAMEnabled  bool
+	// Progress, when set, records the ISO downloads of machines.
+	Progress handler.ProgressRecorder
 	// parsedURL derives a url.URL from the SourceISO field.
 	// It needed for validation of SourceISO and easier modification.
 	parsedURL       *url.URL
@@ -160,7 +161,7 @@ func (h *Handler) RoundTrip(req *http.Request) (*http.Response, error) {
 	fac, dhcpData, err := h.getFacility(req.Context(), ha, h.Backend)
 	if err != nil {
 		log.Info("unable to get the hardware object", "error", err, "mac", ha)
-		if apierrors.IsNotFound(err) {
+		if handler.IsNotFound(err) {
 			return &http.Response{
 				Status:     fmt.Sprintf("%d %s", http.StatusNotFound, http.StatusText(http.StatusNotFound)),
 				StatusCode: http.StatusNotFound,
@@ -218,6 +219,10 @@ func (h *Handler) RoundTrip(req *http.Request) (*http.Response, error) {
 		log.Info("response received", "sourceIso", h.SourceISO, "status", resp.Status)
 	}
 
+	if h.Progress != nil && resp.StatusCode < http.StatusBadRequest {
+		h.Progress.RecordProgress(req.Context(), ha, nil, handler.StageISO, "")
+	}
+
 	log.V(1).Info("roundtrip complete")
 
 	return resp, nil
@@ -3,6 +3,7 @@ package iso
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -18,6 +19,7 @@ import (
 	"github.com/diskfs/go-diskfs/filesystem/iso9660"
 	"github.com/go-logr/logr"
 	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/smee/internal/backend/file"
 	"github.com/tinkerbell/smee/internal/dhcp/data"
 )
 
@@ -55,6 +57,43 @@ func TestReqPathInvalid(t *testing.T) {
 	}
 }
 
+func TestReqHardwareNotFound(t *testing.T) {
+	w, err := file.NewWatcher(logr.Discard(), "../backend/file/testdata/example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		backend    BackendReader
+		statusCode int
+	}{
+		"mac not in the file backend": {backend: w, statusCode: http.StatusNotFound},
+		"backend error":               {backend: &errBackend{}, statusCode: http.StatusInternalServerError},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			u, _ := url.Parse("http://10.10.10.10:8080/iso/02:00:00:00:00:01/hook.iso")
+			h := &Handler{
+				Logger:    logr.Discard(),
+				Backend:   tt.backend,
+				parsedURL: u,
+			}
+			req := http.Request{
+				Method: http.MethodGet,
+				URL:    u,
+			}
+
+			got, err := h.RoundTrip(&req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got.Body.Close()
+			if got.StatusCode != tt.statusCode {
+				t.Fatalf("got response status code: %d, want status code: %d", got.StatusCode, tt.statusCode)
+			}
+		})
+	}
+}
+
 func TestCreateISO(t *testing.T) {
 	t.Skip("Unskip this test to create a new ISO file")
 	grubCfg := `set timeout=0
@@ -192,3 +231,9 @@ func (m *mockBackend) GetByIP(context.Context, net.IP) (*data.DHCP, *data.Netboo
 	}
 	return d, n, nil
 }
+
+type errBackend struct{}
+
+func (errBackend) GetByMac(context.Context, net.HardwareAddr) (*data.DHCP, *data.Netboot, error) {
+	return nil, nil, errors.New("backend unavailable")
+}
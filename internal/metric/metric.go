@@ -6,7 +6,10 @@ import (
 )
 
 var (
-	DHCPTotal *prometheus.CounterVec
+	DHCPTotal       *prometheus.CounterVec
+	DHCPDropped     *prometheus.CounterVec
+	DHCPQueued      prometheus.Counter
+	DHCPQueueLength prometheus.Gauge
 
 	DiscoverDuration    prometheus.ObserverVec
 	HardwareDiscovers   *prometheus.CounterVec
@@ -15,6 +18,10 @@ var (
 	JobDuration    prometheus.ObserverVec
 	JobsTotal      *prometheus.CounterVec
 	JobsInProgress *prometheus.GaugeVec
+
+	BackendCacheTotal *prometheus.CounterVec
+
+	KubeHardwareConflicts *prometheus.GaugeVec
 )
 
 func Init() {
@@ -36,6 +43,27 @@ func Init() {
 	}
 	initCounterLabels(DHCPTotal, labelValues)
 
+	DHCPDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dhcp_dropped_total",
+		Help: "Number of DHCP packets dropped before they were handled.",
+	}, []string{"reason"})
+	DHCPQueued = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "dhcp_queued_total",
+		Help: "Number of DHCP packets queued for a worker.",
+	})
+	DHCPQueueLength = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "dhcp_queue_length",
+		Help: "Number of DHCP packets waiting for a worker.",
+	})
+
+	labelValues = []prometheus.Labels{
+		{"reason": "queue_full"},
+		{"reason": "retransmit"},
+		{"reason": "client_rate"},
+		{"reason": "relay_rate"},
+	}
+	initCounterLabels(DHCPDropped, labelValues)
+
 	labelValues = []prometheus.Labels{
 		{"from": "dhcp"},
 		{"from": "ip"},
@@ -93,6 +121,30 @@ func Init() {
 	initObserverLabels(JobDuration, labelValues)
 	initCounterLabels(JobsTotal, labelValues)
 	initGaugeLabels(JobsInProgress, labelValues)
+
+	BackendCacheTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "backend_cache_total",
+		Help: "Number of backend lookups by whether they were served from the cache.",
+	}, []string{"lookup", "result"})
+
+	labelValues = nil
+	for _, lookup := range []string{"mac", "ip", "relay_agent"} {
+		for _, result := range []string{"hit", "negative_hit", "miss"} {
+			labelValues = append(labelValues, prometheus.Labels{"lookup": lookup, "result": result})
+		}
+	}
+	initCounterLabels(BackendCacheTotal, labelValues)
+
+	KubeHardwareConflicts = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kube_hardware_conflicts",
+		Help: "Number of MAC or IP addresses that more than one Hardware object has.",
+	}, []string{"address"})
+
+	labelValues = []prometheus.Labels{
+		{"address": "mac"},
+		{"address": "ip"},
+	}
+	initGaugeLabels(KubeHardwareConflicts, labelValues)
 }
 
 func initCounterLabels(m *prometheus.CounterVec, l []prometheus.Labels) {
@@ -105,6 +105,8 @@ type Config struct {
 	Endpoint    string `json:"endpoint"`
 	Insecure    bool   `json:"insecure"`
 	Logger      logr.Logger
+	// OnError is called with the errors of OpenTelemetry, for example when spans cannot be exported. Optional.
+	OnError func(error) `json:"-"`
 }
 
 // Init sets up the OpenTelemetry plumbing so it's ready to use.
@@ -203,5 +205,8 @@ func (c Config) initTracing(ctx context.Context) (context.Context, context.Cance
 func (c Config) Handle(err error) {
 	if err != nil {
 		c.Logger.Info("OpenTelemetry error", "err", err)
+		if c.OnError != nil {
+			c.OnError(err)
+		}
 	}
 }
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/tinkerbell/smee/internal/health"
 )
 
 var syslogMessagePool = sync.Pool{
@@ -21,51 +22,53 @@ type Receiver struct {
 	c     *net.UDPConn
 	parse chan *message
 	done  chan struct{}
-	err   error
+	// health tracks that the Receiver listens and receives messages, it can be nil.
+	health *health.Listener
 
 	Logger logr.Logger
 }
 
-func StartReceiver(ctx context.Context, logger logr.Logger, laddr string, parsers int) error {
+// StartReceiver listens for syslog messages on laddr and logs them until ctx is canceled.
+// When l is not nil, it records that the Receiver listens, the messages it receives and when it stops.
+func StartReceiver(ctx context.Context, logger logr.Logger, laddr string, parsers int, l *health.Listener) (*Receiver, error) {
 	if parsers < 1 {
 		parsers = 1
 	}
 
 	addr, err := net.ResolveUDPAddr("udp4", laddr)
 	if err != nil {
-		return fmt.Errorf("resolve syslog udp listen address: %w", err)
+		return nil, fmt.Errorf("resolve syslog udp listen address: %w", err)
 	}
 
 	c, err := net.ListenUDP("udp4", addr)
 	if err != nil {
-		return fmt.Errorf("listen on syslog udp address: %w", err)
+		return nil, fmt.Errorf("listen on syslog udp address: %w", err)
 	}
 
 	s := &Receiver{
 		c:      c,
 		parse:  make(chan *message, parsers),
 		done:   make(chan struct{}),
+		health: l,
 		Logger: logger,
 	}
+	l.Listening()
 
 	for i := 0; i < parsers; i++ {
 		go s.runParser()
 	}
 	go s.run(ctx)
 
-	return nil
+	return s, nil
 }
 
 func (r *Receiver) Done() <-chan struct{} {
 	return r.done
 }
 
-func (r *Receiver) Err() error {
-	return r.err
-}
-
 func (r *Receiver) cleanup() {
 	r.c.Close()
+	r.health.Stopped(nil)
 
 	close(r.parse)
 	close(r.done)
@@ -102,13 +105,14 @@ func (r *Receiver) run(ctx context.Context) {
 
 				continue
 			}
-			r.err = err
+			r.health.Stopped(err)
 
 			return
 		}
 		msg.time = time.Now().UTC()
 		msg.host = from.IP
 		msg.size = n
+		r.health.Received()
 		r.parse <- msg
 		msg = nil
 	}
@@ -15,7 +15,7 @@ SHELL := bash
 GO			?= go
 GOIMPORTS	:= $(GO) run golang.org/x/tools/cmd/goimports@latest
 
-.PHONY: all smee crosscompile dc image gen run test
+.PHONY: all smee crosscompile dc image gen proto run test
 
 CGO_ENABLED := 0
 export CGO_ENABLED